@@ -0,0 +1,46 @@
+// Command operator syncs the parameters referenced by ParameterStoreSync resources into Kubernetes Secrets and ConfigMaps.
+//
+// Environment:
+//
+//	PARAMETER_STORE_URL  base URL of the Parameter Store server, required
+//	WATCH_NAMESPACE      only watch this namespace, default every namespace
+//	RECONCILE_INTERVAL   how often resources are checked for a due refresh, default 5s
+//	KUBE_API_URL, KUBE_TOKEN, KUBE_INSECURE_SKIP_VERIFY  run outside of a cluster
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"parameter-store-be/modules/operator"
+	"syscall"
+	"time"
+)
+
+func main() {
+	baseURL := os.Getenv("PARAMETER_STORE_URL")
+	if baseURL == "" {
+		log.Fatal("$PARAMETER_STORE_URL must be set")
+	}
+	kube, err := operator.NewRESTClientFromEnv()
+	if err != nil {
+		log.Fatal("Failed to create kubernetes client: ", err)
+	}
+	interval := 5 * time.Second
+	if value := os.Getenv("RECONCILE_INTERVAL"); value != "" {
+		if interval, err = time.ParseDuration(value); err != nil {
+			log.Fatal("Invalid $RECONCILE_INTERVAL: ", err)
+		}
+	}
+	reconciler := &operator.Reconciler{
+		Kube: kube,
+		// timeout 0 makes the watch API answer at once, 304 when the revision did not change
+		Source:    &operator.WatchClient{BaseURL: baseURL},
+		Namespace: os.Getenv("WATCH_NAMESPACE"),
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Println("Parameter Store operator is watching", operator.Resource, "of namespace", reconciler.Namespace, "against", baseURL)
+	reconciler.Run(ctx, interval)
+}
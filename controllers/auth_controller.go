@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"parameter-store-be/models"
@@ -63,7 +64,9 @@ func Register(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
 		return
 	}
-	// Create the organization in the database
+	if err := sendVerificationEmail(newUser); err != nil {
+		log.Println("Failed to send verification email:", err)
+	}
 
 	// Return success response
 	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully", "email:": r.Email, "organization_name:": r.OrganizationName})
@@ -126,6 +129,15 @@ func Login(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login user"})
 		return
 	}
+	if os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true" && !user.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email is not verified", "email_verification_required": true})
+		return
+	}
+	// ask for the second factor before issuing a session token
+	if user.MFAEnabled {
+		respondMFAChallenge(c, user)
+		return
+	}
 	// find project_id that user is admin of
 	var projectIDs []uint
 	for _, urp := range user.UserRoleProjects {
@@ -140,7 +152,7 @@ func Login(c *gin.Context) {
 	responseLogedInUser.IsOrganizationAdmin = user.IsOrganizationAdmin
 	responseLogedInUser.IsAdminOfProjects = projectIDs
 	// Generate a JWT token
-	jwtToken, err := generateJWTToken(user)
+	jwtToken, err := generateJWTToken(user, false)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token for user"})
 		return
@@ -148,6 +160,7 @@ func Login(c *gin.Context) {
 	// set login time
 	user.LastLogin = time.Now()
 	DB.Save(&user)
+	auditAsUser(c, user, auditEntry{Action: "auth.login", TargetType: "user", TargetID: user.ID, TargetName: user.Username, After: map[string]interface{}{"method": "password"}})
 	// Set the JWT token in a cookie
 	c.SetSameSite(http.SameSiteNoneMode)
 	c.SetCookie(
@@ -161,10 +174,11 @@ func Login(c *gin.Context) {
 	)
 	c.Header("Authorization", jwtToken)
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User logged in successfully",
-		"status:": "success",
-		"token":   jwtToken,
-		"user":    responseLogedInUser,
+		"message":                 "User logged in successfully",
+		"status:":                 "success",
+		"token":                   jwtToken,
+		"user":                    responseLogedInUser,
+		"mfa_enrollment_required": IsMFARequired(user),
 	})
 
 }
@@ -229,8 +243,13 @@ func LoginWithGithub(c *gin.Context) {
 			return
 		}
 	}
+	// ask for the second factor before issuing a session token
+	if user.MFAEnabled {
+		respondMFAChallenge(c, user)
+		return
+	}
 	// Generate a JWT token
-	jwtToken, err := generateJWTToken(user)
+	jwtToken, err := generateJWTToken(user, false)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token for user"})
 		return
@@ -254,10 +273,11 @@ func LoginWithGithub(c *gin.Context) {
 	responseLogedInUser.IsOrganizationAdmin = user.IsOrganizationAdmin
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User logged in successfully",
-		"status:": "success",
-		"token":   jwtToken,
-		"user":    responseLogedInUser,
+		"message":                 "User logged in successfully",
+		"status:":                 "success",
+		"token":                   jwtToken,
+		"user":                    responseLogedInUser,
+		"mfa_enrollment_required": IsMFARequired(user),
 	})
 }
 
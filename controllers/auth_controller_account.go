@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/mailer"
+	"parameter-store-be/modules/signedtoken"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	passwordResetTokenTTL     = time.Hour
+	emailVerificationTokenTTL = 72 * time.Hour
+	minPasswordLength         = 8
+)
+
+// clientLink builds a link to a page of the frontend
+func clientLink(path, token string) string {
+	return fmt.Sprintf("%s%s?token=%s", os.Getenv("CLIENT_URL"), path, token)
+}
+
+// issueUserToken stores the hash of a new single-use token for the user and returns the token
+func issueUserToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	token, hash, err := signedtoken.Generate(os.Getenv("SECRET_KEY"), purpose)
+	if err != nil {
+		return "", err
+	}
+	// only the last token of a purpose is valid
+	DB.Model(&models.Token{}).
+		Where("user_id = ? AND purpose = ? AND is_used = ?", userID, purpose, false).
+		Updates(map[string]interface{}{"is_used": true, "used_at": time.Now()})
+	record := models.Token{
+		UserID:    userID,
+		Token:     hash,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := DB.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeUserToken checks the signature, expiry and single use of a token and marks it as used
+func consumeUserToken(token, purpose string) (models.Token, error) {
+	hash, ok := signedtoken.Verify(os.Getenv("SECRET_KEY"), purpose, token)
+	if !ok {
+		return models.Token{}, errors.New("Token is invalid")
+	}
+	var record models.Token
+	if err := DB.Where("token = ? AND purpose = ?", hash, purpose).First(&record).Error; err != nil {
+		return models.Token{}, errors.New("Token is invalid")
+	}
+	if record.IsUsed {
+		return models.Token{}, errors.New("Token is already used")
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return models.Token{}, errors.New("Token is expired")
+	}
+	// the where clause makes the update fail when a concurrent request used the token first
+	result := DB.Model(&models.Token{}).
+		Where("id = ? AND is_used = ?", record.ID, false).
+		Updates(map[string]interface{}{"is_used": true, "used_at": time.Now()})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return models.Token{}, errors.New("Token is already used")
+	}
+	return record, nil
+}
+
+// sendVerificationEmail sends a link to confirm the email of the user
+func sendVerificationEmail(user models.User) error {
+	token, err := issueUserToken(user.ID, models.TokenPurposeEmailVerification, emailVerificationTokenTTL)
+	if err != nil {
+		return err
+	}
+	return Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hello %s,\n\nPlease confirm your email address by opening the link below:\n%s\n\nThe link expires in %s.\n",
+			user.Username, clientLink("/verify-email", token), emailVerificationTokenTTL),
+	})
+}
+
+type forgotPasswordBody struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Send a password reset link to the email. The response is the same whether the email is registered or not.
+// @Tags Auth / Account
+// @Accept json
+// @Produce json
+// @Param request body controllers.forgotPasswordBody true "Email"
+// @Success 200 string {string} json "{"message": "If the email is registered, a reset link has been sent"}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Router /api/v1/auth/password/forgot [post]
+func ForgotPassword(c *gin.Context) {
+	var body forgotPasswordBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	response := gin.H{"message": "If the email is registered, a reset link has been sent"}
+	var user models.User
+	if err := DB.Where("email = ? AND is_archived = ?", body.Email, false).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	token, err := issueUserToken(user.ID, models.TokenPurposePasswordReset, passwordResetTokenTTL)
+	if err != nil {
+		log.Println("Failed to issue password reset token:", err)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if err := Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nSomeone asked to reset the password of your account. Open the link below to choose a new one:\n%s\n\nThe link expires in %s. If you did not ask for it, ignore this email.\n",
+			user.Username, clientLink("/reset-password", token), passwordResetTokenTTL),
+	}); err != nil {
+		log.Println("Failed to send password reset email:", err)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+type resetPasswordBody struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with the token received by email. The token can be used once.
+// @Tags Auth / Account
+// @Accept json
+// @Produce json
+// @Param request body controllers.resetPasswordBody true "Token and new password"
+// @Success 200 string {string} json "{"message": "Password has been reset"}"
+// @Failure 400 string {string} json "{"error": "Token is invalid"}"
+// @Failure 500 string {string} json "{"error": "Failed to reset password"}"
+// @Router /api/v1/auth/password/reset [post]
+func ResetPassword(c *gin.Context) {
+	var body resetPasswordBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(body.Password) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Password must have at least %d characters", minPasswordLength)})
+		return
+	}
+	record, err := consumeUserToken(body.Token, models.TokenPurposePasswordReset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hash, err := generateBcryptPassword(body.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	// the reset link proves the user owns the email
+	if err := DB.Model(&models.User{}).Where("id = ?", record.UserID).Updates(map[string]interface{}{
+		"password":          hash,
+		"email_verified":    true,
+		"email_verified_at": time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+type verifyEmailBody struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// VerifyEmail godoc
+// @Summary Verify email
+// @Description Confirm the email of the user with the token received by email
+// @Tags Auth / Account
+// @Accept json
+// @Produce json
+// @Param request body controllers.verifyEmailBody true "Token"
+// @Success 200 string {string} json "{"message": "Email verified"}"
+// @Failure 400 string {string} json "{"error": "Token is invalid"}"
+// @Router /api/v1/auth/email/verify [post]
+func VerifyEmail(c *gin.Context) {
+	var body verifyEmailBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	record, err := consumeUserToken(body.Token, models.TokenPurposeEmailVerification)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := DB.Model(&models.User{}).Where("id = ?", record.UserID).Updates(map[string]interface{}{
+		"email_verified":    true,
+		"email_verified_at": time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ResendVerificationEmail godoc
+// @Summary Resend verification email
+// @Description Send a new verification link to the email of the current user
+// @Tags Auth / Account
+// @Accept json
+// @Produce json
+// @Success 200 string {string} json "{"message": "Verification email sent"}"
+// @Failure 400 string {string} json "{"error": "Email is already verified"}"
+// @Failure 500 string {string} json "{"error": "Failed to send verification email"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/auth/email/resend-verification [post]
+func ResendVerificationEmail(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if user.EmailVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+		return
+	}
+	if err := sendVerificationEmail(user); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
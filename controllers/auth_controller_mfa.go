@@ -0,0 +1,475 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/totp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// MFAPendingTokenType marks a token issued after the password check but before the second factor
+	MFAPendingTokenType = "mfa_pending"
+	// MFAPendingTokenTTL is how long the user has to enter the TOTP code after the password check
+	MFAPendingTokenTTL = 5 * time.Minute
+	// MFAMaxFailedAttempts invalid codes in a row lock the second factor of the user for MFALockoutDuration
+	MFAMaxFailedAttempts = 5
+	MFALockoutDuration   = 15 * time.Minute
+)
+
+// errMFALocked is returned while the second factor of the user is locked after too many invalid codes
+var errMFALocked = errors.New("too many invalid MFA codes, try again later")
+
+// checkMFACode accepts a TOTP code once: the step of the accepted code is stored and codes of the same or older steps
+// are rejected. Invalid codes are counted and lock the second factor after MFAMaxFailedAttempts.
+func checkMFACode(user *models.User, code string) error {
+	now := time.Now()
+	if now.Before(user.MFALockedUntil) {
+		return errMFALocked
+	}
+	step, ok := totp.ValidateStep(user.MFASecret, code, now, user.MFALastStep)
+	if !ok {
+		recordMFAFailure(user)
+		return errors.New("invalid MFA code")
+	}
+	// the condition on the step makes two requests with the same code race for a single acceptance
+	result := DB.Model(&models.User{}).Where("id = ? AND mfa_last_step < ?", user.ID, step).
+		Updates(map[string]interface{}{"mfa_last_step": step, "mfa_failed_attempts": 0})
+	if result.Error != nil || result.RowsAffected == 0 {
+		recordMFAFailure(user)
+		return errors.New("invalid MFA code")
+	}
+	user.MFALastStep, user.MFAFailedAttempts = step, 0
+	return nil
+}
+
+// recordMFAFailure counts an invalid code or recovery code and locks the second factor once the limit is reached
+func recordMFAFailure(user *models.User) {
+	DB.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("mfa_failed_attempts", gorm.Expr("mfa_failed_attempts + 1"))
+	DB.Select("id", "mfa_failed_attempts").First(user, user.ID)
+	if user.MFAFailedAttempts >= MFAMaxFailedAttempts {
+		user.MFALockedUntil, user.MFAFailedAttempts = time.Now().Add(MFALockoutDuration), 0
+		DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"mfa_locked_until":    user.MFALockedUntil,
+			"mfa_failed_attempts": 0,
+		})
+	}
+}
+
+// respondMFACodeError answers a rejected code, 429 while the second factor is locked
+func respondMFACodeError(c *gin.Context, err error) {
+	if errors.Is(err, errMFALocked) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many invalid MFA codes, try again later"})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+}
+
+// IsMFARequired reports whether the organization policy forces the user to use MFA
+func IsMFARequired(user models.User) bool {
+	var organization models.Organization
+	if err := DB.Select("id", "mfa_policy").First(&organization, user.OrganizationID).Error; err != nil {
+		return false
+	}
+	switch organization.MFAPolicy {
+	case models.MFAPolicyEveryone:
+		return true
+	case models.MFAPolicyAdmins:
+		if user.IsOrganizationAdmin {
+			return true
+		}
+		// project admins can read every secret of their project too
+		var count int64
+		DB.Model(&models.UserRoleProject{}).Where("user_id = ? AND role_id = ?", user.ID, 2).Count(&count)
+		return count > 0
+	}
+	return false
+}
+
+// respondMFAChallenge answers a successful password check with a pending token instead of a session token
+func respondMFAChallenge(c *gin.Context, user models.User) {
+	mfaToken, err := generateMFAPendingToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token for user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "MFA code required",
+		"status:":      "mfa_required",
+		"mfa_required": true,
+		"mfa_token":    mfaToken,
+	})
+}
+
+// parseMFAPendingToken returns the user ID of a valid pending token
+func parseMFAPendingToken(tokenString string) (uint, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(os.Getenv("SECRET_KEY")), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["typ"] != MFAPendingTokenType {
+		return 0, errors.New("invalid mfa token")
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, errors.New("invalid mfa token")
+	}
+	return uint(userID), nil
+}
+
+// useRecoveryCode marks the matching unused recovery code of the user as used
+func useRecoveryCode(userID uint, code string) bool {
+	var recoveryCodes []models.RecoveryCode
+	DB.Where("user_id = ? AND is_used = ?", userID, false).Find(&recoveryCodes)
+	code = totp.NormalizeRecoveryCode(code)
+	for _, rc := range recoveryCodes {
+		if bcrypt.CompareHashAndPassword([]byte(rc.CodeHash), []byte(code)) == nil {
+			// the is_used condition lets only one of concurrent logins with the same code mark it
+			result := DB.Model(&models.RecoveryCode{}).Where("id = ? AND is_used = ?", rc.ID, false).
+				Updates(map[string]interface{}{"is_used": true, "used_at": time.Now()})
+			return result.Error == nil && result.RowsAffected == 1
+		}
+	}
+	return false
+}
+
+// replaceRecoveryCodes deletes the previous recovery codes of the user and returns a fresh set in plain text
+func replaceRecoveryCodes(userID uint) ([]string, error) {
+	codes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	var recoveryCodes []models.RecoveryCode
+	for _, code := range codes {
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		recoveryCodes = append(recoveryCodes, models.RecoveryCode{UserID: userID, CodeHash: string(hash)})
+	}
+	if err := DB.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Create(&recoveryCodes).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// mfaIssuer is the issuer shown in authenticator apps
+func mfaIssuer() string {
+	if issuer := os.Getenv("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Parameter Store"
+}
+
+type loginMFARequestBody struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// LoginWithMFA exchanges a pending token and a TOTP or recovery code for a session token
+// LoginWithMFA godoc
+// @Summary Complete login with MFA code
+// @Description Exchange the mfa_token returned by login and a TOTP code or recovery code for a session token.
+// @Description A TOTP code is accepted once, after 5 invalid codes in a row the second factor is locked for 15 minutes.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body controllers.loginMFARequestBody true "MFA login request"
+// @Success 200 string {string} json "{"message": "User logged in successfully", "token": "token"}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 401 string {string} json "{"error": "Invalid MFA code"}"
+// @Failure 429 string {string} json "{"error": "Too many invalid MFA codes, try again later"}"
+// @Failure 500 string {string} json "{"error": "Failed to login user"}"
+// @Router /api/v1/auth/login/mfa [post]
+func LoginWithMFA(c *gin.Context) {
+	var body loginMFARequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if body.Code == "" && body.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+	userID, err := parseMFAPendingToken(body.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "MFA token is invalid or expired, please login again"})
+		return
+	}
+	var user models.User
+	if err := DB.Preload("UserRoleProjects").First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to login user"})
+		return
+	}
+	if user.IsArchived || !user.MFAEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if body.Code != "" {
+		if err := checkMFACode(&user, body.Code); err != nil {
+			respondMFACodeError(c, err)
+			return
+		}
+	} else if time.Now().Before(user.MFALockedUntil) {
+		respondMFACodeError(c, errMFALocked)
+		return
+	} else if !useRecoveryCode(user.ID, body.RecoveryCode) {
+		recordMFAFailure(&user)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid recovery code"})
+		return
+	}
+
+	var projectIDs []uint
+	for _, urp := range user.UserRoleProjects {
+		if urp.RoleID == 2 {
+			projectIDs = append(projectIDs, urp.ProjectID)
+		}
+	}
+	var responseLogedInUser responseLogedInUser
+	responseLogedInUser.Username = user.Username
+	responseLogedInUser.Email = user.Email
+	responseLogedInUser.OrganizationID = user.OrganizationID
+	responseLogedInUser.IsOrganizationAdmin = user.IsOrganizationAdmin
+	responseLogedInUser.IsAdminOfProjects = projectIDs
+
+	jwtToken, err := generateJWTToken(user, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token for user"})
+		return
+	}
+	user.LastLogin = time.Now()
+	DB.Save(&user)
+	auditAsUser(c, user, auditEntry{Action: "auth.login", TargetType: "user", TargetID: user.ID, TargetName: user.Username, After: map[string]interface{}{"method": "mfa"}})
+
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(
+		"Authorization",
+		jwtToken,
+		3600*24*30,
+		"",
+		os.Getenv("COOKIE_DOMAIN"),
+		true,
+		true,
+	)
+	c.Header("Authorization", jwtToken)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User logged in successfully",
+		"status:": "success",
+		"token":   jwtToken,
+		"user":    responseLogedInUser,
+	})
+}
+
+// EnrollMFA godoc
+// @Summary Start MFA enrollment
+// @Description Generate a TOTP secret and the otpauth URI to render as QR code. MFA is enabled after the first code is verified.
+// @Tags Auth / MFA
+// @Accept json
+// @Produce json
+// @Success 200 string {string} json "{"secret": "secret", "provisioning_uri": "otpauth://..."}"
+// @Failure 400 string {string} json "{"error": "MFA is already enabled"}"
+// @Failure 500 string {string} json "{"error": "Failed to enroll MFA"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/auth/mfa/enroll [post]
+func EnrollMFA(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if user.MFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA is already enabled"})
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate MFA secret"})
+		return
+	}
+	if err := DB.Model(&user).Update("mfa_secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll MFA"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(secret, mfaIssuer(), user.Email),
+	})
+}
+
+type mfaCodeRequestBody struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// VerifyMFAEnrollment godoc
+// @Summary Verify MFA enrollment
+// @Description Verify the first TOTP code, enable MFA and return recovery codes. The recovery codes are shown only once.
+// @Tags Auth / MFA
+// @Accept json
+// @Produce json
+// @Param request body controllers.mfaCodeRequestBody true "TOTP code"
+// @Success 200 string {string} json "{"message": "MFA enabled", "recovery_codes": [], "token": "token"}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 401 string {string} json "{"error": "Invalid MFA code"}"
+// @Failure 429 string {string} json "{"error": "Too many invalid MFA codes, try again later"}"
+// @Failure 500 string {string} json "{"error": "Failed to enable MFA"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/auth/mfa/verify [post]
+func VerifyMFAEnrollment(c *gin.Context) {
+	var body mfaCodeRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if user.MFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA is already enabled"})
+		return
+	}
+	if user.MFASecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA enrollment is not started"})
+		return
+	}
+	if err := checkMFACode(&user, body.Code); err != nil {
+		respondMFACodeError(c, err)
+		return
+	}
+	recoveryCodes, err := replaceRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	user.MFAEnabled = true
+	user.MFAEnabledAt = time.Now()
+	if err := DB.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable MFA"})
+		return
+	}
+	auditByUser(c, auditEntry{Action: "mfa.enable", TargetType: "user", TargetID: user.ID, TargetName: user.Username})
+	// the current token was issued without MFA, hand out one that passes the policy check
+	jwtToken, err := generateJWTToken(user, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token for user"})
+		return
+	}
+	c.Header("Authorization", jwtToken)
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "MFA enabled",
+		"recovery_codes": recoveryCodes,
+		"token":          jwtToken,
+	})
+}
+
+// DisableMFA godoc
+// @Summary Disable MFA
+// @Description Disable MFA of the current user. Not allowed when the organization policy requires MFA.
+// @Tags Auth / MFA
+// @Accept json
+// @Produce json
+// @Param request body controllers.mfaCodeRequestBody true "TOTP code"
+// @Success 200 string {string} json "{"message": "MFA disabled"}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 401 string {string} json "{"error": "Invalid MFA code"}"
+// @Failure 429 string {string} json "{"error": "Too many invalid MFA codes, try again later"}"
+// @Failure 403 string {string} json "{"error": "MFA is required by organization policy"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/auth/mfa/disable [post]
+func DisableMFA(c *gin.Context) {
+	var body mfaCodeRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !user.MFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA is not enabled"})
+		return
+	}
+	if IsMFARequired(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "MFA is required by organization policy"})
+		return
+	}
+	if err := checkMFACode(&user, body.Code); err != nil {
+		respondMFACodeError(c, err)
+		return
+	}
+	if err := DB.Model(&user).Updates(map[string]interface{}{
+		"mfa_enabled":    false,
+		"mfa_secret":     "",
+		"mfa_enabled_at": time.Time{},
+		// the steps of a new secret start over
+		"mfa_last_step": 0,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable MFA"})
+		return
+	}
+	DB.Unscoped().Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{})
+	auditByUser(c, auditEntry{Action: "mfa.disable", TargetType: "user", TargetID: user.ID, TargetName: user.Username})
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate MFA recovery codes
+// @Description Invalidate the current recovery codes and return a new set. The recovery codes are shown only once.
+// @Tags Auth / MFA
+// @Accept json
+// @Produce json
+// @Param request body controllers.mfaCodeRequestBody true "TOTP code"
+// @Success 200 string {string} json "{"recovery_codes": []}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 401 string {string} json "{"error": "Invalid MFA code"}"
+// @Failure 429 string {string} json "{"error": "Too many invalid MFA codes, try again later"}"
+// @Failure 500 string {string} json "{"error": "Failed to generate recovery codes"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/auth/mfa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var body mfaCodeRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !user.MFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA is not enabled"})
+		return
+	}
+	if err := checkMFACode(&user, body.Code); err != nil {
+		respondMFACodeError(c, err)
+		return
+	}
+	recoveryCodes, err := replaceRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
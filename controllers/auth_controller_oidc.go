@@ -0,0 +1,355 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/oidc"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// oidcLoginStateTTL is how long the user has to come back from the identity provider
+const oidcLoginStateTTL = 10 * time.Minute
+
+// StartOIDCLogin godoc
+// @Summary Start single sign-on login
+// @Description Return the authorization URL of the organization identity provider, the frontend redirects the browser to it
+// @Tags Auth / OIDC
+// @Accept json
+// @Produce json
+// @Param organization_name query string true "Organization name"
+// @Param provider query string false "Provider name, the first enabled provider when empty"
+// @Success 200 string {string} json "{"authorization_url": "https://idp/authorize?...", "state": "state"}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 404 string {string} json "{"error": "Single sign-on is not configured for organization"}"
+// @Failure 502 string {string} json "{"error": "Failed to discover identity provider"}"
+// @Router /api/v1/auth/oidc/authorize [get]
+func StartOIDCLogin(c *gin.Context) {
+	organizationName := c.Query("organization_name")
+	if organizationName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization_name is required"})
+		return
+	}
+	var organization models.Organization
+	if err := DB.Where("name = ?", organizationName).First(&organization).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured for organization"})
+		return
+	}
+	query := DB.Where("organization_id = ? AND is_enabled = ?", organization.ID, true)
+	if providerName := c.Query("provider"); providerName != "" {
+		query = query.Where("name = ?", providerName)
+	}
+	var provider models.OIDCProvider
+	if err := query.Order("id asc").First(&provider).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured for organization"})
+		return
+	}
+	metadata, err := oidc.Discover(provider.IssuerURL)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to discover identity provider"})
+		return
+	}
+	state, errState := oidc.RandomString(24)
+	nonce, errNonce := oidc.RandomString(24)
+	verifier, challenge, errPKCE := oidc.GeneratePKCE()
+	if errState != nil || errNonce != nil || errPKCE != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+	loginState := models.OIDCLoginState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ProviderID:   provider.ID,
+		ExpiresAt:    time.Now().Add(oidcLoginStateTTL),
+	}
+	if err := DB.Create(&loginState).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+	// drop abandoned logins
+	DB.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+
+	c.JSON(http.StatusOK, gin.H{
+		"authorization_url": oidc.AuthorizationURL(metadata, provider.ClientID, provider.RedirectURL, strings.Fields(provider.Scopes), state, nonce, challenge),
+		"state":             state,
+	})
+}
+
+type loginWithOIDCBody struct {
+	State string `json:"state" binding:"required"`
+	Code  string `json:"code" binding:"required"`
+}
+
+// completeOIDCCallback consumes the login state, exchanges the code and validates the id_token. It answers the request on failure.
+func completeOIDCCallback(c *gin.Context, state, code string) (models.OIDCProvider, *oidc.IDTokenClaims, bool) {
+	// the state is single use
+	var loginState models.OIDCLoginState
+	if err := DB.Where("state = ?", state).First(&loginState).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login state is invalid, please start again"})
+		return models.OIDCProvider{}, nil, false
+	}
+	DB.Unscoped().Delete(&loginState)
+	if time.Now().After(loginState.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login state is expired, please start again"})
+		return models.OIDCProvider{}, nil, false
+	}
+	var provider models.OIDCProvider
+	if err := DB.Preload("GroupMappings").First(&provider, loginState.ProviderID).Error; err != nil || !provider.IsEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Single sign-on provider is disabled"})
+		return models.OIDCProvider{}, nil, false
+	}
+	metadata, err := oidc.Discover(provider.IssuerURL)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to discover identity provider"})
+		return models.OIDCProvider{}, nil, false
+	}
+	tokenResponse, err := oidc.ExchangeCode(metadata, provider.ClientID, provider.ClientSecret, provider.RedirectURL, code, loginState.CodeVerifier)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to exchange code"})
+		return models.OIDCProvider{}, nil, false
+	}
+	claims, err := oidc.VerifyIDToken(metadata, tokenResponse.IDToken, provider.ClientID, loginState.Nonce)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to validate id_token"})
+		return models.OIDCProvider{}, nil, false
+	}
+	return provider, claims, true
+}
+
+// LoginWithOIDC godoc
+// @Summary Login a user with single sign-on
+// @Description Exchange the code returned to the redirect URL for a session token. Unknown users are provisioned into the organization of the provider.
+// @Description An existing account with the same email is never linked here, its owner links it with POST /api/v1/auth/oidc/link after a password login.
+// @Tags Auth / OIDC
+// @Accept json
+// @Produce json
+// @Param request body controllers.loginWithOIDCBody true "Authorization response"
+// @Success 200 string {string} json "{"message": "User logged in successfully", "token": "token"}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 401 string {string} json "{"error": "Unauthorized"}"
+// @Failure 409 string {string} json "{"error": "An account already uses this email, sign in with your password and link single sign-on from your account"}"
+// @Failure 502 string {string} json "{"error": "Failed to exchange code"}"
+// @Router /api/v1/auth/login/oidc [post]
+func LoginWithOIDC(c *gin.Context) {
+	var body loginWithOIDCBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	provider, claims, ok := completeOIDCCallback(c, body.State, body.Code)
+	if !ok {
+		return
+	}
+	if claims.Email == "" || (provider.RequireVerified && !claims.EmailVerified) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity provider did not return a verified email"})
+		return
+	}
+
+	user, status, message := findOrProvisionOIDCUser(provider, claims)
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	if user.IsArchived {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	applyOIDCGroupMappings(&user, provider, claims.Groups(provider.GroupsClaim))
+
+	if user.MFAEnabled {
+		respondMFAChallenge(c, user)
+		return
+	}
+	DB.Preload("UserRoleProjects").First(&user, user.ID)
+	var projectIDs []uint
+	for _, urp := range user.UserRoleProjects {
+		if urp.RoleID == 2 {
+			projectIDs = append(projectIDs, urp.ProjectID)
+		}
+	}
+	var responseLogedInUser responseLogedInUser
+	responseLogedInUser.Username = user.Username
+	responseLogedInUser.Email = user.Email
+	responseLogedInUser.OrganizationID = user.OrganizationID
+	responseLogedInUser.IsOrganizationAdmin = user.IsOrganizationAdmin
+	responseLogedInUser.IsAdminOfProjects = projectIDs
+
+	jwtToken, err := generateJWTToken(user, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token for user"})
+		return
+	}
+	user.LastLogin = time.Now()
+	DB.Save(&user)
+	auditAsUser(c, user, auditEntry{Action: "auth.login", TargetType: "user", TargetID: user.ID, TargetName: user.Username, After: map[string]interface{}{"method": "oidc"}})
+
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(
+		"Authorization",
+		jwtToken,
+		3600*24*30,
+		"",
+		os.Getenv("COOKIE_DOMAIN"),
+		true,
+		true,
+	)
+	c.Header("Authorization", jwtToken)
+	c.JSON(http.StatusOK, gin.H{
+		"message":                 "User logged in successfully",
+		"status:":                 "success",
+		"token":                   jwtToken,
+		"user":                    responseLogedInUser,
+		"mfa_enrollment_required": IsMFARequired(user),
+	})
+}
+
+// findOrProvisionOIDCUser resolves the user by subject or creates it, an account using the email must be linked by its owner
+func findOrProvisionOIDCUser(provider models.OIDCProvider, claims *oidc.IDTokenClaims) (models.User, int, string) {
+	var user models.User
+	if err := DB.Where("oidc_provider_id = ? AND oidc_subject = ?", provider.ID, claims.Subject).First(&user).Error; err == nil {
+		return user, http.StatusOK, ""
+	}
+	// an account with the email is not linked automatically: the provider may let anyone set any email
+	if err := DB.Where("email = ?", claims.Email).First(&user).Error; err == nil {
+		if user.OrganizationID != provider.OrganizationID {
+			return models.User{}, http.StatusConflict, "Email is already used in another organization"
+		}
+		return models.User{}, http.StatusConflict, "An account already uses this email, sign in with your password and link single sign-on from your account"
+	}
+	if !provider.AllowJIT {
+		return models.User{}, http.StatusUnauthorized, "User is not registered in organization"
+	}
+
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Email
+	}
+	var count int64
+	DB.Model(&models.User{}).Where("username = ?", username).Count(&count)
+	if count > 0 {
+		username = claims.Email
+	}
+	// the password can not be used, the account signs in through the provider
+	unusablePassword, err := oidc.RandomString(32)
+	if err != nil {
+		return models.User{}, http.StatusInternalServerError, "Failed to register user"
+	}
+	hash, err := generateBcryptPassword(unusablePassword)
+	if err != nil {
+		return models.User{}, http.StatusInternalServerError, "Failed to register user"
+	}
+	user = models.User{
+		Email:          claims.Email,
+		Username:       username,
+		Name:           claims.Name,
+		Password:       hash,
+		OrganizationID: provider.OrganizationID,
+		OIDCProviderID: provider.ID,
+		OIDCSubject:    claims.Subject,
+	}
+	if err := DB.Create(&user).Error; err != nil {
+		return models.User{}, http.StatusInternalServerError, "Failed to register user"
+	}
+	return user, http.StatusOK, ""
+}
+
+// applyOIDCGroupMappings grants the project roles mapped to the groups of the user, existing grants are kept
+func applyOIDCGroupMappings(user *models.User, provider models.OIDCProvider, groups []string) {
+	for _, mapping := range provider.GroupMappings {
+		if !isIn(groups, mapping.Group) {
+			continue
+		}
+		if mapping.ProjectID == 0 || mapping.RoleID == 0 {
+			continue
+		}
+		var upr models.UserRoleProject
+		if err := DB.Where("user_id = ? AND project_id = ?", user.ID, mapping.ProjectID).First(&upr).Error; err != nil {
+			DB.Create(&models.UserRoleProject{UserID: user.ID, ProjectID: mapping.ProjectID, RoleID: mapping.RoleID})
+			continue
+		}
+		// a lower role id is a higher privilege: 2 is Project Admin, 3 is Developer
+		if mapping.RoleID < upr.RoleID {
+			DB.Model(&upr).Update("role_id", mapping.RoleID)
+		}
+	}
+}
+
+type linkOIDCAccountBody struct {
+	State    string `json:"state" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// LinkOIDCAccount godoc
+// @Summary Link single sign-on to the current account
+// @Description Attach the identity returned to the redirect URL to the signed in account, confirmed with the account password.
+// @Description The provider must belong to the organization of the account and must have verified the same email.
+// @Tags Auth / OIDC
+// @Accept json
+// @Produce json
+// @Param request body controllers.linkOIDCAccountBody true "Authorization response and password"
+// @Success 200 string {string} json "{"message": "Single sign-on linked"}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 401 string {string} json "{"error": "Invalid password"}"
+// @Failure 403 string {string} json "{"error": "Identity provider did not verify the email"}"
+// @Failure 409 string {string} json "{"error": "Identity is already linked to another account"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/auth/oidc/link [post]
+func LinkOIDCAccount(c *gin.Context) {
+	var body linkOIDCAccountBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+	provider, claims, ok := completeOIDCCallback(c, body.State, body.Code)
+	if !ok {
+		return
+	}
+	if provider.OrganizationID != user.OrganizationID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Single sign-on provider belongs to another organization"})
+		return
+	}
+	if err := claims.CheckAccountLink(user.Email); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Failed to link single sign-on: " + err.Error()})
+		return
+	}
+	var count int64
+	DB.Model(&models.User{}).Where("oidc_provider_id = ? AND oidc_subject = ? AND id <> ?", provider.ID, claims.Subject, user.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Identity is already linked to another account"})
+		return
+	}
+	before := map[string]interface{}{"oidc_provider_id": user.OIDCProviderID}
+	if err := DB.Model(&user).Updates(map[string]interface{}{"oidc_provider_id": provider.ID, "oidc_subject": claims.Subject}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link single sign-on"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		Action:     "auth.oidc_link",
+		TargetType: "user",
+		TargetID:   user.ID,
+		TargetName: user.Username,
+		Before:     before,
+		After:      map[string]interface{}{"oidc_provider_id": provider.ID, "provider": provider.Name},
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Single sign-on linked"})
+}
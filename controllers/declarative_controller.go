@@ -0,0 +1,1049 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/github"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// The declarative API addresses resources by their names so that infrastructure as code tools can converge on them:
+//   - PUT creates (201) or updates (200) a resource, an archived resource is restored with its ID
+//   - GET looks a resource up by name, for imports
+//   - DELETE archives a resource
+//
+// A missing resource or parent is 404. A name shared by several resources is 409, as is a create-only
+// PUT (If-None-Match: *) on an existing name or a write into an archived project. If-Match with a stale ETag is 412.
+
+type declarativeProjectBody struct {
+	Description  string `json:"description"`
+	RepoURL      string `json:"repo_url"`
+	RepoApiToken string `json:"repo_api_token"` // write only, kept when empty
+	AutoUpdate   *bool  `json:"auto_update"`
+}
+
+type declarativeProjectResponse struct {
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	RepoURL       string `json:"repo_url"`
+	AutoUpdate    bool   `json:"auto_update"`
+	IsArchived    bool   `json:"is_archived"`
+	LatestVersion string `json:"latest_version"`
+}
+
+type declarativeStageBody struct {
+	Description string `json:"description"`
+	Color       string `json:"color"`
+}
+
+type declarativeAgentBody struct {
+	Stage        string `json:"stage" binding:"required"`
+	Environment  string `json:"environment" binding:"required"`
+	WorkflowName string `json:"workflow_name" binding:"required"`
+	Description  string `json:"description"`
+}
+
+type declarativeAgentResponse struct {
+	ID           uint   `json:"id"`
+	Name         string `json:"name"`
+	Stage        string `json:"stage"`
+	Environment  string `json:"environment"`
+	WorkflowName string `json:"workflow_name"`
+	Description  string `json:"description"`
+	IsArchived   bool   `json:"is_archived"`
+}
+
+type declarativeParameterBody struct {
+	Value       string `json:"value" binding:"required"`
+	Description string `json:"description"`
+}
+
+type declarativeParameterResponse struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+	Stage       string `json:"stage"`
+	Environment string `json:"environment"`
+	IsApplied   bool   `json:"is_applied"`
+	IsArchived  bool   `json:"is_archived"`
+}
+
+// declarativeETag identifies the state of a resource, it changes with every update
+func declarativeETag(id uint, updatedAt time.Time) string {
+	return fmt.Sprintf("\"%d-%d\"", id, updatedAt.UnixNano())
+}
+
+// lookupByName loads into out the only row of the scope with the name,
+// it returns http.StatusNotFound when there is none and http.StatusConflict when the name is ambiguous
+func lookupByName(scope *gorm.DB, out interface{}, name string) int {
+	var count int64
+	if err := scope.Session(&gorm.Session{}).Model(out).Where("name = ?", name).Count(&count).Error; err != nil {
+		return http.StatusInternalServerError
+	}
+	if status := NameLookupStatus(count); status != http.StatusOK {
+		return status
+	}
+	if err := scope.Session(&gorm.Session{}).Where("name = ?", name).First(out).Error; err != nil {
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+// NameLookupStatus is the outcome of a lookup by name matching count rows, a name is only usable when it is unique
+func NameLookupStatus(count int64) int {
+	switch {
+	case count == 0:
+		return http.StatusNotFound
+	case count > 1:
+		return http.StatusConflict
+	}
+	return http.StatusOK
+}
+
+// respondLookupError answers a failed lookupByName
+func respondLookupError(c *gin.Context, status int, kind string, name string) {
+	switch status {
+	case http.StatusNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("%s %s not found", kind, name)})
+	case http.StatusConflict:
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Several %ss are named %s, rename them before managing them by name", kind, name)})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get %s", kind)})
+	}
+}
+
+// CheckDeclarativePreconditions applies If-None-Match: * (create only) and If-Match (update only that state),
+// it answers 409 or 412 and returns false when the request must stop
+func CheckDeclarativePreconditions(c *gin.Context, exists bool, etag string) bool {
+	if c.GetHeader("If-None-Match") == "*" && exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "Resource already exists, import it instead of creating it"})
+		return false
+	}
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && (!exists || !etagMatches(ifMatch, etag)) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Resource was changed or removed since it was read"})
+		return false
+	}
+	return true
+}
+
+func respondDeclarative(c *gin.Context, created bool, changed bool, etag string, key string, value interface{}) {
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.Header("ETag", etag)
+	c.JSON(status, gin.H{key: value, "created": created, "changed": changed})
+}
+
+// declarativeProjectByName resolves the project in the path, writes need an organization or project admin
+func declarativeProjectByName(c *gin.Context, write bool) (models.Project, models.User, bool) {
+	var project models.Project
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return project, user, false
+	}
+	name := c.Param("project_name")
+	if status := lookupByName(DB.Where("organization_id = ?", user.OrganizationID), &project, name); status != http.StatusOK {
+		respondLookupError(c, status, "project", name)
+		return project, user, false
+	}
+	if !user.IsOrganizationAdmin {
+		var upr models.UserRoleProject
+		if err := DB.Preload("Role").Where("user_id = ? AND project_id = ?", user.ID, project.ID).First(&upr).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User does not belong to the project"})
+			return project, user, false
+		}
+		if write && upr.Role.Name != "Project Admin" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User is not an admin, please contact the project admin to perform this action"})
+			return project, user, false
+		}
+	}
+	if write && project.IsArchived {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Project %s is archived", project.Name)})
+		return project, user, false
+	}
+	return project, user, true
+}
+
+func toDeclarativeProject(project models.Project) declarativeProjectResponse {
+	var version models.Version
+	DB.Select("id", "number").First(&version, project.LatestVersionID)
+	return declarativeProjectResponse{
+		ID:            project.ID,
+		Name:          project.Name,
+		Description:   project.Description,
+		RepoURL:       project.RepoURL,
+		AutoUpdate:    project.AutoUpdate,
+		IsArchived:    project.IsArchived,
+		LatestVersion: version.Number,
+	}
+}
+
+// GetDeclarativeProject godoc
+// @Summary Get project by name
+// @Description Look up a project by name, e.g. to import it. The repo api token is not returned.
+// @Tags Declarative
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Success 200 {object} controllers.declarativeProjectResponse
+// @Failure 404 string {string} json "{"error": "project shop not found"}"
+// @Failure 409 string {string} json "{"error": "Several projects are named shop"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name} [get]
+func GetDeclarativeProject(c *gin.Context) {
+	project, _, ok := declarativeProjectByName(c, false)
+	if !ok {
+		return
+	}
+	c.Header("ETag", declarativeETag(project.ID, project.UpdatedAt))
+	c.JSON(http.StatusOK, gin.H{"project": toDeclarativeProject(project)})
+}
+
+// PutDeclarativeProject godoc
+// @Summary Create or update project by name
+// @Description Create the project with an initial version, or update it. An archived project is restored. Organization admins only.
+// @Tags Declarative
+// @Accept json
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param Project body controllers.declarativeProjectBody true "Project"
+// @Param If-None-Match header string false "* to only create"
+// @Param If-Match header string false "ETag the update is based on"
+// @Success 200 {object} controllers.declarativeProjectResponse
+// @Success 201 {object} controllers.declarativeProjectResponse
+// @Failure 409 string {string} json "{"error": "Resource already exists, import it instead of creating it"}"
+// @Failure 412 string {string} json "{"error": "Resource was changed or removed since it was read"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name} [put]
+func PutDeclarativeProject(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !user.IsOrganizationAdmin {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User is not an organization admin"})
+		return
+	}
+	var body declarativeProjectBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := c.Param("project_name")
+	var project models.Project
+	status := lookupByName(DB.Where("organization_id = ?", user.OrganizationID), &project, name)
+	if status != http.StatusOK && status != http.StatusNotFound {
+		respondLookupError(c, status, "project", name)
+		return
+	}
+	exists := status == http.StatusOK
+	if !CheckDeclarativePreconditions(c, exists, declarativeETag(project.ID, project.UpdatedAt)) {
+		return
+	}
+
+	if !exists {
+		project = models.Project{
+			OrganizationID: user.OrganizationID,
+			Name:           name,
+			Description:    body.Description,
+			StartAt:        time.Now(),
+			Status:         "In Progress",
+			CurrentSprint:  "1",
+			RepoURL:        body.RepoURL,
+			RepoApiToken:   body.RepoApiToken,
+			AutoUpdate:     true,
+		}
+		if project.RepoURL == "" {
+			project.RepoURL = "github.com/OWNER/REPO"
+		}
+		if body.AutoUpdate != nil {
+			project.AutoUpdate = *body.AutoUpdate
+		}
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&project).Error; err != nil {
+				return err
+			}
+			initVersion := models.Version{Number: "1.0.0", Name: "1.0.0", ProjectID: project.ID, Description: "Initial version"}
+			if err := tx.Create(&initVersion).Error; err != nil {
+				return err
+			}
+			project.LatestVersionID = initVersion.ID
+			// auto_update defaults to true in the table, write it when switched off
+			return tx.Model(&project).Select("latest_version_id", "auto_update").Updates(&project).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
+			return
+		}
+		auditByUser(c, auditEntry{
+			ProjectID:  project.ID,
+			Action:     "project.create",
+			TargetType: "project",
+			TargetID:   project.ID,
+			TargetName: project.Name,
+			After:      map[string]interface{}{"repo_url": project.RepoURL, "auto_update": project.AutoUpdate},
+			Status:     http.StatusCreated,
+		})
+		respondDeclarative(c, true, true, declarativeETag(project.ID, project.UpdatedAt), "project", toDeclarativeProject(project))
+		return
+	}
+
+	before := map[string]interface{}{"description": project.Description, "repo_url": project.RepoURL, "auto_update": project.AutoUpdate, "is_archived": project.IsArchived}
+	changed := project.Description != body.Description ||
+		(body.RepoURL != "" && project.RepoURL != body.RepoURL) ||
+		(body.RepoApiToken != "" && project.RepoApiToken != body.RepoApiToken) ||
+		(body.AutoUpdate != nil && project.AutoUpdate != *body.AutoUpdate) ||
+		project.IsArchived
+	if changed {
+		project.Description = body.Description
+		if body.RepoURL != "" {
+			project.RepoURL = body.RepoURL
+		}
+		if body.RepoApiToken != "" {
+			project.RepoApiToken = body.RepoApiToken
+		}
+		if body.AutoUpdate != nil {
+			project.AutoUpdate = *body.AutoUpdate
+		}
+		if project.IsArchived {
+			project.IsArchived = false
+			project.Status = "In Progress"
+			project.ArchivedBy = ""
+			project.ArchivedAt = time.Time{}
+		}
+		if err := DB.Save(&project).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+			return
+		}
+		auditByUser(c, auditEntry{
+			ProjectID:  project.ID,
+			Action:     "project.update",
+			TargetType: "project",
+			TargetID:   project.ID,
+			TargetName: project.Name,
+			Before:     before,
+			After:      map[string]interface{}{"description": project.Description, "repo_url": project.RepoURL, "auto_update": project.AutoUpdate, "is_archived": project.IsArchived},
+		})
+	}
+	respondDeclarative(c, false, changed, declarativeETag(project.ID, project.UpdatedAt), "project", toDeclarativeProject(project))
+}
+
+// DeleteDeclarativeProject godoc
+// @Summary Archive project by name
+// @Description Archive the project, a later PUT restores it with the same ID. Organization admins only.
+// @Tags Declarative
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Success 200 string {string} json "{"message": "Project archived"}"
+// @Failure 404 string {string} json "{"error": "project shop not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name} [delete]
+func DeleteDeclarativeProject(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !user.IsOrganizationAdmin {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User is not an organization admin"})
+		return
+	}
+	name := c.Param("project_name")
+	var project models.Project
+	if status := lookupByName(DB.Where("organization_id = ?", user.OrganizationID), &project, name); status != http.StatusOK {
+		respondLookupError(c, status, "project", name)
+		return
+	}
+	if !CheckDeclarativePreconditions(c, true, declarativeETag(project.ID, project.UpdatedAt)) {
+		return
+	}
+	if !project.IsArchived {
+		project.Status = "Archived"
+		project.IsArchived = true
+		project.ArchivedAt = time.Now()
+		project.ArchivedBy = user.Email
+		DB.Save(&project)
+		auditByUser(c, auditEntry{ProjectID: project.ID, Action: "project.archive", TargetType: "project", TargetID: project.ID, TargetName: project.Name})
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Project archived"})
+}
+
+// GetDeclarativeStage godoc
+// @Summary Get stage by name
+// @Description Look up a stage of a project by name
+// @Tags Declarative
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param stage_name path string true "Stage name"
+// @Success 200 {object} models.Stage
+// @Failure 404 string {string} json "{"error": "stage Build not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name}/stages/{stage_name} [get]
+func GetDeclarativeStage(c *gin.Context) {
+	project, _, ok := declarativeProjectByName(c, false)
+	if !ok {
+		return
+	}
+	var stage models.Stage
+	if status := lookupByName(DB.Where("project_id = ?", project.ID), &stage, c.Param("stage_name")); status != http.StatusOK {
+		respondLookupError(c, status, "stage", c.Param("stage_name"))
+		return
+	}
+	c.Header("ETag", declarativeETag(stage.ID, stage.UpdatedAt))
+	c.JSON(http.StatusOK, gin.H{"stage": stage})
+}
+
+// PutDeclarativeStage godoc
+// @Summary Create or update stage by name
+// @Description Create or update a stage of a project, an archived stage is restored
+// @Tags Declarative
+// @Accept json
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param stage_name path string true "Stage name"
+// @Param Stage body controllers.declarativeStageBody true "Stage"
+// @Param If-None-Match header string false "* to only create"
+// @Param If-Match header string false "ETag the update is based on"
+// @Success 200 {object} models.Stage
+// @Success 201 {object} models.Stage
+// @Failure 404 string {string} json "{"error": "project shop not found"}"
+// @Failure 409 string {string} json "{"error": "Resource already exists, import it instead of creating it"}"
+// @Failure 412 string {string} json "{"error": "Resource was changed or removed since it was read"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name}/stages/{stage_name} [put]
+func PutDeclarativeStage(c *gin.Context) {
+	project, user, ok := declarativeProjectByName(c, true)
+	if !ok {
+		return
+	}
+	var body declarativeStageBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := c.Param("stage_name")
+	var stage models.Stage
+	status := lookupByName(DB.Where("project_id = ?", project.ID), &stage, name)
+	if status != http.StatusOK && status != http.StatusNotFound {
+		respondLookupError(c, status, "stage", name)
+		return
+	}
+	exists := status == http.StatusOK
+	if !CheckDeclarativePreconditions(c, exists, declarativeETag(stage.ID, stage.UpdatedAt)) {
+		return
+	}
+	if !exists {
+		stage = models.Stage{Name: name, Description: body.Description, Color: body.Color, ProjectID: project.ID}
+		if err := DB.Create(&stage).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stage"})
+			return
+		}
+		projectLogByUser(project.ID, "Create Stage", "Stage created successfully", http.StatusCreated, 0, user.ID)
+		respondDeclarative(c, true, true, declarativeETag(stage.ID, stage.UpdatedAt), "stage", stage)
+		return
+	}
+	changed := stage.Description != body.Description || stage.Color != body.Color || stage.IsArchived
+	if changed {
+		stage.Description = body.Description
+		stage.Color = body.Color
+		stage.IsArchived = false
+		stage.ArchivedBy = ""
+		stage.ArchivedAt = time.Time{}
+		if err := DB.Save(&stage).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stage"})
+			return
+		}
+		projectLogByUser(project.ID, "Update Stage", "Stage updated successfully", http.StatusOK, 0, user.ID)
+	}
+	respondDeclarative(c, false, changed, declarativeETag(stage.ID, stage.UpdatedAt), "stage", stage)
+}
+
+// DeleteDeclarativeStage godoc
+// @Summary Archive stage by name
+// @Description Archive a stage of a project, a later PUT restores it with the same ID
+// @Tags Declarative
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param stage_name path string true "Stage name"
+// @Success 200 string {string} json "{"message": "Stage archived"}"
+// @Failure 404 string {string} json "{"error": "stage Build not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name}/stages/{stage_name} [delete]
+func DeleteDeclarativeStage(c *gin.Context) {
+	project, user, ok := declarativeProjectByName(c, true)
+	if !ok {
+		return
+	}
+	var stage models.Stage
+	if status := lookupByName(DB.Where("project_id = ?", project.ID), &stage, c.Param("stage_name")); status != http.StatusOK {
+		respondLookupError(c, status, "stage", c.Param("stage_name"))
+		return
+	}
+	if !CheckDeclarativePreconditions(c, true, declarativeETag(stage.ID, stage.UpdatedAt)) {
+		return
+	}
+	if !stage.IsArchived {
+		stage.IsArchived = true
+		stage.ArchivedBy = user.Username
+		stage.ArchivedAt = time.Now()
+		DB.Save(&stage)
+		projectLogByUser(project.ID, "Archive Stage", "Stage archived successfully", http.StatusOK, 0, user.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Stage archived"})
+}
+
+// GetDeclarativeEnvironment godoc
+// @Summary Get environment by name
+// @Description Look up an environment of a project by name
+// @Tags Declarative
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param environment_name path string true "Environment name"
+// @Success 200 {object} models.Environment
+// @Failure 404 string {string} json "{"error": "environment Production not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name}/environments/{environment_name} [get]
+func GetDeclarativeEnvironment(c *gin.Context) {
+	project, _, ok := declarativeProjectByName(c, false)
+	if !ok {
+		return
+	}
+	var environment models.Environment
+	if status := lookupByName(DB.Where("project_id = ?", project.ID), &environment, c.Param("environment_name")); status != http.StatusOK {
+		respondLookupError(c, status, "environment", c.Param("environment_name"))
+		return
+	}
+	c.Header("ETag", declarativeETag(environment.ID, environment.UpdatedAt))
+	c.JSON(http.StatusOK, gin.H{"environment": environment})
+}
+
+// PutDeclarativeEnvironment godoc
+// @Summary Create or update environment by name
+// @Description Create or update an environment of a project, an archived environment is restored
+// @Tags Declarative
+// @Accept json
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param environment_name path string true "Environment name"
+// @Param Environment body controllers.declarativeStageBody true "Environment"
+// @Param If-None-Match header string false "* to only create"
+// @Param If-Match header string false "ETag the update is based on"
+// @Success 200 {object} models.Environment
+// @Success 201 {object} models.Environment
+// @Failure 404 string {string} json "{"error": "project shop not found"}"
+// @Failure 409 string {string} json "{"error": "Resource already exists, import it instead of creating it"}"
+// @Failure 412 string {string} json "{"error": "Resource was changed or removed since it was read"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name}/environments/{environment_name} [put]
+func PutDeclarativeEnvironment(c *gin.Context) {
+	project, user, ok := declarativeProjectByName(c, true)
+	if !ok {
+		return
+	}
+	var body declarativeStageBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := c.Param("environment_name")
+	var environment models.Environment
+	status := lookupByName(DB.Where("project_id = ?", project.ID), &environment, name)
+	if status != http.StatusOK && status != http.StatusNotFound {
+		respondLookupError(c, status, "environment", name)
+		return
+	}
+	exists := status == http.StatusOK
+	if !CheckDeclarativePreconditions(c, exists, declarativeETag(environment.ID, environment.UpdatedAt)) {
+		return
+	}
+	if !exists {
+		environment = models.Environment{Name: name, Description: body.Description, Color: body.Color, ProjectID: project.ID}
+		if err := DB.Create(&environment).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create environment"})
+			return
+		}
+		projectLogByUser(project.ID, "Create Environment", "Environment created successfully", http.StatusCreated, 0, user.ID)
+		respondDeclarative(c, true, true, declarativeETag(environment.ID, environment.UpdatedAt), "environment", environment)
+		return
+	}
+	changed := environment.Description != body.Description || environment.Color != body.Color || environment.IsArchived
+	if changed {
+		environment.Description = body.Description
+		environment.Color = body.Color
+		environment.IsArchived = false
+		environment.ArchivedBy = ""
+		environment.ArchivedAt = time.Time{}
+		if err := DB.Save(&environment).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update environment"})
+			return
+		}
+		projectLogByUser(project.ID, "Update Environment", "Environment updated successfully", http.StatusOK, 0, user.ID)
+	}
+	respondDeclarative(c, false, changed, declarativeETag(environment.ID, environment.UpdatedAt), "environment", environment)
+}
+
+// DeleteDeclarativeEnvironment godoc
+// @Summary Archive environment by name
+// @Description Archive an environment of a project, a later PUT restores it with the same ID
+// @Tags Declarative
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param environment_name path string true "Environment name"
+// @Success 200 string {string} json "{"message": "Environment archived"}"
+// @Failure 404 string {string} json "{"error": "environment Production not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name}/environments/{environment_name} [delete]
+func DeleteDeclarativeEnvironment(c *gin.Context) {
+	project, user, ok := declarativeProjectByName(c, true)
+	if !ok {
+		return
+	}
+	var environment models.Environment
+	if status := lookupByName(DB.Where("project_id = ?", project.ID), &environment, c.Param("environment_name")); status != http.StatusOK {
+		respondLookupError(c, status, "environment", c.Param("environment_name"))
+		return
+	}
+	if !CheckDeclarativePreconditions(c, true, declarativeETag(environment.ID, environment.UpdatedAt)) {
+		return
+	}
+	if !environment.IsArchived {
+		environment.IsArchived = true
+		environment.ArchivedBy = user.Username
+		environment.ArchivedAt = time.Now()
+		DB.Save(&environment)
+		projectLogByUser(project.ID, "Archive Environment", "Environment archived successfully", http.StatusOK, 0, user.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Environment archived"})
+}
+
+// declarativeStageAndEnvironment resolves active stage and environment names of the project, answering 404 otherwise
+func declarativeStageAndEnvironment(c *gin.Context, project models.Project, stageName, environmentName string) (models.Stage, models.Environment, bool) {
+	var stage models.Stage
+	var environment models.Environment
+	if status := lookupByName(DB.Where("project_id = ? AND is_archived = ?", project.ID, false), &stage, stageName); status != http.StatusOK {
+		respondLookupError(c, status, "stage", stageName)
+		return stage, environment, false
+	}
+	if status := lookupByName(DB.Where("project_id = ? AND is_archived = ?", project.ID, false), &environment, environmentName); status != http.StatusOK {
+		respondLookupError(c, status, "environment", environmentName)
+		return stage, environment, false
+	}
+	return stage, environment, true
+}
+
+func toDeclarativeAgent(agent models.Agent) declarativeAgentResponse {
+	stage, environment := stageAndEnvironmentNames(agent.StageID, agent.EnvironmentID)
+	return declarativeAgentResponse{
+		ID:           agent.ID,
+		Name:         agent.Name,
+		Stage:        stage,
+		Environment:  environment,
+		WorkflowName: agent.WorkflowName,
+		Description:  agent.Description,
+		IsArchived:   agent.IsArchived,
+	}
+}
+
+// GetDeclarativeAgent godoc
+// @Summary Get agent by name
+// @Description Look up an agent of a project by name, the api token is not returned
+// @Tags Declarative
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param agent_name path string true "Agent name"
+// @Success 200 {object} controllers.declarativeAgentResponse
+// @Failure 404 string {string} json "{"error": "agent deployer not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name}/agents/{agent_name} [get]
+func GetDeclarativeAgent(c *gin.Context) {
+	project, _, ok := declarativeProjectByName(c, false)
+	if !ok {
+		return
+	}
+	var agent models.Agent
+	if status := lookupByName(DB.Where("project_id = ?", project.ID), &agent, c.Param("agent_name")); status != http.StatusOK {
+		respondLookupError(c, status, "agent", c.Param("agent_name"))
+		return
+	}
+	c.Header("ETag", declarativeETag(agent.ID, agent.UpdatedAt))
+	c.JSON(http.StatusOK, gin.H{"agent": toDeclarativeAgent(agent)})
+}
+
+// PutDeclarativeAgent godoc
+// @Summary Create or update agent by name
+// @Description Create or update an agent of a project, an archived agent is restored. The api token is only returned when the agent is created.
+// @Tags Declarative
+// @Accept json
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param agent_name path string true "Agent name"
+// @Param Agent body controllers.declarativeAgentBody true "Agent"
+// @Param If-None-Match header string false "* to only create"
+// @Param If-Match header string false "ETag the update is based on"
+// @Success 200 {object} controllers.declarativeAgentResponse
+// @Success 201 string {string} json "{"agent": {}, "api_token": "..."}"
+// @Failure 404 string {string} json "{"error": "stage Build not found"}"
+// @Failure 409 string {string} json "{"error": "Resource already exists, import it instead of creating it"}"
+// @Failure 412 string {string} json "{"error": "Resource was changed or removed since it was read"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name}/agents/{agent_name} [put]
+func PutDeclarativeAgent(c *gin.Context) {
+	project, _, ok := declarativeProjectByName(c, true)
+	if !ok {
+		return
+	}
+	var body declarativeAgentBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	stage, environment, ok := declarativeStageAndEnvironment(c, project, body.Stage, body.Environment)
+	if !ok {
+		return
+	}
+	name := c.Param("agent_name")
+	var agent models.Agent
+	status := lookupByName(DB.Where("project_id = ?", project.ID), &agent, name)
+	if status != http.StatusOK && status != http.StatusNotFound {
+		respondLookupError(c, status, "agent", name)
+		return
+	}
+	exists := status == http.StatusOK
+	if !CheckDeclarativePreconditions(c, exists, declarativeETag(agent.ID, agent.UpdatedAt)) {
+		return
+	}
+	var workflowID uint
+	if !exists || agent.WorkflowName != body.WorkflowName {
+		if err := github.ValidateWorkflowName(body.WorkflowName, project.RepoURL, project.RepoApiToken); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		var workflow models.Workflow
+		DB.Where("project_id = ? AND name = ?", project.ID, body.WorkflowName).First(&workflow)
+		workflowID = workflow.WorkflowID
+	}
+	after := map[string]interface{}{"stage_id": stage.ID, "environment_id": environment.ID, "workflow_name": body.WorkflowName}
+
+	if !exists {
+		agent = models.Agent{
+			ProjectID:     project.ID,
+			Name:          name,
+			StageID:       stage.ID,
+			EnvironmentID: environment.ID,
+			WorkflowID:    workflowID,
+			WorkflowName:  body.WorkflowName,
+			Description:   body.Description,
+		}
+		if err := DB.Create(&agent).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create agent"})
+			return
+		}
+		agent.APIToken = GenerateTokenForAgent(strconv.Itoa(int(agent.ID)), strconv.Itoa(int(project.OrganizationID)))
+		DB.Save(&agent)
+		auditByUser(c, auditEntry{ProjectID: project.ID, Action: "agent.create", TargetType: "agent", TargetID: agent.ID, TargetName: agent.Name, After: after, Status: http.StatusCreated})
+		c.Header("ETag", declarativeETag(agent.ID, agent.UpdatedAt))
+		c.JSON(http.StatusCreated, gin.H{"agent": toDeclarativeAgent(agent), "api_token": agent.APIToken, "created": true, "changed": true})
+		return
+	}
+
+	before := map[string]interface{}{"stage_id": agent.StageID, "environment_id": agent.EnvironmentID, "workflow_name": agent.WorkflowName}
+	changed := agent.StageID != stage.ID || agent.EnvironmentID != environment.ID ||
+		agent.WorkflowName != body.WorkflowName || agent.Description != body.Description || agent.IsArchived
+	if changed {
+		if agent.WorkflowName != body.WorkflowName {
+			agent.WorkflowID = workflowID
+		}
+		agent.StageID = stage.ID
+		agent.EnvironmentID = environment.ID
+		agent.WorkflowName = body.WorkflowName
+		agent.Description = body.Description
+		action := "agent.update"
+		if agent.IsArchived {
+			action = "agent.restore"
+			agent.IsArchived = false
+			agent.ArchivedBy = ""
+			agent.ArchivedAt = time.Time{}
+		}
+		if err := DB.Save(&agent).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update agent"})
+			return
+		}
+		auditByUser(c, auditEntry{ProjectID: project.ID, Action: action, TargetType: "agent", TargetID: agent.ID, TargetName: agent.Name, Before: before, After: after})
+	}
+	respondDeclarative(c, false, changed, declarativeETag(agent.ID, agent.UpdatedAt), "agent", toDeclarativeAgent(agent))
+}
+
+// DeleteDeclarativeAgent godoc
+// @Summary Archive agent by name
+// @Description Archive an agent of a project, its token stops working until a later PUT restores it
+// @Tags Declarative
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param agent_name path string true "Agent name"
+// @Success 200 string {string} json "{"message": "Agent archived"}"
+// @Failure 404 string {string} json "{"error": "agent deployer not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name}/agents/{agent_name} [delete]
+func DeleteDeclarativeAgent(c *gin.Context) {
+	project, user, ok := declarativeProjectByName(c, true)
+	if !ok {
+		return
+	}
+	var agent models.Agent
+	if status := lookupByName(DB.Where("project_id = ?", project.ID), &agent, c.Param("agent_name")); status != http.StatusOK {
+		respondLookupError(c, status, "agent", c.Param("agent_name"))
+		return
+	}
+	if !CheckDeclarativePreconditions(c, true, declarativeETag(agent.ID, agent.UpdatedAt)) {
+		return
+	}
+	if !agent.IsArchived {
+		agent.IsArchived = true
+		agent.ArchivedBy = user.Username
+		agent.ArchivedAt = time.Now()
+		DB.Save(&agent)
+		auditByUser(c, auditEntry{ProjectID: project.ID, Action: "agent.archive", TargetType: "agent", TargetID: agent.ID, TargetName: agent.Name})
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Agent archived"})
+}
+
+// latestVersionParameters scopes the parameters of the latest version of the project in a stage and environment
+func latestVersionParameters(project models.Project, stage models.Stage, environment models.Environment) *gorm.DB {
+	return DB.
+		Joins("JOIN version_parameters ON version_parameters.parameter_id = parameters.id AND version_parameters.version_id = ?", project.LatestVersionID).
+		Where("parameters.project_id = ? AND parameters.stage_id = ? AND parameters.environment_id = ?", project.ID, stage.ID, environment.ID)
+}
+
+func toDeclarativeParameter(parameter models.Parameter, stage models.Stage, environment models.Environment) declarativeParameterResponse {
+	return declarativeParameterResponse{
+		ID:          parameter.ID,
+		Name:        parameter.Name,
+		Value:       parameter.Value,
+		Description: parameter.Description,
+		Stage:       stage.Name,
+		Environment: environment.Name,
+		IsApplied:   parameter.IsApplied,
+		IsArchived:  parameter.IsArchived,
+	}
+}
+
+// GetDeclarativeParameter godoc
+// @Summary Get parameter by name
+// @Description Look up a parameter of the latest version by project, stage, environment and name
+// @Tags Declarative
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param stage_name path string true "Stage name"
+// @Param environment_name path string true "Environment name"
+// @Param parameter_name path string true "Parameter name"
+// @Success 200 {object} controllers.declarativeParameterResponse
+// @Failure 404 string {string} json "{"error": "parameter DB_HOST not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name}/stages/{stage_name}/environments/{environment_name}/parameters/{parameter_name} [get]
+func GetDeclarativeParameter(c *gin.Context) {
+	project, _, ok := declarativeProjectByName(c, false)
+	if !ok {
+		return
+	}
+	stage, environment, ok := declarativeStageAndEnvironment(c, project, c.Param("stage_name"), c.Param("environment_name"))
+	if !ok {
+		return
+	}
+	var parameter models.Parameter
+	if status := lookupByName(latestVersionParameters(project, stage, environment), &parameter, c.Param("parameter_name")); status != http.StatusOK {
+		respondLookupError(c, status, "parameter", c.Param("parameter_name"))
+		return
+	}
+	c.Header("ETag", declarativeETag(parameter.ID, parameter.UpdatedAt))
+	c.JSON(http.StatusOK, gin.H{"parameter": toDeclarativeParameter(parameter, stage, environment)})
+}
+
+// ApplyDeclarativeParameter writes the declared value and description onto an existing parameter, an archived parameter
+// is restored and a draft gets its value. It returns false when the parameter is already in the declared state.
+func ApplyDeclarativeParameter(parameter *models.Parameter, value, description string) bool {
+	if parameter.Value == value && parameter.Description == description && !parameter.IsArchived && !parameter.IsDraft {
+		return false
+	}
+	if parameter.Value != value || parameter.IsArchived {
+		parameter.IsApplied = false
+	}
+	parameter.SetValue(value)
+	parameter.Description = description
+	parameter.IsArchived = false
+	parameter.ArchivedBy = ""
+	parameter.ArchivedAt = time.Time{}
+	parameter.EditedAt = time.Now().UTC()
+	return true
+}
+
+// PutDeclarativeParameter godoc
+// @Summary Create or update parameter by name
+// @Description Create or update a parameter of the latest version, an archived parameter is restored. CI is not rerun, use apply-parameters once every change is made.
+// @Tags Declarative
+// @Accept json
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param stage_name path string true "Stage name"
+// @Param environment_name path string true "Environment name"
+// @Param parameter_name path string true "Parameter name"
+// @Param Parameter body controllers.declarativeParameterBody true "Parameter"
+// @Param If-None-Match header string false "* to only create"
+// @Param If-Match header string false "ETag the update is based on"
+// @Success 200 {object} controllers.declarativeParameterResponse
+// @Success 201 {object} controllers.declarativeParameterResponse
+// @Failure 404 string {string} json "{"error": "stage Build not found"}"
+// @Failure 409 string {string} json "{"error": "Resource already exists, import it instead of creating it"}"
+// @Failure 412 string {string} json "{"error": "Resource was changed or removed since it was read"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name}/stages/{stage_name}/environments/{environment_name}/parameters/{parameter_name} [put]
+func PutDeclarativeParameter(c *gin.Context) {
+	project, user, ok := declarativeProjectByName(c, true)
+	if !ok {
+		return
+	}
+	var body declarativeParameterBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	stage, environment, ok := declarativeStageAndEnvironment(c, project, c.Param("stage_name"), c.Param("environment_name"))
+	if !ok {
+		return
+	}
+	name := c.Param("parameter_name")
+	var parameter models.Parameter
+	status := lookupByName(latestVersionParameters(project, stage, environment), &parameter, name)
+	if status != http.StatusOK && status != http.StatusNotFound {
+		respondLookupError(c, status, "parameter", name)
+		return
+	}
+	exists := status == http.StatusOK
+	if !CheckDeclarativePreconditions(c, exists, declarativeETag(parameter.ID, parameter.UpdatedAt)) {
+		return
+	}
+
+	if !exists {
+		parameter = models.Parameter{
+			Name:          name,
+			Value:         body.Value,
+			Description:   body.Description,
+			ProjectID:     project.ID,
+			StageID:       stage.ID,
+			EnvironmentID: environment.ID,
+			EditedAt:      time.Now().UTC(),
+		}
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&parameter).Error; err != nil {
+				return err
+			}
+			return tx.Exec("INSERT INTO version_parameters (version_id, parameter_id) VALUES (?, ?)", project.LatestVersionID, parameter.ID).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create parameter"})
+			return
+		}
+		auditByUser(c, auditEntry{
+			ProjectID:  project.ID,
+			Action:     "parameter.create",
+			TargetType: "parameter",
+			TargetID:   parameter.ID,
+			TargetName: parameter.Name,
+			After:      parameterAuditSnapshot(parameter),
+			Status:     http.StatusCreated,
+		})
+		emitProjectEvent(project.ID, WebhookEventParameterCreated, parameterWebhookData(parameter))
+		notifyParameterChanged(user, project.ID, "created", parameter)
+		projectLogByUser(project.ID, "Create Parameter", fmt.Sprint("Created parameter ", parameter.Name), http.StatusCreated, 0, user.ID)
+		respondDeclarative(c, true, true, declarativeETag(parameter.ID, parameter.UpdatedAt), "parameter", toDeclarativeParameter(parameter, stage, environment))
+		return
+	}
+
+	current := parameter
+	changed := ApplyDeclarativeParameter(&parameter, body.Value, body.Description)
+	if changed {
+		if err := DB.Save(&parameter).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update parameter"})
+			return
+		}
+		action, event := "parameter.update", WebhookEventParameterUpdated
+		var data interface{} = gin.H{"before": parameterWebhookData(current), "after": parameterWebhookData(parameter)}
+		if current.IsArchived {
+			action, event, data = "parameter.unarchive", WebhookEventParameterUnarchived, parameterWebhookData(parameter)
+		}
+		auditByUser(c, auditEntry{
+			ProjectID:  project.ID,
+			Action:     action,
+			TargetType: "parameter",
+			TargetID:   parameter.ID,
+			TargetName: parameter.Name,
+			Before:     parameterAuditSnapshot(current),
+			After:      parameterAuditSnapshot(parameter),
+		})
+		emitProjectEvent(project.ID, event, data)
+		notifyParameterChanged(user, project.ID, "updated", parameter)
+		projectLogByUser(project.ID, "Update Parameter", fmt.Sprint("Updated parameter ", parameter.Name), http.StatusOK, 0, user.ID)
+	}
+	respondDeclarative(c, false, changed, declarativeETag(parameter.ID, parameter.UpdatedAt), "parameter", toDeclarativeParameter(parameter, stage, environment))
+}
+
+// DeleteDeclarativeParameter godoc
+// @Summary Archive parameter by name
+// @Description Archive a parameter of the latest version, a later PUT restores it with the same ID
+// @Tags Declarative
+// @Produce json
+// @Param project_name path string true "Project name"
+// @Param stage_name path string true "Stage name"
+// @Param environment_name path string true "Environment name"
+// @Param parameter_name path string true "Parameter name"
+// @Success 200 string {string} json "{"message": "Parameter archived"}"
+// @Failure 404 string {string} json "{"error": "parameter DB_HOST not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/declarative/projects/{project_name}/stages/{stage_name}/environments/{environment_name}/parameters/{parameter_name} [delete]
+func DeleteDeclarativeParameter(c *gin.Context) {
+	project, user, ok := declarativeProjectByName(c, true)
+	if !ok {
+		return
+	}
+	stage, environment, ok := declarativeStageAndEnvironment(c, project, c.Param("stage_name"), c.Param("environment_name"))
+	if !ok {
+		return
+	}
+	var parameter models.Parameter
+	if status := lookupByName(latestVersionParameters(project, stage, environment), &parameter, c.Param("parameter_name")); status != http.StatusOK {
+		respondLookupError(c, status, "parameter", c.Param("parameter_name"))
+		return
+	}
+	if !CheckDeclarativePreconditions(c, true, declarativeETag(parameter.ID, parameter.UpdatedAt)) {
+		return
+	}
+	if !parameter.IsArchived {
+		before := parameterAuditSnapshot(parameter)
+		parameter.IsArchived = true
+		parameter.ArchivedBy = user.Username
+		parameter.ArchivedAt = time.Now()
+		parameter.IsApplied = false
+		DB.Save(&parameter)
+		auditByUser(c, auditEntry{
+			ProjectID:  project.ID,
+			Action:     "parameter.archive",
+			TargetType: "parameter",
+			TargetID:   parameter.ID,
+			TargetName: parameter.Name,
+			Before:     before,
+			After:      parameterAuditSnapshot(parameter),
+		})
+		emitProjectEvent(project.ID, WebhookEventParameterArchived, parameterWebhookData(parameter))
+		notifyParameterChanged(user, project.ID, "archived", parameter)
+		projectLogByUser(project.ID, "Archive Parameter", fmt.Sprint("Archived parameter ", parameter.Name), http.StatusOK, 0, user.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Parameter archived"})
+}
@@ -45,6 +45,15 @@ func agentLog(agent models.Agent, project models.Project, action string, message
 		ExecutedInWorkflowLogID: workflowlogID,
 	}
 	DB.Create(&log)
+	streamProjectEvent(project.ID, StreamEventAgentLog, map[string]interface{}{
+		"agent_id":        agent.ID,
+		"agent_name":      agent.Name,
+		"action":          action,
+		"message":         message,
+		"response_status": responseStatusCode,
+		"latency":         log.Latency,
+		"parameter_count": len(pulledParameters),
+	})
 	if responseStatusCode == 200 {
 		var pulledParameterLogs []models.AgentPullParameterLog
 		for _, parameter := range pulledParameters {
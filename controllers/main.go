@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"parameter-store-be/models"
+	"parameter-store-be/modules/auditsink"
+	"parameter-store-be/modules/mailer"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,7 +20,9 @@ import (
 )
 
 var (
-	DB *gorm.DB
+	DB        *gorm.DB
+	Mailer    mailer.Sender       = mailer.DisabledSender{}
+	AuditSink auditsink.Publisher = auditsink.NopPublisher{}
 )
 
 // SetDB sets the db object
@@ -25,6 +30,16 @@ func SetDB(database *gorm.DB) {
 	DB = database
 }
 
+// SetMailer sets the sender used for invitation, verification and password reset emails
+func SetMailer(sender mailer.Sender) {
+	Mailer = sender
+}
+
+// SetAuditSink sets where stored audit log entries are streamed, e.g. syslog or a SIEM webhook
+func SetAuditSink(publisher auditsink.Publisher) {
+	AuditSink = publisher
+}
+
 // generateBcryptPassword generates a bcrypt hash of the password
 func generateBcryptPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), 10)
@@ -34,12 +49,13 @@ func generateBcryptPassword(password string) (string, error) {
 	return string(hash), nil
 }
 
-// generateJWTToken generates a JWT token
-func generateJWTToken(user models.User) (string, error) {
+// generateJWTToken generates a JWT token, mfaVerified records if the user passed the second factor
+func generateJWTToken(user models.User, mfaVerified bool) (string, error) {
 	// Generate a JWT token
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": user.ID,
 		"org_id":  user.OrganizationID,
+		"mfa":     mfaVerified,
 		"exp":     time.Now().Add(time.Hour * 24 * 30).Unix(),
 	})
 	tokenstring, err := jwtToken.SignedString([]byte(os.Getenv("SECRET_KEY")))
@@ -49,6 +65,16 @@ func generateJWTToken(user models.User) (string, error) {
 	return tokenstring, nil
 }
 
+// generateMFAPendingToken generates a short-lived token which can only be exchanged at /auth/login/mfa
+func generateMFAPendingToken(user models.User) (string, error) {
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": user.ID,
+		"typ":     MFAPendingTokenType,
+		"exp":     time.Now().Add(MFAPendingTokenTTL).Unix(),
+	})
+	return jwtToken.SignedString([]byte(os.Getenv("SECRET_KEY")))
+}
+
 // gen Token for agents, by agentID and orgID
 func GenerateTokenForAgent(agentID, orgID string) string {
 	plainText := agentID + orgID
@@ -93,6 +119,34 @@ func getUserFromContext(c *gin.Context) (models.User, error) {
 	return user.(models.User), nil
 }
 
+// projectOfUser loads the project in the path from the organization of the user, members need a project role and
+// writes need the Project Admin role, organization admins reach every project of their organization
+func projectOfUser(c *gin.Context, requireAdmin bool) (models.Project, models.User, bool) {
+	var project models.Project
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return project, user, false
+	}
+	if err := DB.Where("organization_id = ?", user.OrganizationID).First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return project, user, false
+	}
+	if user.IsOrganizationAdmin {
+		return project, user, true
+	}
+	var upr models.UserRoleProject
+	if err := DB.Preload("Role").Where("user_id = ? AND project_id = ?", user.ID, project.ID).First(&upr).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User does not belong to the project"})
+		return project, user, false
+	}
+	if requireAdmin && upr.Role.Name != "Project Admin" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User is not an admin, please contact the project admin to perform this action"})
+		return project, user, false
+	}
+	return project, user, true
+}
+
 func paginationDataParam(paramList []models.Parameter, page, limit int) []models.Parameter {
 	start := (page - 1) * limit
 	end := page * limit
@@ -59,6 +59,7 @@ func GetOrganizationInformation(c *gin.Context) {
 			"user_count":         usersCount,
 			"project_count":      projectsCount,
 			"address":            organization.Address,
+			"mfa_policy":         organization.MFAPolicy,
 		},
 	}
 
@@ -135,6 +136,66 @@ func UpdateOrganizationInformation(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"organization": organization})
 }
 
+type organizationMFAPolicyBody struct {
+	MFAPolicy string `json:"mfa_policy" binding:"required"`
+}
+
+// UpdateOrganizationMFAPolicy godoc
+// @Summary Update organization MFA policy
+// @Description Require MFA for nobody ("none"), organization and project admins ("admins") or every user ("everyone")
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param organization_id path int true "Organization ID"
+// @Param Policy body organizationMFAPolicyBody true "MFA policy"
+// @Success 200 string {string} json "{"message": "MFA policy updated", "mfa_policy": "admins"}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 500 string {string} json "{"error": "Failed to update MFA policy"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/{organization_id}/mfa-policy [put]
+func UpdateOrganizationMFAPolicy(c *gin.Context) {
+	organizationID, err := strconv.Atoi(c.Param("organization_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization ID must be an integer"})
+		return
+	}
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !user.IsOrganizationAdmin {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User is not an organization admin"})
+		return
+	}
+	if user.OrganizationID != uint(organizationID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User does not belong to the organization"})
+		return
+	}
+	var requestBody organizationMFAPolicyBody
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch requestBody.MFAPolicy {
+	case models.MFAPolicyNone, models.MFAPolicyAdmins, models.MFAPolicyEveryone:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_policy must be one of none, admins, everyone"})
+		return
+	}
+	if err := DB.Model(&models.Organization{}).Where("id = ?", organizationID).Update("mfa_policy", requestBody.MFAPolicy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update MFA policy"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		Action:     "organization.mfa_policy.update",
+		TargetType: "organization",
+		TargetID:   uint(organizationID),
+		After:      map[string]interface{}{"mfa_policy": requestBody.MFAPolicy},
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "MFA policy updated", "mfa_policy": requestBody.MFAPolicy})
+}
+
 // GetOrganizationDashboardTotals godoc
 // @Summary Get organization dashboard totals
 // @Description Get organization dashboard totals
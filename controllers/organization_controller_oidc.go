@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/oidc"
+
+	"github.com/gin-gonic/gin"
+)
+
+type oidcGroupMappingBody struct {
+	Group     string `json:"group" binding:"required"`
+	ProjectID uint   `json:"project_id" binding:"required"`
+	RoleID    uint   `json:"role_id" binding:"required"` // Project Admin or Developer
+}
+
+type oidcProviderBody struct {
+	Name                 string                 `json:"name" binding:"required"`
+	IssuerURL            string                 `json:"issuer_url" binding:"required"`
+	ClientID             string                 `json:"client_id" binding:"required"`
+	ClientSecret         string                 `json:"client_secret"`
+	RedirectURL          string                 `json:"redirect_url" binding:"required"`
+	Scopes               string                 `json:"scopes"`
+	GroupsClaim          string                 `json:"groups_claim"`
+	IsEnabled            *bool                  `json:"is_enabled"`
+	AllowJIT             *bool                  `json:"allow_jit"`
+	RequireVerifiedEmail *bool                  `json:"require_verified_email"`
+	GroupMappings        []oidcGroupMappingBody `json:"group_mappings"`
+}
+
+// ListOIDCProviders godoc
+// @Summary List single sign-on providers
+// @Description List OIDC providers of the organization with their group mappings
+// @Tags Organization / OIDC
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.OIDCProvider
+// @Failure 500 string {string} json "{"error": "Failed to list providers"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/oidc-providers [get]
+func ListOIDCProviders(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var providers []models.OIDCProvider
+	if err := DB.Preload("GroupMappings").Where("organization_id = ?", user.OrganizationID).Find(&providers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list providers"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"providers": providers})
+}
+
+// CreateOIDCProvider godoc
+// @Summary Create single sign-on provider
+// @Description Create an OIDC provider for the organization, the issuer must serve a discovery document
+// @Tags Organization / OIDC
+// @Accept json
+// @Produce json
+// @Param Provider body controllers.oidcProviderBody true "Provider"
+// @Success 201 {object} models.OIDCProvider
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 500 string {string} json "{"error": "Failed to create provider"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/oidc-providers [post]
+func CreateOIDCProvider(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var body oidcProviderBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := oidc.Discover(body.IssuerURL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	provider := models.OIDCProvider{
+		OrganizationID:  user.OrganizationID,
+		Scopes:          "openid email profile",
+		GroupsClaim:     "groups",
+		IsEnabled:       true,
+		AllowJIT:        true,
+		RequireVerified: true,
+	}
+	if msg := applyOIDCProviderBody(&provider, body); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := DB.Create(&provider).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create provider"})
+		return
+	}
+	// flags default to true in the table, write the ones switched off explicitly
+	DB.Model(&provider).Select("is_enabled", "allow_jit", "require_verified").Updates(&provider)
+	c.JSON(http.StatusCreated, gin.H{"provider": provider})
+}
+
+// UpdateOIDCProvider godoc
+// @Summary Update single sign-on provider
+// @Description Update an OIDC provider, the group mappings are replaced by the ones in the body. An empty client_secret keeps the current one.
+// @Tags Organization / OIDC
+// @Accept json
+// @Produce json
+// @Param provider_id path int true "Provider ID"
+// @Param Provider body controllers.oidcProviderBody true "Provider"
+// @Success 200 {object} models.OIDCProvider
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 404 string {string} json "{"error": "Provider not found"}"
+// @Failure 500 string {string} json "{"error": "Failed to update provider"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/oidc-providers/{provider_id} [put]
+func UpdateOIDCProvider(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var provider models.OIDCProvider
+	if err := DB.Where("organization_id = ?", user.OrganizationID).First(&provider, c.Param("provider_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Provider not found"})
+		return
+	}
+	var body oidcProviderBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if body.IssuerURL != provider.IssuerURL {
+		if _, err := oidc.Discover(body.IssuerURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if msg := applyOIDCProviderBody(&provider, body); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	mappings := provider.GroupMappings
+	provider.GroupMappings = nil
+	if err := DB.Save(&provider).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update provider"})
+		return
+	}
+	DB.Unscoped().Where("provider_id = ?", provider.ID).Delete(&models.OIDCGroupMapping{})
+	for i := range mappings {
+		mappings[i].ProviderID = provider.ID
+	}
+	if len(mappings) > 0 {
+		if err := DB.Create(&mappings).Error; err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group mappings"})
+			return
+		}
+	}
+	provider.GroupMappings = mappings
+	c.JSON(http.StatusOK, gin.H{"provider": provider})
+}
+
+// DeleteOIDCProvider godoc
+// @Summary Delete single sign-on provider
+// @Description Delete an OIDC provider and its group mappings. Users keep their accounts.
+// @Tags Organization / OIDC
+// @Accept json
+// @Produce json
+// @Param provider_id path int true "Provider ID"
+// @Success 200 string {string} json "{"message": "Provider deleted"}"
+// @Failure 404 string {string} json "{"error": "Provider not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/oidc-providers/{provider_id} [delete]
+func DeleteOIDCProvider(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var provider models.OIDCProvider
+	if err := DB.Where("organization_id = ?", user.OrganizationID).First(&provider, c.Param("provider_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Provider not found"})
+		return
+	}
+	DB.Unscoped().Where("provider_id = ?", provider.ID).Delete(&models.OIDCGroupMapping{})
+	if err := DB.Delete(&provider).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete provider"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Provider deleted"})
+}
+
+// applyOIDCProviderBody copies the request into the provider, it returns a message when a mapping is invalid
+func applyOIDCProviderBody(provider *models.OIDCProvider, body oidcProviderBody) string {
+	provider.Name = body.Name
+	provider.IssuerURL = body.IssuerURL
+	provider.ClientID = body.ClientID
+	provider.RedirectURL = body.RedirectURL
+	if body.ClientSecret != "" {
+		provider.ClientSecret = body.ClientSecret
+	}
+	if body.Scopes != "" {
+		provider.Scopes = body.Scopes
+	}
+	if body.GroupsClaim != "" {
+		provider.GroupsClaim = body.GroupsClaim
+	}
+	if body.IsEnabled != nil {
+		provider.IsEnabled = *body.IsEnabled
+	}
+	if body.AllowJIT != nil {
+		provider.AllowJIT = *body.AllowJIT
+	}
+	if body.RequireVerifiedEmail != nil {
+		provider.RequireVerified = *body.RequireVerifiedEmail
+	}
+	provider.GroupMappings = nil
+	for _, m := range body.GroupMappings {
+		var project models.Project
+		if err := DB.Where("organization_id = ?", provider.OrganizationID).First(&project, m.ProjectID).Error; err != nil {
+			return "Project of group " + m.Group + " does not belong to organization"
+		}
+		var role models.Role
+		if err := DB.First(&role, m.RoleID).Error; err != nil || !IsProjectRole(role) {
+			return "role_id of group " + m.Group + " must be a project role, Project Admin or Developer"
+		}
+		provider.GroupMappings = append(provider.GroupMappings, models.OIDCGroupMapping{
+			Group:     m.Group,
+			ProjectID: m.ProjectID,
+			RoleID:    m.RoleID,
+		})
+	}
+	return ""
+}
@@ -0,0 +1,649 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/notifier"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type sharedParameterBody struct {
+	Name        string `json:"name" binding:"required"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+	Environment string `json:"environment"`  // every environment when empty
+	IsPlaintext bool   `json:"is_plaintext"` // the value is masked in the audit log unless plaintext
+}
+
+type sharedParameterSetBody struct {
+	Name        string                `json:"name" binding:"required"`
+	Description string                `json:"description"`
+	Parameters  []sharedParameterBody `json:"parameters" binding:"dive"`
+	Rerun       bool                  `json:"rerun"` // rerun the workflows of the affected agents
+}
+
+// sharedSetAgentImpact is an agent that receives other values after a change of a shared set
+type sharedSetAgentImpact struct {
+	AgentID     uint     `json:"agent_id"`
+	AgentName   string   `json:"agent_name"`
+	Stage       string   `json:"stage"`
+	Environment string   `json:"environment"`
+	Names       []string `json:"names"`
+
+	stageID, environmentID uint
+}
+
+// sharedSetProjectImpact is a project linked to a shared set with its affected agents
+type sharedSetProjectImpact struct {
+	ProjectID   uint                   `json:"project_id"`
+	ProjectName string                 `json:"project_name"`
+	Agents      []sharedSetAgentImpact `json:"agents"`
+}
+
+// linkedParameterSets returns the shared sets of a project with their parameters, in the order they were linked
+func linkedParameterSets(projectID uint) ([]models.SharedParameterSet, error) {
+	var links []models.ProjectParameterSet
+	if err := DB.Preload("Set.Parameters").Where("project_id = ?", projectID).Order("id").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	sets := make([]models.SharedParameterSet, 0, len(links))
+	for _, link := range links {
+		if link.Set.ID != 0 {
+			sets = append(sets, link.Set)
+		}
+	}
+	return sets, nil
+}
+
+// SharedValues resolves the shared parameters of an environment, a value for the environment wins over one for every
+// environment whatever the set, and among values of the same kind sets linked later win over the ones linked before
+func SharedValues(sets []models.SharedParameterSet, environment string) map[string]models.SharedParameter {
+	values := map[string]models.SharedParameter{}
+	for _, set := range sets {
+		for _, parameter := range set.Parameters {
+			if parameter.Environment == "" {
+				values[parameter.Name] = parameter
+			}
+		}
+	}
+	for _, set := range sets {
+		for _, parameter := range set.Parameters {
+			if parameter.Environment == environment {
+				values[parameter.Name] = parameter
+			}
+		}
+	}
+	return values
+}
+
+// MergeSharedValues appends the shared values to the parameters the agent receives, the parameters of the project win.
+// Shared values are not saved, their ID is 0.
+func MergeSharedValues(agent models.Agent, parameters []models.Parameter, values map[string]models.SharedParameter) []models.Parameter {
+	own := map[string]bool{}
+	for _, parameter := range parameters {
+		own[parameter.Name] = true
+	}
+	for name, shared := range values {
+		if own[name] {
+			continue
+		}
+		parameters = append(parameters, models.Parameter{
+			ProjectID:     agent.ProjectID,
+			StageID:       agent.StageID,
+			EnvironmentID: agent.EnvironmentID,
+			Name:          shared.Name,
+			Value:         shared.Value,
+			Description:   shared.Description,
+			IsPlaintext:   shared.IsPlaintext,
+		})
+	}
+	sort.SliceStable(parameters, func(i, j int) bool { return parameters[i].Name < parameters[j].Name })
+	return parameters
+}
+
+// addSharedParameters appends the shared values of the agent's environment to the parameters it receives
+func addSharedParameters(agent models.Agent, parameters []models.Parameter) []models.Parameter {
+	sets, err := linkedParameterSets(agent.ProjectID)
+	if err != nil || len(sets) == 0 {
+		return parameters
+	}
+	var environment models.Environment
+	if err := DB.Select("id", "name").First(&environment, agent.EnvironmentID).Error; err != nil {
+		return parameters
+	}
+	return MergeSharedValues(agent, parameters, SharedValues(sets, environment.Name))
+}
+
+// sharedSetImpact lists the projects linked to the set and the agents whose values change when the set gets the parameters
+func sharedSetImpact(set models.SharedParameterSet, parameters []models.SharedParameter) ([]sharedSetProjectImpact, error) {
+	var projects []models.Project
+	err := DB.Joins("JOIN project_parameter_sets ON project_parameter_sets.project_id = projects.id AND project_parameter_sets.deleted_at IS NULL").
+		Where("project_parameter_sets.set_id = ? AND projects.is_archived = ?", set.ID, false).
+		Order("projects.name").Find(&projects).Error
+	if err != nil {
+		return nil, err
+	}
+	impacts := []sharedSetProjectImpact{}
+	for _, project := range projects {
+		before, err := linkedParameterSets(project.ID)
+		if err != nil {
+			return nil, err
+		}
+		after := make([]models.SharedParameterSet, len(before))
+		copy(after, before)
+		for i := range after {
+			if after[i].ID == set.ID {
+				after[i].Parameters = parameters
+			}
+		}
+		current, err := versionParameters(project.ID, project.LatestVersionID)
+		if err != nil {
+			return nil, err
+		}
+		own := map[string]bool{}
+		for _, parameter := range current {
+			own[fmt.Sprintf("%d/%d/%s", parameter.StageID, parameter.EnvironmentID, parameter.Name)] = true
+		}
+		var agents []models.Agent
+		DB.Preload("Stage").Preload("Environment").Where("project_id = ? AND is_archived = ?", project.ID, false).Order("name").Find(&agents)
+
+		impact := sharedSetProjectImpact{ProjectID: project.ID, ProjectName: project.Name, Agents: []sharedSetAgentImpact{}}
+		for _, agent := range agents {
+			oldValues, newValues := SharedValues(before, agent.Environment.Name), SharedValues(after, agent.Environment.Name)
+			names := map[string]bool{}
+			for name, value := range oldValues {
+				if newValue, exists := newValues[name]; !exists || newValue.Value != value.Value {
+					names[name] = true
+				}
+			}
+			for name := range newValues {
+				if _, exists := oldValues[name]; !exists {
+					names[name] = true
+				}
+			}
+			agentImpact := sharedSetAgentImpact{
+				AgentID:       agent.ID,
+				AgentName:     agent.Name,
+				Stage:         agent.Stage.Name,
+				Environment:   agent.Environment.Name,
+				stageID:       agent.StageID,
+				environmentID: agent.EnvironmentID,
+			}
+			for name := range names {
+				if !own[fmt.Sprintf("%d/%d/%s", agent.StageID, agent.EnvironmentID, name)] {
+					agentImpact.Names = append(agentImpact.Names, name)
+				}
+			}
+			if len(agentImpact.Names) > 0 {
+				sort.Strings(agentImpact.Names)
+				impact.Agents = append(impact.Agents, agentImpact)
+			}
+		}
+		impacts = append(impacts, impact)
+	}
+	return impacts, nil
+}
+
+// applySharedParameterSetBody copies the request into the set, it returns a message when a parameter is duplicated
+func applySharedParameterSetBody(set *models.SharedParameterSet, body sharedParameterSetBody) string {
+	set.Name = body.Name
+	set.Description = body.Description
+	set.Parameters = nil
+	seen := map[string]bool{}
+	for _, parameter := range body.Parameters {
+		key := parameter.Environment + "/" + parameter.Name
+		if seen[key] {
+			if parameter.Environment == "" {
+				return fmt.Sprintf("Parameter %s is duplicated", parameter.Name)
+			}
+			return fmt.Sprintf("Parameter %s is duplicated in environment %s", parameter.Name, parameter.Environment)
+		}
+		seen[key] = true
+		set.Parameters = append(set.Parameters, models.SharedParameter{
+			SetID:       set.ID,
+			Name:        parameter.Name,
+			Value:       parameter.Value,
+			Description: parameter.Description,
+			Environment: parameter.Environment,
+			IsPlaintext: parameter.IsPlaintext,
+		})
+	}
+	return ""
+}
+
+// saveSharedParameterSet writes the set and replaces its parameters
+func saveSharedParameterSet(set *models.SharedParameterSet) error {
+	parameters := set.Parameters
+	set.Parameters = nil
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(set).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("set_id = ?", set.ID).Delete(&models.SharedParameter{}).Error; err != nil {
+			return err
+		}
+		for i := range parameters {
+			parameters[i].ID = 0
+			parameters[i].SetID = set.ID
+		}
+		if err := tx.Create(&parameters).Error; err != nil && !errors.Is(err, gorm.ErrEmptySlice) {
+			return err
+		}
+		return nil
+	})
+	set.Parameters = parameters
+	return err
+}
+
+// sharedSetAuditSnapshot is the set with the values of secrets masked
+func sharedSetAuditSnapshot(set models.SharedParameterSet) map[string]interface{} {
+	var parameters []map[string]interface{}
+	for _, parameter := range set.Parameters {
+		parameters = append(parameters, map[string]interface{}{
+			"name":        parameter.Name,
+			"value":       maskValue(parameter.Value, parameter.IsPlaintext),
+			"environment": parameter.Environment,
+		})
+	}
+	return map[string]interface{}{"name": set.Name, "description": set.Description, "parameters": parameters}
+}
+
+// announceSharedSetChange tells the affected projects about the change, wakes their watching agents and reruns their workflows when asked
+func announceSharedSetChange(user models.User, set models.SharedParameterSet, impacts []sharedSetProjectImpact, rerun bool) {
+	for _, impact := range impacts {
+		if len(impact.Agents) == 0 {
+			continue
+		}
+		var names []string
+		seen := map[string]bool{}
+		for _, agent := range impact.Agents {
+			for _, name := range agent.Names {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Strings(names)
+		emitProjectEvent(impact.ProjectID, WebhookEventParameterSetUpdated, gin.H{"set_id": set.ID, "set": set.Name, "names": names, "agents": len(impact.Agents)})
+		message := fmt.Sprintf("Shared parameter set %s changed %v for %d agents", set.Name, names, len(impact.Agents))
+		projectLogByUser(impact.ProjectID, "Update Shared Parameter Set", "Succeed: "+message, http.StatusOK, 0, user.ID)
+		notifyProject(impact.ProjectID, NotificationEventParameterChanged, notifier.Message{
+			Title: fmt.Sprintf("[%s] Shared parameters changed", impact.ProjectName),
+			Text:  fmt.Sprintf("*%s* %s", user.Username, message),
+			Level: notifier.LevelInfo,
+			Fields: []notifier.Field{
+				{Name: "Project", Value: impact.ProjectName},
+				{Name: "Set", Value: set.Name},
+				{Name: "Agents", Value: strconv.Itoa(len(impact.Agents))},
+			},
+		})
+	}
+	if !rerun {
+		return
+	}
+	go func() {
+		for _, impact := range impacts {
+			targets := map[[2]uint]bool{}
+			for _, agent := range impact.Agents {
+				targets[[2]uint{agent.stageID, agent.environmentID}] = true
+			}
+			for target := range targets {
+				if status, _, message, err := rerunCICDWorkflow(impact.ProjectID, target[0], target[1]); err != nil {
+					log.Printf("Failed to rerun workflow after shared set change: %d %s", status, message)
+				}
+			}
+		}
+	}()
+}
+
+// ListSharedParameterSets godoc
+// @Summary List shared parameter sets
+// @Description List the shared parameter sets of the organization with their parameters and the number of linked projects
+// @Tags Organization / Shared Parameter Sets
+// @Accept json
+// @Produce json
+// @Success 200 string {string} json "{"sets": []}"
+// @Failure 500 string {string} json "{"error": "Failed to list sets"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/parameter-sets [get]
+func ListSharedParameterSets(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var sets []models.SharedParameterSet
+	if err := DB.Preload("Parameters").Where("organization_id = ?", user.OrganizationID).Order("name").Find(&sets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sets"})
+		return
+	}
+	type setResponse struct {
+		models.SharedParameterSet
+		LinkedProjects int64 `json:"linked_projects"`
+	}
+	response := make([]setResponse, 0, len(sets))
+	for _, set := range sets {
+		var count int64
+		DB.Model(&models.ProjectParameterSet{}).Where("set_id = ?", set.ID).Count(&count)
+		response = append(response, setResponse{SharedParameterSet: set, LinkedProjects: count})
+	}
+	c.JSON(http.StatusOK, gin.H{"sets": response})
+}
+
+// GetSharedParameterSet godoc
+// @Summary Get shared parameter set
+// @Description Get a shared parameter set with the linked projects and the agents receiving its values
+// @Tags Organization / Shared Parameter Sets
+// @Accept json
+// @Produce json
+// @Param set_id path int true "Set ID"
+// @Success 200 string {string} json "{"set": {}, "projects": []}"
+// @Failure 404 string {string} json "{"error": "Set not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/parameter-sets/{set_id} [get]
+func GetSharedParameterSet(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var set models.SharedParameterSet
+	if err := DB.Preload("Parameters").Where("organization_id = ?", user.OrganizationID).First(&set, c.Param("set_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Set not found"})
+		return
+	}
+	// the impact of removing every value is the list of agents receiving one
+	projects, err := sharedSetImpact(set, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get linked projects"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"set": set, "projects": projects})
+}
+
+// CreateSharedParameterSet godoc
+// @Summary Create shared parameter set
+// @Description Create a set of parameters shared by projects, a parameter applies to the environments of its name or to every environment when it has none
+// @Tags Organization / Shared Parameter Sets
+// @Accept json
+// @Produce json
+// @Param Set body controllers.sharedParameterSetBody true "Set"
+// @Success 201 {object} models.SharedParameterSet
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/parameter-sets [post]
+func CreateSharedParameterSet(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var body sharedParameterSetBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	set := models.SharedParameterSet{OrganizationID: user.OrganizationID}
+	if msg := applySharedParameterSetBody(&set, body); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := saveSharedParameterSet(&set); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create set"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		Action:     "parameter_set.create",
+		TargetType: "parameter_set",
+		TargetID:   set.ID,
+		TargetName: set.Name,
+		After:      sharedSetAuditSnapshot(set),
+		Status:     http.StatusCreated,
+	})
+	c.JSON(http.StatusCreated, gin.H{"set": set})
+}
+
+// UpdateSharedParameterSet godoc
+// @Summary Update shared parameter set
+// @Description Replace the parameters of a shared set. The response lists every linked project and the agents whose values change.
+// @Description With dry_run nothing is saved, with rerun the workflows of the affected agents are rerun.
+// @Tags Organization / Shared Parameter Sets
+// @Accept json
+// @Produce json
+// @Param set_id path int true "Set ID"
+// @Param dry_run query bool false "Only return the affected projects and agents"
+// @Param Set body controllers.sharedParameterSetBody true "Set"
+// @Success 200 string {string} json "{"set": {}, "projects": []}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 404 string {string} json "{"error": "Set not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/parameter-sets/{set_id} [put]
+func UpdateSharedParameterSet(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var set models.SharedParameterSet
+	if err := DB.Preload("Parameters").Where("organization_id = ?", user.OrganizationID).First(&set, c.Param("set_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Set not found"})
+		return
+	}
+	var body sharedParameterSetBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	before := set
+	if msg := applySharedParameterSetBody(&set, body); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	impacts, err := sharedSetImpact(before, set.Parameters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get linked projects"})
+		return
+	}
+	if dryRun, _ := strconv.ParseBool(c.Query("dry_run")); dryRun {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "set": set, "projects": impacts})
+		return
+	}
+	if err := saveSharedParameterSet(&set); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update set"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		Action:     "parameter_set.update",
+		TargetType: "parameter_set",
+		TargetID:   set.ID,
+		TargetName: set.Name,
+		Before:     sharedSetAuditSnapshot(before),
+		After:      sharedSetAuditSnapshot(set),
+	})
+	announceSharedSetChange(user, set, impacts, body.Rerun)
+	c.JSON(http.StatusOK, gin.H{"set": set, "projects": impacts, "rerun": body.Rerun})
+}
+
+// DeleteSharedParameterSet godoc
+// @Summary Delete shared parameter set
+// @Description Delete a shared set and its links, the response lists the agents that no longer receive its values. With dry_run nothing is deleted.
+// @Tags Organization / Shared Parameter Sets
+// @Accept json
+// @Produce json
+// @Param set_id path int true "Set ID"
+// @Param dry_run query bool false "Only return the affected projects and agents"
+// @Success 200 string {string} json "{"message": "Set deleted", "projects": []}"
+// @Failure 404 string {string} json "{"error": "Set not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/parameter-sets/{set_id} [delete]
+func DeleteSharedParameterSet(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var set models.SharedParameterSet
+	if err := DB.Preload("Parameters").Where("organization_id = ?", user.OrganizationID).First(&set, c.Param("set_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Set not found"})
+		return
+	}
+	impacts, err := sharedSetImpact(set, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get linked projects"})
+		return
+	}
+	if dryRun, _ := strconv.ParseBool(c.Query("dry_run")); dryRun {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "projects": impacts})
+		return
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("set_id = ?", set.ID).Delete(&models.ProjectParameterSet{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("set_id = ?", set.ID).Delete(&models.SharedParameter{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&set).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete set"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		Action:     "parameter_set.delete",
+		TargetType: "parameter_set",
+		TargetID:   set.ID,
+		TargetName: set.Name,
+		Before:     sharedSetAuditSnapshot(set),
+	})
+	announceSharedSetChange(user, set, impacts, false)
+	c.JSON(http.StatusOK, gin.H{"message": "Set deleted", "projects": impacts})
+}
+
+// GetProjectParameterSets godoc
+// @Summary List shared parameter sets of project
+// @Description List the shared sets linked to the project in the order they apply, values for the environment win over values for every environment, then later sets win. Parameters of the project win over every set
+// @Tags Project Detail / Parameters
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 string {string} json "{"sets": []}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameter-sets [get]
+func GetProjectParameterSets(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("project_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	sets, err := linkedParameterSets(uint(projectID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sets"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sets": sets})
+}
+
+// LinkProjectParameterSet godoc
+// @Summary Link shared parameter set to project
+// @Description Link a shared set of the organization, its values are delivered to the agents of the project
+// @Tags Project Detail / Parameters
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param set_id path int true "Set ID"
+// @Success 201 string {string} json "{"message": "Set linked"}"
+// @Failure 404 string {string} json "{"error": "Set not found"}"
+// @Failure 409 string {string} json "{"error": "Set is already linked"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameter-sets/{set_id} [post]
+func LinkProjectParameterSet(c *gin.Context) {
+	startTime := time.Now()
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var project models.Project
+	if err := DB.First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	var set models.SharedParameterSet
+	if err := DB.Where("organization_id = ?", project.OrganizationID).First(&set, c.Param("set_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Set not found"})
+		return
+	}
+	var count int64
+	DB.Model(&models.ProjectParameterSet{}).Where("project_id = ? AND set_id = ?", project.ID, set.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Set is already linked"})
+		return
+	}
+	if err := DB.Create(&models.ProjectParameterSet{ProjectID: project.ID, SetID: set.ID}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link set"})
+		return
+	}
+	projectLogByUser(project.ID, "Link Shared Parameter Set", "Succeed: Shared parameter set "+set.Name+" linked", http.StatusCreated, time.Since(startTime), user.ID)
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "parameter_set.link",
+		TargetType: "parameter_set",
+		TargetID:   set.ID,
+		TargetName: set.Name,
+		Status:     http.StatusCreated,
+	})
+	emitProjectEvent(project.ID, WebhookEventParameterSetUpdated, gin.H{"set_id": set.ID, "set": set.Name, "linked": true})
+	c.JSON(http.StatusCreated, gin.H{"message": "Set linked"})
+}
+
+// UnlinkProjectParameterSet godoc
+// @Summary Unlink shared parameter set from project
+// @Description Unlink a shared set, its values are no longer delivered to the agents of the project
+// @Tags Project Detail / Parameters
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param set_id path int true "Set ID"
+// @Success 200 string {string} json "{"message": "Set unlinked"}"
+// @Failure 404 string {string} json "{"error": "Set is not linked"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameter-sets/{set_id} [delete]
+func UnlinkProjectParameterSet(c *gin.Context) {
+	startTime := time.Now()
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var link models.ProjectParameterSet
+	if err := DB.Preload("Set").Where("project_id = ? AND set_id = ?", c.Param("project_id"), c.Param("set_id")).First(&link).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Set is not linked"})
+		return
+	}
+	if err := DB.Unscoped().Delete(&link).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink set"})
+		return
+	}
+	projectLogByUser(link.ProjectID, "Unlink Shared Parameter Set", "Succeed: Shared parameter set "+link.Set.Name+" unlinked", http.StatusOK, time.Since(startTime), user.ID)
+	auditByUser(c, auditEntry{
+		ProjectID:  link.ProjectID,
+		Action:     "parameter_set.unlink",
+		TargetType: "parameter_set",
+		TargetID:   link.SetID,
+		TargetName: link.Set.Name,
+	})
+	emitProjectEvent(link.ProjectID, WebhookEventParameterSetUpdated, gin.H{"set_id": link.SetID, "set": link.Set.Name, "linked": false})
+	c.JSON(http.StatusOK, gin.H{"message": "Set unlinked"})
+}
@@ -0,0 +1,502 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/projecttemplate"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type projectTemplateLayerBody struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+}
+
+type projectTemplateParameterBody struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Type        string `json:"type"`        // string (default), number, boolean, url or secret
+	Stage       string `json:"stage"`       // required in every stage when empty
+	Environment string `json:"environment"` // required in every environment when empty
+}
+
+type projectTemplateAgentBody struct {
+	Name         string `json:"name" binding:"required"`
+	Stage        string `json:"stage" binding:"required"`
+	Environment  string `json:"environment" binding:"required"`
+	WorkflowName string `json:"workflow_name"`
+	Description  string `json:"description"`
+}
+
+type projectTemplateBody struct {
+	Name         string                         `json:"name" binding:"required"`
+	Description  string                         `json:"description"`
+	IsDefault    bool                           `json:"is_default"`
+	Stages       []projectTemplateLayerBody     `json:"stages" binding:"required,dive"`
+	Environments []projectTemplateLayerBody     `json:"environments" binding:"required,dive"`
+	Parameters   []projectTemplateParameterBody `json:"parameters" binding:"dive"`
+	Agents       []projectTemplateAgentBody     `json:"agents" binding:"dive"`
+}
+
+// projectTemplateCompliance is the result of the compliance check of one project
+type projectTemplateCompliance struct {
+	ProjectID   uint                    `json:"project_id"`
+	ProjectName string                  `json:"project_name"`
+	Version     string                  `json:"version"`
+	Compliant   bool                    `json:"compliant"`
+	Missing     int                     `json:"missing"`
+	Invalid     int                     `json:"invalid"`
+	Issues      []projecttemplate.Issue `json:"issues"`
+}
+
+// defaultProjectTemplate is used for new projects when the organization has no default template
+func defaultProjectTemplate() models.ProjectTemplate {
+	return models.ProjectTemplate{
+		Name: "Built-in",
+		Stages: []models.ProjectTemplateStage{
+			{Name: "Build", Description: "Build stage"},
+			{Name: "Test", Description: "Test stage"},
+			{Name: "Release", Description: "Release stage"},
+			{Name: "Deploy", Description: "Deploy stage"},
+		},
+		Environments: []models.ProjectTemplateEnvironment{
+			{Name: "Development", Description: "Development environment"},
+			{Name: "Staging", Description: "Staging environment"},
+			{Name: "Production", Description: "Production environment"},
+		},
+	}
+}
+
+// preloadProjectTemplate loads the stages and environments in their order with the parameters and agents
+func preloadProjectTemplate(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Stages", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Environments", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Parameters").
+		Preload("Agents")
+}
+
+// projectTemplateFor returns the template of the organization with the ID, its default template when the ID is 0,
+// or the built-in layout when it has none
+func projectTemplateFor(organizationID uint, templateID uint) (models.ProjectTemplate, error) {
+	var template models.ProjectTemplate
+	query := preloadProjectTemplate(DB).Where("organization_id = ?", organizationID)
+	if templateID != 0 {
+		err := query.First(&template, templateID).Error
+		return template, err
+	}
+	if err := query.Where("is_default = ?", true).First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return defaultProjectTemplate(), nil
+		}
+		return template, err
+	}
+	return template, nil
+}
+
+// instantiateProjectTemplate creates the stages, environments and agents of the template in the project
+func instantiateProjectTemplate(tx *gorm.DB, project models.Project, template models.ProjectTemplate) error {
+	stageIDs, environmentIDs := map[string]uint{}, map[string]uint{}
+	for _, layer := range template.Stages {
+		stage := models.Stage{Name: layer.Name, Description: layer.Description, Color: layer.Color, ProjectID: project.ID}
+		if err := tx.Create(&stage).Error; err != nil {
+			return err
+		}
+		stageIDs[stage.Name] = stage.ID
+	}
+	for _, layer := range template.Environments {
+		environment := models.Environment{Name: layer.Name, Description: layer.Description, Color: layer.Color, ProjectID: project.ID}
+		if err := tx.Create(&environment).Error; err != nil {
+			return err
+		}
+		environmentIDs[environment.Name] = environment.ID
+	}
+	for _, layout := range template.Agents {
+		agent := models.Agent{
+			ProjectID:     project.ID,
+			Name:          layout.Name,
+			StageID:       stageIDs[layout.Stage],
+			EnvironmentID: environmentIDs[layout.Environment],
+			WorkflowName:  layout.WorkflowName,
+			Description:   layout.Description,
+		}
+		if err := tx.Create(&agent).Error; err != nil {
+			return err
+		}
+		apiToken := GenerateTokenForAgent(strconv.Itoa(int(agent.ID)), strconv.Itoa(int(project.OrganizationID)))
+		if err := tx.Model(&agent).Update("api_token", apiToken).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyProjectTemplateBody copies the request into the template, it returns a message when the body is inconsistent
+func applyProjectTemplateBody(template *models.ProjectTemplate, body projectTemplateBody) string {
+	template.Name = body.Name
+	template.Description = body.Description
+	template.IsDefault = body.IsDefault
+	template.Stages, template.Environments, template.Parameters, template.Agents = nil, nil, nil, nil
+	if len(body.Stages) == 0 || len(body.Environments) == 0 {
+		return "A template needs at least one stage and one environment"
+	}
+	stages, environments := map[string]bool{}, map[string]bool{}
+	for i, stage := range body.Stages {
+		if stages[stage.Name] {
+			return fmt.Sprintf("Stage %s is duplicated", stage.Name)
+		}
+		stages[stage.Name] = true
+		template.Stages = append(template.Stages, models.ProjectTemplateStage{Name: stage.Name, Description: stage.Description, Color: stage.Color, Position: i})
+	}
+	for i, environment := range body.Environments {
+		if environments[environment.Name] {
+			return fmt.Sprintf("Environment %s is duplicated", environment.Name)
+		}
+		environments[environment.Name] = true
+		template.Environments = append(template.Environments, models.ProjectTemplateEnvironment{Name: environment.Name, Description: environment.Description, Color: environment.Color, Position: i})
+	}
+	parameters := map[string]bool{}
+	for _, parameter := range body.Parameters {
+		if !projecttemplate.IsType(parameter.Type) {
+			return fmt.Sprintf("Type %q of parameter %s is not one of %v", parameter.Type, parameter.Name, projecttemplate.Types)
+		}
+		if parameter.Stage != "" && !stages[parameter.Stage] {
+			return fmt.Sprintf("Stage %s of parameter %s is not in the template", parameter.Stage, parameter.Name)
+		}
+		if parameter.Environment != "" && !environments[parameter.Environment] {
+			return fmt.Sprintf("Environment %s of parameter %s is not in the template", parameter.Environment, parameter.Name)
+		}
+		key := projecttemplate.Key(parameter.Stage, parameter.Environment, parameter.Name)
+		if parameters[key] {
+			return fmt.Sprintf("Parameter %s is duplicated", parameter.Name)
+		}
+		parameters[key] = true
+		if parameter.Type == "" {
+			parameter.Type = projecttemplate.TypeString
+		}
+		template.Parameters = append(template.Parameters, models.ProjectTemplateParameter{
+			Name:        parameter.Name,
+			Description: parameter.Description,
+			Type:        parameter.Type,
+			Stage:       parameter.Stage,
+			Environment: parameter.Environment,
+		})
+	}
+	for _, agent := range body.Agents {
+		if !stages[agent.Stage] || !environments[agent.Environment] {
+			return fmt.Sprintf("Stage and environment of agent %s must be in the template", agent.Name)
+		}
+		template.Agents = append(template.Agents, models.ProjectTemplateAgent{
+			Name:         agent.Name,
+			Stage:        agent.Stage,
+			Environment:  agent.Environment,
+			WorkflowName: agent.WorkflowName,
+			Description:  agent.Description,
+		})
+	}
+	return ""
+}
+
+// saveProjectTemplate writes the template and replaces its stages, environments, parameters and agents
+func saveProjectTemplate(template *models.ProjectTemplate) error {
+	stages, environments, parameters, agents := template.Stages, template.Environments, template.Parameters, template.Agents
+	template.Stages, template.Environments, template.Parameters, template.Agents = nil, nil, nil, nil
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(template).Error; err != nil {
+			return err
+		}
+		// only one default template per organization
+		if template.IsDefault {
+			if err := tx.Model(&models.ProjectTemplate{}).Where("organization_id = ? AND id != ?", template.OrganizationID, template.ID).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		for _, children := range []interface{}{&models.ProjectTemplateStage{}, &models.ProjectTemplateEnvironment{}, &models.ProjectTemplateParameter{}, &models.ProjectTemplateAgent{}} {
+			if err := tx.Unscoped().Where("template_id = ?", template.ID).Delete(children).Error; err != nil {
+				return err
+			}
+		}
+		for i := range stages {
+			stages[i].TemplateID = template.ID
+		}
+		for i := range environments {
+			environments[i].TemplateID = template.ID
+		}
+		for i := range parameters {
+			parameters[i].TemplateID = template.ID
+		}
+		for i := range agents {
+			agents[i].TemplateID = template.ID
+		}
+		for _, children := range []interface{}{&stages, &environments, &parameters, &agents} {
+			if err := tx.Create(children).Error; err != nil && !errors.Is(err, gorm.ErrEmptySlice) {
+				return err
+			}
+		}
+		return nil
+	})
+	template.Stages, template.Environments, template.Parameters, template.Agents = stages, environments, parameters, agents
+	return err
+}
+
+// ListProjectTemplates godoc
+// @Summary List project templates
+// @Description List the project templates of the organization with their stages, environments, required parameters and agents
+// @Tags Organization / Project Templates
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.ProjectTemplate
+// @Failure 500 string {string} json "{"error": "Failed to list templates"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/project-templates [get]
+func ListProjectTemplates(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var templates []models.ProjectTemplate
+	if err := preloadProjectTemplate(DB).Where("organization_id = ?", user.OrganizationID).Order("name").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list templates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// CreateProjectTemplate godoc
+// @Summary Create project template
+// @Description Create a project template. Parameters are required in their stage and environment, or in every one when they are empty.
+// @Tags Organization / Project Templates
+// @Accept json
+// @Produce json
+// @Param Template body controllers.projectTemplateBody true "Template"
+// @Success 201 {object} models.ProjectTemplate
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 500 string {string} json "{"error": "Failed to create template"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/project-templates [post]
+func CreateProjectTemplate(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var body projectTemplateBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	template := models.ProjectTemplate{OrganizationID: user.OrganizationID}
+	if msg := applyProjectTemplateBody(&template, body); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := saveProjectTemplate(&template); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		Action:     "project_template.create",
+		TargetType: "project_template",
+		TargetID:   template.ID,
+		TargetName: template.Name,
+		After:      template,
+		Status:     http.StatusCreated,
+	})
+	c.JSON(http.StatusCreated, gin.H{"template": template})
+}
+
+// UpdateProjectTemplate godoc
+// @Summary Update project template
+// @Description Update a project template, its stages, environments, parameters and agents are replaced by the ones in the body. Existing projects are not changed.
+// @Tags Organization / Project Templates
+// @Accept json
+// @Produce json
+// @Param template_id path int true "Template ID"
+// @Param Template body controllers.projectTemplateBody true "Template"
+// @Success 200 {object} models.ProjectTemplate
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 404 string {string} json "{"error": "Template not found"}"
+// @Failure 500 string {string} json "{"error": "Failed to update template"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/project-templates/{template_id} [put]
+func UpdateProjectTemplate(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var template models.ProjectTemplate
+	if err := preloadProjectTemplate(DB).Where("organization_id = ?", user.OrganizationID).First(&template, c.Param("template_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	var body projectTemplateBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	before := template
+	if msg := applyProjectTemplateBody(&template, body); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := saveProjectTemplate(&template); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		Action:     "project_template.update",
+		TargetType: "project_template",
+		TargetID:   template.ID,
+		TargetName: template.Name,
+		Before:     before,
+		After:      template,
+	})
+	c.JSON(http.StatusOK, gin.H{"template": template})
+}
+
+// DeleteProjectTemplate godoc
+// @Summary Delete project template
+// @Description Delete a project template, projects created from it keep their stages, environments and parameters
+// @Tags Organization / Project Templates
+// @Accept json
+// @Produce json
+// @Param template_id path int true "Template ID"
+// @Success 200 string {string} json "{"message": "Template deleted"}"
+// @Failure 404 string {string} json "{"error": "Template not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/project-templates/{template_id} [delete]
+func DeleteProjectTemplate(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var template models.ProjectTemplate
+	if err := DB.Where("organization_id = ?", user.OrganizationID).First(&template, c.Param("template_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		for _, children := range []interface{}{&models.ProjectTemplateStage{}, &models.ProjectTemplateEnvironment{}, &models.ProjectTemplateParameter{}, &models.ProjectTemplateAgent{}} {
+			if err := tx.Unscoped().Where("template_id = ?", template.ID).Delete(children).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&template).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		Action:     "project_template.delete",
+		TargetType: "project_template",
+		TargetID:   template.ID,
+		TargetName: template.Name,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted"})
+}
+
+// GetProjectTemplateCompliance godoc
+// @Summary Check projects against a template
+// @Description Report the projects missing the required parameters of a template in their latest version, or with values not of the required type.
+// @Description The projects created from the template are checked, every active project of the organization with scope=all.
+// @Tags Organization / Project Templates
+// @Accept json
+// @Produce json
+// @Param template_id path int true "Template ID"
+// @Param scope query string false "template (default) or all"
+// @Param project_id query int false "Only this project"
+// @Success 200 string {string} json "{"template": {}, "summary": {}, "projects": []}"
+// @Failure 404 string {string} json "{"error": "Template not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/project-templates/{template_id}/compliance [get]
+func GetProjectTemplateCompliance(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var template models.ProjectTemplate
+	if err := DB.Preload("Parameters").Where("organization_id = ?", user.OrganizationID).First(&template, c.Param("template_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	requirements := make([]projecttemplate.Requirement, 0, len(template.Parameters))
+	for _, parameter := range template.Parameters {
+		requirements = append(requirements, projecttemplate.Requirement{Name: parameter.Name, Type: parameter.Type, Stage: parameter.Stage, Environment: parameter.Environment})
+	}
+
+	var projects []models.Project
+	query := DB.Preload("LatestVersion").Where("organization_id = ? AND is_archived = ?", user.OrganizationID, false).Order("name")
+	if c.Query("scope") != "all" {
+		query = query.Where("template_id = ?", template.ID)
+	}
+	if projectID := c.Query("project_id"); projectID != "" {
+		query = query.Where("id = ?", projectID)
+	}
+	if err := query.Find(&projects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get projects"})
+		return
+	}
+
+	results := []projectTemplateCompliance{}
+	compliant := 0
+	for _, project := range projects {
+		stageIDs, environmentIDs := activeStageAndEnvironmentIDs(project.ID)
+		stages, environments := sortedNames(stageIDs), sortedNames(environmentIDs)
+		parameters, err := versionParameters(project.ID, project.LatestVersionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameters"})
+			return
+		}
+		values := map[string]string{}
+		for _, parameter := range parameters {
+			values[projecttemplate.Key(parameter.Stage.Name, parameter.Environment.Name, parameter.Name)] = parameter.Value
+		}
+		result := projectTemplateCompliance{
+			ProjectID:   project.ID,
+			ProjectName: project.Name,
+			Version:     project.LatestVersion.Number,
+			Issues:      projecttemplate.Check(requirements, stages, environments, values),
+		}
+		for _, issue := range result.Issues {
+			if issue.Problem == projecttemplate.ProblemMissing {
+				result.Missing++
+			} else {
+				result.Invalid++
+			}
+		}
+		if result.Compliant = len(result.Issues) == 0; result.Compliant {
+			compliant++
+		}
+		results = append(results, result)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"template": gin.H{"id": template.ID, "name": template.Name, "parameters": len(template.Parameters)},
+		"summary":  gin.H{"projects": len(results), "compliant": compliant, "non_compliant": len(results) - compliant},
+		"projects": results,
+	})
+}
+
+// sortedNames returns the keys of a map of IDs by name
+func sortedNames(ids map[string]uint) []string {
+	names := make([]string, 0, len(ids))
+	for name := range ids {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
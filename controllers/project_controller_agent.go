@@ -8,10 +8,10 @@ import (
 	"parameter-store-be/models"
 	"parameter-store-be/modules/github"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 type agentResponse struct {
@@ -233,6 +233,13 @@ func ArchiveAgent(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive agent"})
 		return
 	}
+	auditByUser(c, auditEntry{
+		ProjectID:  uint(projectID),
+		Action:     "agent.archive",
+		TargetType: "agent",
+		TargetID:   agent.ID,
+		TargetName: agent.Name,
+	})
 	projectLogByUser(uint(projectID), "Archive Agent", "Succeed: Agent archived", http.StatusOK, time.Since(time.Now()), 0)
 	c.JSON(http.StatusOK, gin.H{"message": "Agent archived"})
 }
@@ -273,6 +280,13 @@ func RestoreAgent(c *gin.Context) {
 	}
 	agent.IsArchived = false
 	DB.Save(&agent)
+	auditByUser(c, auditEntry{
+		ProjectID:  uint(projectID),
+		Action:     "agent.restore",
+		TargetType: "agent",
+		TargetID:   agent.ID,
+		TargetName: agent.Name,
+	})
 	projectLogByUser(uint(projectID), "Restore Agent", "Succeed: Agent restored", http.StatusOK, time.Since(time.Now()), 0)
 	c.JSON(http.StatusOK, gin.H{"message": "Agent restored"})
 }
@@ -363,6 +377,15 @@ func CreateNewAgent(c *gin.Context) {
 	ApiTokenForAgent := GenerateTokenForAgent(strconv.Itoa(int(newAgent.ID)), strconv.Itoa(int(project.OrganizationID)))
 	newAgent.APIToken = ApiTokenForAgent
 	DB.Save(&newAgent)
+	auditByUser(c, auditEntry{
+		ProjectID:  uint(projectID),
+		Action:     "agent.create",
+		TargetType: "agent",
+		TargetID:   newAgent.ID,
+		TargetName: newAgent.Name,
+		After:      map[string]interface{}{"stage_id": newAgent.StageID, "environment_id": newAgent.EnvironmentID, "workflow_name": newAgent.WorkflowName},
+		Status:     http.StatusCreated,
+	})
 	projectLogByUser(uint(projectID), "Create Agent", "Succeed: Agent created", http.StatusCreated, time.Since(time.Now()), 0)
 	c.JSON(http.StatusOK, gin.H{
 		"message":   "Agent created",
@@ -450,6 +473,15 @@ func UpdateAgent(c *gin.Context) {
 		return
 	}
 	userID := user.(models.User).ID
+	updatedAgentID, _ := strconv.Atoi(agentID)
+	auditByUser(c, auditEntry{
+		ProjectID:  uint(projectID),
+		Action:     "agent.update",
+		TargetType: "agent",
+		TargetID:   uint(updatedAgentID),
+		TargetName: agentUpdate.Name,
+		After:      map[string]interface{}{"stage_id": agentUpdate.StageID, "environment_id": agentUpdate.EnvironmentID, "workflow_name": agentUpdate.WorkflowName},
+	})
 	projectLogByUser(uint(projectID), "Update Agent", "Succeed: Agent updated", http.StatusOK, time.Since(time.Now()), userID)
 	c.JSON(http.StatusOK, gin.H{"message": "Agent updated"})
 }
@@ -460,12 +492,17 @@ type requestAuthAgentBody struct {
 
 // GetParameterByAuthAgent godoc
 // @Summary Get parameter by auth agent
-// @Description Get parameter by auth agent
+// @Description Get parameter by auth agent as a KEY=VALUE file.
+// @Description The ETag and X-Parameter-Store-Hash headers carry a hash of the content, send it back in If-None-Match to get 304 when nothing changed.
+// @Description Parameters past their expiry date are left out when the project blocks expired secrets.
+// @Description Dynamic parameters get credentials issued for this pull, If-None-Match is ignored when there are any.
 // @Tags Agents
 // @Accept json
 // @Produce json
 // @Param requestAuthAgentBody body controllers.requestAuthAgentBody true "Request Auth Agent Body"
+// @Param If-None-Match header string false "ETag of the last pulled content"
 // @Success 200 string {string} json "{"message": "Parameter retrieved"}"
+// @Success 304 string {string} json ""
 // @Security ApiKeyAuth
 // @Failure 400 string {string} json "{"error": "Bad request"}"
 // @Failure 500 string {string} json "{"error": "Failed to retrieve parameter"}"
@@ -488,27 +525,16 @@ func GetParameterByAuthAgent(c *gin.Context) {
 		})
 		return
 	}
-	agent.LastUsedAt = time.Now()
-	DB.Save(&agent)
+	DB.Model(&agent).UpdateColumn("last_used_at", time.Now())
 	startTime := time.Now()
-	var project models.Project
 	var foundWorkflowLogsID uint
 	if agent.Workflow.Logs != nil && len(agent.Workflow.Logs) > 0 {
 		foundWorkflowLogsID = agent.Workflow.Logs[0].ID
 	} else {
 		foundWorkflowLogsID = 1 // temp workflow logs id for agent pull without workflow logs is running
 	}
-	if err := DB.
-		Preload("LatestVersion").
-		Preload("LatestVersion.Parameters",
-			"stage_id = ? AND environment_id = ? AND is_archived = ? ", agent.StageID, agent.EnvironmentID, false,
-			func(db *gorm.DB) *gorm.DB { // order by parameter name
-				db = db.Order("parameters.name asc")
-				return db
-			},
-		).
-		First(&project, agent.ProjectID).Error; err != nil {
-
+	project, err := loadAgentParameters(agent)
+	if err != nil {
 		agentLog(agent, project, "Get Parameter", "Failed to get project by agent", http.StatusNotFound, time.Since(startTime), foundWorkflowLogsID, nil)
 		c.JSON(http.StatusNotFound, gin.H{
 			"status":  http.StatusNotFound,
@@ -517,54 +543,80 @@ func GetParameterByAuthAgent(c *gin.Context) {
 
 		return
 	}
+	// the blocked names go in the message of the one log of the pull
+	blockedNote := ""
+	if blocked := blockExpiredSecrets(&project); len(blocked) > 0 {
+		blockedNote = " (blocked expired parameters: " + strings.Join(blocked, ", ") + ")"
+	}
 	if len(project.LatestVersion.Parameters) == 0 {
-		agentLog(agent, project, "Get Parameter", "Failed to get parameter by agent: Not found any parameters.", http.StatusNotFound, time.Since(startTime), foundWorkflowLogsID, nil)
+		agentLog(agent, project, "Get Parameter", "Failed to get parameter by agent: Not found any parameters."+blockedNote, http.StatusNotFound, time.Since(startTime), foundWorkflowLogsID, nil)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": "Failed to get parameter by agent: Not found any parameters.",
 		})
 		return
 	}
-	for _, parameter := range project.LatestVersion.Parameters {
-		parameter.IsApplied = true
-		DB.Save(&parameter)
+	hash := ParameterSetHash(project.LatestVersion.Parameters)
+	etag := fmt.Sprintf("\"%s\"", hash)
+	c.Header("ETag", etag)
+	c.Header(AgentHashHeader, hash)
+	if revision, err := currentParameterSetRevision(agent, hash); err == nil {
+		c.Header(AgentRevisionHeader, strconv.FormatInt(revision.Revision, 10))
+	}
+	// dynamic credentials are issued on every pull, the agent always gets fresh ones
+	if etagMatches(c.GetHeader("If-None-Match"), etag) && !hasDynamicParameters(project.LatestVersion.Parameters) {
+		agentLog(agent, project, "Get Parameter", "Succeed: Parameter not modified"+blockedNote, http.StatusNotModified, time.Since(startTime), foundWorkflowLogsID, nil)
+		c.Status(http.StatusNotModified)
+		return
+	}
+	served, err := issueDynamicCredentials(agent, project.LatestVersion.Parameters)
+	if err != nil {
+		agentLog(agent, project, "Get Parameter", "Failed to issue dynamic credentials: "+err.Error()+blockedNote, http.StatusBadGateway, time.Since(startTime), foundWorkflowLogsID, nil)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"status":  http.StatusBadGateway,
+			"message": "Failed to issue dynamic credentials: " + err.Error(),
+		})
+		return
 	}
+	markParametersApplied(project.LatestVersion.Parameters)
 	latency := time.Since(startTime)
 
 	// debug
 	// fmt.Println("Workflow Logs calling agent", agent.Workflow.Logs[0])
-	agentLog(agent, project, "Get Parameter", "Succeed: Parameter retrieved", http.StatusOK, latency, foundWorkflowLogsID, project.LatestVersion.Parameters)
-
-	// Create a new file
-	filepath := fmt.Sprintf("parameters-%s-Ver.%s.txt", project.Name, project.LatestVersion.Number)
-	file, err := os.Create(filepath) // format KEY=VALUE is paramter.Name=parameter.Value
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file"})
-		return
-	}
+	agentLog(agent, project, "Get Parameter", "Succeed: Parameter retrieved"+blockedNote, http.StatusOK, latency, foundWorkflowLogsID, project.LatestVersion.Parameters)
+	var pulledNames []string
 	for _, parameter := range project.LatestVersion.Parameters {
-		_, err := file.WriteString(fmt.Sprintf("%s=%s\n", parameter.Name, parameter.Value))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write file"})
-			return
-		}
-	}
+		pulledNames = append(pulledNames, parameter.Name)
+	}
+	auditByAgent(c, agent, project, auditEntry{
+		Action:     "parameter.pull",
+		TargetType: "version",
+		TargetID:   project.LatestVersion.ID,
+		TargetName: project.LatestVersion.Number,
+		After:      map[string]interface{}{"stage_id": agent.StageID, "environment_id": agent.EnvironmentID, "parameters": pulledNames, "hash": hash},
+	})
 
+	// format KEY=VALUE is paramter.Name=parameter.Value
+	var content strings.Builder
+	for _, parameter := range served {
+		content.WriteString(fmt.Sprintf("%s=%s\n", parameter.Name, parameter.Value))
+	}
+	filename := fmt.Sprintf("parameters-%s-Ver.%s.txt", project.Name, project.LatestVersion.Number)
 	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Disposition", "attachment; filename="+filepath)
-	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
 	c.Header("Content-Transfer-Encoding", "binary")
-	c.File(filepath)
-	err = os.Remove(filepath)
-	if err != nil {
-		log.Println("Failed to remove file")
-	}
+	c.Data(http.StatusOK, "application/octet-stream", []byte(content.String()))
+}
 
-	// c.JSON(http.StatusOK, gin.H{
-	// 	"status":     http.StatusOK,
-	// 	"message":    "Parameter retrieved",
-	// 	"parameters": project.LatestVersion.Parameters,
-	// })
+// etagMatches tells if an If-None-Match header lists the ETag, weak validators match too
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
 }
 
 // RerunWorkFlowByAgent godoc
@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"parameter-store-be/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	agentWatchDefaultTimeout = 30 * time.Second
+	agentWatchMaxTimeout     = 120 * time.Second
+	// agentWatchPollInterval picks up changes made through other instances of the server
+	agentWatchPollInterval = 5 * time.Second
+	// AgentRevisionHeader carries the revision of the parameter set in watch and pull responses
+	AgentRevisionHeader = "X-Parameter-Store-Revision"
+	// AgentHashHeader carries the content hash of the parameter set, CI can skip steps when it did not change
+	AgentHashHeader = "X-Parameter-Store-Hash"
+)
+
+// agentParameter is a parameter as delivered to an agent
+type agentParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// authenticateAgentByHeader finds the active agent of the token in the Authorization header
+func authenticateAgentByHeader(c *gin.Context) (models.Agent, bool) {
+	var agent models.Agent
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" || DB.Where("api_token = ? AND is_archived = ?", token, false).First(&agent).Error != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": "Failed to authorized agent by API token, please check $PARAMETER_STORE_TOKEN",
+		})
+		return agent, false
+	}
+	return agent, true
+}
+
+// loadAgentParameters loads the project with the parameters of the latest version the agent receives,
+// with the values of the shared sets linked to the project
+func loadAgentParameters(agent models.Agent) (models.Project, error) {
+	var project models.Project
+	err := DB.
+		Preload("LatestVersion").
+		Preload("LatestVersion.Parameters",
+			"stage_id = ? AND environment_id = ? AND is_archived = ? AND is_draft = ?", agent.StageID, agent.EnvironmentID, false, false,
+			func(db *gorm.DB) *gorm.DB { // order by parameter name
+				return db.Order("parameters.name asc")
+			},
+		).
+		First(&project, agent.ProjectID).Error
+	if err == nil {
+		project.LatestVersion.Parameters = addSharedParameters(agent, project.LatestVersion.Parameters)
+	}
+	return project, err
+}
+
+// ParameterSetHash is a deterministic hash of the names and values of a parameter set, whatever their order
+func ParameterSetHash(parameters []models.Parameter) string {
+	pairs := make([][2]string, 0, len(parameters))
+	for _, parameter := range parameters {
+		pairs = append(pairs, [2]string{parameter.Name, parameter.Value})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	// JSON keeps names and values containing "=" or newlines unambiguous
+	encoded, _ := json.Marshal(pairs)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// markParametersApplied flags the delivered parameters as applied in one statement, shared values are skipped
+func markParametersApplied(parameters []models.Parameter) {
+	var ids []uint
+	for _, parameter := range parameters {
+		if parameter.ID != 0 {
+			ids = append(ids, parameter.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	DB.Model(&models.Parameter{}).Where("id IN ?", ids).Update("is_applied", true)
+}
+
+// currentParameterSetRevision returns the revision of the agent's parameter set, bumping it when the hash changed
+func currentParameterSetRevision(agent models.Agent, hash string) (models.ParameterSetRevision, error) {
+	revision := models.ParameterSetRevision{
+		ProjectID:     agent.ProjectID,
+		StageID:       agent.StageID,
+		EnvironmentID: agent.EnvironmentID,
+		Revision:      1,
+		Hash:          hash,
+	}
+	if err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&revision).Error; err != nil {
+		return revision, err
+	}
+	scope := DB.Model(&models.ParameterSetRevision{}).
+		Where("project_id = ? AND stage_id = ? AND environment_id = ?", agent.ProjectID, agent.StageID, agent.EnvironmentID)
+	// the hash condition makes concurrent watchers bump a change only once
+	if err := scope.Session(&gorm.Session{}).Where("hash <> ?", hash).
+		Updates(map[string]interface{}{"revision": gorm.Expr("revision + 1"), "hash": hash, "updated_at": time.Now()}).Error; err != nil {
+		return revision, err
+	}
+	err := scope.Session(&gorm.Session{}).First(&revision).Error
+	return revision, err
+}
+
+// WatchAgentParameters godoc
+// @Summary Watch parameters by agent
+// @Description Long poll for changes of the parameters of the agent's stage and environment, authenticated with the agent token in the Authorization header.
+// @Description Without since, or when since is older than the current revision, the parameters are returned at once.
+// @Description Otherwise the request blocks until the parameters change and returns them with the new revision, or returns 304 at the timeout.
+// @Description When parameters are filled by dynamic credentials, the request also returns with the same revision and new credentials
+// @Description once a fifth of the lifetime of the credentials served last is left, or at once when the agent holds none.
+// @Tags Agents
+// @Produce json
+// @Param since query int false "Last revision the agent has"
+// @Param timeout query int false "Seconds to wait, default 30, max 120"
+// @Success 200 string {string} json "{"revision": 3, "hash": "...", "project": "shop", "stage": "build", "environment": "production", "parameters": [{"name": "KEY", "value": "VALUE"}]}"
+// @Success 304 string {string} json ""
+// @Failure 401 string {string} json "{"message": "Failed to authorized agent by API token"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/agents/watch [get]
+func WatchAgentParameters(c *gin.Context) {
+	agent, ok := authenticateAgentByHeader(c)
+	if !ok {
+		return
+	}
+	var since int64 = -1
+	if value := c.Query("since"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a revision number"})
+			return
+		}
+		since = parsed
+	}
+	timeout := agentWatchDefaultTimeout
+	if value := c.Query("timeout"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "timeout must be a number of seconds"})
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+		if timeout > agentWatchMaxTimeout {
+			timeout = agentWatchMaxTimeout
+		}
+	}
+	DB.Model(&agent).UpdateColumn("last_used_at", time.Now())
+
+	wake := projectEvents.subscribe(agent.ProjectID)
+	defer projectEvents.unsubscribe(agent.ProjectID, wake)
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	poll := time.NewTicker(agentWatchPollInterval)
+	defer poll.Stop()
+	for {
+		startTime := time.Now()
+		project, err := loadAgentParameters(agent)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "message": "Failed to get project by agent"})
+			return
+		}
+		blockExpiredSecrets(&project)
+		parameters := project.LatestVersion.Parameters
+		hash := ParameterSetHash(parameters)
+		revision, err := currentParameterSetRevision(agent, hash)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameter revision"})
+			return
+		}
+		c.Header(AgentRevisionHeader, strconv.FormatInt(revision.Revision, 10))
+		c.Header(AgentHashHeader, hash)
+		// a since ahead of the server means the client is out of sync, it gets the current set
+		if revision.Revision != since {
+			respondAgentWatch(c, agent, project, revision, parameters, time.Since(startTime))
+			return
+		}
+		// the revision follows the templates, dynamic credentials expire without changing it
+		var renew <-chan time.Time
+		stopRenew := func() {}
+		if hasDynamicParameters(parameters) {
+			renewAt := dynamicRenewAt(agent)
+			if !time.Now().Before(renewAt) {
+				respondAgentWatch(c, agent, project, revision, parameters, time.Since(startTime))
+				return
+			}
+			timer := time.NewTimer(time.Until(renewAt))
+			renew, stopRenew = timer.C, func() { timer.Stop() }
+		}
+		select {
+		case <-c.Request.Context().Done():
+			stopRenew()
+			return
+		case <-deadline.C:
+			stopRenew()
+			c.Status(http.StatusNotModified)
+			return
+		case <-wake:
+		case <-poll.C:
+		case <-renew:
+		}
+		stopRenew()
+	}
+}
+
+func respondAgentWatch(c *gin.Context, agent models.Agent, project models.Project, revision models.ParameterSetRevision, parameters []models.Parameter, latency time.Duration) {
+	served, err := issueDynamicCredentials(agent, parameters)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"status": http.StatusBadGateway, "message": "Failed to issue dynamic credentials: " + err.Error()})
+		return
+	}
+	markParametersApplied(parameters)
+	workflowLogID := uint(1) // same placeholder as agent pulls without a running workflow
+	var running models.WorkflowLog
+	if DB.Where("workflow_id = ? AND state != ?", agent.WorkflowID, "completed").First(&running).Error == nil {
+		workflowLogID = running.ID
+	}
+	agentLog(agent, project, "Watch Parameter", "Succeed: Parameter revision "+strconv.FormatInt(revision.Revision, 10)+" retrieved", http.StatusOK, latency, workflowLogID, parameters)
+	var names []string
+	delivered := make([]agentParameter, 0, len(parameters))
+	for _, parameter := range served {
+		names = append(names, parameter.Name)
+		delivered = append(delivered, agentParameter{Name: parameter.Name, Value: parameter.Value})
+	}
+	auditByAgent(c, agent, project, auditEntry{
+		Action:     "parameter.pull",
+		TargetType: "version",
+		TargetID:   project.LatestVersion.ID,
+		TargetName: project.LatestVersion.Number,
+		After:      map[string]interface{}{"stage_id": agent.StageID, "environment_id": agent.EnvironmentID, "parameters": names, "revision": revision.Revision, "via": "watch"},
+	})
+	stage, environment := stageAndEnvironmentNames(agent.StageID, agent.EnvironmentID)
+	c.JSON(http.StatusOK, gin.H{
+		"revision":    revision.Revision,
+		"hash":        revision.Hash,
+		"version":     project.LatestVersion.Number,
+		"project":     project.Name,
+		"stage":       stage,
+		"environment": environment,
+		"parameters":  delivered,
+	})
+}
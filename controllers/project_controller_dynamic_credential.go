@@ -0,0 +1,524 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/dynamiccred"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	dynamicCredentialDefaultTTL = time.Hour
+	dynamicCredentialMaxTTL     = 24 * time.Hour
+	dynamicReaperInterval       = 30 * time.Second
+	dynamicReaperBatchSize      = 20
+	// dynamicRevokeLease lets another instance take over a revoke that never finished
+	dynamicRevokeLease = 5 * time.Minute
+)
+
+type dynamicSourceRequestBody struct {
+	Name           string `json:"name" binding:"required"`
+	ConnectionURL  string `json:"connection_url"` // kept when empty on update
+	GrantTemplate  string `json:"grant_template" binding:"required"`
+	RevokeTemplate string `json:"revoke_template"`
+	RolePrefix     string `json:"role_prefix"`
+	TTL            string `json:"ttl"` // duration like 15m or 1h, 1h by default
+}
+
+type dynamicParameterRequestBody struct {
+	SourceID uint `json:"source_id"` // 0 makes the parameter static again
+}
+
+// applyDynamicSourceBody validates the body into the source, the connection is checked when it changes
+func applyDynamicSourceBody(source *models.DynamicCredentialSource, body dynamicSourceRequestBody) string {
+	ttl := dynamicCredentialDefaultTTL
+	if body.TTL != "" {
+		parsed, err := time.ParseDuration(body.TTL)
+		if err != nil || parsed < time.Minute || parsed > dynamicCredentialMaxTTL {
+			return fmt.Sprintf("ttl must be a duration between 1m and %s", dynamicCredentialMaxTTL)
+		}
+		ttl = parsed
+	}
+	if body.ConnectionURL == "" && source.ConnectionURL == "" {
+		return "connection_url is required"
+	}
+	if body.ConnectionURL != "" {
+		// the error of the driver names hosts and ports, it stays in the server log
+		if err := dynamiccred.Ping(body.ConnectionURL); err != nil {
+			log.Println("Failed to connect with the connection_url of a dynamic credential source:", err)
+			return "Failed to connect with connection_url"
+		}
+		source.ConnectionURL = body.ConnectionURL
+	}
+	source.Name = body.Name
+	source.GrantTemplate = body.GrantTemplate
+	source.RevokeTemplate = body.RevokeTemplate
+	source.RolePrefix = body.RolePrefix
+	source.TTLSeconds = int64(ttl.Seconds())
+	return ""
+}
+
+// hasDynamicParameters tells if any parameter is filled by a dynamic credential source
+func hasDynamicParameters(parameters []models.Parameter) bool {
+	for _, parameter := range parameters {
+		if parameter.DynamicSourceID != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// issueDynamicCredentials creates a role for each source used by the parameters and fills the values,
+// the parameters sharing a source get the same role. The parameters passed in keep their templates.
+func issueDynamicCredentials(agent models.Agent, parameters []models.Parameter) ([]models.Parameter, error) {
+	issued := map[uint]dynamiccred.Credentials{}
+	filled := make([]models.Parameter, len(parameters))
+	for i, parameter := range parameters {
+		filled[i] = parameter
+		if parameter.DynamicSourceID == 0 {
+			continue
+		}
+		credentials, ok := issued[parameter.DynamicSourceID]
+		if !ok {
+			var source models.DynamicCredentialSource
+			if err := DB.Where("project_id = ?", agent.ProjectID).First(&source, parameter.DynamicSourceID).Error; err != nil {
+				return nil, fmt.Errorf("dynamic credential source of %s not found", parameter.Name)
+			}
+			var err error
+			if credentials, err = issueDynamicCredential(agent, source); err != nil {
+				return nil, fmt.Errorf("failed to issue credentials of %s: %w", source.Name, err)
+			}
+			issued[parameter.DynamicSourceID] = credentials
+		}
+		filled[i].Value = dynamiccred.Render(parameter.Value, credentials)
+	}
+	return filled, nil
+}
+
+// dynamicRenewAt is when a watching agent must get new credentials: a fifth of the lifetime before the earliest
+// expiry among the last lease of each source, so the agent switches before the reaper drops the role.
+// The zero time means the agent holds no active lease.
+func dynamicRenewAt(agent models.Agent) time.Time {
+	var leases []models.DynamicCredentialLease
+	DB.Where("agent_id = ? AND status = ?", agent.ID, models.DynamicLeaseActive).Order("id desc").Find(&leases)
+	seen := map[uint]bool{}
+	var renewAt time.Time
+	for _, lease := range leases {
+		if seen[lease.SourceID] {
+			continue
+		}
+		seen[lease.SourceID] = true
+		at := lease.ExpiresAt.Add(-lease.ExpiresAt.Sub(lease.CreatedAt) / 5)
+		if renewAt.IsZero() || at.Before(renewAt) {
+			renewAt = at
+		}
+	}
+	return renewAt
+}
+
+// issueDynamicCredential records the lease before the role exists, so the reaper finds a role left by a crash
+func issueDynamicCredential(agent models.Agent, source models.DynamicCredentialSource) (dynamiccred.Credentials, error) {
+	credentials, err := dynamiccred.NewCredentials(source.RolePrefix, time.Duration(source.TTLSeconds)*time.Second, time.Now())
+	if err != nil {
+		return credentials, err
+	}
+	lease := models.DynamicCredentialLease{
+		SourceID:  source.ID,
+		ProjectID: source.ProjectID,
+		AgentID:   agent.ID,
+		Username:  credentials.Username,
+		Status:    models.DynamicLeaseActive,
+		ExpiresAt: credentials.ExpiresAt,
+	}
+	if err := DB.Create(&lease).Error; err != nil {
+		return credentials, err
+	}
+	if err := dynamiccred.Create(source.ConnectionURL, credentials, source.GrantTemplate); err != nil {
+		DB.Model(&lease).Updates(map[string]interface{}{"status": models.DynamicLeaseFailed, "message": err.Error()})
+		return credentials, err
+	}
+	return credentials, nil
+}
+
+// revokeDynamicLease drops the role of the lease and records the outcome
+func revokeDynamicLease(lease models.DynamicCredentialLease) error {
+	var source models.DynamicCredentialSource
+	err := DB.Unscoped().First(&source, lease.SourceID).Error
+	if err == nil {
+		err = dynamiccred.Revoke(source.ConnectionURL, lease.Username, source.RevokeTemplate)
+	}
+	if err != nil {
+		// back to active, the next round tries again
+		DB.Model(&lease).Updates(map[string]interface{}{"status": models.DynamicLeaseActive, "message": "Failed to revoke: " + err.Error()})
+		return err
+	}
+	return DB.Model(&lease).Updates(map[string]interface{}{"status": models.DynamicLeaseRevoked, "revoked_at": time.Now(), "message": ""}).Error
+}
+
+// reapDynamicCredentials claims the expired leases and revokes their roles, it returns the batch size
+func reapDynamicCredentials() int {
+	var leases []models.DynamicCredentialLease
+	now := time.Now()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND expires_at <= ?) OR (status = ? AND updated_at <= ?)",
+				models.DynamicLeaseActive, now, models.DynamicLeaseRevoking, now.Add(-dynamicRevokeLease)).
+			Order("expires_at asc").Limit(dynamicReaperBatchSize).
+			Find(&leases).Error; err != nil {
+			return err
+		}
+		for _, lease := range leases {
+			if err := tx.Model(&lease).Update("status", models.DynamicLeaseRevoking).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Failed to claim dynamic credential leases:", err)
+		return 0
+	}
+	revoked := 0
+	for _, lease := range leases {
+		if err := revokeDynamicLease(lease); err != nil {
+			log.Println("Failed to revoke dynamic credential", lease.Username, ":", err)
+			continue
+		}
+		revoked++
+	}
+	// failures wait for the next tick instead of being retried at once
+	if revoked < len(leases) {
+		return 0
+	}
+	return len(leases)
+}
+
+// RunDynamicCredentialReaper drops the roles of the dynamic credentials once they expire
+func RunDynamicCredentialReaper() {
+	ticker := time.NewTicker(dynamicReaperInterval)
+	defer ticker.Stop()
+	for {
+		for reapDynamicCredentials() == dynamicReaperBatchSize {
+		}
+		<-ticker.C
+	}
+}
+
+// GetDynamicCredentialSources godoc
+// @Summary List dynamic credential sources
+// @Description List the PostgreSQL servers issuing short-lived roles to the agents of the project
+// @Tags Project Detail / Dynamic Credentials
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 {array} models.DynamicCredentialSource
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/dynamic-credentials [get]
+func GetDynamicCredentialSources(c *gin.Context) {
+	project, _, ok := projectOfUser(c, true)
+	if !ok {
+		return
+	}
+	var sources []models.DynamicCredentialSource
+	if err := DB.Where("project_id = ?", project.ID).Order("id asc").Find(&sources).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dynamic credential sources"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sources": sources})
+}
+
+// CreateDynamicCredentialSource godoc
+// @Summary Create a dynamic credential source
+// @Description Add a PostgreSQL server issuing a role on each agent pull. The connection_url must log in as a role allowed to create roles.
+// @Description grant_template holds the statements granting the new role, separated by semicolons, {{role}} is the role name.
+// @Tags Project Detail / Dynamic Credentials
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param Source body controllers.dynamicSourceRequestBody true "Source"
+// @Success 201 {object} models.DynamicCredentialSource
+// @Failure 400 string {string} json "{"error": "connection_url is required"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/dynamic-credentials [post]
+func CreateDynamicCredentialSource(c *gin.Context) {
+	startTime := time.Now()
+	project, user, ok := projectOfUser(c, true)
+	if !ok {
+		return
+	}
+	var body dynamicSourceRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	source := models.DynamicCredentialSource{ProjectID: project.ID}
+	if msg := applyDynamicSourceBody(&source, body); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := DB.Create(&source).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dynamic credential source"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "dynamic_source.create",
+		TargetType: "dynamic_source",
+		TargetID:   source.ID,
+		TargetName: source.Name,
+		After:      map[string]interface{}{"grant_template": source.GrantTemplate, "ttl_seconds": source.TTLSeconds},
+		Status:     http.StatusCreated,
+	})
+	projectLogByUser(project.ID, "Create Dynamic Credential Source", "Succeed: Created dynamic credential source "+source.Name, http.StatusCreated, time.Since(startTime), user.ID)
+	c.JSON(http.StatusCreated, gin.H{"source": source})
+}
+
+// UpdateDynamicCredentialSource godoc
+// @Summary Update a dynamic credential source
+// @Description Update a dynamic credential source, the connection_url is kept when empty. Issued roles keep their grants.
+// @Tags Project Detail / Dynamic Credentials
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param source_id path int true "Source ID"
+// @Param Source body controllers.dynamicSourceRequestBody true "Source"
+// @Success 200 {object} models.DynamicCredentialSource
+// @Failure 404 string {string} json "{"error": "Dynamic credential source not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/dynamic-credentials/{source_id} [put]
+func UpdateDynamicCredentialSource(c *gin.Context) {
+	startTime := time.Now()
+	project, user, ok := projectOfUser(c, true)
+	if !ok {
+		return
+	}
+	var body dynamicSourceRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var source models.DynamicCredentialSource
+	if err := DB.Where("project_id = ?", project.ID).First(&source, c.Param("source_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dynamic credential source not found"})
+		return
+	}
+	before := map[string]interface{}{"name": source.Name, "grant_template": source.GrantTemplate, "ttl_seconds": source.TTLSeconds}
+	if msg := applyDynamicSourceBody(&source, body); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := DB.Save(&source).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update dynamic credential source"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  source.ProjectID,
+		Action:     "dynamic_source.update",
+		TargetType: "dynamic_source",
+		TargetID:   source.ID,
+		TargetName: source.Name,
+		Before:     before,
+		After:      map[string]interface{}{"name": source.Name, "grant_template": source.GrantTemplate, "ttl_seconds": source.TTLSeconds, "connection_changed": body.ConnectionURL != ""},
+	})
+	projectLogByUser(source.ProjectID, "Update Dynamic Credential Source", "Succeed: Updated dynamic credential source "+source.Name, http.StatusOK, time.Since(startTime), user.ID)
+	c.JSON(http.StatusOK, gin.H{"source": source})
+}
+
+// DeleteDynamicCredentialSource godoc
+// @Summary Delete a dynamic credential source
+// @Description Delete a source no parameter of the latest version uses, the roles already issued are still dropped when they expire
+// @Tags Project Detail / Dynamic Credentials
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param source_id path int true "Source ID"
+// @Success 200 string {string} json "{"message": "Dynamic credential source deleted"}"
+// @Failure 409 string {string} json "{"error": "Dynamic credential source is used by parameters"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/dynamic-credentials/{source_id} [delete]
+func DeleteDynamicCredentialSource(c *gin.Context) {
+	startTime := time.Now()
+	project, user, ok := projectOfUser(c, true)
+	if !ok {
+		return
+	}
+	var source models.DynamicCredentialSource
+	if err := DB.Where("project_id = ?", project.ID).First(&source, c.Param("source_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dynamic credential source not found"})
+		return
+	}
+	var names []string
+	DB.Model(&models.Parameter{}).
+		Joins("JOIN version_parameters ON version_parameters.parameter_id = parameters.id AND version_parameters.version_id = ?", project.LatestVersionID).
+		Where("parameters.dynamic_source_id = ? AND parameters.is_archived = ?", source.ID, false).
+		Pluck("parameters.name", &names)
+	if len(names) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Dynamic credential source is used by parameters", "parameters": names})
+		return
+	}
+	if err := DB.Delete(&source).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete dynamic credential source"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  source.ProjectID,
+		Action:     "dynamic_source.delete",
+		TargetType: "dynamic_source",
+		TargetID:   source.ID,
+		TargetName: source.Name,
+		Before:     map[string]interface{}{"name": source.Name, "grant_template": source.GrantTemplate},
+	})
+	projectLogByUser(source.ProjectID, "Delete Dynamic Credential Source", "Succeed: Deleted dynamic credential source "+source.Name, http.StatusOK, time.Since(startTime), user.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Dynamic credential source deleted"})
+}
+
+// GetDynamicCredentialLeases godoc
+// @Summary List dynamic credential leases
+// @Description List the roles issued to the agents of the project, the latest first
+// @Tags Project Detail / Dynamic Credentials
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param status query string false "active, revoking, revoked or failed"
+// @Success 200 {array} models.DynamicCredentialLease
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/dynamic-credentials/leases [get]
+func GetDynamicCredentialLeases(c *gin.Context) {
+	project, _, ok := projectOfUser(c, true)
+	if !ok {
+		return
+	}
+	query := DB.Where("project_id = ?", project.ID).Order("id desc").Limit(500)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var leases []models.DynamicCredentialLease
+	if err := query.Find(&leases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dynamic credential leases"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"leases": leases})
+}
+
+// RevokeDynamicCredentialLease godoc
+// @Summary Revoke a dynamic credential now
+// @Description Drop the role of an active lease before it expires
+// @Tags Project Detail / Dynamic Credentials
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param lease_id path int true "Lease ID"
+// @Success 200 string {string} json "{"message": "Dynamic credential revoked"}"
+// @Failure 409 string {string} json "{"error": "Dynamic credential is not active"}"
+// @Failure 502 string {string} json "{"error": "Failed to revoke dynamic credential"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/dynamic-credentials/leases/{lease_id} [delete]
+func RevokeDynamicCredentialLease(c *gin.Context) {
+	startTime := time.Now()
+	project, user, ok := projectOfUser(c, true)
+	if !ok {
+		return
+	}
+	var lease models.DynamicCredentialLease
+	if err := DB.Where("project_id = ?", project.ID).First(&lease, c.Param("lease_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dynamic credential lease not found"})
+		return
+	}
+	// the status condition keeps the reaper and this request from revoking the same role
+	result := DB.Model(&lease).Where("status = ?", models.DynamicLeaseActive).Update("status", models.DynamicLeaseRevoking)
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Dynamic credential is not active"})
+		return
+	}
+	if err := revokeDynamicLease(lease); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to revoke dynamic credential: " + err.Error()})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  lease.ProjectID,
+		Action:     "dynamic_lease.revoke",
+		TargetType: "dynamic_lease",
+		TargetID:   lease.ID,
+		TargetName: lease.Username,
+	})
+	projectLogByUser(lease.ProjectID, "Revoke Dynamic Credential", "Succeed: Revoked role "+lease.Username, http.StatusOK, time.Since(startTime), user.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Dynamic credential revoked"})
+}
+
+// UpdateParameterDynamicSource godoc
+// @Summary Make a parameter dynamic
+// @Description Fill the value of the parameter with credentials issued by the source on each agent pull.
+// @Description The value is a template using {{username}}, {{password}} and {{expires_at}}, like postgres://{{username}}:{{password}}@db:5432/app.
+// @Tags Project Detail / Dynamic Credentials
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param parameter_id path int true "Parameter ID"
+// @Param Source body controllers.dynamicParameterRequestBody true "Source, 0 makes the parameter static"
+// @Success 200 string {string} json "{"parameter": {}}"
+// @Failure 400 string {string} json "{"error": "The value must use {{username}} or {{password}}"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameters/{parameter_id}/dynamic-source [put]
+func UpdateParameterDynamicSource(c *gin.Context) {
+	startTime := time.Now()
+	project, user, ok := projectOfUser(c, true)
+	if !ok {
+		return
+	}
+	var body dynamicParameterRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var parameter models.Parameter
+	if err := DB.Joins("JOIN version_parameters ON version_parameters.parameter_id = parameters.id AND version_parameters.version_id = ?", project.LatestVersionID).
+		Where("parameters.project_id = ? AND parameters.is_archived = ?", project.ID, false).
+		First(&parameter, c.Param("parameter_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Parameter not found in the latest version"})
+		return
+	}
+	sourceName := ""
+	if body.SourceID != 0 {
+		var source models.DynamicCredentialSource
+		if err := DB.Where("project_id = ?", project.ID).First(&source, body.SourceID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Dynamic credential source not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dynamic credential source"})
+			}
+			return
+		}
+		if !dynamiccred.HasPlaceholder(parameter.Value) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The value must use {{username}} or {{password}}"})
+			return
+		}
+		sourceName = source.Name
+	}
+	before := parameter.DynamicSourceID
+	if err := DB.Model(&parameter).Update("dynamic_source_id", body.SourceID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update parameter"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "parameter.dynamic_source",
+		TargetType: "parameter",
+		TargetID:   parameter.ID,
+		TargetName: parameter.Name,
+		Before:     map[string]interface{}{"dynamic_source_id": before},
+		After:      map[string]interface{}{"dynamic_source_id": body.SourceID, "source": sourceName},
+	})
+	message := "Succeed: Parameter " + parameter.Name + " is static"
+	if body.SourceID != 0 {
+		message = "Succeed: Parameter " + parameter.Name + " is issued by " + sourceName
+	}
+	projectLogByUser(project.ID, "Update Dynamic Parameter", message, http.StatusOK, time.Since(startTime), user.ID)
+	c.JSON(http.StatusOK, gin.H{"parameter": parameter})
+}
@@ -0,0 +1,516 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/github"
+	"parameter-store-be/modules/gitops"
+	"parameter-store-be/modules/notifier"
+	"parameter-store-be/modules/webhook"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// gitopsPushPath is where GitHub sends the push events of the project repos
+const gitopsPushPath = "/api/v1/gitops/github/push"
+
+type gitopsConfigRequestBody struct {
+	Enabled             bool   `json:"enabled"`
+	Path                string `json:"path"`
+	Branch              string `json:"branch"`
+	AutoApply           bool   `json:"auto_apply"`
+	RotateWebhookSecret bool   `json:"rotate_webhook_secret"`
+}
+
+type gitopsApplyRequestBody struct {
+	SHA   string `json:"sha"`   // blob SHA of the previewed manifest, the apply fails if the manifest changed since
+	Force bool   `json:"force"` // let the manifest win over values edited in the UI
+}
+
+// gitopsPlan is a preview of the manifest against the latest version
+type gitopsPlan struct {
+	SHA      string         `json:"sha"`
+	Path     string         `json:"path"`
+	Plan     gitops.Plan    `json:"plan"`
+	declared []gitops.Entry // entries of the manifest
+}
+
+// gitopsConfigOf returns the config of the project, a disabled default when there is none
+func gitopsConfigOf(projectID uint) models.GitOpsConfig {
+	config := models.GitOpsConfig{ProjectID: projectID, Path: gitops.DefaultPath}
+	DB.Where("project_id = ?", projectID).First(&config)
+	return config
+}
+
+func gitopsBaseline(config models.GitOpsConfig) map[string]string {
+	baseline := map[string]string{}
+	if config.Baseline != "" {
+		json.Unmarshal([]byte(config.Baseline), &baseline)
+	}
+	return baseline
+}
+
+// latestVersionEntries returns the active parameters of the latest version and the keys of those that are not plaintext
+func latestVersionEntries(project models.Project) ([]gitops.Entry, map[string]bool, error) {
+	parameters, err := versionParameters(project.ID, project.LatestVersionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var entries []gitops.Entry
+	secrets := map[string]bool{}
+	for _, parameter := range parameters {
+		entry := gitops.Entry{Stage: parameter.Stage.Name, Environment: parameter.Environment.Name, Name: parameter.Name, Value: parameter.Value, Description: parameter.Description}
+		entries = append(entries, entry)
+		if !parameter.IsPlaintext {
+			secrets[entry.Key()] = true
+		}
+	}
+	return entries, secrets, nil
+}
+
+// activeStageAndEnvironmentIDs maps the names of the stages and environments of the project that are not archived to their IDs
+func activeStageAndEnvironmentIDs(projectID uint) (map[string]uint, map[string]uint) {
+	var stages []models.Stage
+	var environments []models.Environment
+	DB.Where("project_id = ? AND is_archived = ?", projectID, false).Find(&stages)
+	DB.Where("project_id = ? AND is_archived = ?", projectID, false).Find(&environments)
+	stageIDs, environmentIDs := map[string]uint{}, map[string]uint{}
+	for _, stage := range stages {
+		stageIDs[stage.Name] = stage.ID
+	}
+	for _, environment := range environments {
+		environmentIDs[environment.Name] = environment.ID
+	}
+	return stageIDs, environmentIDs
+}
+
+// planGitOps reads the manifest from the repo and compares it with the latest version
+func planGitOps(project models.Project, config models.GitOpsConfig) (gitopsPlan, error) {
+	result := gitopsPlan{Path: config.Path}
+	repository, err := github.ParseRepoURL(project.RepoURL)
+	if err != nil {
+		return result, err
+	}
+	content, sha, err := github.GetFileContentAtRef(repository.Owner, repository.Name, config.Path, config.Branch, project.RepoApiToken)
+	if err != nil {
+		if errors.Is(err, github.ErrFileNotFound) {
+			return result, fmt.Errorf("%s not found in %s", config.Path, project.RepoURL)
+		}
+		return result, err
+	}
+	result.SHA = sha
+	declared, err := gitops.Parse([]byte(content))
+	if err != nil {
+		return result, err
+	}
+
+	current, secrets, err := latestVersionEntries(project)
+	if err != nil {
+		return result, err
+	}
+	baseline := gitopsBaseline(config)
+	stageIDs, environmentIDs := activeStageAndEnvironmentIDs(project.ID)
+	var problems []string
+	for _, entry := range declared {
+		_, synced := baseline[entry.Key()]
+		switch {
+		case stageIDs[entry.Stage] == 0:
+			problems = append(problems, fmt.Sprintf("%s: stage %s does not exist", entry.Key(), entry.Stage))
+		case environmentIDs[entry.Environment] == 0:
+			problems = append(problems, fmt.Sprintf("%s: environment %s does not exist", entry.Key(), entry.Environment))
+		// entries synced before are manifest values, the others must not overwrite a secret set in the UI
+		case secrets[entry.Key()] && !synced:
+			problems = append(problems, fmt.Sprintf("%s: is a secret, secrets must not be committed and are managed in the UI", entry.Key()))
+		case len(entry.Value) > 255 || len(entry.Description) > 255:
+			problems = append(problems, fmt.Sprintf("%s: value and description are limited to 255 characters", entry.Key()))
+		case len(entry.Name) > 100:
+			problems = append(problems, fmt.Sprintf("%s: name is limited to 100 characters", entry.Key()))
+		}
+	}
+
+	result.Plan = gitops.Diff(declared, current, baseline)
+	result.Plan.Errors = append(result.Plan.Errors, problems...)
+	result.declared = declared
+	return result, nil
+}
+
+// applyGitOpsPlan writes the manifest into a new version cloned from the latest one
+func applyGitOpsPlan(project models.Project, config *models.GitOpsConfig, plan gitopsPlan) (models.Version, error) {
+	stageIDs, environmentIDs := activeStageAndEnvironmentIDs(project.ID)
+	var changes []VersionChange
+	for _, change := range plan.Plan.Changes {
+		versionChange := VersionChange{StageID: stageIDs[change.Stage], EnvironmentID: environmentIDs[change.Environment], Name: change.Name}
+		if change.Action == gitops.ActionRemove {
+			versionChange.Remove = true
+		} else {
+			// the values are committed to the repo, they are not secret
+			versionChange.Value, versionChange.Description, versionChange.IsPlaintext = change.After.Value, change.After.Description, true
+		}
+		changes = append(changes, versionChange)
+	}
+	short := plan.SHA
+	if len(short) > 7 {
+		short = short[:7]
+	}
+	newVersion, err := newVersionFromLatest(project, nextVersionNumber(project, "gitops."+short), fmt.Sprintf("Synced from %s at %s", plan.Path, plan.SHA), changes)
+	if err != nil {
+		return models.Version{}, err
+	}
+
+	baseline, _ := json.Marshal(gitops.Baseline(plan.declared))
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := createLatestVersion(tx, project, &newVersion); err != nil {
+			return err
+		}
+		config.Baseline = string(baseline)
+		config.LastSHA = plan.SHA
+		config.LastVersionID = newVersion.ID
+		config.LastSyncedAt = time.Now()
+		config.LastStatus = models.GitOpsStatusSynced
+		config.LastError = ""
+		return tx.Save(config).Error
+	})
+	if err != nil {
+		return models.Version{}, err
+	}
+	emitVersionCreated(project.ID, newVersion)
+	return newVersion, nil
+}
+
+// gitopsSummary counts the changes of a plan by action
+func gitopsSummary(plan gitops.Plan) map[string]int {
+	summary := map[string]int{gitops.ActionAdd: 0, gitops.ActionUpdate: 0, gitops.ActionRemove: 0, "conflicts": plan.Conflicts}
+	for _, change := range plan.Changes {
+		summary[change.Action]++
+	}
+	return summary
+}
+
+// notifyGitOps tells the channels the outcome of a sync triggered by a push
+func notifyGitOps(project models.Project, plan gitopsPlan, status string, version models.Version) {
+	summary := gitopsSummary(plan.Plan)
+	message := notifier.Message{
+		Fields: []notifier.Field{
+			{Name: "Project", Value: project.Name},
+			{Name: "Manifest", Value: plan.Path + " @ " + plan.SHA},
+			{Name: "Added", Value: strconv.Itoa(summary[gitops.ActionAdd])},
+			{Name: "Updated", Value: strconv.Itoa(summary[gitops.ActionUpdate])},
+			{Name: "Removed", Value: strconv.Itoa(summary[gitops.ActionRemove])},
+		},
+	}
+	switch status {
+	case models.GitOpsStatusSynced:
+		message.Title = fmt.Sprintf("[%s] Parameters synced from %s", project.Name, plan.Path)
+		message.Text = fmt.Sprintf("Version *%s* was created from the manifest", version.Number)
+		message.Level = notifier.LevelSuccess
+	case models.GitOpsStatusPending:
+		message.Title = fmt.Sprintf("[%s] %s changed, review needed", project.Name, plan.Path)
+		message.Text = fmt.Sprintf("The manifest has %d conflicts with values edited in the UI, preview and apply it in the project settings", plan.Plan.Conflicts)
+		message.Level = notifier.LevelInfo
+		if plan.Plan.Conflicts == 0 {
+			message.Text = "Auto apply is off, preview and apply the manifest in the project settings"
+		}
+	default:
+		message.Title = fmt.Sprintf("[%s] Failed to sync %s", project.Name, plan.Path)
+		message.Text = strings.Join(plan.Plan.Errors, "\n")
+		message.Level = notifier.LevelFailure
+	}
+	notifyProject(project.ID, NotificationEventParameterChanged, message)
+}
+
+// syncGitOpsOnPush previews the manifest pushed to the repo and applies it when auto apply is on and nothing conflicts
+func syncGitOpsOnPush(c *gin.Context, project models.Project, config models.GitOpsConfig) {
+	plan, err := planGitOps(project, config)
+	if err == nil && len(plan.Plan.Errors) > 0 {
+		err = errors.New(strings.Join(plan.Plan.Errors, "; "))
+	}
+	if err != nil {
+		DB.Model(&config).Updates(map[string]interface{}{"last_status": models.GitOpsStatusFailed, "last_error": err.Error()})
+		plan.Plan.Errors = []string{err.Error()}
+		notifyGitOps(project, plan, models.GitOpsStatusFailed, models.Version{})
+		return
+	}
+	if len(plan.Plan.Changes) == 0 {
+		DB.Model(&config).Updates(map[string]interface{}{"last_status": models.GitOpsStatusUpToDate, "last_error": "", "last_sha": plan.SHA})
+		return
+	}
+	if !config.AutoApply || plan.Plan.Conflicts > 0 {
+		DB.Model(&config).Updates(map[string]interface{}{"last_status": models.GitOpsStatusPending, "last_error": ""})
+		notifyGitOps(project, plan, models.GitOpsStatusPending, models.Version{})
+		return
+	}
+	version, err := applyGitOpsPlan(project, &config, plan)
+	if err != nil {
+		log.Println("Failed to apply gitops manifest:", err)
+		DB.Model(&config).Updates(map[string]interface{}{"last_status": models.GitOpsStatusFailed, "last_error": err.Error()})
+		return
+	}
+	auditBySystem(c, project.OrganizationID, "gitops", auditEntry{
+		ProjectID:  project.ID,
+		Action:     "gitops.apply",
+		TargetType: "version",
+		TargetID:   version.ID,
+		TargetName: version.Number,
+		After:      map[string]interface{}{"sha": plan.SHA, "path": plan.Path, "changes": gitopsSummary(plan.Plan)},
+	})
+	notifyGitOps(project, plan, models.GitOpsStatusSynced, version)
+}
+
+// GetGitOpsConfig godoc
+// @Summary Get GitOps config
+// @Description Get how the project syncs parameters from the manifest in its repo
+// @Tags Project Detail / GitOps
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 {object} models.GitOpsConfig
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/gitops [get]
+func GetGitOpsConfig(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("project_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	config := gitopsConfigOf(uint(projectID))
+	c.JSON(http.StatusOK, gin.H{"gitops": config, "webhook_url": os.Getenv("HOSTNAME_URL") + gitopsPushPath})
+}
+
+// UpdateGitOpsConfig godoc
+// @Summary Update GitOps config
+// @Description Enable or configure the sync from the manifest. The webhook secret to set on the GitHub push webhook is only returned when it is generated.
+// @Tags Project Detail / GitOps
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param GitOps body controllers.gitopsConfigRequestBody true "GitOps config"
+// @Success 200 string {string} json "{"gitops": {}, "webhook_url": "...", "webhook_secret": "..."}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/gitops [put]
+func UpdateGitOpsConfig(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("project_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	var body gitopsConfigRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	path := strings.TrimPrefix(strings.TrimSpace(body.Path), "/")
+	if path == "" {
+		path = gitops.DefaultPath
+	}
+	if strings.Contains(path, "..") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid manifest path"})
+		return
+	}
+	config := gitopsConfigOf(uint(projectID))
+	before := gin.H{"enabled": config.Enabled, "path": config.Path, "branch": config.Branch, "auto_apply": config.AutoApply}
+	config.Enabled = body.Enabled
+	config.Path = path
+	config.Branch = strings.TrimSpace(body.Branch)
+	config.AutoApply = body.AutoApply
+
+	response := gin.H{"webhook_url": os.Getenv("HOSTNAME_URL") + gitopsPushPath}
+	if config.WebhookSecret == "" || body.RotateWebhookSecret {
+		secret, err := webhook.GenerateSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook secret"})
+			return
+		}
+		config.WebhookSecret = secret
+		response["webhook_secret"] = secret
+	}
+	if err := DB.Save(&config).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save GitOps config"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  config.ProjectID,
+		Action:     "gitops.configure",
+		TargetType: "gitops",
+		TargetID:   config.ID,
+		TargetName: config.Path,
+		Before:     before,
+		After:      gin.H{"enabled": config.Enabled, "path": config.Path, "branch": config.Branch, "auto_apply": config.AutoApply, "secret_rotated": response["webhook_secret"] != nil},
+	})
+	response["gitops"] = config
+	c.JSON(http.StatusOK, response)
+}
+
+// PreviewGitOpsSync godoc
+// @Summary Preview GitOps sync
+// @Description Read the manifest from the repo and show what applying it would change in the latest version, with conflicts for values edited in the UI since the last sync
+// @Tags Project Detail / GitOps
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 {object} controllers.gitopsPlan
+// @Failure 422 string {string} json "{"error": ".parameter-store.yaml not found in github.com/OWNER/REPO"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/gitops/plan [get]
+func PreviewGitOpsSync(c *gin.Context) {
+	var project models.Project
+	if err := DB.First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	plan, err := planGitOps(project, gitopsConfigOf(project.ID))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sha": plan.SHA, "path": plan.Path, "plan": plan.Plan, "summary": gitopsSummary(plan.Plan)})
+}
+
+// ApplyGitOpsSync godoc
+// @Summary Apply GitOps sync
+// @Description Write the manifest into a new version. Conflicts are refused unless force is set, and the apply fails if the manifest changed since the preview of sha.
+// @Tags Project Detail / GitOps
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param Apply body controllers.gitopsApplyRequestBody true "Apply"
+// @Success 201 string {string} json "{"version": {}, "summary": {}}"
+// @Failure 409 string {string} json "{"error": "Manifest has conflicts", "plan": {}}"
+// @Failure 422 string {string} json "{"error": "Manifest has errors", "plan": {}}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/gitops/apply [post]
+func ApplyGitOpsSync(c *gin.Context) {
+	var body gitopsApplyRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var project models.Project
+	if err := DB.First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	if project.IsArchived {
+		c.JSON(http.StatusConflict, gin.H{"error": "Project is archived"})
+		return
+	}
+	config := gitopsConfigOf(project.ID)
+	plan, err := planGitOps(project, config)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if body.SHA != "" && body.SHA != plan.SHA {
+		c.JSON(http.StatusConflict, gin.H{"error": "Manifest changed since the preview, preview it again", "sha": plan.SHA})
+		return
+	}
+	if len(plan.Plan.Errors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Manifest has errors", "plan": plan.Plan})
+		return
+	}
+	if plan.Plan.Conflicts > 0 && !body.Force {
+		c.JSON(http.StatusConflict, gin.H{"error": "Manifest has conflicts with values edited in the UI, apply with force to overwrite them", "plan": plan.Plan})
+		return
+	}
+	if len(plan.Plan.Changes) == 0 {
+		if config.ID != 0 {
+			DB.Model(&config).Updates(map[string]interface{}{"last_status": models.GitOpsStatusUpToDate, "last_sha": plan.SHA})
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Latest version already matches the manifest", "summary": gitopsSummary(plan.Plan)})
+		return
+	}
+	// a project without config is synced once by hand, its baseline is kept for the next sync
+	if config.ID == 0 {
+		DB.Create(&config)
+	}
+	version, err := applyGitOpsPlan(project, &config, plan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply manifest"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "gitops.apply",
+		TargetType: "version",
+		TargetID:   version.ID,
+		TargetName: version.Number,
+		After:      map[string]interface{}{"sha": plan.SHA, "path": plan.Path, "changes": gitopsSummary(plan.Plan), "force": body.Force},
+		Status:     http.StatusCreated,
+	})
+	c.JSON(http.StatusCreated, gin.H{"version": gin.H{"id": version.ID, "number": version.Number}, "summary": gitopsSummary(plan.Plan)})
+}
+
+// ReceiveGitHubPush godoc
+// @Summary Receive GitHub push
+// @Description Webhook for GitHub push events, signed with the GitOps webhook secret of the project. A push touching the manifest is previewed and applied when auto apply is on and nothing conflicts.
+// @Tags GitOps
+// @Accept json
+// @Produce json
+// @Param X-Hub-Signature-256 header string true "sha256=HMAC of the body"
+// @Param X-GitHub-Event header string true "push"
+// @Success 202 string {string} json "{"projects": 1}"
+// @Failure 401 string {string} json "{"error": "Invalid signature"}"
+// @Router /api/v1/gitops/github/push [post]
+func ReceiveGitHubPush(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 5<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
+	switch c.GetHeader("X-GitHub-Event") {
+	case "ping":
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+		return
+	case "push":
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"message": "Event ignored"})
+		return
+	}
+	var event github.PushEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.Repository.FullName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid push event"})
+		return
+	}
+
+	var projects []models.Project
+	DB.Where("LOWER(repo_url) = ? AND is_archived = ?", strings.ToLower("github.com/"+event.Repository.FullName), false).Find(&projects)
+	signed := false
+	var due []models.Project
+	var configs []models.GitOpsConfig
+	for _, project := range projects {
+		config := gitopsConfigOf(project.ID)
+		if config.ID == 0 || !github.VerifyWebhookSignature(config.WebhookSecret, body, c.GetHeader("X-Hub-Signature-256")) {
+			continue
+		}
+		signed = true
+		branch := config.Branch
+		if branch == "" {
+			branch = event.Repository.DefaultBranch
+		}
+		if !config.Enabled || event.Branch() != branch || !event.Touches(config.Path) {
+			continue
+		}
+		due = append(due, project)
+		configs = append(configs, config)
+	}
+	if !signed {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+	// GitHub gives up after 10 seconds, sync in the background
+	request := c.Copy()
+	go func() {
+		for i := range due {
+			syncGitOpsOnPush(request, due[i], configs[i])
+		}
+	}()
+	c.JSON(http.StatusAccepted, gin.H{"projects": len(due)})
+}
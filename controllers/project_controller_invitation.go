@@ -0,0 +1,342 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/mailer"
+	"parameter-store-be/modules/signedtoken"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	invitationTokenPurpose = "invitation"
+	invitationTTL          = 7 * 24 * time.Hour
+)
+
+// projectRoleNames are the roles a user can hold in a project, Organization Admin is not granted per project
+var projectRoleNames = []string{"Project Admin", "Developer"}
+
+// IsProjectRole tells if the role can be given in a project
+func IsProjectRole(role models.Role) bool {
+	return isIn(projectRoleNames, role.Name)
+}
+
+type invitationRequestBody struct {
+	Email  string `json:"email" binding:"required,email"`
+	RoleID uint   `json:"role_id" binding:"required"`
+}
+
+// InviteUserToProject godoc
+// @Summary Invite a user to project
+// @Description Send an invitation link to the email. The invitee joins the project with the role and sets their own password.
+// @Tags Project Detail / Invitations
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param Invitation body controllers.invitationRequestBody true "Invitation"
+// @Success 201 string {string} json "{"message": "Invitation sent"}"
+// @Failure 400 string {string} json "{"error": "role_id must be a project role, Project Admin or Developer"}"
+// @Failure 409 string {string} json "{"error": "Email is already used in another organization"}"
+// @Failure 500 string {string} json "{"error": "Failed to create invitation"}"
+// @Failure 503 string {string} json "{"error": "Failed to send invitation email, email is not configured on the server"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/invitations [post]
+func InviteUserToProject(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("project_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var body invitationRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var project models.Project
+	if err := DB.First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to get project"})
+		return
+	}
+	var role models.Role
+	if err := DB.First(&role, body.RoleID).Error; err != nil || !IsProjectRole(role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role_id must be a project role, Project Admin or Developer"})
+		return
+	}
+	var existingUser models.User
+	if err := DB.Where("email = ?", body.Email).First(&existingUser).Error; err == nil {
+		if existingUser.OrganizationID != project.OrganizationID {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is already used in another organization"})
+			return
+		}
+		var count int64
+		DB.Model(&models.UserRoleProject{}).Where("user_id = ? AND project_id = ?", existingUser.ID, projectID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User is already in project"})
+			return
+		}
+	}
+
+	token, hash, err := signedtoken.Generate(os.Getenv("SECRET_KEY"), invitationTokenPurpose)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+	invitation := models.Invitation{
+		OrganizationID: project.OrganizationID,
+		Email:          body.Email,
+		ProjectID:      project.ID,
+		RoleID:         role.ID,
+		InvitedByID:    user.ID,
+		TokenHash:      hash,
+		ExpiresAt:      time.Now().Add(invitationTTL),
+	}
+	if err := DB.Create(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+	if err := Mailer.Send(mailer.Message{
+		To:      body.Email,
+		Subject: fmt.Sprintf("You are invited to %s", project.Name),
+		Body: fmt.Sprintf("Hello,\n\n%s invited you to join the project %s as %s.\nOpen the link below to accept the invitation:\n%s\n\nThe link expires in 7 days.\n",
+			user.Username, project.Name, role.Name, clientLink("/accept-invitation", token)),
+	}); err != nil {
+		log.Println("Failed to send invitation email:", err)
+		if errors.Is(err, mailer.ErrNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to send invitation email, email is not configured on the server"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send invitation email"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "invitation.create",
+		TargetType: "invitation",
+		TargetID:   invitation.ID,
+		TargetName: invitation.Email,
+		After:      map[string]interface{}{"role": role.Name},
+		Status:     http.StatusCreated,
+	})
+	projectLogByUser(project.ID, "Invite User", fmt.Sprint("Invited ", body.Email, " as ", role.Name), http.StatusCreated, 0, user.ID)
+	c.JSON(http.StatusCreated, gin.H{"message": "Invitation sent", "invitation": invitation})
+}
+
+// ListProjectInvitations godoc
+// @Summary List pending invitations of project
+// @Description List invitations of project which are not accepted yet
+// @Tags Project Detail / Invitations
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 {array} models.Invitation
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/invitations [get]
+func ListProjectInvitations(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("project_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	var invitations []models.Invitation
+	DB.Preload("Role").Preload("InvitedBy").
+		Where("project_id = ? AND is_accepted = ?", projectID, false).
+		Order("created_at desc").Find(&invitations)
+
+	type invitationResponse struct {
+		ID        uint      `json:"id"`
+		Email     string    `json:"email"`
+		Role      string    `json:"role"`
+		InvitedBy string    `json:"invited_by"`
+		ExpiresAt time.Time `json:"expires_at"`
+		IsExpired bool      `json:"is_expired"`
+	}
+	var response []invitationResponse
+	for _, invitation := range invitations {
+		response = append(response, invitationResponse{
+			ID:        invitation.ID,
+			Email:     invitation.Email,
+			Role:      invitation.Role.Name,
+			InvitedBy: invitation.InvitedBy.Username,
+			ExpiresAt: invitation.ExpiresAt,
+			IsExpired: time.Now().After(invitation.ExpiresAt),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"invitations": response})
+}
+
+// RevokeInvitation godoc
+// @Summary Revoke invitation
+// @Description Revoke a pending invitation, its link stops working
+// @Tags Project Detail / Invitations
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param invitation_id path int true "Invitation ID"
+// @Success 200 string {string} json "{"message": "Invitation revoked"}"
+// @Failure 404 string {string} json "{"error": "Invitation not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/invitations/{invitation_id} [delete]
+func RevokeInvitation(c *gin.Context) {
+	var invitation models.Invitation
+	if err := DB.Where("project_id = ? AND is_accepted = ?", c.Param("project_id"), false).
+		First(&invitation, c.Param("invitation_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	DB.Delete(&invitation)
+	auditByUser(c, auditEntry{
+		ProjectID:  invitation.ProjectID,
+		Action:     "invitation.revoke",
+		TargetType: "invitation",
+		TargetID:   invitation.ID,
+		TargetName: invitation.Email,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// findPendingInvitation returns the pending invitation of a token
+func findPendingInvitation(token string) (models.Invitation, string) {
+	hash, ok := signedtoken.Verify(os.Getenv("SECRET_KEY"), invitationTokenPurpose, token)
+	if !ok {
+		return models.Invitation{}, "Invitation is invalid"
+	}
+	var invitation models.Invitation
+	if err := DB.Preload("Project").Preload("Role").Where("token_hash = ?", hash).First(&invitation).Error; err != nil {
+		return models.Invitation{}, "Invitation is invalid"
+	}
+	if invitation.IsAccepted {
+		return models.Invitation{}, "Invitation is already accepted"
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		return models.Invitation{}, "Invitation is expired"
+	}
+	return invitation, ""
+}
+
+// GetInvitation godoc
+// @Summary Get invitation
+// @Description Get the project, role and email of an invitation token, and whether the email already has an account
+// @Tags Auth / Account
+// @Accept json
+// @Produce json
+// @Param token query string true "Invitation token"
+// @Success 200 string {string} json "{"invitation": {}}"
+// @Failure 400 string {string} json "{"error": "Invitation is invalid"}"
+// @Router /api/v1/auth/invitations [get]
+func GetInvitation(c *gin.Context) {
+	invitation, msg := findPendingInvitation(c.Query("token"))
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	var count int64
+	DB.Model(&models.User{}).Where("email = ?", invitation.Email).Count(&count)
+	c.JSON(http.StatusOK, gin.H{
+		"invitation": gin.H{
+			"email":       invitation.Email,
+			"project":     invitation.Project.Name,
+			"role":        invitation.Role.Name,
+			"expires_at":  invitation.ExpiresAt,
+			"user_exists": count > 0,
+		},
+	})
+}
+
+type acceptInvitationBody struct {
+	Token    string `json:"token" binding:"required"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AcceptInvitation godoc
+// @Summary Accept invitation
+// @Description Join the project of the invitation. A new account is created with the username and password, an existing account only gets the project role.
+// @Tags Auth / Account
+// @Accept json
+// @Produce json
+// @Param request body controllers.acceptInvitationBody true "Invitation token and credentials"
+// @Success 200 string {string} json "{"message": "Invitation accepted"}"
+// @Failure 400 string {string} json "{"error": "Invitation is invalid"}"
+// @Failure 500 string {string} json "{"error": "Failed to accept invitation"}"
+// @Router /api/v1/auth/invitations/accept [post]
+func AcceptInvitation(c *gin.Context) {
+	var body acceptInvitationBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	invitation, msg := findPendingInvitation(body.Token)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var user models.User
+	if err := DB.Where("email = ?", invitation.Email).First(&user).Error; err != nil {
+		if body.Username == "" || len(body.Password) < minPasswordLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("username and a password of at least %d characters are required", minPasswordLength)})
+			return
+		}
+		hash, err := generateBcryptPassword(body.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+			return
+		}
+		// the invitation link proves the user owns the email
+		user = models.User{
+			Email:           invitation.Email,
+			Username:        body.Username,
+			Password:        hash,
+			OrganizationID:  invitation.OrganizationID,
+			EmailVerified:   true,
+			EmailVerifiedAt: time.Now(),
+		}
+		if err := DB.Create(&user).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Username is already used"})
+			return
+		}
+	} else if user.OrganizationID != invitation.OrganizationID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already used in another organization"})
+		return
+	}
+
+	// mark the invitation first so the link can not be used twice
+	result := DB.Model(&models.Invitation{}).
+		Where("id = ? AND is_accepted = ?", invitation.ID, false).
+		Updates(map[string]interface{}{"is_accepted": true, "accepted_at": time.Now()})
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invitation is already accepted"})
+		return
+	}
+	var count int64
+	DB.Model(&models.UserRoleProject{}).Where("user_id = ? AND project_id = ?", user.ID, invitation.ProjectID).Count(&count)
+	if count == 0 {
+		if err := DB.Create(&models.UserRoleProject{UserID: user.ID, ProjectID: invitation.ProjectID, RoleID: invitation.RoleID}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add user to project"})
+			return
+		}
+	}
+	auditAsUser(c, user, auditEntry{
+		ProjectID:  invitation.ProjectID,
+		Action:     "invitation.accept",
+		TargetType: "invitation",
+		TargetID:   invitation.ID,
+		TargetName: invitation.Email,
+		After:      map[string]interface{}{"role_id": invitation.RoleID},
+	})
+	projectLogByUser(invitation.ProjectID, "Accept Invitation", fmt.Sprint(user.Email, " joined as ", invitation.Role.Name), http.StatusOK, 0, user.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted", "email": user.Email, "project_id": invitation.ProjectID})
+}
@@ -0,0 +1,396 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/mailer"
+	"parameter-store-be/modules/notifier"
+	"parameter-store-be/modules/webhook"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Events sent to notification channels
+const (
+	NotificationEventParameterChanged  = "parameter.changed"
+	NotificationEventWorkflowStarted   = "workflow.started"
+	NotificationEventWorkflowCompleted = "workflow.completed"
+	NotificationEventSecretExpiring    = "secret.expiring"
+)
+
+var notificationEvents = []string{
+	NotificationEventParameterChanged,
+	NotificationEventWorkflowStarted,
+	NotificationEventWorkflowCompleted,
+	NotificationEventSecretExpiring,
+}
+
+var notificationChannelTypes = []string{
+	models.NotificationChannelSlack,
+	models.NotificationChannelTeams,
+	models.NotificationChannelEmail,
+}
+
+type notificationChannelRequestBody struct {
+	Name     string   `json:"name" binding:"required"`
+	Type     string   `json:"type" binding:"required"`
+	Target   string   `json:"target"`
+	Events   []string `json:"events" binding:"required"`
+	IsActive *bool    `json:"is_active"`
+}
+
+// validateNotificationChannelBody checks the type, target and events, it returns the events as stored
+func validateNotificationChannelBody(body notificationChannelRequestBody, requireTarget bool) (string, string) {
+	if !isIn(notificationChannelTypes, body.Type) {
+		return "", fmt.Sprintf("type must be one of %s", strings.Join(notificationChannelTypes, ", "))
+	}
+	if body.Target == "" && requireTarget {
+		return "", "target is required"
+	}
+	if body.Target != "" {
+		if body.Type == models.NotificationChannelEmail {
+			if _, err := mail.ParseAddressList(body.Target); err != nil {
+				return "", "target must be a comma separated list of emails"
+			}
+		} else {
+			parsed, err := url.Parse(body.Target)
+			if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+				return "", "target must be the https URL of an incoming webhook"
+			}
+			if err := webhook.ValidateURL(body.Target); err != nil {
+				return "", err.Error()
+			}
+		}
+	}
+	if len(body.Events) == 0 {
+		return "", "events must not be empty"
+	}
+	for _, event := range body.Events {
+		if event != "*" && !isIn(notificationEvents, event) {
+			return "", fmt.Sprintf("unknown event %s, events are %s or *", event, strings.Join(notificationEvents, ", "))
+		}
+	}
+	return strings.Join(body.Events, ","), ""
+}
+
+// notificationChannelSubscribes tells if the channel wants the event
+func notificationChannelSubscribes(channel models.NotificationChannel, event string) bool {
+	events := strings.Split(channel.Events, ",")
+	return isIn(events, "*") || isIn(events, event)
+}
+
+// notifyProject sends the message in the background to every active channel of the project subscribed to the event
+func notifyProject(projectID uint, event string, message notifier.Message) {
+	var channels []models.NotificationChannel
+	if err := DB.Where("project_id = ? AND is_active = ?", projectID, true).Find(&channels).Error; err != nil || len(channels) == 0 {
+		return
+	}
+	for _, channel := range channels {
+		if notificationChannelSubscribes(channel, event) {
+			go sendNotification(channel, message)
+		}
+	}
+}
+
+// sendNotification renders the message for the channel type, the result is kept on the channel
+func sendNotification(channel models.NotificationChannel, message notifier.Message) error {
+	var err error
+	switch channel.Type {
+	case models.NotificationChannelSlack:
+		err = notifier.PostJSON(channel.Target, notifier.SlackPayload(message))
+	case models.NotificationChannelTeams:
+		err = notifier.PostJSON(channel.Target, notifier.TeamsPayload(message))
+	case models.NotificationChannelEmail:
+		body := notifier.PlainText(message)
+		for _, address := range strings.Split(channel.Target, ",") {
+			if sendErr := Mailer.Send(mailer.Message{To: strings.TrimSpace(address), Subject: message.Title, Body: body}); sendErr != nil {
+				err = sendErr
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown channel type %s", channel.Type)
+	}
+	updates := map[string]interface{}{"last_sent_at": time.Now(), "last_error": ""}
+	if err != nil {
+		log.Println("Failed to send notification to channel", channel.Name, ":", err)
+		updates["last_error"] = err.Error()
+	}
+	DB.Model(&channel).Updates(updates)
+	return err
+}
+
+// stageAndEnvironmentNames returns the names shown in notifications for the ids of a parameter or agent
+func stageAndEnvironmentNames(stageID, environmentID uint) (string, string) {
+	var stage models.Stage
+	var environment models.Environment
+	DB.Select("id", "name").First(&stage, stageID)
+	DB.Select("id", "name").First(&environment, environmentID)
+	return stage.Name, environment.Name
+}
+
+// workflowRunURL is the GitHub page of a workflow run of the project repository
+func workflowRunURL(repoURL string, workflowRunID uint64) string {
+	return fmt.Sprintf("%s/actions/runs/%d", strings.TrimSuffix(strings.TrimSuffix(repoURL, "/"), ".git"), workflowRunID)
+}
+
+// notifyParameterChanged tells the channels who changed which parameter, action is e.g. "updated"
+func notifyParameterChanged(user models.User, projectID uint, action string, parameter models.Parameter) {
+	var project models.Project
+	DB.Select("id", "name").First(&project, projectID)
+	stage, environment := stageAndEnvironmentNames(parameter.StageID, parameter.EnvironmentID)
+	notifyProject(projectID, NotificationEventParameterChanged, notifier.Message{
+		Title: fmt.Sprintf("[%s] Parameter %s %s", project.Name, parameter.Name, action),
+		Text:  fmt.Sprintf("*%s* %s parameter *%s* in %s / %s", user.Username, action, parameter.Name, stage, environment),
+		Level: notifier.LevelInfo,
+		Fields: []notifier.Field{
+			{Name: "Project", Value: project.Name},
+			{Name: "Parameter", Value: parameter.Name},
+			{Name: "Stage", Value: stage},
+			{Name: "Environment", Value: environment},
+			{Name: "Changed by", Value: user.Username},
+		},
+	})
+}
+
+// workflowCompletedMessage describes the final outcome of a workflow run started to apply parameters
+func workflowCompletedMessage(project models.Project, workflow models.Workflow, logg models.WorkflowLog, runURL string) notifier.Message {
+	var agent models.Agent
+	DB.Where("project_id = ? AND workflow_id = ?", project.ID, workflow.WorkflowID).First(&agent)
+	stage, environment := stageAndEnvironmentNames(agent.StageID, agent.EnvironmentID)
+	conclusion := logg.Conclusion
+	if conclusion == "" {
+		conclusion = "unknown"
+	}
+	level := notifier.LevelFailure
+	if conclusion == "success" {
+		level = notifier.LevelSuccess
+	}
+	duration := (time.Duration(logg.Duration) * time.Millisecond).Round(time.Second)
+	return notifier.Message{
+		Title: fmt.Sprintf("[%s] Workflow %s finished: %s", project.Name, workflow.Name, conclusion),
+		Text:  fmt.Sprintf("Run #%d of *%s* for %s / %s finished with *%s* in %s", logg.WorkflowRunId, workflow.Name, stage, environment, conclusion, duration),
+		Level: level,
+		Fields: []notifier.Field{
+			{Name: "Project", Value: project.Name},
+			{Name: "Stage", Value: stage},
+			{Name: "Environment", Value: environment},
+			{Name: "Outcome", Value: conclusion},
+			{Name: "Duration", Value: duration.String()},
+			{Name: "Attempt", Value: strconv.Itoa(logg.AttemptNumber)},
+		},
+		LinkURL:  runURL,
+		LinkText: "View run on GitHub",
+	}
+}
+
+// findProjectNotificationChannel loads a notification channel of the project in the path
+func findProjectNotificationChannel(c *gin.Context) (models.NotificationChannel, bool) {
+	var channel models.NotificationChannel
+	if err := DB.Where("project_id = ?", c.Param("project_id")).First(&channel, c.Param("channel_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification channel not found"})
+		return channel, false
+	}
+	return channel, true
+}
+
+// ListNotificationChannels godoc
+// @Summary List notification channels
+// @Description List Slack, Teams and email channels of project, targets are not returned
+// @Tags Project Detail / Notifications
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 {array} models.NotificationChannel
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/notifications [get]
+func ListNotificationChannels(c *gin.Context) {
+	var channels []models.NotificationChannel
+	DB.Where("project_id = ?", c.Param("project_id")).Order("id asc").Find(&channels)
+	c.JSON(http.StatusOK, gin.H{"channels": channels, "events": notificationEvents, "types": notificationChannelTypes})
+}
+
+// CreateNotificationChannel godoc
+// @Summary Create notification channel
+// @Description Send messages about parameter changes and CI runs to a Slack or Teams incoming webhook URL, or to a comma separated list of emails
+// @Tags Project Detail / Notifications
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param Channel body controllers.notificationChannelRequestBody true "Notification channel"
+// @Success 201 {object} models.NotificationChannel
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 500 string {string} json "{"error": "Failed to create notification channel"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/notifications [post]
+func CreateNotificationChannel(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("project_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	var body notificationChannelRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events, msg := validateNotificationChannelBody(body, true)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	channel := models.NotificationChannel{
+		ProjectID: uint(projectID),
+		Name:      body.Name,
+		Type:      body.Type,
+		Target:    body.Target,
+		Events:    events,
+		IsActive:  true,
+	}
+	if body.IsActive != nil {
+		channel.IsActive = *body.IsActive
+	}
+	if err := DB.Create(&channel).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create notification channel"})
+		return
+	}
+	// is_active defaults to true in the table, write it when switched off
+	DB.Model(&channel).Select("is_active").Updates(&channel)
+	auditByUser(c, auditEntry{
+		ProjectID:  channel.ProjectID,
+		Action:     "notification_channel.create",
+		TargetType: "notification_channel",
+		TargetID:   channel.ID,
+		TargetName: channel.Name,
+		After:      map[string]interface{}{"type": channel.Type, "events": channel.Events, "is_active": channel.IsActive},
+		Status:     http.StatusCreated,
+	})
+	c.JSON(http.StatusCreated, gin.H{"channel": channel})
+}
+
+// UpdateNotificationChannel godoc
+// @Summary Update notification channel
+// @Description Update name, events and state of a notification channel, the target is kept when it is empty
+// @Tags Project Detail / Notifications
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param channel_id path int true "Channel ID"
+// @Param Channel body controllers.notificationChannelRequestBody true "Notification channel"
+// @Success 200 {object} models.NotificationChannel
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 404 string {string} json "{"error": "Notification channel not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/notifications/{channel_id} [put]
+func UpdateNotificationChannel(c *gin.Context) {
+	channel, ok := findProjectNotificationChannel(c)
+	if !ok {
+		return
+	}
+	var body notificationChannelRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// the stored target belongs to the old type
+	events, msg := validateNotificationChannelBody(body, body.Type != channel.Type)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	before := map[string]interface{}{"name": channel.Name, "type": channel.Type, "events": channel.Events, "is_active": channel.IsActive}
+	channel.Name = body.Name
+	channel.Type = body.Type
+	channel.Events = events
+	if body.Target != "" {
+		channel.Target = body.Target
+	}
+	if body.IsActive != nil {
+		channel.IsActive = *body.IsActive
+	}
+	if err := DB.Save(&channel).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification channel"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  channel.ProjectID,
+		Action:     "notification_channel.update",
+		TargetType: "notification_channel",
+		TargetID:   channel.ID,
+		TargetName: channel.Name,
+		Before:     before,
+		After:      map[string]interface{}{"name": channel.Name, "type": channel.Type, "events": channel.Events, "is_active": channel.IsActive, "target_changed": body.Target != ""},
+	})
+	c.JSON(http.StatusOK, gin.H{"channel": channel})
+}
+
+// DeleteNotificationChannel godoc
+// @Summary Delete notification channel
+// @Description Delete a notification channel
+// @Tags Project Detail / Notifications
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param channel_id path int true "Channel ID"
+// @Success 200 string {string} json "{"message": "Notification channel deleted"}"
+// @Failure 404 string {string} json "{"error": "Notification channel not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/notifications/{channel_id} [delete]
+func DeleteNotificationChannel(c *gin.Context) {
+	channel, ok := findProjectNotificationChannel(c)
+	if !ok {
+		return
+	}
+	DB.Delete(&channel)
+	auditByUser(c, auditEntry{
+		ProjectID:  channel.ProjectID,
+		Action:     "notification_channel.delete",
+		TargetType: "notification_channel",
+		TargetID:   channel.ID,
+		TargetName: channel.Name,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Notification channel deleted"})
+}
+
+// TestNotificationChannel godoc
+// @Summary Test notification channel
+// @Description Send a test message to the channel and return the result
+// @Tags Project Detail / Notifications
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param channel_id path int true "Channel ID"
+// @Success 200 string {string} json "{"message": "Test notification sent"}"
+// @Failure 404 string {string} json "{"error": "Notification channel not found"}"
+// @Failure 502 string {string} json "{"error": "Failed to send test notification"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/notifications/{channel_id}/test [post]
+func TestNotificationChannel(c *gin.Context) {
+	channel, ok := findProjectNotificationChannel(c)
+	if !ok {
+		return
+	}
+	user, _ := getUserFromContext(c)
+	var project models.Project
+	DB.Select("id", "name").First(&project, channel.ProjectID)
+	err := sendNotification(channel, notifier.Message{
+		Title: fmt.Sprintf("[%s] Test notification", project.Name),
+		Text:  fmt.Sprintf("*%s* sent a test message to channel *%s*", user.Username, channel.Name),
+		Level: notifier.LevelInfo,
+		Fields: []notifier.Field{
+			{Name: "Project", Value: project.Name},
+			{Name: "Events", Value: channel.Events},
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send test notification: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Test notification sent"})
+}
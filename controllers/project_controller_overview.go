@@ -146,6 +146,7 @@ type projectBody struct {
 	AutoUpdate    bool   `json:"auto_update" `
 	RepoURL       string `json:"repo_url" `
 	RepoApiToken  string `json:"repo_api_token" `
+	TemplateID    uint   `json:"template_id"` // only on create
 }
 
 func (pb projectBody) Print() {
@@ -304,6 +305,14 @@ func AddUserToProject(c *gin.Context) {
 	}
 	// Save the new user to project relationship to the database
 	DB.Create(&urp)
+	auditByUser(c, auditEntry{
+		ProjectID:  urp.ProjectID,
+		Action:     "project.member.add",
+		TargetType: "user",
+		TargetID:   addedUser.ID,
+		TargetName: addedUser.Username,
+		After:      map[string]interface{}{"role": role.Name},
+	})
 
 	c.JSON(http.StatusOK, gin.H{"message": "User added to project"})
 }
@@ -336,6 +345,13 @@ func RemoveUserFromProject(c *gin.Context) {
 
 	// Delete the user to project relationship from the database
 	DB.Delete(&urp)
+	auditByUser(c, auditEntry{
+		ProjectID:  urp.ProjectID,
+		Action:     "project.member.remove",
+		TargetType: "user",
+		TargetID:   urp.UserID,
+		Before:     map[string]interface{}{"role_id": urp.RoleID},
+	})
 
 	c.JSON(http.StatusOK, gin.H{"message": "User removed from project"})
 }
@@ -434,9 +450,19 @@ func UpdateUserInProject(c *gin.Context) {
 	}
 
 	// Update the user to project relationship in the database
+	before := map[string]interface{}{"user_id": urp.UserID, "role_id": urp.RoleID}
 	urp.UserID = user.ID
 	urp.RoleID = role.ID
 	DB.Save(&urp)
+	auditByUser(c, auditEntry{
+		ProjectID:  urp.ProjectID,
+		Action:     "project.member.update",
+		TargetType: "user",
+		TargetID:   user.ID,
+		TargetName: user.Username,
+		Before:     before,
+		After:      map[string]interface{}{"user_id": urp.UserID, "role_id": urp.RoleID, "role": role.Name},
+	})
 
 	c.JSON(http.StatusOK, gin.H{"message": "User updated in project"})
 }
@@ -8,6 +8,7 @@ import (
 	"os"
 	"parameter-store-be/models"
 	"parameter-store-be/modules/github"
+	"parameter-store-be/modules/notifier"
 	"strconv"
 	"sync"
 	"time"
@@ -399,11 +400,13 @@ func CreateParameter(c *gin.Context) {
 	// modeling user
 	u := user.(models.User)
 	type createParameterRequestBody struct {
-		Name        string `json:"name" binding:"required"`
-		Value       string `json:"value" binding:"required"`
-		Stage       string `json:"stage"`
-		Environment string `json:"environment"`
-		Description string `json:"description"`
+		Name                  string `json:"name" binding:"required"`
+		Value                 string `json:"value" binding:"required"`
+		Stage                 string `json:"stage"`
+		Environment           string `json:"environment"`
+		Description           string `json:"description"`
+		IsEnvironmentSpecific bool   `json:"is_environment_specific"`
+		IsPlaintext           bool   `json:"is_plaintext"` // values are masked in logs and payloads unless plaintext
 	}
 	newParameterBody := createParameterRequestBody{}
 	if err := c.ShouldBindJSON(&newParameterBody); err != nil {
@@ -450,17 +453,8 @@ func CreateParameter(c *gin.Context) {
 		}
 	}
 
-	githubRepository, err := github.ParseRepoURL(project.RepoURL)
-	if err != nil {
-		log.Println("Failed to parse repo URL in project", project.Name)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse repo URL"})
-		return
-	}
-	resultSearching := FindCodeInRepo(githubRepository.Owner, githubRepository.Name, project.RepoApiToken, newParameterBody.Name)
-	if resultSearching == "" {
-		resultSearching = "null"
-		return
-	}
+	// usages come from the index of the last repository scan
+	resultSearching := indexedParameterUsage(project, newParameterBody.Name)
 
 	newParameter := models.Parameter{
 		Name:          newParameterBody.Name,
@@ -474,6 +468,9 @@ func CreateParameter(c *gin.Context) {
 		EditedAt:      time.Now().UTC(),
 		Description:   newParameterBody.Description,
 		IsUsingAtFile: resultSearching,
+
+		IsEnvironmentSpecific: newParameterBody.IsEnvironmentSpecific,
+		IsPlaintext:           newParameterBody.IsPlaintext,
 	}
 
 	// Append the new parameter to the latest version's Parameters slice
@@ -487,6 +484,16 @@ func CreateParameter(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update latest version"})
 		return
 	}
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "parameter.create",
+		TargetType: "parameter",
+		TargetName: newParameter.Name,
+		After:      parameterAuditSnapshot(newParameter),
+		Status:     http.StatusCreated,
+	})
+	emitProjectEvent(project.ID, WebhookEventParameterCreated, parameterWebhookData(newParameter))
+	notifyParameterChanged(u, project.ID, "created", newParameter)
 
 	// rerun github actions workflow if project.AutoUpdate is true
 	if project.AutoUpdate {
@@ -578,6 +585,7 @@ func ArchiveParameter(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameter"})
 		return
 	}
+	before := parameterAuditSnapshot(parameter)
 	parameter.IsArchived = true
 	parameter.ArchivedBy = u.Username
 	parameter.ArchivedAt = time.Now()
@@ -586,6 +594,17 @@ func ArchiveParameter(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive parameter"})
 		return
 	}
+	auditByUser(c, auditEntry{
+		ProjectID:  parameter.ProjectID,
+		Action:     "parameter.archive",
+		TargetType: "parameter",
+		TargetID:   parameter.ID,
+		TargetName: parameter.Name,
+		Before:     before,
+		After:      parameterAuditSnapshot(parameter),
+	})
+	emitProjectEvent(parameter.ProjectID, WebhookEventParameterArchived, parameterWebhookData(parameter))
+	notifyParameterChanged(u, parameter.ProjectID, "archived", parameter)
 	// rerun github actions workflow
 	if project.AutoUpdate {
 
@@ -647,6 +666,7 @@ func UnarchiveParameter(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameter"})
 		return
 	}
+	before := parameterAuditSnapshot(parameter)
 	parameter.IsArchived = false
 	parameter.ArchivedBy = ""
 	parameter.ArchivedAt = time.Time{}
@@ -654,6 +674,17 @@ func UnarchiveParameter(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unarchive parameter"})
 		return
 	}
+	auditByUser(c, auditEntry{
+		ProjectID:  parameter.ProjectID,
+		Action:     "parameter.unarchive",
+		TargetType: "parameter",
+		TargetID:   parameter.ID,
+		TargetName: parameter.Name,
+		Before:     before,
+		After:      parameterAuditSnapshot(parameter),
+	})
+	emitProjectEvent(parameter.ProjectID, WebhookEventParameterUnarchived, parameterWebhookData(parameter))
+	notifyParameterChanged(u, parameter.ProjectID, "unarchived", parameter)
 	// rerun github actions workflow
 	if project.AutoUpdate {
 
@@ -712,11 +743,13 @@ func UpdateParameter(c *gin.Context) {
 	// modeling user
 	u := user.(models.User)
 	type updateParameterRequestBody struct {
-		Name        string `json:"name"`
-		Value       string `json:"value"`
-		Stage       string `json:"stage"`
-		Environment string `json:"environment"`
-		Description string `json:"description"`
+		Name                  string `json:"name"`
+		Value                 string `json:"value"`
+		Stage                 string `json:"stage"`
+		Environment           string `json:"environment"`
+		Description           string `json:"description"`
+		IsEnvironmentSpecific *bool  `json:"is_environment_specific"`
+		IsPlaintext           *bool  `json:"is_plaintext"`
 	}
 	updateParameterBody := updateParameterRequestBody{}
 	if err := c.ShouldBindJSON(&updateParameterBody); err != nil {
@@ -765,7 +798,7 @@ func UpdateParameter(c *gin.Context) {
 		parameter.Name = updateParameterBody.Name
 	}
 	if updateParameterBody.Value != "" {
-		parameter.Value = updateParameterBody.Value
+		parameter.SetValue(updateParameterBody.Value)
 	}
 	if updateParameterBody.Description != "" {
 		parameter.Description = updateParameterBody.Description
@@ -776,18 +809,15 @@ func UpdateParameter(c *gin.Context) {
 	if updateParameterBody.Environment != "" {
 		parameter.EnvironmentID = findingEnvironment.ID
 	}
-
-	githubRepository, err := github.ParseRepoURL(project.RepoURL)
-	if err != nil {
-		log.Println("Failed to parse repo URL in project", project.Name)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse repo URL"})
-		return
+	if updateParameterBody.IsEnvironmentSpecific != nil {
+		parameter.IsEnvironmentSpecific = *updateParameterBody.IsEnvironmentSpecific
 	}
-	resultSearching := FindCodeInRepo(githubRepository.Owner, githubRepository.Name, project.RepoApiToken, parameter.Name)
-	if resultSearching == "" {
-		resultSearching = "null"
-		return
+	if updateParameterBody.IsPlaintext != nil {
+		parameter.IsPlaintext = *updateParameterBody.IsPlaintext
 	}
+
+	// usages come from the index of the last repository scan
+	resultSearching := indexedParameterUsage(project, parameter.Name)
 	parameter.IsUsingAtFile = resultSearching
 	parameter.IsApplied = false
 	parameter.EditedAt = time.Now().UTC()
@@ -796,6 +826,20 @@ func UpdateParameter(c *gin.Context) {
 		return
 	}
 	// DB.Save(&parameter)
+	auditByUser(c, auditEntry{
+		ProjectID:  parameter.ProjectID,
+		Action:     "parameter.update",
+		TargetType: "parameter",
+		TargetID:   parameter.ID,
+		TargetName: parameter.Name,
+		Before:     parameterAuditSnapshot(currentParameter),
+		After:      parameterAuditSnapshot(parameter),
+	})
+	emitProjectEvent(parameter.ProjectID, WebhookEventParameterUpdated, gin.H{
+		"before": parameterWebhookData(currentParameter),
+		"after":  parameterWebhookData(parameter),
+	})
+	notifyParameterChanged(u, parameter.ProjectID, "updated", parameter)
 
 	// debug currentParameter and parameter
 	if !project.AutoUpdate {
@@ -892,6 +936,30 @@ func rerunCICDWorkflow(updatedProjectID uint, updatedStageID uint, updatedEnviro
 	}
 	//save workflow log
 	workflowLog(usedAgent.WorkflowID, uint(lastWorkflowRunIDUint64), lastAttemptNumber)
+	if err == nil && responseStatusCode != 403 {
+		emitProjectEvent(project.ID, WebhookEventApplyStarted, gin.H{
+			"stage_id":        updatedStageID,
+			"environment_id":  updatedEnvironmentID,
+			"agent_id":        usedAgent.ID,
+			"workflow_name":   usedAgent.WorkflowName,
+			"workflow_run_id": lastWorkflowRunIDUint64,
+			"attempt_number":  lastAttemptNumber,
+		})
+		stage, environment := stageAndEnvironmentNames(updatedStageID, updatedEnvironmentID)
+		notifyProject(project.ID, NotificationEventWorkflowStarted, notifier.Message{
+			Title: fmt.Sprintf("[%s] Workflow %s started", project.Name, usedAgent.WorkflowName),
+			Text:  fmt.Sprintf("Applying parameters of %s / %s with run #%d", stage, environment, lastWorkflowRunIDUint64),
+			Level: notifier.LevelInfo,
+			Fields: []notifier.Field{
+				{Name: "Project", Value: project.Name},
+				{Name: "Stage", Value: stage},
+				{Name: "Environment", Value: environment},
+				{Name: "Agent", Value: usedAgent.Name},
+			},
+			LinkURL:  workflowRunURL(project.RepoURL, lastWorkflowRunIDUint64),
+			LinkText: "View run on GitHub",
+		})
+	}
 	log.Println(responseMessage)
 	if responseStatusCode == 403 {
 		return 201, latency, fmt.Sprintf("Parameter updated. Failed to rerun workflow: Workflow is already running. Check github actions at %s/actions", project.RepoURL), nil
@@ -1237,7 +1305,7 @@ func UploadParameters(c *gin.Context) {
 				parameter.EnvironmentID == uploadFileParamContent.EnvironmentID {
 				//
 				isExist = true
-				parameter.Value = uploadFileParamContent.Value
+				parameter.SetValue(uploadFileParamContent.Value)
 				parameter.EditedAt = time.Now().UTC()
 				parameter.Description = uploadFileParamContent.Description
 				overwriteCount++
@@ -0,0 +1,401 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/notifier"
+	"parameter-store-be/modules/paramio"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Actions of the rows of an import plan
+const (
+	importActionCreate = "create"
+	importActionUpdate = "update"
+	importActionSkip   = "skip"
+	importActionError  = "error"
+)
+
+// maxImportFileSize is the largest file accepted by ImportParameters
+const maxImportFileSize = 5 << 20
+
+// importPlanRow is what the import does with one row of the file, values of secrets are masked
+type importPlanRow struct {
+	Line        int    `json:"line"`
+	Stage       string `json:"stage"`
+	Environment string `json:"environment"`
+	Name        string `json:"name"`
+	Action      string `json:"action"`
+	Reason      string `json:"reason,omitempty"`
+	Value       string `json:"value,omitempty"`
+	Before      string `json:"before,omitempty"`
+
+	row       paramio.Row
+	parameter models.Parameter // the existing parameter on update
+}
+
+// versionParameters returns the parameters of a version of the project that are not archived, with their stage and environment
+func versionParameters(projectID uint, versionID uint) ([]models.Parameter, error) {
+	var parameters []models.Parameter
+	err := DB.Preload("Stage").Preload("Environment").
+		Joins("JOIN version_parameters ON version_parameters.parameter_id = parameters.id AND version_parameters.version_id = ?", versionID).
+		Where("parameters.project_id = ? AND parameters.is_archived = ?", projectID, false).
+		Order("parameters.name").
+		Find(&parameters).Error
+	return parameters, err
+}
+
+// parameterKey identifies a parameter by the names of its stage and environment
+func parameterKey(stage, environment, name string) string {
+	return stage + "/" + environment + "/" + name
+}
+
+// readImportFile returns the uploaded file, or the request body, and its name
+func readImportFile(c *gin.Context) ([]byte, string, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			file, err = c.FormFile("uploadFile")
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get file")
+		}
+		if file.Size > maxImportFileSize {
+			return nil, "", fmt.Errorf("file is larger than %d bytes", maxImportFileSize)
+		}
+		src, err := file.Open()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open file")
+		}
+		defer src.Close()
+		data, err := io.ReadAll(src)
+		return data, file.Filename, err
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportFileSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxImportFileSize {
+		return nil, "", fmt.Errorf("file is larger than %d bytes", maxImportFileSize)
+	}
+	return data, "", nil
+}
+
+// planImport decides what to do with each row, onExisting is "update" or "skip"
+func planImport(rows []paramio.Row, rowErrors []paramio.RowError, project models.Project, current []models.Parameter, onExisting string) []importPlanRow {
+	stageIDs, environmentIDs := activeStageAndEnvironmentIDs(project.ID)
+	existing := map[string]models.Parameter{}
+	for _, parameter := range current {
+		existing[parameterKey(parameter.Stage.Name, parameter.Environment.Name, parameter.Name)] = parameter
+	}
+	seen := map[string]int{}
+	var plan []importPlanRow
+	for _, rowError := range rowErrors {
+		plan = append(plan, importPlanRow{Line: rowError.Line, Action: importActionError, Reason: rowError.Error})
+	}
+	for _, row := range rows {
+		item := importPlanRow{Line: row.Line, Stage: row.Stage, Environment: row.Environment, Name: row.Name, row: row}
+		key := parameterKey(row.Stage, row.Environment, row.Name)
+		parameter, exists := existing[key]
+		item.Value = maskValue(row.Value, exists && parameter.IsPlaintext)
+		switch {
+		case strings.TrimSpace(row.Name) == "":
+			item.Action, item.Reason = importActionError, "name is required"
+		case len(row.Name) > 100:
+			item.Action, item.Reason = importActionError, "name is limited to 100 characters"
+		case len(row.Value) > 255 || len(row.Description) > 255:
+			item.Action, item.Reason = importActionError, "value and description are limited to 255 characters"
+		case stageIDs[row.Stage] == 0:
+			item.Action, item.Reason = importActionError, fmt.Sprintf("stage %s does not exist", row.Stage)
+		case environmentIDs[row.Environment] == 0:
+			item.Action, item.Reason = importActionError, fmt.Sprintf("environment %s does not exist", row.Environment)
+		case seen[key] != 0:
+			item.Action, item.Reason = importActionError, fmt.Sprintf("duplicate of line %d", seen[key])
+		case !exists:
+			item.Action = importActionCreate
+		case parameter.Value == row.Value && parameter.Description == row.Description && !parameter.IsDraft:
+			item.Action, item.Reason = importActionSkip, "unchanged"
+		case onExisting == importActionSkip:
+			item.Action, item.Reason = importActionSkip, "already exists"
+		default:
+			item.Action = importActionUpdate
+			item.Before = maskSecretValue(parameter)
+		}
+		if item.Action != importActionError || seen[key] == 0 {
+			seen[key] = row.Line
+		}
+		item.parameter = parameter
+		plan = append(plan, item)
+	}
+	sort.SliceStable(plan, func(i, j int) bool { return plan[i].Line < plan[j].Line })
+	return plan
+}
+
+func importSummary(plan []importPlanRow) map[string]int {
+	summary := map[string]int{importActionCreate: 0, importActionUpdate: 0, importActionSkip: 0, importActionError: 0}
+	for _, item := range plan {
+		summary[item.Action]++
+	}
+	return summary
+}
+
+// ImportParameters godoc
+// @Summary Import parameters
+// @Description Import parameters of the latest version from a dotenv file (one stage and environment), a JSON or YAML document or an Excel sheet.
+// @Description The response has a create, update, skip or error action per row. With dry_run nothing is written, and rows with errors block the import unless skip_errors is set.
+// @Tags Project Detail / Parameters
+// @Accept multipart/form-data
+// @Produce json
+// @Param project_id path string true "Project ID"
+// @Param file formData file false "File, the request body is read when missing"
+// @Param format query string false "dotenv, json, yaml or xlsx, guessed from the file name when missing"
+// @Param stage query string false "Stage of a dotenv file"
+// @Param environment query string false "Environment of a dotenv file"
+// @Param dry_run query bool false "Only return the plan"
+// @Param on_existing query string false "update (default) or skip existing parameters"
+// @Param skip_errors query bool false "Import the valid rows when some rows have errors"
+// @Success 200 string {string} json "{"dry_run": true, "summary": {}, "rows": []}"
+// @Success 201 string {string} json "{"dry_run": false, "summary": {}, "rows": []}"
+// @Failure 400 string {string} json "{"error": "unsupported format"}"
+// @Failure 422 string {string} json "{"error": "Some rows have errors", "rows": []}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameters/import [post]
+func ImportParameters(c *gin.Context) {
+	startTime := time.Now()
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var project models.Project
+	if err := DB.First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+	skipErrors := c.Query("skip_errors") == "true"
+	onExisting := c.DefaultQuery("on_existing", importActionUpdate)
+	if onExisting != importActionUpdate && onExisting != importActionSkip {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "on_existing must be update or skip"})
+		return
+	}
+	if project.IsArchived && !dryRun {
+		c.JSON(http.StatusConflict, gin.H{"error": "Project is archived"})
+		return
+	}
+
+	data, fileName, err := readImportFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := c.Query("format")
+	if format == "" {
+		format = paramio.FormatOf(fileName)
+	}
+	rows, rowErrors, err := paramio.Parse(format, data, c.Query("stage"), c.Query("environment"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	current, err := versionParameters(project.ID, project.LatestVersionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameters"})
+		return
+	}
+	plan := planImport(rows, rowErrors, project, current, onExisting)
+	summary := importSummary(plan)
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "format": format, "summary": summary, "rows": plan})
+		return
+	}
+	if summary[importActionError] > 0 && !skipErrors {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Some rows have errors, fix them or import with skip_errors", "dry_run": false, "format": format, "summary": summary, "rows": plan})
+		return
+	}
+
+	stageIDs, environmentIDs := activeStageAndEnvironmentIDs(project.ID)
+	now := time.Now().UTC()
+	var changed []models.Parameter
+	var events []string
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		for _, item := range plan {
+			switch item.Action {
+			case importActionCreate:
+				parameter := models.Parameter{
+					Name:          item.row.Name,
+					Value:         item.row.Value,
+					Description:   item.row.Description,
+					ProjectID:     project.ID,
+					StageID:       stageIDs[item.row.Stage],
+					EnvironmentID: environmentIDs[item.row.Environment],
+					EditedAt:      now,
+				}
+				if err := tx.Create(&parameter).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec("INSERT INTO version_parameters (version_id, parameter_id) VALUES (?, ?)", project.LatestVersionID, parameter.ID).Error; err != nil {
+					return err
+				}
+				changed = append(changed, parameter)
+				events = append(events, WebhookEventParameterCreated)
+			case importActionUpdate:
+				parameter := item.parameter
+				if parameter.Value != item.row.Value {
+					parameter.IsApplied = false
+				}
+				parameter.SetValue(item.row.Value)
+				parameter.Description = item.row.Description
+				parameter.EditedAt = now
+				if err := tx.Model(&parameter).Select("value", "description", "is_applied", "edited_at", "is_draft", "value_changed_at").Updates(&parameter).Error; err != nil {
+					return err
+				}
+				changed = append(changed, parameter)
+				events = append(events, WebhookEventParameterUpdated)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import parameters"})
+		return
+	}
+
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "parameter.import",
+		TargetType: "project",
+		TargetID:   project.ID,
+		TargetName: project.Name,
+		After:      map[string]interface{}{"format": format, "file": fileName, "summary": summary},
+		Status:     http.StatusCreated,
+	})
+	for i, parameter := range changed {
+		emitProjectEvent(project.ID, events[i], parameterWebhookData(parameter))
+	}
+	if len(changed) > 0 {
+		notifyProject(project.ID, NotificationEventParameterChanged, notifier.Message{
+			Title: fmt.Sprintf("[%s] %d parameters imported", project.Name, len(changed)),
+			Text:  fmt.Sprintf("*%s* imported a %s file: %d created, %d updated", user.Username, format, summary[importActionCreate], summary[importActionUpdate]),
+			Level: notifier.LevelInfo,
+			Fields: []notifier.Field{
+				{Name: "Project", Value: project.Name},
+				{Name: "Imported by", Value: user.Username},
+			},
+		})
+	}
+
+	message := "Parameters imported"
+	if project.AutoUpdate && len(changed) > 0 {
+		// rerun once per stage and environment that changed
+		targets := map[[2]uint]bool{}
+		for _, parameter := range changed {
+			targets[[2]uint{parameter.StageID, parameter.EnvironmentID}] = true
+		}
+		go func() {
+			for target := range targets {
+				if status, _, message, err := rerunCICDWorkflow(project.ID, target[0], target[1]); err != nil {
+					log.Printf("Failed to rerun workflow after import: %d %s", status, message)
+				}
+			}
+		}()
+		message = "Parameters imported. Started rerun cicd. Check github actions of the project's repo."
+	}
+	projectLogByUser(project.ID, "Import Parameters", message, http.StatusCreated, time.Since(startTime), user.ID)
+	c.JSON(http.StatusCreated, gin.H{"dry_run": false, "format": format, "message": message, "summary": summary, "rows": plan})
+}
+
+// ExportParameters godoc
+// @Summary Export parameters
+// @Description Export the parameters of a version as a dotenv file (one stage and environment), a JSON or YAML document or an Excel sheet, which ImportParameters reads back
+// @Tags Project Detail / Parameters
+// @Produce octet-stream
+// @Param project_id path string true "Project ID"
+// @Param format query string false "dotenv, json (default), yaml or xlsx"
+// @Param version query string false "Version number, the latest when missing"
+// @Param stage query string false "Only this stage, required for dotenv"
+// @Param environment query string false "Only this environment, required for dotenv"
+// @Success 200 {file} file
+// @Failure 400 string {string} json "{"error": "stage and environment are required for dotenv"}"
+// @Failure 404 string {string} json "{"error": "Version not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameters/export [get]
+func ExportParameters(c *gin.Context) {
+	var project models.Project
+	if err := DB.First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	format := c.DefaultQuery("format", paramio.FormatJSON)
+	stage, environment := c.Query("stage"), c.Query("environment")
+	if format == paramio.FormatDotenv && (stage == "" || environment == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "stage and environment are required for dotenv"})
+		return
+	}
+	version := models.Version{}
+	query := DB.Where("project_id = ?", project.ID)
+	if number := c.Query("version"); number != "" {
+		query = query.Where("number = ?", number)
+	} else {
+		query = query.Where("id = ?", project.LatestVersionID)
+	}
+	if err := query.First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	parameters, err := versionParameters(project.ID, version.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameters"})
+		return
+	}
+	var rows []paramio.Row
+	for _, parameter := range parameters {
+		if (stage != "" && parameter.Stage.Name != stage) || (environment != "" && parameter.Environment.Name != environment) {
+			continue
+		}
+		rows = append(rows, paramio.Row{Stage: parameter.Stage.Name, Environment: parameter.Environment.Name, Name: parameter.Name, Value: parameter.Value, Description: parameter.Description})
+	}
+
+	var data []byte
+	var extension string
+	switch format {
+	case paramio.FormatDotenv:
+		var builder strings.Builder
+		err = paramio.WriteDotenv(&builder, fmt.Sprintf("Project: %s, Version: %s, Stage: %s, Environment: %s", project.Name, version.Number, stage, environment), rows)
+		data, extension = []byte(builder.String()), "env"
+	case paramio.FormatJSON, paramio.FormatYAML:
+		data, err = paramio.MarshalDocument(format, project.Name, version.Number, rows)
+		extension = format
+	case paramio.FormatXLSX:
+		data, err = paramio.MarshalXLSX(rows)
+		extension = "xlsx"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported format %q, use one of %s", format, strings.Join(paramio.Formats, ", "))})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write file"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "parameter.export",
+		TargetType: "version",
+		TargetID:   version.ID,
+		TargetName: version.Number,
+		After:      map[string]interface{}{"format": format, "stage": stage, "environment": environment, "parameters": len(rows)},
+	})
+	fileName := fmt.Sprintf("parameters-%s-Ver.%s.%s", project.Name, version.Number, extension)
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(fileName))
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/paramio"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetParameterMatrix godoc
+// @Summary Compare parameters across stages and environments
+// @Description Every parameter name of a version as a row and every stage and environment as a column, with the value or a missing marker.
+// @Description missing_in lists the columns without the name among the stages that have it, differs tells if the values are not all the same.
+// @Description Values of parameters not marked plaintext are replaced by a fingerprint.
+// @Tags Project Detail / Parameters
+// @Produce json,octet-stream
+// @Param project_id path string true "Project ID"
+// @Param version query string false "Version number, the latest when missing"
+// @Param stage query string false "Only this stage"
+// @Param format query string false "json (default), csv or xlsx"
+// @Success 200 {object} paramio.Matrix
+// @Failure 400 string {string} json "{"error": "unsupported format"}"
+// @Failure 404 string {string} json "{"error": "Version not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameters/matrix [get]
+func GetParameterMatrix(c *gin.Context) {
+	project, _, ok := projectOfUser(c, false)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" && format != paramio.FormatXLSX {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported format %q, use one of json, csv, xlsx", format)})
+		return
+	}
+	version := models.Version{}
+	query := DB.Where("project_id = ?", project.ID)
+	if number := c.Query("version"); number != "" {
+		query = query.Where("number = ?", number)
+	} else {
+		query = query.Where("id = ?", project.LatestVersionID)
+	}
+	if err := query.First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+
+	var stages []models.Stage
+	var environments []models.Environment
+	stageQuery := DB.Where("project_id = ? AND is_archived = ?", project.ID, false).Order("id")
+	if stage := c.Query("stage"); stage != "" {
+		stageQuery = stageQuery.Where("name = ?", stage)
+	}
+	if err := stageQuery.Find(&stages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stages"})
+		return
+	}
+	if len(stages) == 0 && c.Query("stage") != "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Stage %s not found", c.Query("stage"))})
+		return
+	}
+	if err := DB.Where("project_id = ? AND is_archived = ?", project.ID, false).Order("id").Find(&environments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get environments"})
+		return
+	}
+	var columns []paramio.MatrixColumn
+	for _, stage := range stages {
+		for _, environment := range environments {
+			columns = append(columns, paramio.MatrixColumn{Stage: stage.Name, Environment: environment.Name})
+		}
+	}
+
+	parameters, err := versionParameters(project.ID, version.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameters"})
+		return
+	}
+	rows := make([]paramio.Row, 0, len(parameters))
+	for _, parameter := range parameters {
+		// secret values are compared by their fingerprint, equal values still have the same one
+		rows = append(rows, paramio.Row{Stage: parameter.Stage.Name, Environment: parameter.Environment.Name, Name: parameter.Name, Value: maskSecretValue(parameter)})
+	}
+	matrix := paramio.BuildMatrix(columns, rows)
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"version": version.Number, "columns": matrix.Columns, "rows": matrix.Rows})
+		return
+	}
+
+	var data []byte
+	switch format {
+	case "csv":
+		var buffer bytes.Buffer
+		err = paramio.WriteMatrixCSV(&buffer, matrix)
+		data = buffer.Bytes()
+	case paramio.FormatXLSX:
+		data, err = paramio.MarshalMatrixXLSX(matrix)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write file"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "parameter.export",
+		TargetType: "version",
+		TargetID:   version.ID,
+		TargetName: version.Number,
+		After:      map[string]interface{}{"format": "matrix-" + format, "stage": c.Query("stage"), "parameters": len(matrix.Rows)},
+	})
+	fileName := fmt.Sprintf("parameter-matrix-%s-Ver.%s.%s", project.Name, version.Number, format)
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(fileName))
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
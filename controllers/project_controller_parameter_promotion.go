@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/notifier"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Actions of a promotion
+const (
+	promotionActionCreate    = "create"
+	promotionActionUpdate    = "update"
+	promotionActionUnchanged = "unchanged"
+	promotionActionSkip      = "skip"
+)
+
+type promotionRequestBody struct {
+	SourceEnvironment string   `json:"source_environment" binding:"required"`
+	TargetEnvironment string   `json:"target_environment" binding:"required"`
+	Stage             string   `json:"stage"`   // only this stage, every stage when empty
+	Include           []string `json:"include"` // name patterns like DB_*, every name when empty
+	Exclude           []string `json:"exclude"`
+	DryRun            bool     `json:"dry_run"`
+	Description       string   `json:"description"`
+}
+
+// PromotionChange is what the promotion does with one parameter of the source, values of secrets are masked
+type PromotionChange struct {
+	Stage  string `json:"stage"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+
+	change VersionChange
+}
+
+// matchesAny tells if the name matches one of the patterns, a malformed pattern matches nothing
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanPromotion compares the source parameters with those of the target environment, include and exclude are name patterns
+func PlanPromotion(include []string, exclude []string, parameters []models.Parameter, stageNames map[uint]string, sourceID uint, targetID uint, stageID uint) []PromotionChange {
+	type key struct {
+		stageID uint
+		name    string
+	}
+	targets := map[key]models.Parameter{}
+	for _, parameter := range parameters {
+		if parameter.EnvironmentID == targetID {
+			targets[key{parameter.StageID, parameter.Name}] = parameter
+		}
+	}
+	var changes []PromotionChange
+	for _, source := range parameters {
+		if source.EnvironmentID != sourceID || (stageID != 0 && source.StageID != stageID) {
+			continue
+		}
+		if (len(include) > 0 && !matchesAny(include, source.Name)) || matchesAny(exclude, source.Name) {
+			continue
+		}
+		change := PromotionChange{
+			Stage: stageNames[source.StageID],
+			Name:  source.Name,
+			After: maskSecretValue(source),
+			change: VersionChange{
+				StageID:       source.StageID,
+				EnvironmentID: targetID,
+				Name:          source.Name,
+				Value:         source.Value,
+				Description:   source.Description,
+			},
+		}
+		target, exists := targets[key{source.StageID, source.Name}]
+		switch {
+		case source.IsEnvironmentSpecific:
+			change.Action, change.Reason = promotionActionSkip, "environment specific in the source"
+		case source.IsDraft:
+			change.Action, change.Reason = promotionActionSkip, "draft in the source"
+		// the value is a template of credentials issued per environment, each environment sets its own source
+		case source.DynamicSourceID != 0:
+			change.Action, change.Reason = promotionActionSkip, "dynamic credentials in the source"
+		case exists && target.DynamicSourceID != 0:
+			change.Action, change.Reason = promotionActionSkip, "dynamic credentials in the target"
+		case exists && target.IsEnvironmentSpecific:
+			change.Action, change.Reason = promotionActionSkip, "environment specific in the target"
+		case !exists:
+			change.Action = promotionActionCreate
+		case target.Value == source.Value && target.Description == source.Description && !target.IsDraft:
+			change.Action = promotionActionUnchanged
+		default:
+			change.Action = promotionActionUpdate
+		}
+		if exists {
+			change.Before = maskSecretValue(target)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// validatePromotionBody returns a message when the environments are the same or a pattern is malformed
+func validatePromotionBody(body promotionRequestBody) string {
+	if body.SourceEnvironment == body.TargetEnvironment {
+		return "Source and target environments must differ"
+	}
+	for _, pattern := range append(append([]string{}, body.Include...), body.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Sprintf("Invalid pattern %q", pattern)
+		}
+	}
+	return ""
+}
+
+// errPromotionNotFound is returned when the stage or an environment of a promotion does not exist
+var errPromotionNotFound = errors.New("not found")
+
+// planProjectPromotion compares the latest parameters of the source and target environments of the project,
+// it also returns the ID of the target environment
+func planProjectPromotion(project models.Project, body promotionRequestBody) ([]PromotionChange, uint, error) {
+	stageIDs, environmentIDs := activeStageAndEnvironmentIDs(project.ID)
+	sourceID, targetID := environmentIDs[body.SourceEnvironment], environmentIDs[body.TargetEnvironment]
+	for _, name := range []string{body.SourceEnvironment, body.TargetEnvironment} {
+		if environmentIDs[name] == 0 {
+			return nil, 0, fmt.Errorf("Environment %s %w", name, errPromotionNotFound)
+		}
+	}
+	var stageID uint
+	if body.Stage != "" {
+		if stageID = stageIDs[body.Stage]; stageID == 0 {
+			return nil, 0, fmt.Errorf("Stage %s %w", body.Stage, errPromotionNotFound)
+		}
+	}
+	stageNames := map[uint]string{}
+	for name, id := range stageIDs {
+		stageNames[id] = name
+	}
+	parameters, err := versionParameters(project.ID, project.LatestVersionID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return PlanPromotion(body.Include, body.Exclude, parameters, stageNames, sourceID, targetID, stageID), targetID, nil
+}
+
+// PromoteParameters godoc
+// @Summary Promote parameters between environments
+// @Description Copy the parameters of a source environment, optionally of one stage, onto a target environment as a new version.
+// @Description Include and exclude take name patterns like DB_*, parameters marked environment specific, drafts and dynamic credentials are skipped. With dry_run only the diff is returned.
+// @Tags Project Detail / Parameters
+// @Accept json
+// @Produce json
+// @Param project_id path string true "Project ID"
+// @Param Promotion body controllers.promotionRequestBody true "Promotion"
+// @Success 200 string {string} json "{"dry_run": true, "summary": {}, "changes": []}"
+// @Success 201 string {string} json "{"version": {}, "summary": {}, "changes": []}"
+// @Failure 400 string {string} json "{"error": "Source and target environments must differ"}"
+// @Failure 404 string {string} json "{"error": "Environment Staging not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameters/promote [post]
+func PromoteParameters(c *gin.Context) {
+	startTime := time.Now()
+	project, user, ok := projectOfUser(c, true)
+	if !ok {
+		return
+	}
+	var body promotionRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := validatePromotionBody(body); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if project.IsArchived && !body.DryRun {
+		c.JSON(http.StatusConflict, gin.H{"error": "Project is archived"})
+		return
+	}
+	changes, targetID, err := planProjectPromotion(project, body)
+	if err != nil {
+		if errors.Is(err, errPromotionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameters"})
+		}
+		return
+	}
+	summary := map[string]int{promotionActionCreate: 0, promotionActionUpdate: 0, promotionActionUnchanged: 0, promotionActionSkip: 0}
+	var versionChanges []VersionChange
+	for _, change := range changes {
+		summary[change.Action]++
+		if change.Action == promotionActionCreate || change.Action == promotionActionUpdate {
+			versionChanges = append(versionChanges, change.change)
+		}
+	}
+	if body.DryRun || len(versionChanges) == 0 {
+		c.JSON(http.StatusOK, gin.H{"dry_run": body.DryRun, "summary": summary, "changes": changes})
+		return
+	}
+
+	description := body.Description
+	if description == "" {
+		description = fmt.Sprintf("Promoted %s to %s", body.SourceEnvironment, body.TargetEnvironment)
+		if body.Stage != "" {
+			description += " in stage " + body.Stage
+		}
+	}
+	version, err := newVersionFromLatest(project, nextVersionNumber(project, "promotion."+strconv.FormatInt(time.Now().Unix(), 10)), description, versionChanges)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to promote parameters"})
+		return
+	}
+	if err := DB.Transaction(func(tx *gorm.DB) error { return createLatestVersion(tx, project, &version) }); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to promote parameters"})
+		return
+	}
+
+	message := fmt.Sprintf("Promoted %d parameters from %s to %s into version %s", len(versionChanges), body.SourceEnvironment, body.TargetEnvironment, version.Number)
+	DB.Create(&models.ProjectLog{
+		UserID:         user.ID,
+		Action:         "Promote Parameters",
+		ProjectID:      project.ID,
+		Path:           versionPath(project.ID, version.ID),
+		ResponseStatus: http.StatusCreated,
+		Message:        message,
+		Latency:        int(time.Since(startTime).Milliseconds()),
+	})
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "parameter.promote",
+		TargetType: "version",
+		TargetID:   version.ID,
+		TargetName: version.Number,
+		After: map[string]interface{}{
+			"source_environment": body.SourceEnvironment,
+			"target_environment": body.TargetEnvironment,
+			"stage":              body.Stage,
+			"include":            body.Include,
+			"exclude":            body.Exclude,
+			"summary":            summary,
+		},
+		Status: http.StatusCreated,
+	})
+	emitVersionCreated(project.ID, version)
+	notifyProject(project.ID, NotificationEventParameterChanged, notifier.Message{
+		Title: fmt.Sprintf("[%s] Parameters promoted to %s", project.Name, body.TargetEnvironment),
+		Text:  fmt.Sprintf("*%s* %s", user.Username, message),
+		Level: notifier.LevelInfo,
+		Fields: []notifier.Field{
+			{Name: "Project", Value: project.Name},
+			{Name: "From", Value: body.SourceEnvironment},
+			{Name: "To", Value: body.TargetEnvironment},
+			{Name: "Version", Value: version.Number},
+		},
+	})
+	if project.AutoUpdate {
+		targets := map[uint]bool{}
+		for _, change := range versionChanges {
+			targets[change.StageID] = true
+		}
+		go func() {
+			for stageID := range targets {
+				if status, _, message, err := rerunCICDWorkflow(project.ID, stageID, targetID); err != nil {
+					log.Printf("Failed to rerun workflow after promotion: %d %s", status, message)
+				}
+			}
+		}()
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"version": gin.H{"id": version.ID, "number": version.Number, "path": versionPath(project.ID, version.ID)},
+		"summary": summary,
+		"changes": changes,
+	})
+}
@@ -0,0 +1,315 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/envrefs"
+	"parameter-store-be/modules/github"
+	"parameter-store-be/modules/usagescan"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// missingReferencesShown caps the references listed for each missing parameter
+const missingReferencesShown = 10
+
+type missingParameter struct {
+	Name          string              `json:"name"`
+	Stage         string              `json:"stage"`
+	Environment   string              `json:"environment"`
+	StageID       uint                `json:"stage_id"`
+	EnvironmentID uint                `json:"environment_id"`
+	References    []envrefs.Reference `json:"references"`
+}
+
+type missingParameterReport struct {
+	Commit    string             `json:"commit"`
+	Workflows int                `json:"workflows"`
+	Files     int                `json:"files"`
+	Warnings  []string           `json:"warnings"`
+	Missing   []missingParameter `json:"missing"`
+}
+
+type stageEnvironment struct {
+	stageID, environmentID uint
+}
+
+// findMissingParameters reads the workflows and the code of the repository and reports the variables they read
+// that the latest version does not define. A workflow counts for the stages and environments of the agents running it,
+// code counts for those of every agent.
+func findMissingParameters(project models.Project) (missingParameterReport, error) {
+	report := missingParameterReport{Warnings: []string{}, Missing: []missingParameter{}}
+	repository, err := github.ParseRepoURL(project.RepoURL)
+	if err != nil {
+		return report, err
+	}
+	workflows, err := github.GetWorkflows(project.RepoURL, project.RepoApiToken)
+	if err != nil {
+		return report, err
+	}
+	workflowNames := map[string]string{}
+	for _, workflow := range workflows.Workflows {
+		workflowNames[workflow.Path] = workflow.Name
+	}
+
+	references := map[string][]envrefs.Reference{} // by workflow name, code under ""
+	definedInWorkflows := map[string]bool{}
+	report.Commit, err = github.WalkRepoFiles(repository.Owner, repository.Name, "", project.RepoApiToken, func(path string, content []byte) error {
+		if workflowName, ok := workflowNames[path]; ok {
+			report.Workflows++
+			found, defined, err := envrefs.WorkflowReferences(path, content)
+			if err != nil {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("Failed to parse %s: %v", path, err))
+				return nil
+			}
+			for _, name := range defined {
+				definedInWorkflows[name] = true
+			}
+			references[workflowName] = append(references[workflowName], found...)
+			return nil
+		}
+		// workflow files GitHub does not list are disabled or not workflows
+		if strings.HasPrefix(path, ".github/") || usagescan.Skip(path) {
+			return nil
+		}
+		report.Files++
+		references[""] = append(references[""], envrefs.CodeReferences(path, content)...)
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	stageIDs, environmentIDs := activeStageAndEnvironmentIDs(project.ID)
+	stageNames, environmentNames := map[uint]string{}, map[uint]string{}
+	for name, id := range stageIDs {
+		stageNames[id] = name
+	}
+	for name, id := range environmentIDs {
+		environmentNames[id] = name
+	}
+	var everyPair []stageEnvironment
+	for stageID := range stageNames {
+		for environmentID := range environmentNames {
+			everyPair = append(everyPair, stageEnvironment{stageID, environmentID})
+		}
+	}
+	var agents []models.Agent
+	DB.Where("project_id = ? AND is_archived = ?", project.ID, false).Find(&agents)
+	pairsByWorkflow := map[string][]stageEnvironment{}
+	var agentPairs []stageEnvironment
+	seenPairs := map[stageEnvironment]bool{}
+	for _, agent := range agents {
+		pair := stageEnvironment{agent.StageID, agent.EnvironmentID}
+		if stageNames[pair.stageID] == "" || environmentNames[pair.environmentID] == "" {
+			continue
+		}
+		pairsByWorkflow[agent.WorkflowName] = append(pairsByWorkflow[agent.WorkflowName], pair)
+		if !seenPairs[pair] {
+			seenPairs[pair] = true
+			agentPairs = append(agentPairs, pair)
+		}
+	}
+	if len(agentPairs) == 0 {
+		agentPairs = everyPair
+	}
+
+	parameters, err := versionParameters(project.ID, project.LatestVersionID)
+	if err != nil {
+		return report, err
+	}
+	defined := map[stageEnvironment]map[string]bool{}
+	for _, pair := range everyPair {
+		names := map[string]bool{}
+		// shared sets linked to the project count as defined
+		for _, parameter := range addSharedParameters(models.Agent{ProjectID: project.ID, StageID: pair.stageID, EnvironmentID: pair.environmentID}, nil) {
+			names[parameter.Name] = true
+		}
+		defined[pair] = names
+	}
+	for _, parameter := range parameters {
+		if names, ok := defined[stageEnvironment{parameter.StageID, parameter.EnvironmentID}]; ok {
+			names[parameter.Name] = true
+		}
+	}
+
+	missing := map[string]*missingParameter{}
+	for workflowName, found := range references {
+		pairs := agentPairs
+		if workflowName != "" && len(pairsByWorkflow[workflowName]) > 0 {
+			pairs = pairsByWorkflow[workflowName]
+		}
+		for _, reference := range found {
+			if workflowName == "" && definedInWorkflows[reference.Name] {
+				continue
+			}
+			for _, pair := range pairs {
+				if defined[pair][reference.Name] {
+					continue
+				}
+				key := fmt.Sprintf("%d/%d/%s", pair.stageID, pair.environmentID, reference.Name)
+				entry, ok := missing[key]
+				if !ok {
+					entry = &missingParameter{
+						Name:          reference.Name,
+						Stage:         stageNames[pair.stageID],
+						Environment:   environmentNames[pair.environmentID],
+						StageID:       pair.stageID,
+						EnvironmentID: pair.environmentID,
+					}
+					missing[key] = entry
+				}
+				if len(entry.References) < missingReferencesShown {
+					entry.References = append(entry.References, reference)
+				}
+			}
+		}
+	}
+	for _, entry := range missing {
+		report.Missing = append(report.Missing, *entry)
+	}
+	sort.Slice(report.Missing, func(i, j int) bool {
+		a, b := report.Missing[i], report.Missing[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Stage != b.Stage {
+			return a.Stage < b.Stage
+		}
+		return a.Environment < b.Environment
+	})
+	return report, nil
+}
+
+// loadReferenceProject loads the project of the request, it answers the request when it has no repository
+func loadReferenceProject(c *gin.Context) (models.Project, bool) {
+	var project models.Project
+	if err := DB.First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return project, false
+	}
+	if project.RepoURL == "" || project.RepoApiToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project has no repository"})
+		return project, false
+	}
+	return project, true
+}
+
+// GetMissingParameters godoc
+// @Summary Get missing parameters
+// @Description Read the workflows of the repository for ${{ env.NAME }} and the code for usual environment reads like os.Getenv("NAME") or process.env.NAME,
+// @Description and list the names the latest version lacks for each stage and environment.
+// @Description Workflows count for the stages and environments of the agents running them, code for those of every agent.
+// @Tags Project Detail / Parameters
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 {object} controllers.missingParameterReport
+// @Failure 502 string {string} json "{"error": "Failed to read repository"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameters/missing [get]
+func GetMissingParameters(c *gin.Context) {
+	project, ok := loadReferenceProject(c)
+	if !ok {
+		return
+	}
+	report, err := findMissingParameters(project)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to read repository: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// CreateMissingParameterDrafts godoc
+// @Summary Create drafts of missing parameters
+// @Description Add every missing parameter to a new version as an empty draft. Agents do not receive drafts until a value is set.
+// @Tags Project Detail / Parameters
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 201 string {string} json "{"version": {}, "drafts": []}"
+// @Success 200 string {string} json "{"message": "No missing parameters"}"
+// @Failure 502 string {string} json "{"error": "Failed to read repository"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameters/missing/drafts [post]
+func CreateMissingParameterDrafts(c *gin.Context) {
+	startTime := time.Now()
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	project, ok := loadReferenceProject(c)
+	if !ok {
+		return
+	}
+	report, err := findMissingParameters(project)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to read repository: " + err.Error()})
+		return
+	}
+	if len(report.Missing) == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "No missing parameters", "drafts": report.Missing})
+		return
+	}
+	var changes []VersionChange
+	drafts := map[string]bool{}
+	for _, entry := range report.Missing {
+		reference := entry.References[0]
+		changes = append(changes, VersionChange{
+			StageID:       entry.StageID,
+			EnvironmentID: entry.EnvironmentID,
+			Name:          entry.Name,
+			Description:   fmt.Sprintf("Draft: read at %s:%d", reference.Path, reference.Line),
+		})
+		drafts[fmt.Sprintf("%d/%d/%s", entry.StageID, entry.EnvironmentID, entry.Name)] = true
+	}
+	version, err := newVersionFromLatest(project, nextVersionNumber(project, "drafts."+strconv.FormatInt(time.Now().Unix(), 10)),
+		fmt.Sprintf("Drafts of %d missing parameters", len(changes)), changes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create drafts"})
+		return
+	}
+	for i, parameter := range version.Parameters {
+		if drafts[fmt.Sprintf("%d/%d/%s", parameter.StageID, parameter.EnvironmentID, parameter.Name)] {
+			version.Parameters[i].IsDraft = true
+		}
+	}
+	if err := DB.Transaction(func(tx *gorm.DB) error { return createLatestVersion(tx, project, &version) }); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create drafts"})
+		return
+	}
+	emitVersionCreated(project.ID, version)
+
+	var names []string
+	for _, entry := range report.Missing {
+		names = append(names, fmt.Sprintf("%s (%s / %s)", entry.Name, entry.Stage, entry.Environment))
+	}
+	message := fmt.Sprintf("Created %d draft parameters into version %s", len(changes), version.Number)
+	DB.Create(&models.ProjectLog{
+		UserID:         user.ID,
+		Action:         "Create Draft Parameters",
+		ProjectID:      project.ID,
+		Path:           versionPath(project.ID, version.ID),
+		ResponseStatus: http.StatusCreated,
+		Message:        "Succeed: " + message,
+		Latency:        int(time.Since(startTime).Milliseconds()),
+	})
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "parameter.draft_create",
+		TargetType: "version",
+		TargetID:   version.ID,
+		TargetName: version.Number,
+		After:      map[string]interface{}{"drafts": names, "commit": report.Commit},
+		Status:     http.StatusCreated,
+	})
+	c.JSON(http.StatusCreated, gin.H{"message": message, "version": version, "drafts": report.Missing})
+}
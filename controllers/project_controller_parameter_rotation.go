@@ -0,0 +1,420 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/notifier"
+	"parameter-store-be/modules/rotation"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// rotationWarnWindow is how early a rotation or an expiry is reported and notified
+	rotationWarnWindow       = 14 * 24 * time.Hour
+	rotationNotifierInterval = time.Hour
+)
+
+type rotationReportItem struct {
+	ProjectID    uint      `json:"project_id"`
+	ProjectName  string    `json:"project_name"`
+	ParameterID  uint      `json:"parameter_id"`
+	Name         string    `json:"name"`
+	Stage        string    `json:"stage"`
+	Environment  string    `json:"environment"`
+	IsSecret     bool      `json:"is_secret"`
+	RotationDays int       `json:"rotation_days"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RotatedAt    time.Time `json:"rotated_at"`
+	rotation.State
+}
+
+type rotationPolicyRequestBody struct {
+	RotationDays int        `json:"rotation_days"` // 0 disables the rotation
+	ExpiresAt    *time.Time `json:"expires_at"`    // RFC 3339, no expiry when null
+}
+
+type rotationSettingsRequestBody struct {
+	BlockExpiredSecrets bool `json:"block_expired_secrets"`
+}
+
+// ParameterRotationPolicy is the policy of the parameter, rotated when its value last changed. Parameters saved before
+// ValueChangedAt was recorded fall back to EditedAt, then to their creation.
+func ParameterRotationPolicy(parameter models.Parameter) rotation.Policy {
+	rotatedAt := parameter.ValueChangedAt
+	if rotatedAt.IsZero() {
+		rotatedAt = parameter.EditedAt
+	}
+	if rotatedAt.IsZero() {
+		rotatedAt = parameter.CreatedAt
+	}
+	return rotation.Policy{RotationDays: parameter.RotationDays, ExpiresAt: parameter.ExpiresAt, RotatedAt: rotatedAt}
+}
+
+// rotationParameters returns the parameters with a rotation policy in the latest versions of the active projects,
+// of every project when projectIDs is nil
+func rotationParameters(projectIDs []uint) ([]models.Parameter, map[uint]models.Project, error) {
+	var projects []models.Project
+	query := DB.Where("is_archived = ?", false)
+	if projectIDs != nil {
+		query = query.Where("id IN ?", projectIDs)
+	}
+	if err := query.Find(&projects).Error; err != nil {
+		return nil, nil, err
+	}
+	byID := map[uint]models.Project{}
+	var versionIDs []uint
+	for _, project := range projects {
+		byID[project.ID] = project
+		versionIDs = append(versionIDs, project.LatestVersionID)
+	}
+	if len(versionIDs) == 0 {
+		return nil, byID, nil
+	}
+	var parameters []models.Parameter
+	err := DB.Preload("Stage").Preload("Environment").
+		Joins("JOIN version_parameters ON version_parameters.parameter_id = parameters.id").
+		Where("version_parameters.version_id IN ? AND parameters.is_archived = ? AND (parameters.rotation_days > 0 OR parameters.expires_at > ?)", versionIDs, false, time.Time{}).
+		Order("parameters.project_id, parameters.name").
+		Find(&parameters).Error
+	return parameters, byID, err
+}
+
+// rotationReport places the parameters with a policy against it, the most urgent first
+func rotationReport(projectIDs []uint, warn time.Duration, includeOK bool) ([]rotationReportItem, map[string]int, error) {
+	parameters, projects, err := rotationParameters(projectIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	items := []rotationReportItem{}
+	summary := map[string]int{rotation.StatusOK: 0, rotation.StatusExpiring: 0, rotation.StatusStale: 0, rotation.StatusExpired: 0}
+	for _, parameter := range parameters {
+		policy := ParameterRotationPolicy(parameter)
+		state := rotation.Evaluate(policy, now, warn)
+		summary[state.Status]++
+		if state.Status == rotation.StatusOK && !includeOK {
+			continue
+		}
+		items = append(items, rotationReportItem{
+			ProjectID:    parameter.ProjectID,
+			ProjectName:  projects[parameter.ProjectID].Name,
+			ParameterID:  parameter.ID,
+			Name:         parameter.Name,
+			Stage:        parameter.Stage.Name,
+			Environment:  parameter.Environment.Name,
+			IsSecret:     !parameter.IsPlaintext,
+			RotationDays: parameter.RotationDays,
+			ExpiresAt:    parameter.ExpiresAt,
+			RotatedAt:    policy.RotatedAt,
+			State:        state,
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Status != items[j].Status {
+			return rotation.MoreUrgent(items[i].Status, items[j].Status)
+		}
+		return items[i].DueAt.Before(items[j].DueAt)
+	})
+	return items, summary, nil
+}
+
+// rotationReportQuery reads the warning window in days and whether the parameters that are ok are listed too
+func rotationReportQuery(c *gin.Context) (time.Duration, bool, bool) {
+	warn := rotationWarnWindow
+	if value := c.Query("within"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return 0, false, false
+		}
+		warn = time.Duration(days) * 24 * time.Hour
+	}
+	return warn, c.Query("all") == "true", true
+}
+
+// blockExpiredSecrets drops the parameters past their expiry from those served to an agent when the project asks for it,
+// it returns the names dropped
+func blockExpiredSecrets(project *models.Project) []string {
+	if !project.BlockExpired {
+		return nil
+	}
+	now := time.Now()
+	var blocked []string
+	kept := project.LatestVersion.Parameters[:0]
+	for _, parameter := range project.LatestVersion.Parameters {
+		if !parameter.ExpiresAt.IsZero() && !now.Before(parameter.ExpiresAt) {
+			blocked = append(blocked, parameter.Name)
+			continue
+		}
+		kept = append(kept, parameter)
+	}
+	project.LatestVersion.Parameters = kept
+	return blocked
+}
+
+// rotationNoticeLine describes a parameter in a notification
+func rotationNoticeLine(item rotationReportItem) string {
+	when := fmt.Sprintf("in %d days", item.DaysLeft)
+	if item.DaysLeft < 0 {
+		when = fmt.Sprintf("%d days ago", -item.DaysLeft)
+	}
+	return fmt.Sprintf("%s (%s / %s): %s, due %s %s", item.Name, item.Stage, item.Environment, item.Status, item.DueAt.UTC().Format("2006-01-02"), when)
+}
+
+// notifySecretRotation notifies the projects of the parameters whose rotation status changed since the last notice
+func notifySecretRotation() {
+	items, _, err := rotationReport(nil, rotationWarnWindow, true)
+	if err != nil {
+		log.Println("Failed to check secret rotation:", err)
+		return
+	}
+	var noticed []models.Parameter
+	if err := DB.Select("id", "rotation_notice").Where("id IN ?", rotationItemIDs(items)).Find(&noticed).Error; err != nil {
+		log.Println("Failed to check secret rotation:", err)
+		return
+	}
+	notices := map[uint]string{}
+	for _, parameter := range noticed {
+		notices[parameter.ID] = parameter.RotationNotice
+	}
+	due := map[uint][]rotationReportItem{}
+	var projectIDs []uint
+	for _, item := range items {
+		previous := notices[item.ParameterID]
+		if previous == item.Status {
+			continue
+		}
+		// the condition keeps another instance from sending the same notice
+		result := DB.Model(&models.Parameter{}).Where("id = ? AND COALESCE(rotation_notice, '') = ?", item.ParameterID, previous).UpdateColumn("rotation_notice", item.Status)
+		if result.Error != nil || result.RowsAffected == 0 || item.Status == rotation.StatusOK {
+			continue
+		}
+		if _, ok := due[item.ProjectID]; !ok {
+			projectIDs = append(projectIDs, item.ProjectID)
+		}
+		due[item.ProjectID] = append(due[item.ProjectID], item)
+	}
+	for _, projectID := range projectIDs {
+		level := notifier.LevelInfo
+		var lines []string
+		for _, item := range due[projectID] {
+			lines = append(lines, rotationNoticeLine(item))
+			if item.Status != rotation.StatusExpiring {
+				level = notifier.LevelFailure
+			}
+		}
+		notifyProject(projectID, NotificationEventSecretExpiring, notifier.Message{
+			Title: fmt.Sprintf("[%s] %d parameters need rotation", due[projectID][0].ProjectName, len(lines)),
+			Text:  strings.Join(lines, "\n"),
+			Level: level,
+		})
+	}
+}
+
+func rotationItemIDs(items []rotationReportItem) []uint {
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ParameterID)
+	}
+	return ids
+}
+
+// RunSecretRotationNotifier notifies the projects as their parameters approach their rotation date or expiry
+func RunSecretRotationNotifier() {
+	ticker := time.NewTicker(rotationNotifierInterval)
+	defer ticker.Stop()
+	for {
+		notifySecretRotation()
+		<-ticker.C
+	}
+}
+
+// GetProjectRotationReport godoc
+// @Summary Get the secret rotation report of a project
+// @Description List the parameters of the latest version that are expired, overdue for rotation or due within the window
+// @Tags Project Detail / Parameters
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param within query int false "Warning window in days, 14 by default"
+// @Param all query bool false "List the parameters in order too"
+// @Success 200 string {string} json "{"summary": {}, "parameters": []}"
+// @Failure 400 string {string} json "{"error": "within must be a number of days"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/secret-rotation [get]
+func GetProjectRotationReport(c *gin.Context) {
+	warn, includeOK, ok := rotationReportQuery(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "within must be a number of days"})
+		return
+	}
+	var project models.Project
+	if err := DB.First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	items, summary, err := rotationReport([]uint{project.ID}, warn, includeOK)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameters"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"block_expired_secrets": project.BlockExpired, "summary": summary, "parameters": items})
+}
+
+// GetOrganizationRotationReport godoc
+// @Summary Get the secret rotation report of the organization
+// @Description List the parameters of every active project that are expired, overdue for rotation or due within the window
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param within query int false "Warning window in days, 14 by default"
+// @Param all query bool false "List the parameters in order too"
+// @Success 200 string {string} json "{"summary": {}, "parameters": []}"
+// @Failure 400 string {string} json "{"error": "within must be a number of days"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/organizations/secret-rotation [get]
+func GetOrganizationRotationReport(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	warn, includeOK, ok := rotationReportQuery(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "within must be a number of days"})
+		return
+	}
+	projectIDs := []uint{}
+	if err := DB.Model(&models.Project{}).Where("organization_id = ?", user.OrganizationID).Pluck("id", &projectIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get projects"})
+		return
+	}
+	items, summary, err := rotationReport(projectIDs, warn, includeOK)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameters"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"summary": summary, "parameters": items})
+}
+
+// UpdateParameterRotationPolicy godoc
+// @Summary Set the rotation policy of a parameter
+// @Description Rotate the value every rotation_days days after it was last edited, and expire it at expires_at. Zero and null disable them.
+// @Tags Project Detail / Parameters
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param parameter_id path int true "Parameter ID"
+// @Param Policy body controllers.rotationPolicyRequestBody true "Policy"
+// @Success 200 string {string} json "{"parameter": {}, "rotation": {}}"
+// @Failure 404 string {string} json "{"error": "Parameter not found in the latest version"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameters/{parameter_id}/rotation-policy [put]
+func UpdateParameterRotationPolicy(c *gin.Context) {
+	startTime := time.Now()
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var body rotationPolicyRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if body.RotationDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rotation_days must not be negative"})
+		return
+	}
+	var project models.Project
+	if err := DB.First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	// older versions keep their copy, only the latest one is served
+	var parameter models.Parameter
+	if err := DB.Joins("JOIN version_parameters ON version_parameters.parameter_id = parameters.id AND version_parameters.version_id = ?", project.LatestVersionID).
+		Where("parameters.project_id = ? AND parameters.is_archived = ?", project.ID, false).
+		First(&parameter, c.Param("parameter_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Parameter not found in the latest version"})
+		return
+	}
+	before := map[string]interface{}{"rotation_days": parameter.RotationDays, "expires_at": parameter.ExpiresAt}
+	parameter.RotationDays = body.RotationDays
+	parameter.ExpiresAt = time.Time{}
+	if body.ExpiresAt != nil {
+		parameter.ExpiresAt = body.ExpiresAt.UTC()
+	}
+	// a new policy is notified from scratch
+	if err := DB.Model(&parameter).Updates(map[string]interface{}{"rotation_days": parameter.RotationDays, "expires_at": parameter.ExpiresAt, "rotation_notice": ""}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update parameter"})
+		return
+	}
+	after := map[string]interface{}{"rotation_days": parameter.RotationDays, "expires_at": parameter.ExpiresAt}
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "parameter.rotation_policy",
+		TargetType: "parameter",
+		TargetID:   parameter.ID,
+		TargetName: parameter.Name,
+		Before:     before,
+		After:      after,
+	})
+	projectLogByUser(project.ID, "Update Rotation Policy", "Succeed: Updated rotation policy of parameter "+parameter.Name, http.StatusOK, time.Since(startTime), user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"parameter": parameter,
+		"rotation":  rotation.Evaluate(ParameterRotationPolicy(parameter), time.Now(), rotationWarnWindow),
+	})
+}
+
+// UpdateProjectRotationSettings godoc
+// @Summary Update the secret rotation settings of a project
+// @Description Block the parameters past their expiry date from being served to agents
+// @Tags Project Detail / Parameters
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param Settings body controllers.rotationSettingsRequestBody true "Settings"
+// @Success 200 string {string} json "{"block_expired_secrets": true}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/secret-rotation/settings [put]
+func UpdateProjectRotationSettings(c *gin.Context) {
+	startTime := time.Now()
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var body rotationSettingsRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var project models.Project
+	if err := DB.First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	before := project.BlockExpired
+	if err := DB.Model(&project).UpdateColumn("block_expired", body.BlockExpiredSecrets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "project.rotation_settings",
+		TargetType: "project",
+		TargetID:   project.ID,
+		TargetName: project.Name,
+		Before:     map[string]interface{}{"block_expired_secrets": before},
+		After:      map[string]interface{}{"block_expired_secrets": body.BlockExpiredSecrets},
+	})
+	projectLogByUser(project.ID, "Update Rotation Settings", fmt.Sprintf("Succeed: Block expired secrets set to %t", body.BlockExpiredSecrets), http.StatusOK, time.Since(startTime), user.ID)
+	c.JSON(http.StatusOK, gin.H{"block_expired_secrets": body.BlockExpiredSecrets})
+}
@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/github"
+	"parameter-store-be/modules/usagescan"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	repoScanInterval = time.Hour
+	// repoScanMaxAge is how often the scanner refreshes the index of each project
+	repoScanMaxAge = 24 * time.Hour
+	// repoScanTimeout lets a new scan start when a running one never finished
+	repoScanTimeout = 30 * time.Minute
+	// parameterUsagePathMaxLength is the size of the path column
+	parameterUsagePathMaxLength = 500
+)
+
+var errRepoScanRunning = errors.New("a scan of the repository is already running")
+
+type parameterUsageGroup struct {
+	Name        string                 `json:"name"`
+	Occurrences []usagescan.Occurrence `json:"occurrences"`
+}
+
+// latestParameterNames returns the distinct names of the active parameters of the latest version
+func latestParameterNames(project models.Project) ([]string, error) {
+	var names []string
+	err := DB.Model(&models.Parameter{}).
+		Joins("JOIN version_parameters ON version_parameters.parameter_id = parameters.id AND version_parameters.version_id = ?", project.LatestVersionID).
+		Where("parameters.project_id = ? AND parameters.is_archived = ?", project.ID, false).
+		Distinct().Order("parameters.name").Pluck("parameters.name", &names).Error
+	return names, err
+}
+
+// usingAtByName groups usages the way Parameter.IsUsingAtFile stores them, with links to the scanned commit
+func usingAtByName(repoURL, commit string, usages []models.ParameterUsage) map[string][]UsingAt {
+	result := map[string][]UsingAt{}
+	for _, usage := range usages {
+		files := result[usage.Name]
+		if len(files) == 0 || files[len(files)-1].FileName != usage.Path {
+			files = append(files, UsingAt{
+				FileName:     usage.Path,
+				FileHTMLPath: fmt.Sprintf("https://%s/blob/%s/%s", repoURL, commit, usage.Path),
+			})
+		}
+		files[len(files)-1].LineNumber = append(files[len(files)-1].LineNumber, usage.Line)
+		result[usage.Name] = files
+	}
+	return result
+}
+
+// indexedParameterUsage returns where the name is used from the last scan, as stored in Parameter.IsUsingAtFile
+func indexedParameterUsage(project models.Project, name string) string {
+	var scan models.RepoScan
+	var usages []models.ParameterUsage
+	if DB.Where("project_id = ?", project.ID).First(&scan).Error != nil ||
+		DB.Where("project_id = ? AND name = ?", project.ID, name).Order("path, line").Find(&usages).Error != nil || len(usages) == 0 {
+		return "null"
+	}
+	encoded, _ := json.Marshal(usingAtByName(project.RepoURL, scan.CommitSHA, usages)[name])
+	return string(encoded)
+}
+
+// claimRepoScan marks the scan of the project as running, it fails when another one runs
+func claimRepoScan(projectID uint) error {
+	scan := models.RepoScan{ProjectID: projectID}
+	if err := DB.Where("project_id = ?", projectID).Attrs(models.RepoScan{Status: models.RepoScanFailed}).FirstOrCreate(&scan).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	result := DB.Model(&models.RepoScan{}).
+		Where("id = ? AND (status <> ? OR started_at < ?)", scan.ID, models.RepoScanRunning, now.Add(-repoScanTimeout)).
+		Updates(map[string]interface{}{"status": models.RepoScanRunning, "started_at": now, "message": ""})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errRepoScanRunning
+	}
+	return nil
+}
+
+// runRepoScan indexes every occurrence of the parameter names in the repository, with the download done once,
+// and refreshes IsUsingAtFile of the parameters of the latest version. The scan must be claimed.
+func runRepoScan(project models.Project) {
+	startTime := time.Now()
+	files, occurrences, commit, err := indexRepo(project)
+	updates := map[string]interface{}{"finished_at": time.Now(), "status": models.RepoScanSucceeded, "commit_sha": commit, "files": files, "occurrences": occurrences}
+	message := fmt.Sprintf("Succeed: Found %d occurrences of parameters in %d files at %s", occurrences, files, commit)
+	status := http.StatusOK
+	if err != nil {
+		updates = map[string]interface{}{"finished_at": time.Now(), "status": models.RepoScanFailed, "message": err.Error()}
+		message, status = "Failed to scan repository: "+err.Error(), http.StatusInternalServerError
+	}
+	if err := DB.Model(&models.RepoScan{}).Where("project_id = ?", project.ID).Updates(updates).Error; err != nil {
+		log.Println("Failed to save repository scan:", err)
+	}
+	DB.Create(&models.ProjectLog{
+		Action:         "Scan Repository",
+		ProjectID:      project.ID,
+		ResponseStatus: status,
+		Latency:        int(time.Since(startTime).Milliseconds()),
+		Message:        message,
+	})
+}
+
+func indexRepo(project models.Project) (int, int, string, error) {
+	repository, err := github.ParseRepoURL(project.RepoURL)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	names, err := latestParameterNames(project)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	scanner := usagescan.NewScanner(names)
+	var usages []models.ParameterUsage
+	files := 0
+	commit, err := github.WalkRepoFiles(repository.Owner, repository.Name, "", project.RepoApiToken, func(path string, content []byte) error {
+		if usagescan.Skip(path) || len(path) > parameterUsagePathMaxLength {
+			return nil
+		}
+		files++
+		for _, occurrence := range scanner.Scan(strings.ToValidUTF8(path, ""), content) {
+			usages = append(usages, models.ParameterUsage{ProjectID: project.ID, Name: occurrence.Name, Path: occurrence.Path, Line: occurrence.Line, Snippet: occurrence.Snippet})
+		}
+		return nil
+	})
+	if err != nil {
+		return files, 0, commit, err
+	}
+	sort.SliceStable(usages, func(i, j int) bool {
+		if usages[i].Path != usages[j].Path {
+			return usages[i].Path < usages[j].Path
+		}
+		return usages[i].Line < usages[j].Line
+	})
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ?", project.ID).Delete(&models.ParameterUsage{}).Error; err != nil {
+			return err
+		}
+		if len(usages) > 0 {
+			if err := tx.CreateInBatches(&usages, 500).Error; err != nil {
+				return err
+			}
+		}
+		// the UI still reads the usage of each parameter from is_using_at_file
+		byName := usingAtByName(project.RepoURL, commit, usages)
+		for _, name := range names {
+			usingAt := "null"
+			if files, ok := byName[name]; ok {
+				encoded, _ := json.Marshal(files)
+				usingAt = string(encoded)
+			}
+			if err := tx.Model(&models.Parameter{}).
+				Where("project_id = ? AND name = ? AND id IN (?)", project.ID, name,
+					tx.Table("version_parameters").Select("parameter_id").Where("version_id = ?", project.LatestVersionID)).
+				UpdateColumn("is_using_at_file", usingAt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return files, len(usages), commit, err
+}
+
+// scanStaleRepos rescans the repositories of the active projects whose index is older than repoScanMaxAge
+func scanStaleRepos() {
+	var projects []models.Project
+	if err := DB.Where("is_archived = ? AND repo_url <> '' AND repo_api_token <> ''", false).Find(&projects).Error; err != nil {
+		log.Println("Failed to get projects to scan:", err)
+		return
+	}
+	for _, project := range projects {
+		var scan models.RepoScan
+		if DB.Where("project_id = ?", project.ID).First(&scan).Error == nil && scan.Status == models.RepoScanSucceeded && time.Since(scan.FinishedAt) < repoScanMaxAge {
+			continue
+		}
+		if err := claimRepoScan(project.ID); err != nil {
+			continue
+		}
+		runRepoScan(project)
+	}
+}
+
+// RunRepoUsageScanner keeps the index of parameter usages of every project fresh
+func RunRepoUsageScanner() {
+	ticker := time.NewTicker(repoScanInterval)
+	defer ticker.Stop()
+	for {
+		scanStaleRepos()
+		<-ticker.C
+	}
+}
+
+// ScanParameterUsage godoc
+// @Summary Scan the repository for parameter usages
+// @Description Download the repository once and index every line using a parameter name, the scan runs in the background
+// @Tags Project Detail / Parameters
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 202 string {string} json "{"message": "Scan started"}"
+// @Failure 409 string {string} json "{"error": "a scan of the repository is already running"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameters/usage/scan [post]
+func ScanParameterUsage(c *gin.Context) {
+	var project models.Project
+	if err := DB.First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	if project.RepoURL == "" || project.RepoApiToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project has no repository"})
+		return
+	}
+	if err := claimRepoScan(project.ID); err != nil {
+		if errors.Is(err, errRepoScanRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start scan"})
+		}
+		return
+	}
+	go runRepoScan(project)
+	c.JSON(http.StatusAccepted, gin.H{"message": "Scan started"})
+}
+
+// GetParameterUsage godoc
+// @Summary Get parameter usages
+// @Description Get the lines of the repository using each parameter name, from the last scan
+// @Tags Project Detail / Parameters
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param name query string false "Only this parameter name"
+// @Success 200 string {string} json "{"scan": {}, "parameters": []}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameters/usage [get]
+func GetParameterUsage(c *gin.Context) {
+	var scan models.RepoScan
+	if err := DB.Where("project_id = ?", c.Param("project_id")).First(&scan).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{"scan": nil, "parameters": []parameterUsageGroup{}})
+		return
+	}
+	query := DB.Where("project_id = ?", scan.ProjectID).Order("name, path, line")
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name = ?", name)
+	}
+	var usages []models.ParameterUsage
+	if err := query.Find(&usages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameter usages"})
+		return
+	}
+	groups := []parameterUsageGroup{}
+	for _, usage := range usages {
+		if len(groups) == 0 || groups[len(groups)-1].Name != usage.Name {
+			groups = append(groups, parameterUsageGroup{Name: usage.Name})
+		}
+		last := &groups[len(groups)-1]
+		last.Occurrences = append(last.Occurrences, usagescan.Occurrence{Name: usage.Name, Path: usage.Path, Line: usage.Line, Snippet: usage.Snippet})
+	}
+	c.JSON(http.StatusOK, gin.H{"scan": scan, "parameters": groups})
+}
+
+// GetUnusedParameters godoc
+// @Summary Get unused parameters
+// @Description List the parameter names of the latest version found nowhere in the repository at the last scan
+// @Tags Project Detail / Parameters
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 string {string} json "{"scan": {}, "unused": []}"
+// @Failure 409 string {string} json "{"error": "Repository not scanned yet"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/parameters/usage/unused [get]
+func GetUnusedParameters(c *gin.Context) {
+	var project models.Project
+	if err := DB.First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	var scan models.RepoScan
+	if err := DB.Where("project_id = ? AND commit_sha <> ''", project.ID).First(&scan).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Repository not scanned yet"})
+		return
+	}
+	names, err := latestParameterNames(project)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameters"})
+		return
+	}
+	var used []string
+	if err := DB.Model(&models.ParameterUsage{}).Where("project_id = ?", project.ID).Distinct().Pluck("name", &used).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameter usages"})
+		return
+	}
+	usedNames := map[string]bool{}
+	for _, name := range used {
+		usedNames[name] = true
+	}
+	unused := []string{}
+	for _, name := range names {
+		if !usedNames[name] {
+			unused = append(unused, name)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"scan": scan, "unused": unused})
+}
@@ -1,13 +1,149 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 	"parameter-store-be/models"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// VersionChange is a parameter to write in a new version, found by stage, environment and name
+type VersionChange struct {
+	StageID       uint
+	EnvironmentID uint
+	Name          string
+	Value         string
+	Description   string
+	Remove        bool // leave the parameter out of the new version
+	IsPlaintext   bool // mark the parameter plaintext, a false value keeps the flag of an existing parameter
+}
+
+// nextVersionNumber bumps the patch of the latest version number, or adds the suffix when it is not major.minor.patch
+func nextVersionNumber(project models.Project, suffix string) string {
+	var latest models.Version
+	DB.Select("id", "number").First(&latest, project.LatestVersionID)
+	exists := func(number string) bool {
+		var count int64
+		DB.Model(&models.Version{}).Where("project_id = ? AND number = ?", project.ID, number).Count(&count)
+		return count > 0
+	}
+	parts := strings.Split(latest.Number, ".")
+	if len(parts) == 3 {
+		if patch, err := strconv.Atoi(parts[2]); err == nil {
+			for i := patch + 1; i < patch+1000; i++ {
+				number := fmt.Sprintf("%s.%s.%d", parts[0], parts[1], i)
+				if !exists(number) {
+					return number
+				}
+			}
+		}
+	}
+	return fmt.Sprintf("%s-%s", latest.Number, suffix)
+}
+
+// cloneParameter copies a parameter into a new version
+func cloneParameter(param models.Parameter) models.Parameter {
+	return models.Parameter{
+		StageID:               param.StageID,
+		EnvironmentID:         param.EnvironmentID,
+		Name:                  param.Name,
+		Value:                 param.Value,
+		Description:           param.Description,
+		ProjectID:             param.ProjectID,
+		IsArchived:            param.IsArchived,
+		ArchivedBy:            param.ArchivedBy,
+		ArchivedAt:            param.ArchivedAt,
+		IsApplied:             param.IsApplied,
+		EditedAt:              param.EditedAt,
+		IsEnvironmentSpecific: param.IsEnvironmentSpecific,
+		RotationDays:          param.RotationDays,
+		ExpiresAt:             param.ExpiresAt,
+		RotationNotice:        param.RotationNotice,
+		DynamicSourceID:       param.DynamicSourceID,
+		IsDraft:               param.IsDraft,
+		ValueChangedAt:        param.ValueChangedAt,
+		IsPlaintext:           param.IsPlaintext,
+	}
+}
+
+// newVersionFromLatest clones the active parameters of the latest version with the changes applied, the version is not saved yet
+func newVersionFromLatest(project models.Project, number, description string, changes []VersionChange) (models.Version, error) {
+	current, err := versionParameters(project.ID, project.LatestVersionID)
+	if err != nil {
+		return models.Version{}, err
+	}
+	type key struct {
+		stageID, environmentID uint
+		name                   string
+	}
+	pending := map[key]VersionChange{}
+	for _, change := range changes {
+		pending[key{change.StageID, change.EnvironmentID, change.Name}] = change
+	}
+	version := models.Version{ProjectID: project.ID, Number: number, Name: number, Description: description}
+	now := time.Now().UTC()
+	for _, param := range current {
+		newParam := cloneParameter(param)
+		k := key{param.StageID, param.EnvironmentID, param.Name}
+		if change, changed := pending[k]; changed {
+			delete(pending, k)
+			if change.Remove {
+				continue
+			}
+			if newParam.Value != change.Value {
+				newParam.IsApplied = false
+			}
+			newParam.SetValue(change.Value)
+			newParam.Description = change.Description
+			newParam.EditedAt = now
+			if change.IsPlaintext {
+				newParam.IsPlaintext = true
+			}
+		}
+		version.Parameters = append(version.Parameters, newParam)
+	}
+	// the remaining changes are new parameters, in the order they were given
+	for _, change := range changes {
+		if _, ok := pending[key{change.StageID, change.EnvironmentID, change.Name}]; !ok || change.Remove {
+			continue
+		}
+		version.Parameters = append(version.Parameters, models.Parameter{
+			StageID:       change.StageID,
+			EnvironmentID: change.EnvironmentID,
+			Name:          change.Name,
+			Value:         change.Value,
+			Description:   change.Description,
+			ProjectID:     project.ID,
+			EditedAt:      now,
+			IsPlaintext:   change.IsPlaintext,
+		})
+	}
+	return version, nil
+}
+
+// createLatestVersion stores the version with its parameters and makes it the latest of the project
+func createLatestVersion(tx *gorm.DB, project models.Project, version *models.Version) error {
+	if err := tx.Create(version).Error; err != nil {
+		return err
+	}
+	return tx.Model(&project).Update("latest_version_id", version.ID).Error
+}
+
+// emitVersionCreated sends the version.created event
+func emitVersionCreated(projectID uint, version models.Version) {
+	emitProjectEvent(projectID, WebhookEventVersionCreated, gin.H{
+		"id":          version.ID,
+		"number":      version.Number,
+		"description": version.Description,
+		"parameters":  len(version.Parameters),
+	})
+}
+
 // GetProjectVersions godoc
 // @Summary Get versions of project
 // @Description Get versions of project
@@ -95,20 +231,7 @@ func CreateNewVersion(c *gin.Context) {
 		if param.IsArchived {
 			continue
 		}
-		newParam := models.Parameter{
-			StageID:       param.StageID,
-			EnvironmentID: param.EnvironmentID,
-			Name:          param.Name,
-			Value:         param.Value,
-			Description:   param.Description,
-			ProjectID:     param.ProjectID,
-			IsArchived:    param.IsArchived,
-			ArchivedBy:    param.ArchivedBy,
-			ArchivedAt:    param.ArchivedAt,
-			IsApplied:     param.IsApplied,
-			EditedAt:      param.EditedAt,
-		}
-		newVersion.Parameters = append(newVersion.Parameters, newParam)
+		newVersion.Parameters = append(newVersion.Parameters, cloneParameter(param))
 	}
 	if err := DB.Save(&newVersion).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clone parameter to new version"})
@@ -120,5 +243,39 @@ func CreateNewVersion(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project latest version"})
 		return
 	}
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "version.create",
+		TargetType: "version",
+		TargetID:   newVersion.ID,
+		TargetName: newVersion.Number,
+		After:      map[string]interface{}{"number": newVersion.Number, "parameters": len(newVersion.Parameters)},
+	})
+	emitVersionCreated(project.ID, newVersion)
 	c.JSON(http.StatusOK, gin.H{"message": "Version created"})
 }
+
+// GetProjectVersion godoc
+// @Summary Get version of project
+// @Description Get a version of the project with its parameters
+// @Tags Project Detail / Versions
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param version_id path int true "Version ID"
+// @Success 200 {object} models.Version
+// @Failure 404 string {string} json "{"error": "Version not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/versions/{version_id} [get]
+func GetProjectVersion(c *gin.Context) {
+	var version models.Version
+	if err := DB.Preload("Parameters").Where("project_id = ?", c.Param("project_id")).First(&version, c.Param("version_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"version": version})
+}
+
+// versionPath is the API path of a version, kept in project logs
+func versionPath(projectID uint, versionID uint) string {
+	return fmt.Sprintf("/api/v1/projects/%d/versions/%d", projectID, versionID)
+}
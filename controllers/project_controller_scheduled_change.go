@@ -0,0 +1,589 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/notifier"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	scheduledChangeWorkerInterval  = 15 * time.Second
+	scheduledChangeWorkerBatchSize = 10
+	// scheduledChangeClaimLease is how long a claimed change stays with its worker before another one takes it over
+	scheduledChangeClaimLease = 5 * time.Minute
+	// scheduledChangeActor is the name of the scheduler in audit logs
+	scheduledChangeActor = "scheduler"
+)
+
+type scheduledParameterSpec struct {
+	Stage       string `json:"stage" binding:"required"`
+	Environment string `json:"environment" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	Value       string `json:"value"`
+	Description string `json:"description"` // kept when empty
+}
+
+type scheduledChangeRequestBody struct {
+	Kind        string                  `json:"kind" binding:"required"`     // parameter_update or promotion
+	ApplyAt     time.Time               `json:"apply_at" binding:"required"` // RFC 3339
+	RevertAfter string                  `json:"revert_after"`                // duration like 2h or 30m, no revert when empty
+	Description string                  `json:"description"`
+	Parameter   *scheduledParameterSpec `json:"parameter"`
+	Promotion   *promotionRequestBody   `json:"promotion"`
+}
+
+// ScheduledAppliedChange is one value written by a scheduled change, with what was there before
+type ScheduledAppliedChange struct {
+	StageID           uint   `json:"stage_id"`
+	EnvironmentID     uint   `json:"environment_id"`
+	Name              string `json:"name"`
+	Existed           bool   `json:"existed"`
+	Before            string `json:"before"`
+	BeforeDescription string `json:"before_description"`
+	After             string `json:"after"`
+}
+
+// latestParameter finds a parameter of the latest version of the project by its stage, environment and name
+func latestParameter(project models.Project, stageID, environmentID uint, name string) (models.Parameter, error) {
+	var parameter models.Parameter
+	err := DB.Joins("JOIN version_parameters ON version_parameters.parameter_id = parameters.id AND version_parameters.version_id = ?", project.LatestVersionID).
+		Where("parameters.project_id = ? AND parameters.stage_id = ? AND parameters.environment_id = ? AND parameters.name = ? AND parameters.is_archived = ?",
+			project.ID, stageID, environmentID, name, false).
+		First(&parameter).Error
+	return parameter, err
+}
+
+// updateLatestParameter changes a parameter of the latest version in place, like UpdateParameter
+func updateLatestParameter(project models.Project, stageID, environmentID uint, name, value, description string) (models.Parameter, models.Parameter, error) {
+	parameter, err := latestParameter(project, stageID, environmentID, name)
+	if err != nil {
+		return parameter, parameter, err
+	}
+	before := parameter
+	parameter.SetValue(value)
+	if description != "" {
+		parameter.Description = description
+	}
+	parameter.IsApplied = false
+	parameter.EditedAt = time.Now().UTC()
+	err = DB.Save(&parameter).Error
+	return before, parameter, err
+}
+
+// scheduledChangeUser is the user who scheduled the change, named as the scheduler in notifications
+func scheduledChangeUser(change models.ScheduledChange) models.User {
+	user := models.User{Username: change.CreatedBy}
+	DB.First(&user, change.CreatedByID)
+	user.Username += " (scheduled)"
+	return user
+}
+
+// rerunScheduledTargets reruns the workflows of the stages and environments when the project updates automatically
+func rerunScheduledTargets(project models.Project, targets map[[2]uint]bool) string {
+	if !project.AutoUpdate || len(targets) == 0 {
+		return ""
+	}
+	var failures []string
+	for target := range targets {
+		if status, _, message, err := rerunCICDWorkflow(project.ID, target[0], target[1]); err != nil || status >= http.StatusBadRequest {
+			failures = append(failures, message)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Sprintf(", rerun failed: %s", strings.Join(failures, "; "))
+	}
+	return fmt.Sprintf(", %d workflows rerun", len(targets))
+}
+
+// applyScheduledParameterUpdate writes the value of the parameter
+func applyScheduledParameterUpdate(change models.ScheduledChange, project models.Project) ([]ScheduledAppliedChange, map[[2]uint]bool, string, error) {
+	var spec scheduledParameterSpec
+	if err := json.Unmarshal([]byte(change.Spec), &spec); err != nil {
+		return nil, nil, "", err
+	}
+	stageIDs, environmentIDs := activeStageAndEnvironmentIDs(project.ID)
+	stageID, environmentID := stageIDs[spec.Stage], environmentIDs[spec.Environment]
+	before, after, err := updateLatestParameter(project, stageID, environmentID, spec.Name, spec.Value, spec.Description)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, "", fmt.Errorf("parameter %s not found in %s / %s", spec.Name, spec.Stage, spec.Environment)
+		}
+		return nil, nil, "", err
+	}
+	emitProjectEvent(project.ID, WebhookEventParameterUpdated, gin.H{"before": parameterWebhookData(before), "after": parameterWebhookData(after)})
+	notifyParameterChanged(scheduledChangeUser(change), project.ID, "updated", after)
+	applied := []ScheduledAppliedChange{{
+		StageID: stageID, EnvironmentID: environmentID, Name: spec.Name,
+		Existed: true, Before: before.Value, BeforeDescription: before.Description, After: after.Value,
+	}}
+	return applied, map[[2]uint]bool{{stageID, environmentID}: true}, fmt.Sprintf("Updated parameter %s in %s / %s", spec.Name, spec.Stage, spec.Environment), nil
+}
+
+// applyScheduledPromotion promotes the parameters into a new version
+func applyScheduledPromotion(change *models.ScheduledChange, project models.Project) ([]ScheduledAppliedChange, map[[2]uint]bool, string, error) {
+	var body promotionRequestBody
+	if err := json.Unmarshal([]byte(change.Spec), &body); err != nil {
+		return nil, nil, "", err
+	}
+	changes, targetID, err := planProjectPromotion(project, body)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	var versionChanges []VersionChange
+	var applied []ScheduledAppliedChange
+	targets := map[[2]uint]bool{}
+	for _, promotion := range changes {
+		if promotion.Action != promotionActionCreate && promotion.Action != promotionActionUpdate {
+			continue
+		}
+		versionChanges = append(versionChanges, promotion.change)
+		item := ScheduledAppliedChange{StageID: promotion.change.StageID, EnvironmentID: targetID, Name: promotion.change.Name, After: promotion.change.Value}
+		if current, err := latestParameter(project, promotion.change.StageID, targetID, promotion.change.Name); err == nil {
+			item.Existed, item.Before, item.BeforeDescription = true, current.Value, current.Description
+		}
+		applied = append(applied, item)
+		targets[[2]uint{promotion.change.StageID, targetID}] = true
+	}
+	if len(versionChanges) == 0 {
+		return nil, nil, fmt.Sprintf("Nothing to promote from %s to %s", body.SourceEnvironment, body.TargetEnvironment), nil
+	}
+	description := change.Description
+	if description == "" {
+		description = fmt.Sprintf("Scheduled promotion of %s to %s", body.SourceEnvironment, body.TargetEnvironment)
+	}
+	version, err := newVersionFromLatest(project, nextVersionNumber(project, "scheduled."+strconv.FormatInt(time.Now().Unix(), 10)), description, versionChanges)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if err := DB.Transaction(func(tx *gorm.DB) error { return createLatestVersion(tx, project, &version) }); err != nil {
+		return nil, nil, "", err
+	}
+	change.VersionID = version.ID
+	emitVersionCreated(project.ID, version)
+	return applied, targets, fmt.Sprintf("Promoted %d parameters from %s to %s into version %s", len(versionChanges), body.SourceEnvironment, body.TargetEnvironment, version.Number), nil
+}
+
+// PlanScheduledRevert returns the values the revert puts back and how many are skipped: values changed since the change
+// or removed are left alone, parameters created by the change are removed. current finds a parameter of the latest version.
+func PlanScheduledRevert(applied []ScheduledAppliedChange, current func(stageID, environmentID uint, name string) (models.Parameter, bool)) ([]VersionChange, int) {
+	var reverts []VersionChange
+	skipped := 0
+	for _, item := range applied {
+		parameter, ok := current(item.StageID, item.EnvironmentID, item.Name)
+		if !ok || parameter.Value != item.After {
+			skipped++
+			continue
+		}
+		reverts = append(reverts, VersionChange{
+			StageID: item.StageID, EnvironmentID: item.EnvironmentID, Name: item.Name,
+			Value: item.Before, Description: item.BeforeDescription, Remove: !item.Existed,
+		})
+	}
+	return reverts, skipped
+}
+
+// revertScheduledChange puts back the values the change replaced, values changed since are left alone
+func revertScheduledChange(change models.ScheduledChange, project models.Project) (map[[2]uint]bool, string, error) {
+	var applied []ScheduledAppliedChange
+	if err := json.Unmarshal([]byte(change.Applied), &applied); err != nil {
+		return nil, "", err
+	}
+	reverts, skipped := PlanScheduledRevert(applied, func(stageID, environmentID uint, name string) (models.Parameter, bool) {
+		parameter, err := latestParameter(project, stageID, environmentID, name)
+		return parameter, err == nil
+	})
+	targets := map[[2]uint]bool{}
+	var versionChanges []VersionChange
+	for _, revert := range reverts {
+		targets[[2]uint{revert.StageID, revert.EnvironmentID}] = true
+		if change.Kind == models.ScheduledChangeParameterUpdate {
+			before, after, err := updateLatestParameter(project, revert.StageID, revert.EnvironmentID, revert.Name, revert.Value, revert.Description)
+			if err != nil {
+				return nil, "", err
+			}
+			emitProjectEvent(project.ID, WebhookEventParameterUpdated, gin.H{"before": parameterWebhookData(before), "after": parameterWebhookData(after)})
+			notifyParameterChanged(scheduledChangeUser(change), project.ID, "reverted", after)
+			continue
+		}
+		versionChanges = append(versionChanges, revert)
+	}
+	if len(versionChanges) > 0 {
+		version, err := newVersionFromLatest(project, nextVersionNumber(project, "revert."+strconv.FormatInt(time.Now().Unix(), 10)), "Revert of scheduled change "+strconv.Itoa(int(change.ID)), versionChanges)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := DB.Transaction(func(tx *gorm.DB) error { return createLatestVersion(tx, project, &version) }); err != nil {
+			return nil, "", err
+		}
+		emitVersionCreated(project.ID, version)
+	}
+	message := fmt.Sprintf("Reverted %d parameters", len(applied)-skipped)
+	if skipped > 0 {
+		message += fmt.Sprintf(", %d changed since or removed were kept", skipped)
+	}
+	return targets, message, nil
+}
+
+// runScheduledChange applies or reverts a claimed change and records the outcome
+func runScheduledChange(change models.ScheduledChange, revert bool) {
+	startTime := time.Now()
+	action, logAction := "scheduled_change.apply", "Apply Scheduled Change"
+	if revert {
+		action, logAction = "scheduled_change.revert", "Revert Scheduled Change"
+	}
+	var project models.Project
+	err := DB.First(&project, change.ProjectID).Error
+	if err == nil && project.IsArchived {
+		err = errors.New("project is archived")
+	}
+	var targets map[[2]uint]bool
+	var message string
+	updates := map[string]interface{}{}
+	if err == nil && revert {
+		targets, message, err = revertScheduledChange(change, project)
+		if err == nil {
+			updates["status"], updates["reverted_at"] = models.ScheduledChangeReverted, time.Now()
+		}
+	} else if err == nil {
+		var applied []ScheduledAppliedChange
+		if change.Kind == models.ScheduledChangePromotion {
+			applied, targets, message, err = applyScheduledPromotion(&change, project)
+		} else {
+			applied, targets, message, err = applyScheduledParameterUpdate(change, project)
+		}
+		if err == nil {
+			encoded, _ := json.Marshal(applied)
+			updates["applied"], updates["applied_at"], updates["version_id"] = string(encoded), time.Now(), change.VersionID
+			updates["status"] = models.ScheduledChangeApplied
+			if change.RevertAfter > 0 && len(applied) > 0 {
+				updates["status"], updates["revert_at"] = models.ScheduledChangeActive, time.Now().Add(time.Duration(change.RevertAfter)*time.Second)
+			}
+		}
+	}
+	status := http.StatusOK
+	if err != nil {
+		status, message = http.StatusInternalServerError, "Failed: "+err.Error()
+		updates["status"] = models.ScheduledChangeFailed
+	} else {
+		message = "Succeed: " + message + rerunScheduledTargets(project, targets)
+	}
+	updates["message"] = message
+	claimed := models.ScheduledChangeApplying
+	if revert {
+		claimed = models.ScheduledChangeReverting
+	}
+	if err := DB.Model(&models.ScheduledChange{}).Where("id = ? AND status = ?", change.ID, claimed).Updates(updates).Error; err != nil {
+		log.Println("Failed to save scheduled change:", err)
+	}
+	projectLogByUser(change.ProjectID, logAction, message, status, time.Since(startTime), change.CreatedByID)
+	auditBySystem(nil, project.OrganizationID, scheduledChangeActor, auditEntry{
+		ProjectID:  change.ProjectID,
+		Action:     action,
+		TargetType: "scheduled_change",
+		TargetID:   change.ID,
+		TargetName: change.Kind,
+		After:      map[string]interface{}{"message": message, "created_by": change.CreatedBy, "version_id": change.VersionID},
+		Status:     status,
+	})
+	if err != nil {
+		notifyProject(change.ProjectID, NotificationEventParameterChanged, notifier.Message{
+			Title: fmt.Sprintf("[%s] Scheduled change %d failed", project.Name, change.ID),
+			Text:  message,
+			Level: notifier.LevelFailure,
+			Fields: []notifier.Field{
+				{Name: "Kind", Value: change.Kind},
+				{Name: "Scheduled by", Value: change.CreatedBy},
+			},
+		})
+	}
+}
+
+// processScheduledChanges claims the changes due to be applied or reverted and runs them, it returns the batch size.
+// The claim moves the change to applying or reverting, which a cancel does not match anymore.
+func processScheduledChanges() int {
+	var changes []models.ScheduledChange
+	now := time.Now()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND apply_at <= ?) OR (status = ? AND revert_at <= ?) OR (status IN ? AND claimed_until <= ?)",
+				models.ScheduledChangeScheduled, now, models.ScheduledChangeActive, now,
+				[]string{models.ScheduledChangeApplying, models.ScheduledChangeReverting}, now).
+			Order("id asc").Limit(scheduledChangeWorkerBatchSize).
+			Find(&changes).Error; err != nil {
+			return err
+		}
+		for i, change := range changes {
+			claimed := models.ScheduledChangeApplying
+			if change.Status == models.ScheduledChangeActive || change.Status == models.ScheduledChangeReverting {
+				claimed = models.ScheduledChangeReverting
+			}
+			if err := tx.Model(&models.ScheduledChange{}).Where("id = ?", change.ID).
+				Updates(map[string]interface{}{"status": claimed, "claimed_until": now.Add(scheduledChangeClaimLease)}).Error; err != nil {
+				return err
+			}
+			changes[i].Status = claimed
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Failed to claim scheduled changes:", err)
+		return 0
+	}
+	for _, change := range changes {
+		runScheduledChange(change, change.Status == models.ScheduledChangeReverting)
+	}
+	return len(changes)
+}
+
+// RunScheduledChangeWorker applies the scheduled changes when they are due and reverts them after their duration
+func RunScheduledChangeWorker() {
+	ticker := time.NewTicker(scheduledChangeWorkerInterval)
+	defer ticker.Stop()
+	for {
+		for processScheduledChanges() == scheduledChangeWorkerBatchSize {
+		}
+		<-ticker.C
+	}
+}
+
+// GetScheduledChanges godoc
+// @Summary List scheduled changes
+// @Description List the scheduled parameter updates and promotions of the project, the latest first
+// @Tags Project Detail / Scheduled Changes
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param status query string false "scheduled, applying, active, reverting, applied, reverted, cancelled or failed"
+// @Success 200 {array} models.ScheduledChange
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/scheduled-changes [get]
+func GetScheduledChanges(c *gin.Context) {
+	project, _, ok := projectOfUser(c, false)
+	if !ok {
+		return
+	}
+	query := DB.Where("project_id = ?", project.ID).Order("id desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var changes []models.ScheduledChange
+	if err := query.Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get scheduled changes"})
+		return
+	}
+	type scheduledChangeResponse struct {
+		models.ScheduledChange
+		Spec json.RawMessage `json:"spec"`
+	}
+	// the values are masked like the parameters they update, those not created yet are secret
+	parameters, err := versionParameters(project.ID, project.LatestVersionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameters"})
+		return
+	}
+	plaintext := map[string]bool{}
+	for _, parameter := range parameters {
+		plaintext[parameterKey(parameter.Stage.Name, parameter.Environment.Name, parameter.Name)] = parameter.IsPlaintext
+	}
+	response := make([]scheduledChangeResponse, 0, len(changes))
+	for _, change := range changes {
+		spec := change.Spec
+		if change.Kind == models.ScheduledChangeParameterUpdate {
+			var parameter scheduledParameterSpec
+			if json.Unmarshal([]byte(spec), &parameter) == nil {
+				parameter.Value = maskValue(parameter.Value, plaintext[parameterKey(parameter.Stage, parameter.Environment, parameter.Name)])
+				encoded, _ := json.Marshal(parameter)
+				spec = string(encoded)
+			}
+		}
+		response = append(response, scheduledChangeResponse{ScheduledChange: change, Spec: json.RawMessage(spec)})
+	}
+	c.JSON(http.StatusOK, gin.H{"scheduled_changes": response})
+}
+
+// CreateScheduledChange godoc
+// @Summary Schedule a change
+// @Description Schedule a parameter update or a promotion between environments at apply_at, reverted after revert_after when it is set.
+// @Description The scheduler applies it like a change made by hand, workflows are rerun when the project updates automatically.
+// @Tags Project Detail / Scheduled Changes
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param Change body controllers.scheduledChangeRequestBody true "Change"
+// @Success 201 {object} models.ScheduledChange
+// @Failure 400 string {string} json "{"error": "apply_at must be in the future"}"
+// @Failure 404 string {string} json "{"error": "Parameter not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/scheduled-changes [post]
+func CreateScheduledChange(c *gin.Context) {
+	startTime := time.Now()
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var body scheduledChangeRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !body.ApplyAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "apply_at must be in the future"})
+		return
+	}
+	var revertAfter time.Duration
+	if body.RevertAfter != "" {
+		if revertAfter, err = time.ParseDuration(body.RevertAfter); err != nil || revertAfter < time.Minute {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "revert_after must be a duration of at least 1m, like 30m or 2h"})
+			return
+		}
+	}
+	var project models.Project
+	if err := DB.First(&project, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	if project.IsArchived {
+		c.JSON(http.StatusConflict, gin.H{"error": "Project is archived"})
+		return
+	}
+
+	var spec interface{}
+	targetName := ""
+	switch body.Kind {
+	case models.ScheduledChangeParameterUpdate:
+		if body.Parameter == nil || body.Parameter.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parameter is required for a parameter update"})
+			return
+		}
+		stageIDs, environmentIDs := activeStageAndEnvironmentIDs(project.ID)
+		if _, err := latestParameter(project, stageIDs[body.Parameter.Stage], environmentIDs[body.Parameter.Environment], body.Parameter.Name); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Parameter %s not found in %s / %s", body.Parameter.Name, body.Parameter.Stage, body.Parameter.Environment)})
+			return
+		}
+		spec, targetName = body.Parameter, body.Parameter.Name
+	case models.ScheduledChangePromotion:
+		if body.Promotion == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "promotion is required for a promotion"})
+			return
+		}
+		if msg := validatePromotionBody(*body.Promotion); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if _, _, err := planProjectPromotion(project, *body.Promotion); errors.Is(err, errPromotionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		body.Promotion.DryRun = false
+		spec, targetName = body.Promotion, body.Promotion.SourceEnvironment+" -> "+body.Promotion.TargetEnvironment
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be parameter_update or promotion"})
+		return
+	}
+	encoded, _ := json.Marshal(spec)
+	change := models.ScheduledChange{
+		ProjectID:   project.ID,
+		CreatedByID: user.ID,
+		CreatedBy:   user.Username,
+		Kind:        body.Kind,
+		Description: body.Description,
+		Status:      models.ScheduledChangeScheduled,
+		ApplyAt:     body.ApplyAt,
+		RevertAfter: int64(revertAfter.Seconds()),
+		Spec:        string(encoded),
+	}
+	if err := DB.Create(&change).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule change"})
+		return
+	}
+	message := fmt.Sprintf("Succeed: Scheduled %s of %s at %s", change.Kind, targetName, change.ApplyAt.UTC().Format(time.RFC3339))
+	if revertAfter > 0 {
+		message += ", reverted after " + revertAfter.String()
+	}
+	projectLogByUser(project.ID, "Schedule Change", message, http.StatusCreated, time.Since(startTime), user.ID)
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "scheduled_change.create",
+		TargetType: "scheduled_change",
+		TargetID:   change.ID,
+		TargetName: targetName,
+		After:      map[string]interface{}{"kind": change.Kind, "apply_at": change.ApplyAt, "revert_after": change.RevertAfter},
+		Status:     http.StatusCreated,
+	})
+	c.JSON(http.StatusCreated, gin.H{"scheduled_change": change})
+}
+
+// CancelScheduledChange godoc
+// @Summary Cancel a scheduled change
+// @Description Cancel a change that is not applied yet, or the pending revert of an applied change
+// @Tags Project Detail / Scheduled Changes
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param change_id path int true "Scheduled change ID"
+// @Success 200 string {string} json "{"message": "Scheduled change cancelled"}"
+// @Failure 404 string {string} json "{"error": "Scheduled change not found"}"
+// @Failure 409 string {string} json "{"error": "Scheduled change is already applied"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/scheduled-changes/{change_id} [delete]
+func CancelScheduledChange(c *gin.Context) {
+	startTime := time.Now()
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var change models.ScheduledChange
+	if err := DB.Where("project_id = ?", c.Param("project_id")).First(&change, c.Param("change_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled change not found"})
+		return
+	}
+	var updates map[string]interface{}
+	message := "Scheduled change cancelled"
+	switch change.Status {
+	case models.ScheduledChangeScheduled:
+		updates = map[string]interface{}{"status": models.ScheduledChangeCancelled, "cancelled_by": user.Username}
+	case models.ScheduledChangeActive:
+		updates = map[string]interface{}{"status": models.ScheduledChangeApplied, "cancelled_by": user.Username}
+		message = "Revert of scheduled change cancelled"
+	case models.ScheduledChangeApplying, models.ScheduledChangeReverting:
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheduled change is " + change.Status})
+		return
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheduled change is already " + change.Status})
+		return
+	}
+	// a worker claiming the change meanwhile moved it to applying or reverting, the status condition then matches nothing
+	result := DB.Model(&models.ScheduledChange{}).Where("id = ? AND status = ?", change.ID, change.Status).Updates(updates)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel scheduled change"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheduled change is being applied"})
+		return
+	}
+	projectLogByUser(change.ProjectID, "Cancel Scheduled Change", "Succeed: "+message, http.StatusOK, time.Since(startTime), user.ID)
+	auditByUser(c, auditEntry{
+		ProjectID:  change.ProjectID,
+		Action:     "scheduled_change.cancel",
+		TargetType: "scheduled_change",
+		TargetID:   change.ID,
+		TargetName: change.Kind,
+		Before:     map[string]interface{}{"status": change.Status},
+		After:      updates,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/signedtoken"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// Events only sent to the live stream, the others are the webhook events
+const (
+	StreamEventAgentLog      = "agent.log"
+	StreamEventWorkflowState = "workflow.state"
+	streamEventReset         = "stream.reset"
+)
+
+const (
+	// streamPollInterval picks up events stored by other instances of the server
+	streamPollInterval      = 3 * time.Second
+	streamHeartbeatInterval = 15 * time.Second
+	streamBatchSize         = 200
+	// ProjectEventRetention is how long a disconnected client can resume from its last event ID
+	ProjectEventRetention = 24 * time.Hour
+)
+
+// projectEventBroker wakes the streams of a project when this instance stores one of its events
+type projectEventBroker struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan struct{}]struct{}
+}
+
+var projectEvents = &projectEventBroker{subscribers: map[uint]map[chan struct{}]struct{}{}}
+
+func (b *projectEventBroker) subscribe(projectID uint) chan struct{} {
+	wake := make(chan struct{}, 1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers[projectID] == nil {
+		b.subscribers[projectID] = map[chan struct{}]struct{}{}
+	}
+	b.subscribers[projectID][wake] = struct{}{}
+	return wake
+}
+
+func (b *projectEventBroker) unsubscribe(projectID uint, wake chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers[projectID], wake)
+	if len(b.subscribers[projectID]) == 0 {
+		delete(b.subscribers, projectID)
+	}
+}
+
+func (b *projectEventBroker) notify(projectID uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for wake := range b.subscribers[projectID] {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// streamProjectEvent stores the event for the live stream of the project and wakes its listeners
+func streamProjectEvent(projectID uint, event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Println("Failed to marshal project event:", err)
+		return
+	}
+	projectEvent := models.ProjectEvent{ProjectID: projectID, Event: event, Data: string(payload)}
+	if err := DB.Create(&projectEvent).Error; err != nil {
+		log.Println("Failed to store project event:", err)
+		return
+	}
+	projectEvents.notify(projectID)
+}
+
+// RunProjectEventRetention deletes stream events older than ProjectEventRetention every hour,
+// the newest deleted event of each project is recorded first so resuming clients know what they missed
+func RunProjectEventRetention() {
+	for {
+		pruneProjectEvents(time.Now().Add(-ProjectEventRetention))
+		time.Sleep(time.Hour)
+	}
+}
+
+func pruneProjectEvents(before time.Time) {
+	var pruned []models.ProjectEventPrune
+	err := DB.Model(&models.ProjectEvent{}).Select("project_id, MAX(id) AS last_pruned_id").
+		Where("created_at < ?", before).Group("project_id").Scan(&pruned).Error
+	if err != nil {
+		log.Println("Failed to read expired project events:", err)
+		return
+	}
+	for _, prune := range pruned {
+		err := DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "project_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"last_pruned_id", "updated_at"}),
+		}).Create(&prune).Error
+		if err != nil {
+			log.Println("Failed to record expired project events:", err)
+			continue
+		}
+		DB.Where("project_id = ? AND id <= ?", prune.ProjectID, prune.LastPrunedID).Delete(&models.ProjectEvent{})
+	}
+}
+
+// StreamMissedEvents tells if a client resuming after lastID missed events of the project deleted by the retention
+func StreamMissedEvents(lastPrunedID uint, lastID uint) bool {
+	return lastPrunedID > lastID
+}
+
+func writeServerSentEvent(c *gin.Context, id uint, event string, data string) {
+	fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+}
+
+// sendPendingProjectEvents writes the events after lastID, it returns the ID of the last event written
+func sendPendingProjectEvents(c *gin.Context, projectID uint, lastID uint) uint {
+	for {
+		var events []models.ProjectEvent
+		if err := DB.Where("project_id = ? AND id > ?", projectID, lastID).Order("id asc").Limit(streamBatchSize).Find(&events).Error; err != nil {
+			log.Println("Failed to read project events:", err)
+			return lastID
+		}
+		for _, event := range events {
+			data, _ := json.Marshal(gin.H{"created_at": event.CreatedAt, "data": json.RawMessage(event.Data)})
+			writeServerSentEvent(c, event.ID, event.Event, string(data))
+			lastID = event.ID
+		}
+		if len(events) > 0 {
+			c.Writer.Flush()
+		}
+		if len(events) < streamBatchSize {
+			return lastID
+		}
+	}
+}
+
+// StreamProjectEvents godoc
+// @Summary Stream project events
+// @Description Server-sent events of parameter changes, version creation, agent pulls (agent.log) and workflow state transitions (workflow.state) of project.
+// @Description Every event has an id, a reconnecting client sends the last one in the Last-Event-ID header (or last_event_id query) to receive what it missed.
+// @Description Events are kept 24 hours, a stream.reset event tells the client to reload when older events are gone.
+// @Description The browser EventSource cannot set headers, it passes a ticket from POST /events/ticket in the ticket query instead.
+// @Description A ticket opens one stream, a reconnecting client gets a new ticket and resumes with the last_event_id query.
+// @Tags Project Detail / Events
+// @Produce text/event-stream
+// @Param project_id path int true "Project ID"
+// @Param last_event_id query int false "Resume after this event ID"
+// @Param ticket query string false "Single-use stream ticket, when the Authorization header cannot be set"
+// @Success 200 string {string} text "id: 1\nevent: parameter.updated\ndata: {...}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/events [get]
+func StreamProjectEvents(c *gin.Context) {
+	// a ticket is checked again, the user may have left the project since it was issued
+	project, _, ok := projectOfUser(c, false)
+	if !ok {
+		return
+	}
+	projectID := project.ID
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var lastID uint
+	if lastEventID != "" {
+		parsed, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last event ID"})
+			return
+		}
+		lastID = uint(parsed)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // nginx must not buffer the stream
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+
+	var newestID uint
+	DB.Model(&models.ProjectEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&newestID)
+	if lastEventID == "" {
+		// a new client loads the current state through the API, it only needs what happens next
+		lastID = newestID
+	} else {
+		// the events of other projects are pruned too, only those of this project matter
+		var prune models.ProjectEventPrune
+		DB.Where("project_id = ?", projectID).Limit(1).Find(&prune)
+		if StreamMissedEvents(prune.LastPrunedID, lastID) {
+			data, _ := json.Marshal(gin.H{"reason": "Events after the last event ID have expired, reload the project"})
+			writeServerSentEvent(c, newestID, streamEventReset, string(data))
+			lastID = newestID
+		}
+	}
+	c.Writer.Flush()
+
+	wake := projectEvents.subscribe(projectID)
+	defer projectEvents.unsubscribe(projectID, wake)
+	poll := time.NewTicker(streamPollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		lastID = sendPendingProjectEvents(c, projectID, lastID)
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-wake:
+		case <-poll.C:
+		case <-heartbeat.C:
+			// comment lines keep proxies from closing an idle stream
+			fmt.Fprint(c.Writer, ": keepalive\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// streamTicketTTL is how long a stream ticket waits to be used
+const streamTicketTTL = time.Minute
+
+// ConsumeStreamTicket returns the user of a stream ticket for the project, the ticket can not be used again
+func ConsumeStreamTicket(ticket, projectID string) (models.User, error) {
+	record, err := consumeUserToken(ticket, models.TokenPurposeStreamTicket)
+	if err != nil {
+		return models.User{}, err
+	}
+	if strconv.FormatUint(uint64(record.ProjectID), 10) != projectID {
+		return models.User{}, errors.New("Ticket is for another project")
+	}
+	var user models.User
+	if err := DB.First(&user, record.UserID).Error; err != nil {
+		return models.User{}, errors.New("User not found")
+	}
+	return user, nil
+}
+
+// CreateStreamTicket godoc
+// @Summary Create a stream ticket
+// @Description Return a single-use ticket opening the event stream of project within a minute, for clients that can not set the Authorization header.
+// @Description Session tokens are never accepted in the query, URLs end up in access logs, proxies and browser history.
+// @Tags Project Detail / Events
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 201 string {string} json "{"ticket": "...", "expires_in": 60}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/events/ticket [post]
+func CreateStreamTicket(c *gin.Context) {
+	project, user, ok := projectOfUser(c, false)
+	if !ok {
+		return
+	}
+	ticket, hash, err := signedtoken.Generate(os.Getenv("SECRET_KEY"), models.TokenPurposeStreamTicket)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stream ticket"})
+		return
+	}
+	record := models.Token{
+		UserID:    user.ID,
+		Token:     hash,
+		Purpose:   models.TokenPurposeStreamTicket,
+		ProjectID: project.ID,
+		ExpiresAt: time.Now().Add(streamTicketTTL),
+	}
+	if err := DB.Create(&record).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stream ticket"})
+		return
+	}
+	// drop the tickets of past streams
+	DB.Unscoped().Where("purpose = ? AND expires_at < ?", models.TokenPurposeStreamTicket, time.Now().Add(-time.Hour)).Delete(&models.Token{})
+	c.JSON(http.StatusCreated, gin.H{"ticket": ticket, "expires_in": int(streamTicketTTL.Seconds())})
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"net/http"
+	"strconv"
 
 	"parameter-store-be/models"
 
@@ -15,6 +16,8 @@ import (
 // @Accept json
 // @Produce json
 // @Param project_id path string true "Project ID"
+// @Param page query int false "Page"
+// @Param limit query int false "Limit of each log list"
 // @Success 200 string {string} json "{"tracking": "tracking"}"
 // @Failure 400 string {string} json "{"error": "Bad request"}"
 // @Failure 500 string {string} json "{"error": "Failed to get project tracking"}"
@@ -49,17 +52,25 @@ func GetProjectTracking(c *gin.Context) {
 	// Retrieve tracking from the database using the project ID
 	var agentLogs []models.AgentLog
 	var projectLogs []models.ProjectLog
+	agentQuery := DB.Preload("Agent").Where("project_id = ?", projectID)
+	projectQuery := DB.Preload("User").Where("project_id = ?", projectID)
 	if from != "" && to != "" {
 
 		from := startOfDay(c.Query("from"))
 		to := endOfDay(c.Query("to"))
 
-		DB.Preload("Agent").Where("project_id = ? AND created_at BETWEEN ? AND ?", projectID, from, to).Find(&agentLogs)
-		DB.Preload("User").Where("project_id = ? AND created_at BETWEEN ? AND ?", projectID, from, to).Find(&projectLogs)
-	} else {
-		DB.Preload("Agent").Where("project_id = ?", projectID).Find(&agentLogs)
-		DB.Preload("User").Where("project_id = ?", projectID).Find(&projectLogs)
+		agentQuery = agentQuery.Where("created_at BETWEEN ? AND ?", from, to)
+		projectQuery = projectQuery.Where("created_at BETWEEN ? AND ?", from, to)
 	}
+	// page and limit apply to each list, without them every log is returned
+	page, errPage := strconv.Atoi(c.Query("page"))
+	limit, errLimit := strconv.Atoi(c.Query("limit"))
+	if errPage == nil && errLimit == nil && page > 0 && limit > 0 {
+		agentQuery = agentQuery.Order("created_at desc").Offset((page - 1) * limit).Limit(limit)
+		projectQuery = projectQuery.Order("created_at desc").Offset((page - 1) * limit).Limit(limit)
+	}
+	agentQuery.Find(&agentLogs)
+	projectQuery.Find(&projectLogs)
 	// fmt.Println(agentLogs)
 	// fmt.Println(projectLogs)
 	// Combine agentLogs and projectLogs
@@ -0,0 +1,514 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/webhook"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Events sent to project webhooks
+const (
+	WebhookEventParameterCreated    = "parameter.created"
+	WebhookEventParameterUpdated    = "parameter.updated"
+	WebhookEventParameterArchived   = "parameter.archived"
+	WebhookEventParameterUnarchived = "parameter.unarchived"
+	WebhookEventVersionCreated      = "version.created"
+	WebhookEventApplyStarted        = "apply.started"
+	WebhookEventWorkflowCompleted   = "workflow.completed"
+	WebhookEventParameterSetUpdated = "parameter_set.updated"
+	webhookEventPing                = "ping"
+)
+
+var webhookEvents = []string{
+	WebhookEventParameterCreated,
+	WebhookEventParameterUpdated,
+	WebhookEventParameterArchived,
+	WebhookEventParameterUnarchived,
+	WebhookEventVersionCreated,
+	WebhookEventApplyStarted,
+	WebhookEventWorkflowCompleted,
+	WebhookEventParameterSetUpdated,
+}
+
+const (
+	webhookMaxAttempts     = 6
+	webhookRetryBase       = 30 * time.Second
+	webhookWorkerInterval  = 5 * time.Second
+	webhookWorkerBatchSize = 20
+	// webhookClaimLease hides a claimed delivery from other workers while it is being sent
+	webhookClaimLease = 2 * time.Minute
+)
+
+// webhookWake lets a new delivery be sent right away instead of at the next tick
+var webhookWake = make(chan struct{}, 1)
+
+type webhookRequestBody struct {
+	URL          string   `json:"url" binding:"required"`
+	Events       []string `json:"events" binding:"required"`
+	Description  string   `json:"description"`
+	IsActive     *bool    `json:"is_active"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+// validateWebhookBody checks the url and events, it returns the events as stored
+func validateWebhookBody(body webhookRequestBody) (string, string) {
+	if err := webhook.ValidateURL(body.URL); err != nil {
+		return "", err.Error()
+	}
+	if len(body.Events) == 0 {
+		return "", "events must not be empty"
+	}
+	for _, event := range body.Events {
+		if event != "*" && !isIn(webhookEvents, event) {
+			return "", fmt.Sprintf("unknown event %s, events are %s or *", event, strings.Join(webhookEvents, ", "))
+		}
+	}
+	return strings.Join(body.Events, ","), ""
+}
+
+// webhookSubscribes tells if the webhook wants the event
+func webhookSubscribes(hook models.Webhook, event string) bool {
+	events := strings.Split(hook.Events, ",")
+	return isIn(events, "*") || isIn(events, event)
+}
+
+// parameterWebhookData is the data of parameter events, secret values are masked
+func parameterWebhookData(parameter models.Parameter) map[string]interface{} {
+	data := parameterAuditSnapshot(parameter)
+	data["id"] = parameter.ID
+	return data
+}
+
+// emitProjectEvent pushes the event to the live stream and queues a delivery to every active webhook of the project subscribed to it
+func emitProjectEvent(projectID uint, event string, data interface{}) {
+	streamProjectEvent(projectID, event, data)
+	var hooks []models.Webhook
+	if err := DB.Where("project_id = ? AND is_active = ?", projectID, true).Find(&hooks).Error; err != nil || len(hooks) == 0 {
+		return
+	}
+	var project models.Project
+	DB.Select("id", "name").First(&project, projectID)
+	for _, hook := range hooks {
+		if webhookSubscribes(hook, event) {
+			queueWebhookDelivery(hook, project, event, data)
+		}
+	}
+}
+
+func queueWebhookDelivery(hook models.Webhook, project models.Project, event string, data interface{}) (models.WebhookDelivery, error) {
+	deliveryID, err := webhook.NewDeliveryID()
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	payload, err := json.Marshal(gin.H{
+		"id":         deliveryID,
+		"event":      event,
+		"created_at": time.Now().UTC(),
+		"project":    gin.H{"id": project.ID, "name": project.Name},
+		"data":       data,
+	})
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	delivery := models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		ProjectID:     hook.ProjectID,
+		DeliveryID:    deliveryID,
+		Event:         event,
+		Payload:       string(payload),
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := DB.Create(&delivery).Error; err != nil {
+		log.Println("Failed to queue webhook delivery:", err)
+		return delivery, err
+	}
+	wakeWebhookWorker()
+	return delivery, nil
+}
+
+func wakeWebhookWorker() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// RunWebhookDeliveryWorker sends the due deliveries, failed attempts are retried with exponential backoff
+func RunWebhookDeliveryWorker() {
+	ticker := time.NewTicker(webhookWorkerInterval)
+	defer ticker.Stop()
+	for {
+		for processWebhookDeliveries() == webhookWorkerBatchSize {
+		}
+		select {
+		case <-ticker.C:
+		case <-webhookWake:
+		}
+	}
+}
+
+// processWebhookDeliveries claims a batch of due deliveries and sends them, it returns the batch size
+func processWebhookDeliveries() int {
+	var deliveries []models.WebhookDelivery
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, time.Now()).
+			Order("next_attempt_at asc").Limit(webhookWorkerBatchSize).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+		var ids []uint
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(webhookClaimLease)).Error
+	})
+	if err != nil {
+		log.Println("Failed to claim webhook deliveries:", err)
+		return 0
+	}
+	for _, delivery := range deliveries {
+		attemptWebhookDelivery(delivery)
+	}
+	return len(deliveries)
+}
+
+func attemptWebhookDelivery(delivery models.WebhookDelivery) {
+	var hook models.Webhook
+	if err := DB.First(&hook, delivery.WebhookID).Error; err != nil || !hook.IsActive {
+		DB.Model(&delivery).Updates(map[string]interface{}{"status": models.WebhookDeliveryFailed, "error": "Webhook is deleted or inactive"})
+		return
+	}
+	result, err := webhook.Deliver(hook.URL, hook.Secret, delivery.Event, delivery.DeliveryID, []byte(delivery.Payload))
+	attempts := delivery.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"response_status": result.StatusCode,
+		"response_body":   result.Body,
+		"duration":        int(result.Duration.Milliseconds()),
+		"error":           "",
+	}
+	switch {
+	case err == nil && result.StatusCode >= 200 && result.StatusCode < 300:
+		updates["status"] = models.WebhookDeliverySucceeded
+		updates["delivered_at"] = time.Now()
+	case attempts >= webhookMaxAttempts:
+		updates["status"] = models.WebhookDeliveryFailed
+	default:
+		// 30s, 1m, 2m, 4m, 8m
+		updates["next_attempt_at"] = time.Now().Add(webhookRetryBase * time.Duration(math.Pow(2, float64(attempts-1))))
+	}
+	if err != nil {
+		updates["error"] = err.Error()
+	} else if result.StatusCode >= 300 {
+		updates["error"] = fmt.Sprintf("Receiver responded %d", result.StatusCode)
+	}
+	DB.Model(&delivery).Updates(updates)
+}
+
+// findProjectWebhook loads a webhook of the project in the path
+func findProjectWebhook(c *gin.Context) (models.Webhook, bool) {
+	var hook models.Webhook
+	if err := DB.Where("project_id = ?", c.Param("project_id")).First(&hook, c.Param("webhook_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return hook, false
+	}
+	return hook, true
+}
+
+// ListWebhooks godoc
+// @Summary List webhooks
+// @Description List webhooks of project, secrets are not returned
+// @Tags Project Detail / Webhooks
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 {array} models.Webhook
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/webhooks [get]
+func ListWebhooks(c *gin.Context) {
+	var hooks []models.Webhook
+	DB.Where("project_id = ?", c.Param("project_id")).Order("id asc").Find(&hooks)
+	c.JSON(http.StatusOK, gin.H{"webhooks": hooks, "events": webhookEvents})
+}
+
+// CreateWebhook godoc
+// @Summary Create webhook
+// @Description Subscribe a URL to events of project. Deliveries are signed with HMAC-SHA256 of "<timestamp>.<body>" in the X-Parameter-Store-Signature header. The secret is shown only in this response.
+// @Description The URL must resolve to public addresses, redirects are not followed and only a short excerpt of the response is kept.
+// @Tags Project Detail / Webhooks
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param Webhook body controllers.webhookRequestBody true "Webhook"
+// @Success 201 string {string} json "{"webhook": {}, "secret": "whsec_..."}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 500 string {string} json "{"error": "Failed to create webhook"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/webhooks [post]
+func CreateWebhook(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("project_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	var body webhookRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events, msg := validateWebhookBody(body)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	hook := models.Webhook{
+		ProjectID:   uint(projectID),
+		URL:         body.URL,
+		Secret:      secret,
+		Events:      events,
+		Description: body.Description,
+		IsActive:    true,
+	}
+	if body.IsActive != nil {
+		hook.IsActive = *body.IsActive
+	}
+	if err := DB.Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	// is_active defaults to true in the table, write it when switched off
+	DB.Model(&hook).Select("is_active").Updates(&hook)
+	auditByUser(c, auditEntry{
+		ProjectID:  hook.ProjectID,
+		Action:     "webhook.create",
+		TargetType: "webhook",
+		TargetID:   hook.ID,
+		TargetName: hook.URL,
+		After:      map[string]interface{}{"events": hook.Events, "is_active": hook.IsActive},
+		Status:     http.StatusCreated,
+	})
+	c.JSON(http.StatusCreated, gin.H{"webhook": hook, "secret": secret})
+}
+
+// UpdateWebhook godoc
+// @Summary Update webhook
+// @Description Update url, events and state of a webhook. With rotate_secret a new secret is returned.
+// @Tags Project Detail / Webhooks
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param webhook_id path int true "Webhook ID"
+// @Param Webhook body controllers.webhookRequestBody true "Webhook"
+// @Success 200 {object} models.Webhook
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 404 string {string} json "{"error": "Webhook not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/webhooks/{webhook_id} [put]
+func UpdateWebhook(c *gin.Context) {
+	hook, ok := findProjectWebhook(c)
+	if !ok {
+		return
+	}
+	var body webhookRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events, msg := validateWebhookBody(body)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	before := map[string]interface{}{"url": hook.URL, "events": hook.Events, "is_active": hook.IsActive}
+	hook.URL = body.URL
+	hook.Events = events
+	hook.Description = body.Description
+	if body.IsActive != nil {
+		hook.IsActive = *body.IsActive
+	}
+	response := gin.H{}
+	if body.RotateSecret {
+		secret, err := webhook.GenerateSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+			return
+		}
+		hook.Secret = secret
+		response["secret"] = secret
+	}
+	if err := DB.Save(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  hook.ProjectID,
+		Action:     "webhook.update",
+		TargetType: "webhook",
+		TargetID:   hook.ID,
+		TargetName: hook.URL,
+		Before:     before,
+		After:      map[string]interface{}{"url": hook.URL, "events": hook.Events, "is_active": hook.IsActive, "secret_rotated": body.RotateSecret},
+	})
+	response["webhook"] = hook
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete webhook
+// @Description Delete a webhook, pending deliveries are not sent
+// @Tags Project Detail / Webhooks
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param webhook_id path int true "Webhook ID"
+// @Success 200 string {string} json "{"message": "Webhook deleted"}"
+// @Failure 404 string {string} json "{"error": "Webhook not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/webhooks/{webhook_id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	hook, ok := findProjectWebhook(c)
+	if !ok {
+		return
+	}
+	DB.Delete(&hook)
+	DB.Model(&models.WebhookDelivery{}).
+		Where("webhook_id = ? AND status = ?", hook.ID, models.WebhookDeliveryPending).
+		Updates(map[string]interface{}{"status": models.WebhookDeliveryFailed, "error": "Webhook is deleted"})
+	auditByUser(c, auditEntry{
+		ProjectID:  hook.ProjectID,
+		Action:     "webhook.delete",
+		TargetType: "webhook",
+		TargetID:   hook.ID,
+		TargetName: hook.URL,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// PingWebhook godoc
+// @Summary Ping webhook
+// @Description Send a ping event to the webhook to check the receiver
+// @Tags Project Detail / Webhooks
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param webhook_id path int true "Webhook ID"
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 404 string {string} json "{"error": "Webhook not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/webhooks/{webhook_id}/ping [post]
+func PingWebhook(c *gin.Context) {
+	hook, ok := findProjectWebhook(c)
+	if !ok {
+		return
+	}
+	var project models.Project
+	DB.Select("id", "name").First(&project, hook.ProjectID)
+	delivery, err := queueWebhookDelivery(hook, project, webhookEventPing, gin.H{"webhook_id": hook.ID, "events": strings.Split(hook.Events, ",")})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue ping"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"delivery": delivery})
+}
+
+// ListWebhookDeliveries godoc
+// @Summary List webhook deliveries
+// @Description List deliveries of a webhook, newest first, with the response of the last attempt
+// @Tags Project Detail / Webhooks
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param webhook_id path int true "Webhook ID"
+// @Param status query string false "pending, succeeded or failed"
+// @Param page query int false "Page"
+// @Param limit query int false "Limit"
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 404 string {string} json "{"error": "Webhook not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/webhooks/{webhook_id}/deliveries [get]
+func ListWebhookDeliveries(c *gin.Context) {
+	hook, ok := findProjectWebhook(c)
+	if !ok {
+		return
+	}
+	query := DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", hook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	query.Count(&total)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	var deliveries []models.WebhookDelivery
+	query.Order("id desc").Offset((page - 1) * limit).Limit(limit).Find(&deliveries)
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries, "total": total, "page": page, "limit": limit})
+}
+
+// RedeliverWebhookDelivery godoc
+// @Summary Redeliver webhook delivery
+// @Description Send the payload of a delivery again, with the same delivery ID so receivers can deduplicate
+// @Tags Project Detail / Webhooks
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param webhook_id path int true "Webhook ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 404 string {string} json "{"error": "Delivery not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/projects/{project_id}/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver [post]
+func RedeliverWebhookDelivery(c *gin.Context) {
+	hook, ok := findProjectWebhook(c)
+	if !ok {
+		return
+	}
+	var original models.WebhookDelivery
+	if err := DB.Where("webhook_id = ?", hook.ID).First(&original, c.Param("delivery_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+	delivery := models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		ProjectID:     hook.ProjectID,
+		DeliveryID:    original.DeliveryID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: time.Now(),
+		RedeliveryOf:  original.ID,
+	}
+	if err := DB.Create(&delivery).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue redelivery"})
+		return
+	}
+	wakeWebhookWorker()
+	c.JSON(http.StatusAccepted, gin.H{"delivery": delivery})
+}
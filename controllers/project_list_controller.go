@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
 	"parameter-store-be/models"
 	"time"
@@ -63,7 +64,8 @@ func ListProjects(c *gin.Context) {
 
 // CreateNewProject godoc
 // @Summary Create new project
-// @Description Create new project for organization
+// @Description Create new project for organization with the stages, environments and agents of a template.
+// @Description The default template of the organization is used when template_id is missing.
 // @Tags Project List
 // @Accept json
 // @Produce json
@@ -93,6 +95,11 @@ func CreateNewProject(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	template, err := projectTemplateFor(userOrganizationID, requestBody.TemplateID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
 	// Create a new project3
 	project := models.Project{
 		OrganizationID:  userOrganizationID,
@@ -103,6 +110,7 @@ func CreateNewProject(c *gin.Context) {
 		CurrentSprint:   "1",
 		RepoURL:         "github.com/OWNER/REPO",
 		LatestVersionID: 1,
+		TemplateID:      template.ID,
 	}
 	// Save the new project to the database
 	DB.Create(&project)
@@ -116,54 +124,10 @@ func CreateNewProject(c *gin.Context) {
 
 	project.LatestVersionID = initVersion.ID
 	DB.Save(&project)
-	// remove versionid 1 from project
-	// DB.Delete()
-	newStages := []models.Stage{
-		{
-			Name:        "Build",
-			Description: "Build stage",
-			ProjectID:   project.ID,
-		},
-		{
-			Name:        "Test",
-			Description: "Test stage",
-			ProjectID:   project.ID,
-		},
-		{
-			Name:        "Release",
-			Description: "Release stage",
-			ProjectID:   project.ID,
-		},
-		{
-			Name:        "Deploy",
-			Description: "Deploy stage",
-			ProjectID:   project.ID,
-		},
-	}
-	for _, stage := range newStages {
-		DB.Create(&stage)
-	}
-
-	newEnvironment := []models.Environment{
-		{
-
-			Name:        "Development",
-			Description: "Development environment",
-			ProjectID:   project.ID,
-		},
-		{
-			Name:        "Staging",
-			Description: "Staging environment",
-			ProjectID:   project.ID,
-		},
-		{
-			Name:        "Production",
-			Description: "Production environment",
-			ProjectID:   project.ID,
-		},
-	}
-	for _, environment := range newEnvironment {
-		DB.Create(&environment)
+	if err := instantiateProjectTemplate(DB, project, template); err != nil {
+		log.Println(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
+		return
 	}
 	newVersion := models.Version{
 		Number:      "1.0.0",
@@ -0,0 +1,288 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/github"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type cloneProjectRequestBody struct {
+	Name         string `json:"name" binding:"required"`
+	Description  string `json:"description"` // the description of the source when empty
+	RepoURL      string `json:"repo_url" binding:"required"`
+	RepoApiToken string `json:"repo_api_token"` // the token of the source when empty, required for another repo_url
+	BlankSecrets bool   `json:"blank_secrets"`  // clear the values of parameters not marked plaintext
+}
+
+// clonedAgent is an agent of the clone with its new token, which is only shown once
+type clonedAgent struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Stage       string `json:"stage"`
+	Environment string `json:"environment"`
+	APIToken    string `json:"api_token"`
+	Workflow    string `json:"workflow"`
+	// WorkflowMissing is set when the new repo has no workflow with the name of the agent, the agent can not rerun it
+	WorkflowMissing bool `json:"workflow_missing"`
+}
+
+// SameRepoURL tells if both URLs name the same GitHub repository, owner and name are not case sensitive
+func SameRepoURL(a, b string) bool {
+	repoA, errA := github.ParseRepoURL(strings.TrimSuffix(strings.TrimSpace(a), "/"))
+	repoB, errB := github.ParseRepoURL(strings.TrimSuffix(strings.TrimSpace(b), "/"))
+	if errA != nil || errB != nil {
+		return false
+	}
+	return strings.EqualFold(repoA.Owner, repoB.Owner) && strings.EqualFold(repoA.Name, repoB.Name)
+}
+
+// CloneProject godoc
+// @Summary Clone project
+// @Description Create a project from an existing one with its stages, environments, the parameters of its latest version and its agents.
+// @Description Agents get fresh tokens, values of secrets are cleared with blank_secrets. The clone points at repo_url, its workflows are read from there.
+// @Description repo_api_token is required when repo_url is not the repository of the source, its token is only reused for the same repository.
+// @Description Agents are linked to the workflow of the same name in repo_url, those without one are returned with workflow_missing and can not rerun a workflow until they are updated.
+// @Tags Project List
+// @Accept json
+// @Produce json
+// @Param project_id path string true "Project ID to clone"
+// @Param Clone body controllers.cloneProjectRequestBody true "Clone"
+// @Success 201 string {string} json "{"project": {}, "agents": [], "parameters": 0, "agents_without_workflow": 0}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 404 string {string} json "{"error": "Project not found"}"
+// @Failure 500 string {string} json "{"error": "Failed to clone project"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/project-list/{project_id}/clone [post]
+func CloneProject(c *gin.Context) {
+	startTime := time.Now()
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !user.IsOrganizationAdmin {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User is not an organization admin"})
+		return
+	}
+	var body cloneProjectRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var source models.Project
+	if err := DB.Where("organization_id = ?", user.OrganizationID).First(&source, c.Param("project_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	if body.Description == "" {
+		body.Description = source.Description
+	}
+	if body.RepoApiToken == "" {
+		// the token of the source must not give access to a repository chosen by the caller
+		if !SameRepoURL(body.RepoURL, source.RepoURL) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "repo_api_token is required when repo_url is not the repository of the source project"})
+			return
+		}
+		body.RepoApiToken = source.RepoApiToken
+	}
+	if err := github.ValidateGithubRepo(body.RepoURL, body.RepoApiToken); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	repoWorkflows, err := github.GetWorkflows(body.RepoURL, body.RepoApiToken)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	workflowsByName := map[string]models.Workflow{}
+	for _, workflow := range repoWorkflows.Workflows {
+		workflowsByName[workflow.Name] = models.Workflow{WorkflowID: uint(workflow.ID), Name: workflow.Name, Path: workflow.Path, State: workflow.State}
+	}
+
+	var stages []models.Stage
+	var environments []models.Environment
+	var agents []models.Agent
+	DB.Where("project_id = ? AND is_archived = ?", source.ID, false).Order("id").Find(&stages)
+	DB.Where("project_id = ? AND is_archived = ?", source.ID, false).Order("id").Find(&environments)
+	DB.Preload("Stage").Preload("Environment").Where("project_id = ? AND is_archived = ?", source.ID, false).Order("id").Find(&agents)
+	parameters, err := versionParameters(source.ID, source.LatestVersionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parameters"})
+		return
+	}
+
+	project := models.Project{
+		OrganizationID: user.OrganizationID,
+		Name:           body.Name,
+		Description:    body.Description,
+		StartAt:        time.Now(),
+		Status:         "In Progress",
+		CurrentSprint:  "1",
+		RepoURL:        body.RepoURL,
+		RepoApiToken:   body.RepoApiToken,
+		AutoUpdate:     source.AutoUpdate,
+	}
+	var version models.Version
+	var clonedAgents []clonedAgent
+	blanked, withoutWorkflow := 0, 0
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&project).Error; err != nil {
+			return err
+		}
+		// false is the zero value, so the default of the column is used on create
+		if !source.AutoUpdate {
+			if err := tx.Model(&project).Update("auto_update", false).Error; err != nil {
+				return err
+			}
+		}
+		stageIDs, environmentIDs := map[uint]uint{}, map[uint]uint{}
+		for _, stage := range stages {
+			clone := models.Stage{Name: stage.Name, Description: stage.Description, Color: stage.Color, ProjectID: project.ID}
+			if err := tx.Create(&clone).Error; err != nil {
+				return err
+			}
+			stageIDs[stage.ID] = clone.ID
+		}
+		for _, environment := range environments {
+			clone := models.Environment{Name: environment.Name, Description: environment.Description, Color: environment.Color, ProjectID: project.ID}
+			if err := tx.Create(&clone).Error; err != nil {
+				return err
+			}
+			environmentIDs[environment.ID] = clone.ID
+		}
+
+		version = models.Version{
+			Number:      "1.0.0",
+			Name:        "1.0.0",
+			ProjectID:   project.ID,
+			Description: "Cloned from " + source.Name,
+		}
+		var sourceVersion models.Version
+		if tx.First(&sourceVersion, source.LatestVersionID).Error == nil {
+			version.Description += " version " + sourceVersion.Number
+		}
+		for _, parameter := range parameters {
+			clone := cloneParameter(parameter)
+			clone.ProjectID = project.ID
+			clone.StageID, clone.EnvironmentID = stageIDs[parameter.StageID], environmentIDs[parameter.EnvironmentID]
+			clone.IsApplied = false
+			// dynamic credential sources stay with their project, the value keeps its template
+			clone.DynamicSourceID = 0
+			if clone.StageID == 0 || clone.EnvironmentID == 0 {
+				continue
+			}
+			if body.BlankSecrets && !clone.IsPlaintext && clone.Value != "" {
+				clone.SetValue("")
+				blanked++
+			}
+			version.Parameters = append(version.Parameters, clone)
+		}
+		if err := createLatestVersion(tx, project, &version); err != nil {
+			return err
+		}
+		project.LatestVersionID = version.ID
+
+		for _, agent := range agents {
+			stageID, environmentID := stageIDs[agent.StageID], environmentIDs[agent.EnvironmentID]
+			if stageID == 0 || environmentID == 0 {
+				continue
+			}
+			workflowID, err := cloneWorkflow(tx, project, workflowsByName, agent.WorkflowName)
+			if err != nil {
+				return err
+			}
+			clone := models.Agent{
+				ProjectID:     project.ID,
+				Name:          agent.Name,
+				StageID:       stageID,
+				EnvironmentID: environmentID,
+				WorkflowName:  agent.WorkflowName,
+				WorkflowID:    workflowID,
+				Description:   agent.Description,
+			}
+			if err := tx.Create(&clone).Error; err != nil {
+				return err
+			}
+			clone.APIToken = GenerateTokenForAgent(strconv.Itoa(int(clone.ID)), strconv.Itoa(int(project.OrganizationID)))
+			if err := tx.Model(&clone).Update("api_token", clone.APIToken).Error; err != nil {
+				return err
+			}
+			if workflowID == 0 {
+				withoutWorkflow++
+			}
+			clonedAgents = append(clonedAgents, clonedAgent{
+				ID:              clone.ID,
+				Name:            clone.Name,
+				Stage:           agent.Stage.Name,
+				Environment:     agent.Environment.Name,
+				APIToken:        clone.APIToken,
+				Workflow:        clone.WorkflowName,
+				WorkflowMissing: workflowID == 0,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clone project"})
+		return
+	}
+
+	message := fmt.Sprintf("Succeed: Project cloned from %s with %d stages, %d environments, %d parameters and %d agents, %d without workflow", source.Name, len(stages), len(environments), len(version.Parameters), len(clonedAgents), withoutWorkflow)
+	projectLogByUser(project.ID, "Clone Project", message, http.StatusCreated, time.Since(startTime), user.ID)
+	auditByUser(c, auditEntry{
+		ProjectID:  project.ID,
+		Action:     "project.clone",
+		TargetType: "project",
+		TargetID:   project.ID,
+		TargetName: project.Name,
+		After: map[string]interface{}{
+			"source_project_id": source.ID,
+			"source_project":    source.Name,
+			"repo_url":          project.RepoURL,
+			"parameters":        len(version.Parameters),
+			"blanked_secrets":   blanked,
+			"agents":            len(clonedAgents),
+			"without_workflow":  withoutWorkflow,
+		},
+		Status: http.StatusCreated,
+	})
+	c.JSON(http.StatusCreated, gin.H{
+		"project":         project,
+		"parameters":      len(version.Parameters),
+		"blanked_secrets": blanked,
+		"agents":          clonedAgents,
+		// agents of the source whose workflow does not exist in the new repo
+		"agents_without_workflow": withoutWorkflow,
+	})
+}
+
+// cloneWorkflow returns the ID of the workflow with the given name in the repo of the clone, recording it for the clone
+// like the workflow list does, or 0 when the repo has no such workflow.
+// A workflow already recorded by another project on the same repo is shared, the GitHub ID is the key of the workflows.
+func cloneWorkflow(tx *gorm.DB, project models.Project, workflowsByName map[string]models.Workflow, name string) (uint, error) {
+	workflow, ok := workflowsByName[name]
+	if !ok || name == "" {
+		return 0, nil
+	}
+	var existing models.Workflow
+	if err := tx.Where("workflow_id = ?", workflow.WorkflowID).Limit(1).Find(&existing).Error; err != nil {
+		return 0, err
+	}
+	if existing.WorkflowID != 0 {
+		return existing.WorkflowID, nil
+	}
+	workflow.ProjectID = project.ID
+	if err := tx.Create(&workflow).Error; err != nil {
+		return 0, err
+	}
+	return workflow.WorkflowID, nil
+}
@@ -40,15 +40,52 @@ func ScheduleWorkflowCheck() {
 
 					// print all this repo.Owner, repo.Name, project.RepoApiToken, workflow.WorkflowID, workflow.AttemptNumber
 					// log.Println(repo.Owner, repo.Name, project.RepoApiToken, logg.WorkflowRunId, workflow.AttemptNumber)
-					started_at, duration, err := github.GetLastAttemptInformationOfWorkflowRun(repo.Owner, repo.Name, project.RepoApiToken, int(logg.WorkflowRunId), workflow.AttemptNumber)
+					run, err := github.GetWorkflowRunAttempt(repo.Owner, repo.Name, project.RepoApiToken, int(logg.WorkflowRunId), workflow.AttemptNumber)
 					if err != nil {
 						log.Println(err.Error())
 						continue
+					}
+					if run.Status != logg.State {
+						streamProjectEvent(project.ID, StreamEventWorkflowState, map[string]interface{}{
+							"workflow_id":     workflow.WorkflowID,
+							"workflow_name":   workflow.Name,
+							"workflow_log_id": logg.ID,
+							"workflow_run_id": logg.WorkflowRunId,
+							"attempt_number":  logg.AttemptNumber,
+							"previous_state":  logg.State,
+							"state":           run.Status,
+							"conclusion":      run.Conclusion,
+							"html_url":        run.HTMLURL,
+						})
+					}
+					if run.Status != "completed" {
+						if run.Status != logg.State {
+							logg.State = run.Status
+							DB.Save(&logg)
+						}
+						continue
 					} else {
+						duration := run.Duration()
 						logg.State = "completed"
+						logg.Conclusion = run.Conclusion
 						logg.Duration = int(duration.Milliseconds())
-						logg.StartedAt = started_at
+						logg.StartedAt = run.RunStartedAt
 						DB.Save(&logg)
+						emitProjectEvent(project.ID, WebhookEventWorkflowCompleted, map[string]interface{}{
+							"workflow_id":     workflow.WorkflowID,
+							"workflow_name":   workflow.Name,
+							"workflow_run_id": logg.WorkflowRunId,
+							"attempt_number":  logg.AttemptNumber,
+							"started_at":      logg.StartedAt,
+							"duration":        logg.Duration,
+							"conclusion":      logg.Conclusion,
+							"html_url":        run.HTMLURL,
+						})
+						runURL := run.HTMLURL
+						if runURL == "" {
+							runURL = workflowRunURL(project.RepoURL, uint64(logg.WorkflowRunId))
+						}
+						notifyProject(project.ID, NotificationEventWorkflowCompleted, workflowCompletedMessage(project, workflow, logg, runURL))
 						workflow.IsUpdatedLastest = true
 						log.Println(duration)
 					}
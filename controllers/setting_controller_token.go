@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/signedtoken"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// PersonalAccessTokenPrefix tells RequiredAuth the bearer is a personal access token and not a JWT
+	PersonalAccessTokenPrefix = "pst_"
+	// personalAccessTokenUsageInterval limits how often last_used_at is written
+	personalAccessTokenUsageInterval = time.Minute
+	maxPersonalAccessTokenDays       = 365
+)
+
+type personalAccessTokenBody struct {
+	Name          string `json:"name" binding:"required"`
+	Scope         string `json:"scope" binding:"required"`
+	ProjectID     uint   `json:"project_id"`
+	ExpiresInDays int    `json:"expires_in_days"`
+}
+
+// AuthenticatePersonalAccessToken returns the token and its user, it records the usage of the token
+func AuthenticatePersonalAccessToken(raw, clientIP string) (models.PersonalAccessToken, models.User, error) {
+	var pat models.PersonalAccessToken
+	if err := DB.Where("token_hash = ?", signedtoken.Hash(raw)).First(&pat).Error; err != nil {
+		return pat, models.User{}, errors.New("Token is invalid")
+	}
+	if err := CheckPersonalAccessToken(pat); err != nil {
+		return pat, models.User{}, err
+	}
+	var user models.User
+	if err := DB.First(&user, pat.UserID).Error; err != nil {
+		return pat, models.User{}, errors.New("User not found")
+	}
+	if !PersonalAccessTokenMeetsMFAPolicy(pat, user, IsMFARequired(user)) {
+		return pat, models.User{}, errors.New("MFA is required by organization policy, enable MFA and create a new token")
+	}
+	if time.Since(pat.LastUsedAt) > personalAccessTokenUsageInterval || pat.LastUsedIP != clientIP {
+		DB.Model(&pat).Updates(map[string]interface{}{"last_used_at": time.Now(), "last_used_ip": clientIP})
+	}
+	return pat, user, nil
+}
+
+// CheckPersonalAccessToken rejects revoked and expired tokens
+func CheckPersonalAccessToken(pat models.PersonalAccessToken) error {
+	if pat.IsRevoked {
+		return errors.New("Token is revoked")
+	}
+	if pat.IsExpired() {
+		return errors.New("Token is expired")
+	}
+	return nil
+}
+
+// PersonalAccessTokenMeetsMFAPolicy tells if the token may be used when the organization requires MFA from the user:
+// the user must have MFA enabled and the token must be created after it, by a session that passed the second factor
+func PersonalAccessTokenMeetsMFAPolicy(pat models.PersonalAccessToken, user models.User, mfaRequired bool) bool {
+	if !mfaRequired {
+		return true
+	}
+	return user.MFAEnabled && !pat.CreatedAt.Before(user.MFAEnabledAt)
+}
+
+// PersonalAccessTokenAllows checks the scope of the token against the request method and project
+func PersonalAccessTokenAllows(pat models.PersonalAccessToken, method, projectID string) bool {
+	readOnly := method == http.MethodGet || method == http.MethodHead
+	switch pat.Scope {
+	case models.TokenScopeAdmin:
+		return true
+	case models.TokenScopeReadOnly:
+		return readOnly
+	case models.TokenScopeProject:
+		// routes without a project_id read organization data, they are out of the scope
+		return projectID != "" && projectID == strconv.FormatUint(uint64(pat.ProjectID), 10)
+	}
+	return false
+}
+
+// requireSessionAuth rejects requests made with a personal access token, so a leaked token can not mint new ones
+func requireSessionAuth(c *gin.Context) bool {
+	if _, exists := c.Get("personal_access_token"); exists {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Personal access tokens can not be managed with a personal access token"})
+		return false
+	}
+	return true
+}
+
+// ListPersonalAccessTokens godoc
+// @Summary List personal access tokens
+// @Description List personal access tokens of the current user, the token values are never returned
+// @Tags Setting / Token
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.PersonalAccessToken
+// @Failure 500 string {string} json "{"error": "Failed to list tokens"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/settings/tokens [get]
+func ListPersonalAccessTokens(c *gin.Context) {
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var tokens []models.PersonalAccessToken
+	if err := DB.Where("user_id = ?", user.ID).Order("created_at desc").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// CreatePersonalAccessToken godoc
+// @Summary Create personal access token
+// @Description Create a token for scripts, send it as "Authorization: Bearer <token>". The token is shown only in this response. Scope is read, project or admin, expires_in_days 0 means 90 days.
+// @Description When the organization requires MFA from the user, only tokens created after MFA was enabled are accepted.
+// @Tags Setting / Token
+// @Accept json
+// @Produce json
+// @Param Token body controllers.personalAccessTokenBody true "Token"
+// @Success 201 string {string} json "{"token": "pst_...", "personal_access_token": {}}"
+// @Failure 400 string {string} json "{"error": "Bad request"}"
+// @Failure 403 string {string} json "{"error": "User does not belong to the project"}"
+// @Failure 500 string {string} json "{"error": "Failed to create token"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/settings/tokens [post]
+func CreatePersonalAccessToken(c *gin.Context) {
+	if !requireSessionAuth(c) {
+		return
+	}
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var body personalAccessTokenBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch body.Scope {
+	case models.TokenScopeReadOnly, models.TokenScopeAdmin:
+		body.ProjectID = 0
+	case models.TokenScopeProject:
+		if body.ProjectID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id is required for project scope"})
+			return
+		}
+		var project models.Project
+		if err := DB.Where("organization_id = ?", user.OrganizationID).First(&project, body.ProjectID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Project not found"})
+			return
+		}
+		if !user.IsOrganizationAdmin {
+			var count int64
+			DB.Model(&models.UserRoleProject{}).Where("user_id = ? AND project_id = ?", user.ID, body.ProjectID).Count(&count)
+			if count == 0 {
+				c.JSON(http.StatusForbidden, gin.H{"error": "User does not belong to the project"})
+				return
+			}
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be read, project or admin"})
+		return
+	}
+	if body.ExpiresInDays == 0 {
+		body.ExpiresInDays = 90
+	}
+	if body.ExpiresInDays < 0 || body.ExpiresInDays > maxPersonalAccessTokenDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must be between 1 and 365"})
+		return
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+	token := PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	pat := models.PersonalAccessToken{
+		UserID:    user.ID,
+		Name:      body.Name,
+		TokenHash: signedtoken.Hash(token),
+		Prefix:    token[:len(PersonalAccessTokenPrefix)+6],
+		Scope:     body.Scope,
+		ProjectID: body.ProjectID,
+		ExpiresAt: time.Now().AddDate(0, 0, body.ExpiresInDays),
+	}
+	if err := DB.Create(&pat).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+	auditByUser(c, auditEntry{
+		ProjectID:  pat.ProjectID,
+		Action:     "token.create",
+		TargetType: "personal_access_token",
+		TargetID:   pat.ID,
+		TargetName: pat.Name,
+		After:      map[string]interface{}{"scope": pat.Scope, "project_id": pat.ProjectID, "expires_at": pat.ExpiresAt},
+		Status:     http.StatusCreated,
+	})
+	c.JSON(http.StatusCreated, gin.H{
+		"message":               "Copy the token now, it will not be shown again",
+		"token":                 token,
+		"personal_access_token": pat,
+	})
+}
+
+// RevokePersonalAccessToken godoc
+// @Summary Revoke personal access token
+// @Description Revoke a personal access token of the current user, requests with it are rejected right away
+// @Tags Setting / Token
+// @Accept json
+// @Produce json
+// @Param token_id path int true "Token ID"
+// @Success 200 string {string} json "{"message": "Token revoked"}"
+// @Failure 404 string {string} json "{"error": "Token not found"}"
+// @Security ApiKeyAuth
+// @Router /api/v1/settings/tokens/{token_id} [delete]
+func RevokePersonalAccessToken(c *gin.Context) {
+	if !requireSessionAuth(c) {
+		return
+	}
+	user, err := getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var pat models.PersonalAccessToken
+	if err := DB.Where("user_id = ?", user.ID).First(&pat, c.Param("token_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+	DB.Model(&pat).Updates(map[string]interface{}{"is_revoked": true, "revoked_at": time.Now()})
+	auditByUser(c, auditEntry{
+		ProjectID:  pat.ProjectID,
+		Action:     "token.revoke",
+		TargetType: "personal_access_token",
+		TargetID:   pat.ID,
+		TargetName: pat.Name,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked"})
+}
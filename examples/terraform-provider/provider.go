@@ -0,0 +1,308 @@
+// Package provider shows how a Terraform or OpenTofu provider maps its resources onto the declarative API.
+// It keeps the shape of the plugin SDK (Create, Read, Update, Delete, Import on a ResourceData) without depending on it,
+// a real provider would register these functions in its schema.Resource.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"parameter-store-be/modules/declarative"
+	"strings"
+)
+
+// ResourceData is the state of one resource, like schema.ResourceData
+type ResourceData struct {
+	ID         string // import ID, the names joined with "/"
+	Attributes map[string]string
+}
+
+func (d *ResourceData) Get(key string) string {
+	return d.Attributes[key]
+}
+
+func (d *ResourceData) Set(key, value string) {
+	if d.Attributes == nil {
+		d.Attributes = map[string]string{}
+	}
+	d.Attributes[key] = value
+}
+
+// Resource is what the provider does for every resource type
+type Resource interface {
+	Create(ctx context.Context, d *ResourceData) error
+	Read(ctx context.Context, d *ResourceData) error
+	Update(ctx context.Context, d *ResourceData) error
+	Delete(ctx context.Context, d *ResourceData) error
+	// Import fills the name attributes from an ID given to terraform import, Read is called next
+	Import(ctx context.Context, d *ResourceData) error
+}
+
+// Provider is configured with the server URL and a personal access token
+type Provider struct {
+	Client *declarative.Client
+}
+
+// Resources are the resource types of the provider by Terraform type name
+func (p *Provider) Resources() map[string]Resource {
+	return map[string]Resource{
+		"parameterstore_project":     &projectResource{p.Client},
+		"parameterstore_stage":       &stageResource{p.Client, "stage"},
+		"parameterstore_environment": &stageResource{p.Client, "environment"},
+		"parameterstore_agent":       &agentResource{p.Client},
+		"parameterstore_parameter":   &parameterResource{p.Client},
+	}
+}
+
+// importID splits an import ID into the given name attributes
+func importID(d *ResourceData, names ...string) error {
+	parts := strings.Split(d.ID, "/")
+	if len(parts) != len(names) {
+		return fmt.Errorf("import ID must be %s", strings.Join(names, "/"))
+	}
+	for i, name := range names {
+		d.Set(name, parts[i])
+	}
+	return nil
+}
+
+// gone removes the resource from state when the server no longer has it, so the next plan creates it again
+func gone(d *ResourceData, err error) error {
+	if errors.Is(err, declarative.ErrNotFound) {
+		d.ID = ""
+		return nil
+	}
+	return err
+}
+
+// created explains a conflict on create, the resource exists and must be imported
+func created(d *ResourceData, err error) error {
+	if errors.Is(err, declarative.ErrConflict) {
+		return fmt.Errorf("%s already exists, run terraform import with ID %s: %w", d.ID, d.ID, err)
+	}
+	return err
+}
+
+type projectResource struct{ client *declarative.Client }
+
+func (r *projectResource) body(d *ResourceData) declarative.ProjectBody {
+	body := declarative.ProjectBody{Description: d.Get("description"), RepoURL: d.Get("repo_url"), RepoApiToken: d.Get("repo_api_token")}
+	if value := d.Get("auto_update"); value != "" {
+		autoUpdate := value == "true"
+		body.AutoUpdate = &autoUpdate
+	}
+	return body
+}
+
+func (r *projectResource) write(ctx context.Context, d *ResourceData, options declarative.WriteOptions) error {
+	var project declarative.Project
+	result, err := r.client.Put(ctx, declarative.ProjectPath(d.Get("name")), "project", r.body(d), options, &project)
+	if err != nil {
+		return err
+	}
+	d.ID = project.Name
+	d.Set("project_id", fmt.Sprint(project.ID))
+	d.Set("latest_version", project.LatestVersion)
+	d.Set("etag", result.ETag)
+	return nil
+}
+
+func (r *projectResource) Create(ctx context.Context, d *ResourceData) error {
+	d.ID = d.Get("name")
+	return created(d, r.write(ctx, d, declarative.WriteOptions{CreateOnly: true}))
+}
+
+func (r *projectResource) Read(ctx context.Context, d *ResourceData) error {
+	var project declarative.Project
+	etag, err := r.client.Get(ctx, declarative.ProjectPath(d.Get("name")), "project", &project)
+	if err != nil || project.IsArchived {
+		d.ID = ""
+		return gone(d, err)
+	}
+	d.Set("description", project.Description)
+	d.Set("repo_url", project.RepoURL)
+	d.Set("auto_update", fmt.Sprint(project.AutoUpdate))
+	d.Set("project_id", fmt.Sprint(project.ID))
+	d.Set("latest_version", project.LatestVersion)
+	d.Set("etag", etag)
+	return nil
+}
+
+func (r *projectResource) Update(ctx context.Context, d *ResourceData) error {
+	return r.write(ctx, d, declarative.WriteOptions{IfMatch: d.Get("etag")})
+}
+
+func (r *projectResource) Delete(ctx context.Context, d *ResourceData) error {
+	return gone(d, r.client.Delete(ctx, declarative.ProjectPath(d.Get("name")), declarative.WriteOptions{}))
+}
+
+func (r *projectResource) Import(ctx context.Context, d *ResourceData) error {
+	return importID(d, "name")
+}
+
+// stageResource manages stages and environments, which have the same attributes
+type stageResource struct {
+	client *declarative.Client
+	kind   string
+}
+
+func (r *stageResource) path(d *ResourceData) string {
+	if r.kind == "environment" {
+		return declarative.EnvironmentPath(d.Get("project"), d.Get("name"))
+	}
+	return declarative.StagePath(d.Get("project"), d.Get("name"))
+}
+
+func (r *stageResource) write(ctx context.Context, d *ResourceData, options declarative.WriteOptions) error {
+	var stage declarative.Stage
+	body := declarative.StageBody{Description: d.Get("description"), Color: d.Get("color")}
+	result, err := r.client.Put(ctx, r.path(d), r.kind, body, options, &stage)
+	if err != nil {
+		return err
+	}
+	d.ID = d.Get("project") + "/" + stage.Name
+	d.Set(r.kind+"_id", fmt.Sprint(stage.ID))
+	d.Set("etag", result.ETag)
+	return nil
+}
+
+func (r *stageResource) Create(ctx context.Context, d *ResourceData) error {
+	d.ID = d.Get("project") + "/" + d.Get("name")
+	return created(d, r.write(ctx, d, declarative.WriteOptions{CreateOnly: true}))
+}
+
+func (r *stageResource) Read(ctx context.Context, d *ResourceData) error {
+	var stage declarative.Stage
+	etag, err := r.client.Get(ctx, r.path(d), r.kind, &stage)
+	if err != nil || stage.IsArchived {
+		d.ID = ""
+		return gone(d, err)
+	}
+	d.Set("description", stage.Description)
+	d.Set("color", stage.Color)
+	d.Set(r.kind+"_id", fmt.Sprint(stage.ID))
+	d.Set("etag", etag)
+	return nil
+}
+
+func (r *stageResource) Update(ctx context.Context, d *ResourceData) error {
+	return r.write(ctx, d, declarative.WriteOptions{IfMatch: d.Get("etag")})
+}
+
+func (r *stageResource) Delete(ctx context.Context, d *ResourceData) error {
+	return gone(d, r.client.Delete(ctx, r.path(d), declarative.WriteOptions{}))
+}
+
+func (r *stageResource) Import(ctx context.Context, d *ResourceData) error {
+	return importID(d, "project", "name")
+}
+
+type agentResource struct{ client *declarative.Client }
+
+func (r *agentResource) write(ctx context.Context, d *ResourceData, options declarative.WriteOptions) error {
+	body := declarative.AgentBody{
+		Stage:        d.Get("stage"),
+		Environment:  d.Get("environment"),
+		WorkflowName: d.Get("workflow_name"),
+		Description:  d.Get("description"),
+	}
+	agent, token, result, err := r.client.PutAgent(ctx, d.Get("project"), d.Get("name"), body, options)
+	if err != nil {
+		return err
+	}
+	d.ID = d.Get("project") + "/" + agent.Name
+	d.Set("agent_id", fmt.Sprint(agent.ID))
+	d.Set("etag", result.ETag)
+	if token != "" {
+		// sensitive, only known once
+		d.Set("api_token", token)
+	}
+	return nil
+}
+
+func (r *agentResource) Create(ctx context.Context, d *ResourceData) error {
+	d.ID = d.Get("project") + "/" + d.Get("name")
+	return created(d, r.write(ctx, d, declarative.WriteOptions{CreateOnly: true}))
+}
+
+func (r *agentResource) Read(ctx context.Context, d *ResourceData) error {
+	var agent declarative.Agent
+	etag, err := r.client.Get(ctx, declarative.AgentPath(d.Get("project"), d.Get("name")), "agent", &agent)
+	if err != nil || agent.IsArchived {
+		d.ID = ""
+		return gone(d, err)
+	}
+	d.Set("stage", agent.Stage)
+	d.Set("environment", agent.Environment)
+	d.Set("workflow_name", agent.WorkflowName)
+	d.Set("description", agent.Description)
+	d.Set("agent_id", fmt.Sprint(agent.ID))
+	d.Set("etag", etag)
+	return nil
+}
+
+func (r *agentResource) Update(ctx context.Context, d *ResourceData) error {
+	return r.write(ctx, d, declarative.WriteOptions{IfMatch: d.Get("etag")})
+}
+
+func (r *agentResource) Delete(ctx context.Context, d *ResourceData) error {
+	return gone(d, r.client.Delete(ctx, declarative.AgentPath(d.Get("project"), d.Get("name")), declarative.WriteOptions{}))
+}
+
+func (r *agentResource) Import(ctx context.Context, d *ResourceData) error {
+	return importID(d, "project", "name")
+}
+
+type parameterResource struct{ client *declarative.Client }
+
+func (r *parameterResource) path(d *ResourceData) string {
+	return declarative.ParameterPath(d.Get("project"), d.Get("stage"), d.Get("environment"), d.Get("name"))
+}
+
+func (r *parameterResource) id(d *ResourceData) string {
+	return strings.Join([]string{d.Get("project"), d.Get("stage"), d.Get("environment"), d.Get("name")}, "/")
+}
+
+func (r *parameterResource) write(ctx context.Context, d *ResourceData, options declarative.WriteOptions) error {
+	var parameter declarative.Parameter
+	body := declarative.ParameterBody{Value: d.Get("value"), Description: d.Get("description")}
+	result, err := r.client.Put(ctx, r.path(d), "parameter", body, options, &parameter)
+	if err != nil {
+		return err
+	}
+	d.ID = r.id(d)
+	d.Set("parameter_id", fmt.Sprint(parameter.ID))
+	d.Set("etag", result.ETag)
+	return nil
+}
+
+func (r *parameterResource) Create(ctx context.Context, d *ResourceData) error {
+	d.ID = r.id(d)
+	return created(d, r.write(ctx, d, declarative.WriteOptions{CreateOnly: true}))
+}
+
+func (r *parameterResource) Read(ctx context.Context, d *ResourceData) error {
+	var parameter declarative.Parameter
+	etag, err := r.client.Get(ctx, r.path(d), "parameter", &parameter)
+	if err != nil || parameter.IsArchived {
+		d.ID = ""
+		return gone(d, err)
+	}
+	d.Set("value", parameter.Value)
+	d.Set("description", parameter.Description)
+	d.Set("parameter_id", fmt.Sprint(parameter.ID))
+	d.Set("etag", etag)
+	return nil
+}
+
+func (r *parameterResource) Update(ctx context.Context, d *ResourceData) error {
+	return r.write(ctx, d, declarative.WriteOptions{IfMatch: d.Get("etag")})
+}
+
+func (r *parameterResource) Delete(ctx context.Context, d *ResourceData) error {
+	return gone(d, r.client.Delete(ctx, r.path(d), declarative.WriteOptions{}))
+}
+
+func (r *parameterResource) Import(ctx context.Context, d *ResourceData) error {
+	return importID(d, "project", "stage", "environment", "name")
+}
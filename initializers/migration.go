@@ -41,11 +41,31 @@ func Migration(db *gorm.DB) error {
 		log.Println("Failed to migrate User models")
 		return err
 	}
+	err = db.AutoMigrate(&models.RecoveryCode{})
+	if err != nil {
+		log.Println("Failed to migrate RecoveryCode models")
+		return err
+	}
+	err = db.AutoMigrate(&models.OIDCProvider{}, &models.OIDCGroupMapping{}, &models.OIDCLoginState{})
+	if err != nil {
+		log.Println("Failed to migrate OIDC models")
+		return err
+	}
 	err = db.AutoMigrate(&models.Token{})
 	if err != nil {
 		log.Println("Failed to migrate Token models")
 		return err
 	}
+	err = db.AutoMigrate(&models.PersonalAccessToken{})
+	if err != nil {
+		log.Println("Failed to migrate PersonalAccessToken models")
+		return err
+	}
+	err = db.AutoMigrate(&models.Invitation{})
+	if err != nil {
+		log.Println("Failed to migrate Invitation models")
+		return err
+	}
 	err = db.AutoMigrate(&models.UserRoleProject{})
 	if err != nil {
 		log.Println("Failed to migrate UserRoleProject models")
@@ -86,6 +106,125 @@ func Migration(db *gorm.DB) error {
 	if err != nil {
 		log.Println("Failed to migrate WorkflowLog models")
 	}
+	err = db.AutoMigrate(&models.Webhook{}, &models.WebhookDelivery{})
+	if err != nil {
+		log.Println("Failed to migrate Webhook models")
+		return err
+	}
+	err = db.AutoMigrate(&models.ParameterSetRevision{})
+	if err != nil {
+		log.Println("Failed to migrate ParameterSetRevision models")
+		return err
+	}
+	err = db.AutoMigrate(&models.ProjectEvent{})
+	if err != nil {
+		log.Println("Failed to migrate ProjectEvent models")
+		return err
+	}
+	err = db.AutoMigrate(&models.ProjectEventPrune{})
+	if err != nil {
+		log.Println("Failed to migrate ProjectEventPrune models")
+		return err
+	}
+	err = db.AutoMigrate(&models.NotificationChannel{})
+	if err != nil {
+		log.Println("Failed to migrate NotificationChannel models")
+		return err
+	}
+	err = db.AutoMigrate(&models.GitOpsConfig{})
+	if err != nil {
+		log.Println("Failed to migrate GitOpsConfig models")
+		return err
+	}
+	err = db.AutoMigrate(&models.ProjectTemplate{})
+	if err != nil {
+		log.Println("Failed to migrate ProjectTemplate models")
+		return err
+	}
+	err = db.AutoMigrate(&models.ProjectTemplateStage{})
+	if err != nil {
+		log.Println("Failed to migrate ProjectTemplateStage models")
+		return err
+	}
+	err = db.AutoMigrate(&models.ProjectTemplateEnvironment{})
+	if err != nil {
+		log.Println("Failed to migrate ProjectTemplateEnvironment models")
+		return err
+	}
+	err = db.AutoMigrate(&models.ProjectTemplateParameter{})
+	if err != nil {
+		log.Println("Failed to migrate ProjectTemplateParameter models")
+		return err
+	}
+	err = db.AutoMigrate(&models.ProjectTemplateAgent{})
+	if err != nil {
+		log.Println("Failed to migrate ProjectTemplateAgent models")
+		return err
+	}
+	err = db.AutoMigrate(&models.SharedParameterSet{})
+	if err != nil {
+		log.Println("Failed to migrate SharedParameterSet models")
+		return err
+	}
+	err = db.AutoMigrate(&models.SharedParameter{})
+	if err != nil {
+		log.Println("Failed to migrate SharedParameter models")
+		return err
+	}
+	err = db.AutoMigrate(&models.ProjectParameterSet{})
+	if err != nil {
+		log.Println("Failed to migrate ProjectParameterSet models")
+		return err
+	}
+	err = db.AutoMigrate(&models.ScheduledChange{})
+	if err != nil {
+		log.Println("Failed to migrate ScheduledChange models")
+		return err
+	}
+	err = db.AutoMigrate(&models.DynamicCredentialSource{})
+	if err != nil {
+		log.Println("Failed to migrate DynamicCredentialSource models")
+		return err
+	}
+	err = db.AutoMigrate(&models.DynamicCredentialLease{})
+	if err != nil {
+		log.Println("Failed to migrate DynamicCredentialLease models")
+		return err
+	}
+	err = db.AutoMigrate(&models.RepoScan{})
+	if err != nil {
+		log.Println("Failed to migrate RepoScan models")
+		return err
+	}
+	err = db.AutoMigrate(&models.ParameterUsage{})
+	if err != nil {
+		log.Println("Failed to migrate ParameterUsage models")
+		return err
+	}
+	err = db.AutoMigrate(&models.AuditLog{})
+	if err != nil {
+		log.Println("Failed to migrate AuditLog models")
+		return err
+	}
+	// reject UPDATE and DELETE on the audit trail even from outside the application
+	for _, statement := range []string{
+		`CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs`,
+		`CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs
+	FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only()`,
+	} {
+		if err = db.Exec(statement).Error; err != nil {
+			break
+		}
+	}
+	if err != nil {
+		log.Println("Failed to create audit_logs trigger")
+		return err
+	}
 	log.Printf("Database migrated\n")
 	return nil
 }
@@ -197,6 +197,27 @@ func SeedDatabase(db *gorm.DB) error {
 	}
 	sampleOrganizations = organization
 
+	template := models.ProjectTemplate{
+		OrganizationID: organization.ID,
+		Name:           "Standard",
+		Description:    "Default layout of new projects",
+		IsDefault:      true,
+		Stages: []models.ProjectTemplateStage{
+			{Name: "Build", Description: "Build stage", Position: 0},
+			{Name: "Test", Description: "Test stage", Position: 1},
+			{Name: "Release", Description: "Release stage", Position: 2},
+			{Name: "Deploy", Description: "Deploy stage", Position: 3},
+		},
+		Environments: []models.ProjectTemplateEnvironment{
+			{Name: "Development", Description: "Development environment", Position: 0},
+			{Name: "Staging", Description: "Staging environment", Position: 1},
+			{Name: "Production", Description: "Production environment", Position: 2},
+		},
+	}
+	if err := db.Create(&template).Error; err != nil {
+		return err
+	}
+
 	log.Printf("\nDefault organization data is seeded.\n")
 	//////////////////////////////////////////// Sample Users ////////////////////////////////////////////
 	admin := models.User{
@@ -5,6 +5,8 @@ import (
 	"os"
 	"parameter-store-be/controllers"
 	"parameter-store-be/initializers"
+	"parameter-store-be/modules/auditsink"
+	"parameter-store-be/modules/mailer"
 	"parameter-store-be/routes"
 
 	"github.com/gin-gonic/gin"
@@ -32,6 +34,8 @@ func init() {
 
 	// Set controller
 	controllers.SetDB(db) // set controller use that db *gorm.DB
+	controllers.SetMailer(mailer.NewSenderFromEnv())
+	controllers.SetAuditSink(auditsink.NewPublisherFromEnv())
 	log.Println("Finished init.")
 }
 
@@ -54,9 +58,15 @@ func main() {
 	if os.Getenv("ENABLE_HEALTH_CHECK") == "true" {
 		go controllers.ScheduleWorkflowCheck()
 	}
-	// if os.Getenv("ENABLE_HEALTH_CHECK") == "true" {
-	// go controllers.AutoUpdateParameterUsingInFile()
-	// }/
+	go controllers.RunWebhookDeliveryWorker()
+	go controllers.RunProjectEventRetention()
+	go controllers.RunScheduledChangeWorker()
+	go controllers.RunSecretRotationNotifier()
+	go controllers.RunDynamicCredentialReaper()
+	// the scanner downloads the repository of every project every hour, a scan can still be requested per project without it
+	if os.Getenv("ENABLE_REPO_USAGE_SCAN") == "true" {
+		go controllers.RunRepoUsageScanner()
+	}
 	if os.Getenv("ENABLE_HTTPS_LOCAL") == "true" {
 		log.Println("Server is running on port", port, "in", os.Getenv("GIN_MODE"), "gin mode with HTTPS")
 		r.RunTLS(":"+port, os.Getenv("CERT_FILE_PATH"), os.Getenv("KEY_FILE_PATH"))
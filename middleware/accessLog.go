@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sensitiveQueryParameters carry tokens, their values are left out of the access log
+var sensitiveQueryParameters = []string{"ticket", "token", "access_token"}
+
+// RedactQuery replaces the values of sensitive query parameters of a request path
+func RedactQuery(path string) string {
+	base, rawQuery, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return base + "?[unparsable query]"
+	}
+	for _, name := range sensitiveQueryParameters {
+		if _, ok := query[name]; ok {
+			query[name] = []string{"REDACTED"}
+		}
+	}
+	return base + "?" + query.Encode()
+}
+
+// AccessLogFormatter is the line of the gin logger with the sensitive query parameters redacted
+func AccessLogFormatter(param gin.LogFormatterParams) string {
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		RedactQuery(param.Path),
+		param.ErrorMessage,
+	)
+}
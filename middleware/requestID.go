@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID, a value sent by a proxy is kept
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID sets the request ID in the context and the response, the audit log records it
+func RequestID(c *gin.Context) {
+	requestID := c.GetHeader(RequestIDHeader)
+	if requestID == "" || len(requestID) > 64 {
+		b := make([]byte, 16)
+		rand.Read(b)
+		requestID = hex.EncodeToString(b)
+	}
+	c.Set("request_id", requestID)
+	c.Header(RequestIDHeader, requestID)
+	c.Next()
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"parameter-store-be/controllers"
 	"parameter-store-be/models"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,12 +22,16 @@ func RequiredAuth(c *gin.Context) {
 	// 	c.AbortWithStatus(http.StatusUnauthorized)
 	// 	return
 	// }
-	tokenString := c.GetHeader("Authorization")
+	tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
 	// log.Printf("debug: tokenString \"%s\"", tokenString)
 	if tokenString == "" {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Failed to get token in header"})
 		return
 	}
+	if strings.HasPrefix(tokenString, controllers.PersonalAccessTokenPrefix) {
+		requiredPersonalAccessToken(c, tokenString)
+		return
+	}
 
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// Don't forget to validate the alg is what you expect:
@@ -61,6 +66,20 @@ func RequiredAuth(c *gin.Context) {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User is archived"})
 			return
 		}
+		// A pending token only proves the password, it must be exchanged at /auth/login/mfa
+		if claims["typ"] == controllers.MFAPendingTokenType {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "MFA code required"})
+			return
+		}
+		// Sessions without a verified second factor may only enroll when MFA is enforced
+		mfaVerified, _ := claims["mfa"].(bool)
+		if !mfaVerified && (user.MFAEnabled || controllers.IsMFARequired(user)) && !isMFAExemptPath(c.FullPath()) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":                   "MFA is required, please enroll or login again with your MFA code",
+				"mfa_enrollment_required": !user.MFAEnabled,
+			})
+			return
+		}
 		// Set the user and their organization_id in the context
 		c.Set("user", user)
 		orgID, ok := claims["org_id"].(float64)
@@ -79,3 +98,36 @@ func RequiredAuth(c *gin.Context) {
 	}
 	c.Next()
 }
+
+// requiredPersonalAccessToken authenticates scripts with a personal access token instead of a login JWT
+func requiredPersonalAccessToken(c *gin.Context, tokenString string) {
+	pat, user, err := controllers.AuthenticatePersonalAccessToken(tokenString, c.ClientIP())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if user.IsArchived {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User is archived"})
+		return
+	}
+	if !controllers.PersonalAccessTokenAllows(pat, c.Request.Method, c.Param("project_id")) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token scope does not allow this request"})
+		return
+	}
+	c.Set("user", user)
+	c.Set("org_id", user.OrganizationID)
+	c.Set("personal_access_token", pat)
+	c.Next()
+}
+
+// isMFAExemptPath lists the routes a user without a verified second factor can still call
+func isMFAExemptPath(path string) bool {
+	switch path {
+	case "/api/v1/auth/mfa/enroll",
+		"/api/v1/auth/mfa/verify",
+		"/api/v1/auth/validate",
+		"/api/v1/auth/logout":
+		return true
+	}
+	return false
+}
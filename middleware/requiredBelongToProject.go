@@ -12,27 +12,37 @@ func RequiredBelongToProject(c *gin.Context) {
 	// get user from context
 	userInContext, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
 		return
 	}
 	user := userInContext.(models.User)
-	// check if user is organization admin
-	if user.IsOrganizationAdmin {
-		c.Next()
-		return
-	}
-
 	// get project_id from path
 	project_id := c.Param("project_id")
 	if project_id == "0" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get project ID from user"})
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get project ID from user"})
+		return
+	}
+	// check if user is organization admin, of the organization owning the project on project routes
+	if user.IsOrganizationAdmin {
+		if project_id != "" && !projectInOrganization(project_id, user.OrganizationID) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User does not belong to the project"})
+			return
+		}
+		c.Next()
 		return
 	}
 	// check if user belongs to the project
 	var upr models.UserRoleProject
 	if err := controllers.DB.Where("user_id = ? AND project_id = ?", user.ID, project_id).First(&upr).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User does not belong to the project"})
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User does not belong to the project"})
 		return
 	}
 	c.Next()
 }
+
+// projectInOrganization reports whether the project exists in the organization, organization admins only reach their own projects
+func projectInOrganization(projectID string, organizationID uint) bool {
+	var count int64
+	controllers.DB.Model(&models.Project{}).Where("id = ? AND organization_id = ?", projectID, organizationID).Count(&count)
+	return count > 0
+}
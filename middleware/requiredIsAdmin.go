@@ -13,34 +13,37 @@ func RequiredIsAdmin(c *gin.Context) {
 	userInContext, exists := c.Get("user")
 	if !exists {
 		// log.Println("Failed to get user from context")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
 		return
 	}
 	user := userInContext.(models.User)
-	// check if user is organization admin
-	if user.IsOrganizationAdmin {
-		// log.Println("User is organization admin")
-		c.Next()
-		return
-	}
-
 	// get project_id from path
 	project_id := c.Param("project_id")
 	if project_id == "0" {
 		// log.Println("Failed to get project ID from user")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get project ID from user"})
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get project ID from user"})
+		return
+	}
+	// check if user is organization admin, of the organization owning the project on project routes
+	if user.IsOrganizationAdmin {
+		// log.Println("User is organization admin")
+		if project_id != "" && !projectInOrganization(project_id, user.OrganizationID) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User does not belong to the project"})
+			return
+		}
+		c.Next()
 		return
 	}
 	// check if user belongs to the project
 	var upr models.UserRoleProject
-	if err := controllers.DB.Where("user_id = ? AND project_id = ?", user.ID, project_id).First(&upr).Error; err != nil {
+	if err := controllers.DB.Preload("Role").Where("user_id = ? AND project_id = ?", user.ID, project_id).First(&upr).Error; err != nil {
 		// log.Println("Failed to get user role project")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User does not belong to the project"})
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User does not belong to the project"})
 		return
 	}
 	if upr.Role.Name != "Project Admin" {
 		// log.Println("User is not an admin, please contact the project admin to perform this action")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User is not an admin, please contact the project admin to perform this action"})
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User is not an admin, please contact the project admin to perform this action"})
 		return
 	}
 	c.Next()
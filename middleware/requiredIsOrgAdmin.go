@@ -11,7 +11,7 @@ func RequiredIsOrgAdmin(c *gin.Context) {
 	// get user from context
 	userInContext, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
 		return
 	}
 	user := userInContext.(models.User)
@@ -20,5 +20,5 @@ func RequiredIsOrgAdmin(c *gin.Context) {
 		c.Next()
 		return
 	}
-	c.JSON(http.StatusUnauthorized, gin.H{"error": "User is not an organization admin"})
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User is not an organization admin"})
 }
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"parameter-store-be/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequiredStreamAuth authenticates the event stream with the Authorization header, or with a single-use ticket in the
+// ticket query for the browser EventSource which cannot set headers. Session tokens are never read from the query.
+func RequiredStreamAuth(c *gin.Context) {
+	if c.GetHeader("Authorization") != "" {
+		RequiredAuth(c)
+		return
+	}
+	ticket := c.Query("ticket")
+	if ticket == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Failed to get token in header or ticket in query"})
+		return
+	}
+	user, err := controllers.ConsumeStreamTicket(ticket, c.Param("project_id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if user.IsArchived {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User is archived"})
+		return
+	}
+	c.Set("user", user)
+	c.Set("org_id", user.OrganizationID)
+	c.Next()
+}
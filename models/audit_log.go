@@ -0,0 +1,88 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Actor types of an audit log entry
+const (
+	AuditActorUser   = "user"
+	AuditActorAgent  = "agent"
+	AuditActorSystem = "system"
+)
+
+// ErrAuditLogAppendOnly is returned when an audit log entry is updated or deleted
+var ErrAuditLogAppendOnly = errors.New("audit log is append-only")
+
+// AuditLog is an append-only entry of the audit trail. Entries of an organization are chained:
+// each Hash covers the entry and the Hash of the previous one, so a changed or removed row breaks the chain.
+// The hash is keyed with a server secret, rows rewritten by someone without the key do not verify.
+type AuditLog struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:idx_audit_org_sequence" json:"organization_id"`
+	Sequence       uint64    `gorm:"not null;uniqueIndex:idx_audit_org_sequence" json:"sequence"`
+	CreatedAt      time.Time `gorm:"type:timestamp;not null" json:"created_at"`
+	ProjectID      uint      `gorm:"index" json:"project_id"`
+	ActorType      string    `gorm:"type:varchar(20);not null" json:"actor_type"`
+	ActorID        uint      `json:"actor_id"`
+	ActorName      string    `gorm:"type:varchar(255)" json:"actor_name"`
+	Action         string    `gorm:"type:varchar(100);not null" json:"action"`
+	TargetType     string    `gorm:"type:varchar(50)" json:"target_type"`
+	TargetID       uint      `json:"target_id"`
+	TargetName     string    `gorm:"type:varchar(255)" json:"target_name"`
+	Before         string    `gorm:"type:text" json:"before"`
+	After          string    `gorm:"type:text" json:"after"`
+	RequestID      string    `gorm:"type:varchar(64)" json:"request_id"`
+	ClientIP       string    `gorm:"type:varchar(64)" json:"client_ip"`
+	ResponseStatus int       `json:"response_status"`
+	PrevHash       string    `gorm:"type:varchar(64)" json:"prev_hash"`
+	Hash           string    `gorm:"type:varchar(64);not null" json:"hash"`
+}
+
+// ComputeHash returns the HMAC-SHA256 with the key of the entry content and PrevHash, the ID and Hash are not covered
+func (a AuditLog) ComputeHash(key []byte) string {
+	// the field order of the anonymous struct is the canonical form, do not reorder
+	content, _ := json.Marshal(struct {
+		OrganizationID uint
+		Sequence       uint64
+		CreatedAt      string
+		ProjectID      uint
+		ActorType      string
+		ActorID        uint
+		ActorName      string
+		Action         string
+		TargetType     string
+		TargetID       uint
+		TargetName     string
+		Before         string
+		After          string
+		RequestID      string
+		ClientIP       string
+		ResponseStatus int
+		PrevHash       string
+	}{
+		a.OrganizationID, a.Sequence, a.CreatedAt.UTC().Format(time.RFC3339Nano), a.ProjectID,
+		a.ActorType, a.ActorID, a.ActorName, a.Action, a.TargetType, a.TargetID, a.TargetName,
+		a.Before, a.After, a.RequestID, a.ClientIP, a.ResponseStatus, a.PrevHash,
+	})
+	mac := hmac.New(sha256.New, key)
+	mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// BeforeUpdate keeps the entries immutable from the application, the table also has a trigger
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
+
+// BeforeDelete keeps the entries immutable from the application, the table also has a trigger
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// States of dynamic credential leases
+const (
+	DynamicLeaseActive   = "active"
+	DynamicLeaseRevoking = "revoking"
+	DynamicLeaseRevoked  = "revoked"
+	DynamicLeaseFailed   = "failed" // the role could not be created
+)
+
+// DynamicCredentialSource is a PostgreSQL server that issues a short-lived role to each agent pull
+type DynamicCredentialSource struct {
+	gorm.Model
+	ProjectID      uint   `gorm:"not null;index" json:"project_id"`
+	Name           string `gorm:"type:varchar(100);not null" json:"name"`
+	ConnectionURL  string `gorm:"type:text;not null" json:"-"`              // DSN of a role allowed to create roles
+	GrantTemplate  string `gorm:"type:text;not null" json:"grant_template"` // statements run after the role is created, {{role}} is the role
+	RevokeTemplate string `gorm:"type:text" json:"revoke_template"`         // statements run before the role is dropped
+	RolePrefix     string `gorm:"type:varchar(30)" json:"role_prefix"`
+	TTLSeconds     int64  `gorm:"not null" json:"ttl_seconds"`
+}
+
+// DynamicCredentialLease is a role issued to an agent, the reaper drops it at ExpiresAt
+type DynamicCredentialLease struct {
+	gorm.Model
+	SourceID  uint      `gorm:"not null;index" json:"source_id"`
+	ProjectID uint      `gorm:"not null;index" json:"project_id"`
+	AgentID   uint      `gorm:"index" json:"agent_id"`
+	Username  string    `gorm:"type:varchar(63);not null" json:"username"`
+	Status    string    `gorm:"type:varchar(20);not null;index" json:"status"`
+	ExpiresAt time.Time `gorm:"type:timestamp;index" json:"expires_at"`
+	RevokedAt time.Time `gorm:"type:timestamp;" json:"revoked_at"`
+	Message   string    `gorm:"type:text" json:"message"`
+}
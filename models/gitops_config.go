@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GitOps sync states
+const (
+	GitOpsStatusSynced   = "synced"
+	GitOpsStatusPending  = "pending" // the manifest changed and waits for a review, e.g. because of conflicts
+	GitOpsStatusFailed   = "failed"
+	GitOpsStatusUpToDate = "up_to_date"
+)
+
+// GitOpsConfig is how a project syncs its non-secret parameters from a manifest in its repo
+type GitOpsConfig struct {
+	gorm.Model
+	ProjectID     uint      `gorm:"not null;uniqueIndex" json:"project_id"`
+	Enabled       bool      `gorm:"default:false" json:"enabled"`
+	Path          string    `gorm:"type:varchar(255);not null" json:"path"`
+	Branch        string    `gorm:"type:varchar(255)" json:"branch"` // empty for the default branch
+	AutoApply     bool      `gorm:"default:false" json:"auto_apply"` // apply pushes without conflicts at once
+	WebhookSecret string    `gorm:"type:varchar(255)" json:"-"`
+	Baseline      string    `gorm:"type:text" json:"-"` // JSON of the values the last sync wrote, by stage/environment/name
+	LastSHA       string    `gorm:"type:varchar(64)" json:"last_sha"`
+	LastVersionID uint      `json:"last_version_id"`
+	LastSyncedAt  time.Time `gorm:"type:timestamp;" json:"last_synced_at"`
+	LastStatus    string    `gorm:"type:varchar(20)" json:"last_status"`
+	LastError     string    `gorm:"type:text" json:"last_error"`
+}
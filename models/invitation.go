@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Invitation lets an admin add an email to a project with a role, the invitee sets their own password
+type Invitation struct {
+	gorm.Model
+	OrganizationID uint      `gorm:"not null" json:"organization_id"`
+	Email          string    `gorm:"type:varchar(255);not null" json:"email"`
+	ProjectID      uint      `gorm:"not null" json:"project_id"`
+	RoleID         uint      `gorm:"not null" json:"role_id"`
+	InvitedByID    uint      `json:"invited_by_id"`
+	TokenHash      string    `gorm:"type:varchar(255);not null;index" json:"-"`
+	ExpiresAt      time.Time `gorm:"type:timestamp;" json:"expires_at"`
+	IsAccepted     bool      `gorm:"default:false" json:"is_accepted"`
+	AcceptedAt     time.Time `gorm:"type:timestamp;" json:"accepted_at"`
+
+	Project   Project `json:"project"`
+	Role      Role    `json:"role"`
+	InvitedBy User    `gorm:"foreignKey:InvitedByID" json:"invited_by"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Types of notification channels
+const (
+	NotificationChannelSlack = "slack"
+	NotificationChannelTeams = "teams"
+	NotificationChannelEmail = "email"
+)
+
+// NotificationChannel sends human readable messages about a project to a chat or a mailing list
+type NotificationChannel struct {
+	gorm.Model
+	ProjectID  uint      `gorm:"not null;index" json:"project_id"`
+	Name       string    `gorm:"type:varchar(100);not null" json:"name"`
+	Type       string    `gorm:"type:varchar(20);not null" json:"type"`
+	Target     string    `gorm:"type:text;not null" json:"-"`      // webhook URL, or comma separated emails
+	Events     string    `gorm:"type:text;not null" json:"events"` // comma separated, "*" for every event
+	IsActive   bool      `gorm:"default:true" json:"is_active"`
+	LastSentAt time.Time `gorm:"type:timestamp" json:"last_sent_at"`
+	LastError  string    `gorm:"type:text" json:"last_error"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OIDCProvider is an OpenID Connect identity provider (Google Workspace, Keycloak, Azure AD...) of an organization
+type OIDCProvider struct {
+	gorm.Model
+	OrganizationID  uint   `gorm:"not null;index" json:"organization_id"`
+	Name            string `gorm:"type:varchar(100);not null" json:"name"`
+	IssuerURL       string `gorm:"type:varchar(255);not null" json:"issuer_url"`
+	ClientID        string `gorm:"type:varchar(255);not null" json:"client_id"`
+	ClientSecret    string `gorm:"type:varchar(255)" json:"-"`
+	RedirectURL     string `gorm:"type:varchar(255);not null" json:"redirect_url"`
+	Scopes          string `gorm:"type:varchar(255);default:'openid email profile'" json:"scopes"` // space separated
+	GroupsClaim     string `gorm:"type:varchar(100);default:groups" json:"groups_claim"`
+	IsEnabled       bool   `gorm:"default:true" json:"is_enabled"`
+	AllowJIT        bool   `gorm:"default:true" json:"allow_jit"` // create unknown users on first login
+	RequireVerified bool   `gorm:"default:true" json:"require_verified_email"`
+
+	GroupMappings []OIDCGroupMapping `gorm:"foreignKey:ProviderID" json:"group_mappings"`
+}
+
+// OIDCGroupMapping grants a project role to members of an IdP group, organization admins are only set in the settings
+type OIDCGroupMapping struct {
+	gorm.Model
+	ProviderID uint   `gorm:"not null;index" json:"provider_id"`
+	Group      string `gorm:"type:varchar(255);not null" json:"group"`
+	ProjectID  uint   `json:"project_id"`
+	RoleID     uint   `json:"role_id"`
+}
+
+// OIDCLoginState keeps the state, nonce and PKCE verifier of a login between the redirect and the callback
+type OIDCLoginState struct {
+	gorm.Model
+	State        string    `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Nonce        string    `gorm:"type:varchar(100);not null"`
+	CodeVerifier string    `gorm:"type:varchar(100);not null"`
+	ProviderID   uint      `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"type:timestamp;"`
+}
@@ -6,6 +6,13 @@ import (
 	"gorm.io/gorm"
 )
 
+// MFA policies of an organization
+const (
+	MFAPolicyNone     = "none"
+	MFAPolicyAdmins   = "admins"
+	MFAPolicyEveryone = "everyone"
+)
+
 // Organization model
 type Organization struct {
 	gorm.Model
@@ -14,6 +21,7 @@ type Organization struct {
 	EstablishmentDate time.Time `json:"establishment_date"`
 	Description       string    `gorm:"type:text" json:"description"`
 	Address           string    `gorm:"type:text" json:"address"`
+	MFAPolicy         string    `gorm:"type:varchar(20);default:none" json:"mfa_policy"` // none, admins or everyone
 
 	Projects []Project `gorm:"one2many:organization_prs;" json:"projects"`
 	Users    []User    `gorm:"one2many:organization_usr;" json:"users"`
@@ -20,8 +20,32 @@ type Parameter struct {
 	IsApplied     bool      `gorm:"default:false" json:"is_applied"`
 	EditedAt      time.Time `json:"edited_at"`
 	IsUsingAtFile string    `gorm:"text" json:"is_using_at_file"`
+	// IsEnvironmentSpecific keeps the value out of promotions between environments
+	IsEnvironmentSpecific bool `gorm:"default:false" json:"is_environment_specific"`
+	// RotationDays and ExpiresAt are the rotation policy, the value is due for rotation RotationDays after ValueChangedAt
+	RotationDays   int       `gorm:"default:0" json:"rotation_days"`
+	ExpiresAt      time.Time `gorm:"type:timestamp;" json:"expires_at"`
+	RotationNotice string    `gorm:"type:varchar(20)" json:"-"` // the last rotation status notified
+	// DynamicSourceID makes the value a template filled with credentials issued by the source on each pull
+	DynamicSourceID uint `gorm:"default:0" json:"dynamic_source_id"`
+	// IsDraft marks a parameter opened for a missing reference, agents do not receive it until a value is set
+	IsDraft bool `gorm:"default:false" json:"is_draft"`
+	// ValueChangedAt is when the value was last written with a different value, unlike EditedAt other edits keep it
+	ValueChangedAt time.Time `gorm:"type:timestamp;" json:"value_changed_at"`
+	// IsPlaintext opts the value out of masking, values are secret by default and only their fingerprint is
+	// written to the audit log, webhooks, the event stream and agent logs
+	IsPlaintext bool `gorm:"default:false" json:"is_plaintext"`
 
 	// UpdatedBy   User		`gorm:"foreignKey:UpdatedBy" json:"updated_by"` // foreign key to user model
 	Stage       Stage       `gorm:"foreignKey:StageID" json:"stage"`
 	Environment Environment `gorm:"foreignKey:EnvironmentID" json:"environment"`
 }
+
+// SetValue writes the value, a draft becomes a regular parameter once a value is written
+func (p *Parameter) SetValue(value string) {
+	if value != p.Value {
+		p.ValueChangedAt = time.Now().UTC()
+	}
+	p.Value = value
+	p.IsDraft = false
+}
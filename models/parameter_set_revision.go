@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ParameterSetRevision counts the changes of the parameters an agent of a stage and environment receives,
+// the revision grows by one every time the content hash of that set changes
+type ParameterSetRevision struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ProjectID     uint      `gorm:"not null;uniqueIndex:idx_parameter_set_revision" json:"project_id"`
+	StageID       uint      `gorm:"not null;uniqueIndex:idx_parameter_set_revision" json:"stage_id"`
+	EnvironmentID uint      `gorm:"not null;uniqueIndex:idx_parameter_set_revision" json:"environment_id"`
+	Revision      int64     `gorm:"not null" json:"revision"`
+	Hash          string    `gorm:"type:varchar(64);not null" json:"hash"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// States of repository scans
+const (
+	RepoScanRunning   = "running"
+	RepoScanSucceeded = "succeeded"
+	RepoScanFailed    = "failed"
+)
+
+// RepoScan is the last scan of the repository of a project for parameter names
+type RepoScan struct {
+	gorm.Model
+	ProjectID   uint      `gorm:"not null;uniqueIndex" json:"project_id"`
+	Status      string    `gorm:"type:varchar(20);not null" json:"status"`
+	CommitSHA   string    `gorm:"type:varchar(40)" json:"commit"`
+	StartedAt   time.Time `gorm:"type:timestamp;" json:"started_at"`
+	FinishedAt  time.Time `gorm:"type:timestamp;" json:"finished_at"`
+	Files       int       `json:"files"`
+	Occurrences int       `json:"occurrences"`
+	Message     string    `gorm:"type:text" json:"message"`
+}
+
+// ParameterUsage is a line of the repository where a parameter name appears, by name since parameters are copied per version
+type ParameterUsage struct {
+	ID        uint   `gorm:"primarykey" json:"id"`
+	ProjectID uint   `gorm:"not null;index:idx_parameter_usage_name" json:"project_id"`
+	Name      string `gorm:"type:varchar(100);not null;index:idx_parameter_usage_name" json:"name"`
+	Path      string `gorm:"type:varchar(500);not null" json:"path"`
+	Line      int    `json:"line"`
+	Snippet   string `gorm:"type:varchar(255)" json:"snippet"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scopes of a personal access token
+const (
+	TokenScopeReadOnly = "read"    // GET requests on everything the user can see
+	TokenScopeProject  = "project" // every request on the routes of one project, nothing else
+	TokenScopeAdmin    = "admin"   // same rights as the user
+)
+
+// PersonalAccessToken lets scripts call the API as a user, only the hash of the token is stored
+type PersonalAccessToken struct {
+	gorm.Model
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	Name       string    `gorm:"type:varchar(100);not null" json:"name"`
+	TokenHash  string    `gorm:"type:varchar(255);not null;uniqueIndex" json:"-"`
+	Prefix     string    `gorm:"type:varchar(20)" json:"prefix"`
+	Scope      string    `gorm:"type:varchar(20);not null" json:"scope"`
+	ProjectID  uint      `json:"project_id"`
+	ExpiresAt  time.Time `gorm:"type:timestamp;" json:"expires_at"` // zero value never expires
+	LastUsedAt time.Time `gorm:"type:timestamp;" json:"last_used_at"`
+	LastUsedIP string    `gorm:"type:varchar(64)" json:"last_used_ip"`
+	IsRevoked  bool      `gorm:"default:false" json:"is_revoked"`
+	RevokedAt  time.Time `gorm:"type:timestamp;" json:"revoked_at"`
+}
+
+// IsExpired tells if the token has an expiry in the past
+func (t PersonalAccessToken) IsExpired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
@@ -23,6 +23,8 @@ type Project struct {
 	ArchivedAt      time.Time         `gorm:"type:timestamp;" json:"archived_at"`
 	LatestVersionID uint              `gorm:"foreignKey:LatestVersionID" json:"latest_version_id"`
 	AutoUpdate      bool              `gorm:"default:true" json:"auto_update"`
+	TemplateID      uint              `json:"template_id"` // the template the project was created from
+	BlockExpired    bool              `gorm:"default:false" json:"block_expired_secrets"`
 	LatestVersion   Version           `gorm:"foreignKey:LatestVersionID" json:"latest_version"`
 	Stages          []Stage           `gorm:"one2many:project_stages;" json:"stages"`
 	Environments    []Environment     `gorm:"one2many:project_environments;" json:"environments"`
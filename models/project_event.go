@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ProjectEvent is a change pushed to the live stream of a project, its ID is the resumable event ID
+type ProjectEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	ProjectID uint      `gorm:"not null;index" json:"project_id"`
+	Event     string    `gorm:"type:varchar(100);not null" json:"event"`
+	Data      string    `gorm:"type:text;not null" json:"data"` // JSON
+}
+
+// ProjectEventPrune is the newest event of a project deleted by the retention,
+// a client resuming from an older event ID missed events and has to reload
+type ProjectEventPrune struct {
+	ProjectID    uint      `gorm:"primaryKey;autoIncrement:false" json:"project_id"`
+	LastPrunedID uint      `gorm:"not null" json:"last_pruned_id"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
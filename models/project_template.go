@@ -0,0 +1,60 @@
+package models
+
+import "gorm.io/gorm"
+
+// ProjectTemplate is the layout of the new projects of an organization, the default one is used when none is given
+type ProjectTemplate struct {
+	gorm.Model
+	OrganizationID uint   `gorm:"not null;index" json:"organization_id"`
+	Name           string `gorm:"type:varchar(100);not null" json:"name"`
+	Description    string `gorm:"type:text" json:"description"`
+	IsDefault      bool   `gorm:"default:false" json:"is_default"`
+
+	Stages       []ProjectTemplateStage       `gorm:"foreignKey:TemplateID" json:"stages"`
+	Environments []ProjectTemplateEnvironment `gorm:"foreignKey:TemplateID" json:"environments"`
+	Parameters   []ProjectTemplateParameter   `gorm:"foreignKey:TemplateID" json:"parameters"`
+	Agents       []ProjectTemplateAgent       `gorm:"foreignKey:TemplateID" json:"agents"`
+}
+
+// ProjectTemplateStage is a stage created with the project, Position keeps the order
+type ProjectTemplateStage struct {
+	gorm.Model
+	TemplateID  uint   `gorm:"not null;index" json:"template_id"`
+	Name        string `gorm:"type:varchar(100);not null" json:"name"`
+	Description string `gorm:"type:text" json:"description"`
+	Color       string `gorm:"type:varchar(100)" json:"color"`
+	Position    int    `json:"position"`
+}
+
+// ProjectTemplateEnvironment is an environment created with the project, Position keeps the order
+type ProjectTemplateEnvironment struct {
+	gorm.Model
+	TemplateID  uint   `gorm:"not null;index" json:"template_id"`
+	Name        string `gorm:"type:varchar(100);not null" json:"name"`
+	Description string `gorm:"type:text" json:"description"`
+	Color       string `gorm:"type:varchar(100)" json:"color"`
+	Position    int    `json:"position"`
+}
+
+// ProjectTemplateParameter is a parameter the projects of the template must have,
+// in one stage and environment or in every one when they are empty
+type ProjectTemplateParameter struct {
+	gorm.Model
+	TemplateID  uint   `gorm:"not null;index" json:"template_id"`
+	Name        string `gorm:"type:varchar(100);not null" json:"name"`
+	Description string `gorm:"type:varchar(255)" json:"description"`
+	Type        string `gorm:"type:varchar(20);default:string" json:"type"` // string, number, boolean, url or secret
+	Stage       string `gorm:"type:varchar(100)" json:"stage"`
+	Environment string `gorm:"type:varchar(100)" json:"environment"`
+}
+
+// ProjectTemplateAgent is an agent created with the project, its workflow is found by name in the repo
+type ProjectTemplateAgent struct {
+	gorm.Model
+	TemplateID   uint   `gorm:"not null;index" json:"template_id"`
+	Name         string `gorm:"type:varchar(100);not null" json:"name"`
+	Stage        string `gorm:"type:varchar(100);not null" json:"stage"`
+	Environment  string `gorm:"type:varchar(100);not null" json:"environment"`
+	WorkflowName string `gorm:"type:varchar(100)" json:"workflow_name"`
+	Description  string `gorm:"type:text" json:"description"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a single-use MFA recovery code, stored as a bcrypt hash
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint      `gorm:"not null;index" json:"user_id"`
+	CodeHash string    `gorm:"type:varchar(255);not null" json:"-"`
+	IsUsed   bool      `gorm:"default:false" json:"is_used"`
+	UsedAt   time.Time `gorm:"type:timestamp;" json:"used_at"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of scheduled changes
+const (
+	ScheduledChangeParameterUpdate = "parameter_update"
+	ScheduledChangePromotion       = "promotion"
+)
+
+// States of scheduled changes
+const (
+	ScheduledChangeScheduled = "scheduled"
+	ScheduledChangeApplying  = "applying" // claimed by a worker, it can not be cancelled anymore
+	ScheduledChangeActive    = "active"   // applied, the revert is pending
+	ScheduledChangeReverting = "reverting"
+	ScheduledChangeApplied   = "applied"
+	ScheduledChangeReverted  = "reverted"
+	ScheduledChangeCancelled = "cancelled"
+	ScheduledChangeFailed    = "failed"
+)
+
+// ScheduledChange is a parameter update or a promotion applied by the scheduler at ApplyAt,
+// and reverted RevertAfter seconds later when it is set
+type ScheduledChange struct {
+	gorm.Model
+	ProjectID   uint      `gorm:"not null;index" json:"project_id"`
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedBy   string    `gorm:"type:varchar(100)" json:"created_by"`
+	Kind        string    `gorm:"type:varchar(30);not null" json:"kind"`
+	Description string    `gorm:"type:text" json:"description"`
+	Status      string    `gorm:"type:varchar(20);not null;index" json:"status"`
+	ApplyAt     time.Time `gorm:"type:timestamp;index" json:"apply_at"`
+	RevertAfter int64     `json:"revert_after"` // seconds, no revert when 0
+	RevertAt    time.Time `gorm:"type:timestamp;index" json:"revert_at"`
+	Spec        string    `gorm:"type:text;not null" json:"-"` // the parameter or the promotion as JSON
+	Applied     string    `gorm:"type:text" json:"-"`          // the applied changes as JSON, read by the revert
+	VersionID   uint      `json:"version_id"`                  // the version created by a promotion
+	AppliedAt   time.Time `gorm:"type:timestamp;" json:"applied_at"`
+	RevertedAt  time.Time `gorm:"type:timestamp;" json:"reverted_at"`
+	CancelledBy string    `gorm:"type:varchar(100)" json:"cancelled_by"`
+	Message     string    `gorm:"type:text" json:"message"`
+	// ClaimedUntil lets another worker take over an applying or reverting change whose worker stopped
+	ClaimedUntil time.Time `gorm:"type:timestamp;index" json:"-"`
+}
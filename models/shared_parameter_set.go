@@ -0,0 +1,35 @@
+package models
+
+import "gorm.io/gorm"
+
+// SharedParameterSet is a set of parameters of the organization, like the SMTP host, that projects link to instead of copying
+type SharedParameterSet struct {
+	gorm.Model
+	OrganizationID uint   `gorm:"not null;index" json:"organization_id"`
+	Name           string `gorm:"type:varchar(100);not null" json:"name"`
+	Description    string `gorm:"type:text" json:"description"`
+
+	Parameters []SharedParameter `gorm:"foreignKey:SetID" json:"parameters"`
+}
+
+// SharedParameter is a value of a set for the environments of that name, or for every environment when Environment is empty
+type SharedParameter struct {
+	gorm.Model
+	SetID       uint   `gorm:"not null;index" json:"set_id"`
+	Name        string `gorm:"type:varchar(100);not null" json:"name"`
+	Value       string `gorm:"type:varchar(255)" json:"value"`
+	Description string `gorm:"type:varchar(255)" json:"description"`
+	Environment string `gorm:"type:varchar(100)" json:"environment"`
+	// IsPlaintext opts the value out of masking in the audit log, like the flag of Parameter
+	IsPlaintext bool `gorm:"default:false" json:"is_plaintext"`
+}
+
+// ProjectParameterSet links a project to a shared set, parameters of the project win over the set. Values for the
+// environment win over values for every environment, then sets linked later win over the ones linked before
+type ProjectParameterSet struct {
+	gorm.Model
+	ProjectID uint `gorm:"not null;uniqueIndex:idx_project_parameter_set" json:"project_id"`
+	SetID     uint `gorm:"not null;uniqueIndex:idx_project_parameter_set" json:"set_id"`
+
+	Set SharedParameterSet `gorm:"foreignKey:SetID" json:"set"`
+}
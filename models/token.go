@@ -1,13 +1,27 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
-// Token model
+// Purposes of single-use tokens
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeStreamTicket      = "stream_ticket"
+)
+
+// Token model, a signed single-use token sent by email. Only the hash of the token is stored.
 type Token struct {
 	gorm.Model
-	UserID uint   `gorm:"not null" json:"user_id"`
-	Token  string `gorm:"type:varchar(255);not null" json:"token"`
-	User   User   `gorm:"foreignKey:UserID" json:"user"`
+	UserID    uint      `gorm:"not null" json:"user_id"`
+	Token     string    `gorm:"type:varchar(255);not null;index" json:"-"`
+	Purpose   string    `gorm:"type:varchar(50)" json:"purpose"`
+	ExpiresAt time.Time `gorm:"type:timestamp;" json:"expires_at"`
+	ProjectID uint      `gorm:"default:0" json:"project_id"` // the project a stream ticket opens
+	IsUsed    bool      `gorm:"default:false" json:"is_used"`
+	UsedAt    time.Time `gorm:"type:timestamp;" json:"used_at"`
+	User      User      `gorm:"foreignKey:UserID" json:"user"`
 }
@@ -21,6 +21,17 @@ type User struct {
 	ArchivedAt          time.Time `gorm:"type:timestamp;" json:"archived_at"`
 	AvatarURL           string    `gorm:"type:varchar(255);" json:"avatar_url"`
 	LastLogin           time.Time `gorm:"type:timestamp;" json:"last_login"`
+	EmailVerified       bool      `gorm:"default:false" json:"email_verified"`
+	EmailVerifiedAt     time.Time `gorm:"type:timestamp;" json:"email_verified_at"`
+	MFAEnabled          bool      `gorm:"default:false" json:"mfa_enabled"`
+	MFASecret           string    `gorm:"type:varchar(100);" json:"-"` // base32 TOTP secret, set on enrollment
+	MFAEnabledAt        time.Time `gorm:"type:timestamp;" json:"mfa_enabled_at"`
+	MFALastStep         int64     `gorm:"default:0" json:"-"`               // TOTP time step of the last accepted code, older codes are replays
+	MFAFailedAttempts   int       `gorm:"default:0" json:"-"`               // invalid codes since the last accepted one
+	MFALockedUntil      time.Time `gorm:"type:timestamp;" json:"-"`         // codes are refused until then after too many invalid ones
+	OIDCProviderID      uint      `json:"oidc_provider_id"`                 // set for users provisioned by single sign-on
+	OIDCSubject         string    `gorm:"type:varchar(255);index" json:"-"` // "sub" claim of the identity provider
 
 	UserRoleProjects []UserRoleProject `gorm:"foreignKey:UserID" json:"user_role_projects"`
+	RecoveryCodes    []RecoveryCode    `gorm:"foreignKey:UserID" json:"-"`
 }
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook delivery states
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook is a subscription of an external URL to events of a project
+type Webhook struct {
+	gorm.Model
+	ProjectID   uint   `gorm:"not null;index" json:"project_id"`
+	URL         string `gorm:"type:varchar(500);not null" json:"url"`
+	Secret      string `gorm:"type:varchar(255);not null" json:"-"`
+	Events      string `gorm:"type:text;not null" json:"events"` // comma separated, "*" for every event
+	Description string `gorm:"type:text" json:"description"`
+	IsActive    bool   `gorm:"default:true" json:"is_active"`
+}
+
+// WebhookDelivery is one event sent to a webhook, it is retried until it succeeds or runs out of attempts
+type WebhookDelivery struct {
+	gorm.Model
+	WebhookID      uint      `gorm:"not null;index" json:"webhook_id"`
+	ProjectID      uint      `gorm:"not null;index" json:"project_id"`
+	DeliveryID     string    `gorm:"type:varchar(64);not null;index" json:"delivery_id"`
+	Event          string    `gorm:"type:varchar(100);not null" json:"event"`
+	Payload        string    `gorm:"type:text;not null" json:"payload"`
+	Status         string    `gorm:"type:varchar(20);not null;index" json:"status"`
+	Attempts       int       `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  time.Time `gorm:"type:timestamp;index" json:"next_attempt_at"`
+	ResponseStatus int       `json:"response_status"`
+	ResponseBody   string    `gorm:"type:text" json:"response_body"`
+	Error          string    `gorm:"type:text" json:"error"`
+	Duration       int       `json:"duration"` // milliseconds of the last attempt
+	DeliveredAt    time.Time `gorm:"type:timestamp;" json:"delivered_at"`
+	RedeliveryOf   uint      `json:"redelivery_of"`
+}
@@ -12,6 +12,7 @@ type WorkflowLog struct {
 	WorkflowRunId uint      `json:"workflow_run_id"`
 	AttemptNumber int       `json:"attempt_number"`
 	State         string    `json:"state"`
+	Conclusion    string    `json:"conclusion"`
 	StartedAt     time.Time `json:"started_at"`
 	Duration      int       `json:"duration"`
 	ProjectID     uint      `json:"project_id"`
@@ -0,0 +1,90 @@
+package auditsink
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is an audit log entry forwarded to the sinks, Data is the JSON of the entry
+type Event struct {
+	ID             string
+	Time           time.Time
+	OrganizationID uint
+	Action         string
+	Actor          string
+	RequestID      string
+	Status         int
+	Data           []byte
+}
+
+// Sink receives audit events, implementations are SyslogSink, WebhookSink and FileSink
+type Sink interface {
+	Name() string
+	Write(event Event) error
+	Close() error
+}
+
+// Publisher is what the application calls when an audit entry is stored
+type Publisher interface {
+	Publish(event Event)
+}
+
+// NopPublisher drops the events, used when no sink is configured
+type NopPublisher struct{}
+
+func (NopPublisher) Publish(Event) {}
+
+// NewPublisherFromEnv builds the sinks listed in AUDIT_SINKS (comma separated: syslog, webhook, file)
+func NewPublisherFromEnv() Publisher {
+	var sinks []Sink
+	for _, name := range strings.Split(os.Getenv("AUDIT_SINKS"), ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+			continue
+		case "syslog":
+			sinks = append(sinks, &SyslogSink{
+				Network:  envOr("AUDIT_SYSLOG_NETWORK", "udp"),
+				Address:  os.Getenv("AUDIT_SYSLOG_ADDRESS"),
+				AppName:  envOr("AUDIT_SYSLOG_APP_NAME", "parameter-store"),
+				Facility: envInt("AUDIT_SYSLOG_FACILITY", FacilityLogAudit),
+			})
+		case "webhook":
+			sinks = append(sinks, &WebhookSink{
+				URL:   os.Getenv("AUDIT_WEBHOOK_URL"),
+				Token: os.Getenv("AUDIT_WEBHOOK_TOKEN"),
+			})
+		case "file":
+			sinks = append(sinks, &FileSink{
+				Path:       envOr("AUDIT_FILE_PATH", "audit.log"),
+				MaxBytes:   int64(envInt("AUDIT_FILE_MAX_MB", 100)) * 1024 * 1024,
+				MaxBackups: envInt("AUDIT_FILE_MAX_BACKUPS", 5),
+			})
+		default:
+			log.Println("Unknown audit sink", name)
+		}
+	}
+	if len(sinks) == 0 {
+		return NopPublisher{}
+	}
+	dispatcher := NewDispatcher(envInt("AUDIT_SINK_BUFFER", 1000), sinks...)
+	dispatcher.Start()
+	return dispatcher
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,83 @@
+package auditsink
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// retry policy of a sink write, the event is dropped after the last attempt
+const (
+	maxAttempts  = 3
+	retryBackoff = time.Second
+)
+
+// Dispatcher forwards events to the sinks from a buffered queue, so a slow sink never blocks a request
+type Dispatcher struct {
+	sinks  []Sink
+	events chan Event
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+func NewDispatcher(buffer int, sinks ...Sink) *Dispatcher {
+	return &Dispatcher{sinks: sinks, events: make(chan Event, buffer)}
+}
+
+// Start runs one worker per sink
+func (d *Dispatcher) Start() {
+	queues := make([]chan Event, len(d.sinks))
+	for i, sink := range d.sinks {
+		queues[i] = make(chan Event, cap(d.events))
+		d.wg.Add(1)
+		go d.run(sink, queues[i])
+	}
+	go func() {
+		for event := range d.events {
+			for _, queue := range queues {
+				select {
+				case queue <- event:
+				default:
+					log.Println("Audit sink queue is full, event dropped:", event.ID)
+				}
+			}
+		}
+		for _, queue := range queues {
+			close(queue)
+		}
+	}()
+}
+
+func (d *Dispatcher) run(sink Sink, queue chan Event) {
+	defer d.wg.Done()
+	for event := range queue {
+		for attempt := 1; attempt <= maxAttempts; attempt++ {
+			err := sink.Write(event)
+			if err == nil {
+				break
+			}
+			log.Printf("Audit sink %s failed (attempt %d): %v\n", sink.Name(), attempt, err)
+			if attempt < maxAttempts {
+				time.Sleep(retryBackoff * time.Duration(attempt))
+			}
+		}
+	}
+	if err := sink.Close(); err != nil {
+		log.Printf("Audit sink %s failed to close: %v\n", sink.Name(), err)
+	}
+}
+
+// Publish queues the event, it is dropped when the queue is full
+func (d *Dispatcher) Publish(event Event) {
+	select {
+	case d.events <- event:
+	default:
+		log.Println("Audit event queue is full, event dropped:", event.ID)
+	}
+}
+
+// Close flushes the queued events and closes the sinks
+func (d *Dispatcher) Close() {
+	d.once.Do(func() { close(d.events) })
+	d.wg.Wait()
+}
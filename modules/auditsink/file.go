@@ -0,0 +1,87 @@
+package auditsink
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileSink appends events as NDJSON and rotates the file when it reaches MaxBytes:
+// audit.log becomes audit.log.1, audit.log.1 becomes audit.log.2, up to MaxBackups files
+type FileSink struct {
+	Path       string
+	MaxBytes   int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func (s *FileSink) Name() string { return "file" }
+
+func (s *FileSink) Write(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	line := append(append([]byte{}, event.Data...), '\n')
+	if s.MaxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.MaxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+	if s.MaxBackups <= 0 {
+		if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return s.open()
+	}
+	os.Remove(fmt.Sprintf("%s.%d", s.Path, s.MaxBackups))
+	for i := s.MaxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.Path, i), fmt.Sprintf("%s.%d", s.Path, i+1))
+	}
+	if err := os.Rename(s.Path, s.Path+".1"); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file != nil {
+		err := s.file.Close()
+		s.file = nil
+		return err
+	}
+	return nil
+}
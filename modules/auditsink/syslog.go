@@ -0,0 +1,129 @@
+package auditsink
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FacilityLogAudit is the syslog facility 13 "log audit"
+const FacilityLogAudit = 13
+
+// syslog severities
+const (
+	severityWarning = 4
+	severityInfo    = 6
+)
+
+// structuredDataID identifies the SD element, 32473 is the enterprise number reserved for documentation
+const structuredDataID = "audit@32473"
+
+// SyslogSink sends RFC 5424 messages over udp, tcp or tls. Stream transports use octet counting (RFC 6587).
+type SyslogSink struct {
+	Network  string // udp, tcp or tls
+	Address  string
+	AppName  string
+	Facility int
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (s *SyslogSink) Name() string { return "syslog" }
+
+func (s *SyslogSink) Write(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	message := FormatRFC5424(s.Facility, event, s.AppName)
+	if s.Network != "udp" {
+		message = strconv.Itoa(len(message)) + " " + message
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := s.conn.Write([]byte(message)); err != nil {
+		// reconnect on the next attempt
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	if s.Address == "" {
+		return nil, fmt.Errorf("AUDIT_SYSLOG_ADDRESS is not set")
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if s.Network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", s.Address, &tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	return dialer.Dial(s.Network, s.Address)
+}
+
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// FormatRFC5424 returns "<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG" with the event JSON as MSG
+func FormatRFC5424(facility int, event Event, appName string) string {
+	severity := severityInfo
+	if event.Status >= 400 {
+		severity = severityWarning
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	structuredData := fmt.Sprintf(`[%s id="%s" org="%d" action="%s" actor="%s" request_id="%s" status="%d"]`,
+		structuredDataID, escapeParam(event.ID), event.OrganizationID, escapeParam(event.Action),
+		escapeParam(event.Actor), escapeParam(event.RequestID), event.Status)
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		facility*8+severity,
+		event.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(hostname, 255),
+		headerField(appName, 48),
+		os.Getpid(),
+		headerField(event.Action, 32),
+		structuredData,
+		event.Data,
+	)
+}
+
+// escapeParam escapes '"', '\' and ']' in a structured data value
+func escapeParam(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+// headerField keeps printable US-ASCII without spaces, as required for header fields
+func headerField(value string, maxLength int) string {
+	var b strings.Builder
+	for _, r := range value {
+		if r > 32 && r < 127 {
+			b.WriteRune(r)
+		}
+	}
+	result := b.String()
+	if result == "" {
+		return "-"
+	}
+	if len(result) > maxLength {
+		result = result[:maxLength]
+	}
+	return result
+}
@@ -0,0 +1,45 @@
+package auditsink
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebhookSink posts each event as JSON to an HTTPS endpoint, e.g. a SIEM HTTP collector
+type WebhookSink struct {
+	URL   string
+	Token string // sent as a bearer token when set
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+func (s *WebhookSink) Name() string { return "webhook" }
+
+func (s *WebhookSink) Write(event Event) error {
+	if !strings.HasPrefix(s.URL, "https://") && !strings.HasPrefix(s.URL, "http://localhost") {
+		return fmt.Errorf("AUDIT_WEBHOOK_URL must be an https url")
+	}
+	request, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(event.Data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Audit-Event-ID", event.ID)
+	if s.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	response, err := webhookClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", response.Status)
+	}
+	return nil
+}
+
+func (s *WebhookSink) Close() error { return nil }
@@ -0,0 +1,207 @@
+package declarative
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the resource or one of its parents does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when the name is ambiguous, the resource already exists on a create or the project is archived
+	ErrConflict = errors.New("conflict")
+	// ErrPreconditionFailed is returned when the resource changed since the ETag given in If-Match was read
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// Client calls the declarative API under /api/v1/declarative of a Parameter Store server
+type Client struct {
+	BaseURL    string // e.g. https://param-store.example.com
+	Token      string // personal access token or session token
+	HTTPClient *http.Client
+}
+
+// WriteOptions are the preconditions of a PUT or DELETE
+type WriteOptions struct {
+	CreateOnly bool   // send If-None-Match: *, answered with ErrConflict when the resource exists
+	IfMatch    string // only write when the resource still has this ETag
+}
+
+// Result tells what a PUT did
+type Result struct {
+	Created bool
+	Changed bool
+	ETag    string
+}
+
+// ProjectPath is the path of a project, the others are built on it
+func ProjectPath(project string) string {
+	return "/projects/" + url.PathEscape(project)
+}
+
+func StagePath(project, stage string) string {
+	return ProjectPath(project) + "/stages/" + url.PathEscape(stage)
+}
+
+func EnvironmentPath(project, environment string) string {
+	return ProjectPath(project) + "/environments/" + url.PathEscape(environment)
+}
+
+func AgentPath(project, agent string) string {
+	return ProjectPath(project) + "/agents/" + url.PathEscape(agent)
+}
+
+func ParameterPath(project, stage, environment, parameter string) string {
+	return StagePath(project, stage) + "/environments/" + url.PathEscape(environment) + "/parameters/" + url.PathEscape(parameter)
+}
+
+// Get reads the resource at path into out, under the key the API answers with (project, stage, ...), and returns its ETag
+func (c *Client) Get(ctx context.Context, path string, key string, out interface{}) (string, error) {
+	response, err := c.do(ctx, http.MethodGet, path, nil, WriteOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if err := decodeKey(response.Body, key, out); err != nil {
+		return "", err
+	}
+	return response.Header.Get("ETag"), nil
+}
+
+// Put creates or updates the resource at path and reads the stored resource into out
+func (c *Client) Put(ctx context.Context, path string, key string, body interface{}, options WriteOptions, out interface{}) (Result, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return Result{}, err
+	}
+	response, err := c.do(ctx, http.MethodPut, path, payload, options)
+	if err != nil {
+		return Result{}, err
+	}
+	defer response.Body.Close()
+	raw, err := io.ReadAll(response.Body)
+	if err != nil {
+		return Result{}, err
+	}
+	var result struct {
+		Created bool `json:"created"`
+		Changed bool `json:"changed"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return Result{}, fmt.Errorf("error decoding response: %v", err)
+	}
+	if out != nil {
+		if err := decodeKey(bytes.NewReader(raw), key, out); err != nil {
+			return Result{}, err
+		}
+	}
+	return Result{Created: result.Created, Changed: result.Changed, ETag: response.Header.Get("ETag")}, nil
+}
+
+// Delete archives the resource at path
+func (c *Client) Delete(ctx context.Context, path string, options WriteOptions) error {
+	response, err := c.do(ctx, http.MethodDelete, path, nil, options)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+// PutAgent creates or updates an agent, the api token is only returned when the agent was created
+func (c *Client) PutAgent(ctx context.Context, project, name string, body AgentBody, options WriteOptions) (Agent, string, Result, error) {
+	var agent Agent
+	var token struct {
+		APIToken string `json:"api_token"`
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return agent, "", Result{}, err
+	}
+	response, err := c.do(ctx, http.MethodPut, AgentPath(project, name), payload, options)
+	if err != nil {
+		return agent, "", Result{}, err
+	}
+	defer response.Body.Close()
+	raw, err := io.ReadAll(response.Body)
+	if err != nil {
+		return agent, "", Result{}, err
+	}
+	var result struct {
+		Created bool `json:"created"`
+		Changed bool `json:"changed"`
+	}
+	json.Unmarshal(raw, &token)
+	json.Unmarshal(raw, &result)
+	if err := decodeKey(bytes.NewReader(raw), "agent", &agent); err != nil {
+		return agent, "", Result{}, err
+	}
+	return agent, token.APIToken, Result{Created: result.Created, Changed: result.Changed, ETag: response.Header.Get("ETag")}, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, payload []byte, options WriteOptions) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	endpoint := strings.TrimSuffix(c.BaseURL, "/") + "/api/v1/declarative" + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if options.CreateOnly {
+		req.Header.Set("If-None-Match", "*")
+	}
+	if options.IfMatch != "" {
+		req.Header.Set("If-Match", options.IfMatch)
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 300 {
+		return response, nil
+	}
+	defer response.Body.Close()
+	var apiError struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(response.Body, 4096)).Decode(&apiError)
+	switch response.StatusCode {
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, apiError.Error)
+	case http.StatusConflict:
+		return nil, fmt.Errorf("%w: %s", ErrConflict, apiError.Error)
+	case http.StatusPreconditionFailed:
+		return nil, fmt.Errorf("%w: %s", ErrPreconditionFailed, apiError.Error)
+	}
+	return nil, fmt.Errorf("parameter store responded %s: %s", response.Status, apiError.Error)
+}
+
+func decodeKey(body io.Reader, key string, out interface{}) error {
+	var envelope map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&envelope); err != nil {
+		return fmt.Errorf("error decoding response: %v", err)
+	}
+	raw, ok := envelope[key]
+	if !ok {
+		return fmt.Errorf("response has no %s", key)
+	}
+	return json.Unmarshal(raw, out)
+}
@@ -0,0 +1,67 @@
+package declarative
+
+// ProjectBody is written by PUT on a project, an empty RepoURL or RepoApiToken keeps the stored one
+type ProjectBody struct {
+	Description  string `json:"description"`
+	RepoURL      string `json:"repo_url,omitempty"`
+	RepoApiToken string `json:"repo_api_token,omitempty"`
+	AutoUpdate   *bool  `json:"auto_update,omitempty"`
+}
+
+type Project struct {
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	RepoURL       string `json:"repo_url"`
+	AutoUpdate    bool   `json:"auto_update"`
+	IsArchived    bool   `json:"is_archived"`
+	LatestVersion string `json:"latest_version"`
+}
+
+// StageBody is written by PUT on a stage or an environment
+type StageBody struct {
+	Description string `json:"description"`
+	Color       string `json:"color"`
+}
+
+// Stage is a stage or an environment
+type Stage struct {
+	ID          uint   `json:"ID"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+	IsArchived  bool   `json:"is_archived"`
+}
+
+type AgentBody struct {
+	Stage        string `json:"stage"`
+	Environment  string `json:"environment"`
+	WorkflowName string `json:"workflow_name"`
+	Description  string `json:"description"`
+}
+
+type Agent struct {
+	ID           uint   `json:"id"`
+	Name         string `json:"name"`
+	Stage        string `json:"stage"`
+	Environment  string `json:"environment"`
+	WorkflowName string `json:"workflow_name"`
+	Description  string `json:"description"`
+	IsArchived   bool   `json:"is_archived"`
+}
+
+type ParameterBody struct {
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
+
+type Parameter struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+	Stage       string `json:"stage"`
+	Environment string `json:"environment"`
+	IsApplied   bool   `json:"is_applied"`
+	IsArchived  bool   `json:"is_archived"`
+}
@@ -0,0 +1,178 @@
+// Package dynamiccred issues short-lived PostgreSQL roles and drops them once they expire
+package dynamiccred
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// roleNameMaxLength is the longest identifier PostgreSQL keeps
+const roleNameMaxLength = 63
+
+// Placeholders of the parameter values and of the templates
+const (
+	PlaceholderUsername  = "{{username}}"
+	PlaceholderPassword  = "{{password}}"
+	PlaceholderExpiresAt = "{{expires_at}}"
+	PlaceholderRole      = "{{role}}" // the quoted role name in grant and revoke templates
+)
+
+var unsafePrefix = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// Credentials are the login of an issued role
+type Credentials struct {
+	Username  string
+	Password  string
+	ExpiresAt time.Time
+}
+
+func randomHex(bytes int) (string, error) {
+	buffer := make([]byte, bytes)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
+
+// NewCredentials generates a unique role name starting with the prefix and a random password, valid for ttl
+func NewCredentials(prefix string, ttl time.Duration, now time.Time) (Credentials, error) {
+	suffix, err := randomHex(6)
+	if err != nil {
+		return Credentials{}, err
+	}
+	password, err := randomHex(24)
+	if err != nil {
+		return Credentials{}, err
+	}
+	prefix = strings.Trim(unsafePrefix.ReplaceAllString(strings.ToLower(prefix), "_"), "_")
+	if prefix == "" {
+		prefix = "ps"
+	}
+	username := fmt.Sprintf("%s_%d_%s", prefix, now.Unix(), suffix)
+	if len(username) > roleNameMaxLength {
+		username = username[len(username)-roleNameMaxLength:]
+	}
+	return Credentials{Username: username, Password: password, ExpiresAt: now.Add(ttl).UTC()}, nil
+}
+
+// QuoteIdentifier quotes a role name for SQL
+func QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// QuoteLiteral quotes a string for SQL, CREATE ROLE takes no bind parameters
+func QuoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// Statements renders the role into a template and splits it into statements on semicolons
+func Statements(template string, role string) []string {
+	var statements []string
+	for _, statement := range strings.Split(strings.ReplaceAll(template, PlaceholderRole, QuoteIdentifier(role)), ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// CreateStatements creates the login role, valid until its expiry even when the reaper is late, then grants it
+func CreateStatements(credentials Credentials, grantTemplate string) []string {
+	create := fmt.Sprintf("CREATE ROLE %s WITH LOGIN PASSWORD %s VALID UNTIL %s",
+		QuoteIdentifier(credentials.Username), QuoteLiteral(credentials.Password), QuoteLiteral(credentials.ExpiresAt.UTC().Format("2006-01-02 15:04:05+00")))
+	return append([]string{create}, Statements(grantTemplate, credentials.Username)...)
+}
+
+// RevokeStatements runs the revoke template, ends the sessions of the role and drops it with its privileges
+func RevokeStatements(username string, revokeTemplate string) []string {
+	statements := Statements(revokeTemplate, username)
+	return append(statements,
+		fmt.Sprintf("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE usename = %s", QuoteLiteral(username)),
+		fmt.Sprintf("DROP OWNED BY %s", QuoteIdentifier(username)),
+		fmt.Sprintf("DROP ROLE %s", QuoteIdentifier(username)),
+	)
+}
+
+// HasPlaceholder tells if a parameter value takes anything from the credentials
+func HasPlaceholder(value string) bool {
+	return strings.Contains(value, PlaceholderUsername) || strings.Contains(value, PlaceholderPassword)
+}
+
+// Render fills the credentials into a parameter value like postgres://{{username}}:{{password}}@db:5432/app
+func Render(value string, credentials Credentials) string {
+	return strings.NewReplacer(
+		PlaceholderUsername, credentials.Username,
+		PlaceholderPassword, credentials.Password,
+		PlaceholderExpiresAt, credentials.ExpiresAt.UTC().Format(time.RFC3339),
+	).Replace(value)
+}
+
+func open(dsn string) (*gorm.DB, func(), error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, func() { sqlDB.Close() }, nil
+}
+
+func execAll(tx *gorm.DB, statements []string) error {
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping checks that the server of the DSN is reachable and the login works
+func Ping(dsn string) error {
+	db, closeDB, err := open(dsn)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	return db.Exec("SELECT 1").Error
+}
+
+// Create creates and grants the role in one transaction, nothing is left behind when a grant fails
+func Create(dsn string, credentials Credentials, grantTemplate string) error {
+	db, closeDB, err := open(dsn)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	return db.Transaction(func(tx *gorm.DB) error {
+		return execAll(tx, CreateStatements(credentials, grantTemplate))
+	})
+}
+
+// Revoke drops the role, a role that does not exist is already revoked
+func Revoke(dsn string, username string, revokeTemplate string) error {
+	db, closeDB, err := open(dsn)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	var count int64
+	if err := db.Raw("SELECT count(*) FROM pg_roles WHERE rolname = ?", username).Scan(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return execAll(tx, RevokeStatements(username, revokeTemplate))
+	})
+}
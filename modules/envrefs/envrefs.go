@@ -0,0 +1,133 @@
+// Package envrefs finds the environment variables read by workflows and code
+package envrefs
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sources of references
+const (
+	SourceWorkflow = "workflow"
+	SourceCode     = "code"
+)
+
+// Reference is a line reading an environment variable
+type Reference struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Source string `json:"source"`
+}
+
+const namePattern = `([A-Za-z_][A-Za-z0-9_]*)`
+
+var workflowPattern = regexp.MustCompile(`\$\{\{\s*env\.` + namePattern + `\s*\}\}`)
+
+// codePatterns are the usual ways languages read the environment, the first group is the name
+var codePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`os\.(?:Getenv|LookupEnv)\(\s*"` + namePattern + `"`),            // Go
+	regexp.MustCompile(`process\.env\.` + namePattern),                                  // Node
+	regexp.MustCompile(`process\.env\[\s*['"]` + namePattern + `['"]\s*\]`),             // Node
+	regexp.MustCompile(`import\.meta\.env\.` + namePattern),                             // Vite
+	regexp.MustCompile(`os\.environ(?:\.get\(\s*|\[\s*)['"]` + namePattern + `['"]`),    // Python
+	regexp.MustCompile(`os\.getenv\(\s*['"]` + namePattern + `['"]`),                    // Python
+	regexp.MustCompile(`ENV(?:\.fetch\(\s*|\[\s*)['"]` + namePattern + `['"]`),          // Ruby
+	regexp.MustCompile(`System\.getenv\(\s*"` + namePattern + `"`),                      // Java
+	regexp.MustCompile(`Environment\.GetEnvironmentVariable\(\s*"` + namePattern + `"`), // C#
+	regexp.MustCompile(`env::var\(\s*"` + namePattern + `"`),                            // Rust
+	regexp.MustCompile(`\bgetenv\(\s*['"]` + namePattern + `['"]`),                      // PHP and C
+	regexp.MustCompile(`\benv\(\s*['"]` + namePattern + `['"]`),                         // Laravel
+}
+
+// ignoredNames are set by the runner or the shell, never by the parameter store
+var ignoredNames = map[string]bool{
+	"CI": true, "HOME": true, "PATH": true, "PWD": true, "USER": true, "SHELL": true, "TERM": true, "HOSTNAME": true, "TMPDIR": true, "LANG": true, "TZ": true,
+}
+
+var ignoredPrefixes = []string{"GITHUB_", "RUNNER_", "ACTIONS_"}
+
+// Ignored tells if the name comes from the runner or the shell
+func Ignored(name string) bool {
+	if ignoredNames[name] {
+		return true
+	}
+	for _, prefix := range ignoredPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func find(patterns []*regexp.Regexp, path string, content []byte, source string) []Reference {
+	var references []Reference
+	for number, line := range strings.Split(string(content), "\n") {
+		seen := map[string]bool{}
+		for _, pattern := range patterns {
+			for _, match := range pattern.FindAllStringSubmatch(line, -1) {
+				if seen[match[1]] || Ignored(match[1]) {
+					continue
+				}
+				seen[match[1]] = true
+				references = append(references, Reference{Name: match[1], Path: path, Line: number + 1, Source: source})
+			}
+		}
+	}
+	sort.SliceStable(references, func(i, j int) bool {
+		if references[i].Line != references[j].Line {
+			return references[i].Line < references[j].Line
+		}
+		return references[i].Name < references[j].Name
+	})
+	return references
+}
+
+// CodeReferences finds the variables a source file reads
+func CodeReferences(path string, content []byte) []Reference {
+	return find(codePatterns, path, content, SourceCode)
+}
+
+// WorkflowReferences finds the ${{ env.NAME }} expressions of a workflow, with the names its env blocks set itself
+func WorkflowReferences(path string, content []byte) ([]Reference, []string, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, nil, err
+	}
+	defined := map[string]bool{}
+	collectEnvKeys(document, defined)
+	var references []Reference
+	for _, reference := range find([]*regexp.Regexp{workflowPattern}, path, content, SourceWorkflow) {
+		if !defined[reference.Name] {
+			references = append(references, reference)
+		}
+	}
+	names := make([]string, 0, len(defined))
+	for name := range defined {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return references, names, nil
+}
+
+// collectEnvKeys gathers the keys of every env mapping, at the workflow, job and step levels
+func collectEnvKeys(node interface{}, defined map[string]bool) {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if env, ok := child.(map[string]interface{}); ok && key == "env" {
+				for name := range env {
+					defined[name] = true
+				}
+			}
+			collectEnvKeys(child, defined)
+		}
+	case []interface{}:
+		for _, child := range value {
+			collectEnvKeys(child, defined)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
 // FileContent represents the structure of the file content from GitHub
@@ -86,3 +87,42 @@ func makeGetFileContentRequest(owner, repo, path, token string) (*http.Request,
 	req.Header.Set("Content-Type", "application/json")
 	return req, nil
 }
+
+// ErrFileNotFound is returned by GetFileContentAtRef when the path does not exist at the ref
+var ErrFileNotFound = fmt.Errorf("file not found")
+
+// GetFileContentAtRef returns the decoded content of a file and its blob SHA, ref is a branch, tag or commit, empty for the default branch
+func GetFileContentAtRef(owner, repo, path, ref, token string) (string, string, error) {
+	request, err := makeGetFileContentRequest(owner, repo, path, token)
+	if err != nil {
+		return "", "", err
+	}
+	if ref != "" {
+		query := request.URL.Query()
+		query.Set("ref", ref)
+		request.URL.RawQuery = query.Encode()
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return "", "", ErrFileNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("failed to get file %s: %s", path, response.Status)
+	}
+	var fileContent FileContent
+	if err := json.NewDecoder(response.Body).Decode(&fileContent); err != nil {
+		return "", "", err
+	}
+	if fileContent.Type != "file" {
+		return "", "", fmt.Errorf("%s is not a file", path)
+	}
+	decodedContent, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(fileContent.Content, "\n", ""))
+	if err != nil {
+		return "", "", err
+	}
+	return string(decodedContent), fileContent.SHA, nil
+}
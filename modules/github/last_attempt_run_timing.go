@@ -21,6 +21,13 @@ type WorkflowRunAttempt struct {
 	RunStartedAt time.Time `json:"run_started_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	Status       string    `json:"status"`
+	Conclusion   string    `json:"conclusion"`
+	HTMLURL      string    `json:"html_url"`
+}
+
+// Duration is the time from the start of the attempt to its last update
+func (r WorkflowRunAttempt) Duration() time.Duration {
+	return r.UpdatedAt.Sub(r.RunStartedAt)
 }
 
 func makeGetWorkflowRunWithAttempt(repoOwner string, repoName string, workflowRunID int, attemptNumber int, apiToken string) (*http.Request, error) {
@@ -85,30 +92,50 @@ func GetLastAttemptNumberOfWorkflowRun(repoOwner string, repoName string, apiTok
 }
 
 func GetLastAttemptInformationOfWorkflowRun(repoOwner string, repoName string, apiToken string, workflowRunID int, attemptNumber int) (time.Time, time.Duration, error) {
+	run, err := GetCompletedWorkflowRunAttempt(repoOwner, repoName, apiToken, workflowRunID, attemptNumber)
+	if err != nil {
+		return time.Time{}, time.Duration(0), err
+	}
+	// return subtract of run.UpdateAt - run.RunStartedAt
+	return run.RunStartedAt, run.Duration(), nil
+}
+
+// GetCompletedWorkflowRunAttempt returns the attempt with its conclusion and link, an error while it is still running
+func GetCompletedWorkflowRunAttempt(repoOwner string, repoName string, apiToken string, workflowRunID int, attemptNumber int) (WorkflowRunAttempt, error) {
+	run, err := GetWorkflowRunAttempt(repoOwner, repoName, apiToken, workflowRunID, attemptNumber)
+	if err != nil {
+		return WorkflowRunAttempt{}, err
+	}
+	if run.Status != "completed" {
+		return WorkflowRunAttempt{}, fmt.Errorf("workflow run is not completed")
+	}
+	return run, nil
+}
+
+// GetWorkflowRunAttempt returns the attempt in any status: queued, in_progress or completed
+func GetWorkflowRunAttempt(repoOwner string, repoName string, apiToken string, workflowRunID int, attemptNumber int) (WorkflowRunAttempt, error) {
 	client := &http.Client{}
 	req, err := makeGetWorkflowRunWithAttempt(repoOwner, repoName, workflowRunID, attemptNumber, apiToken)
 	if err != nil {
-		return time.Time{}, time.Duration(0), fmt.Errorf("error creating request: %v", err)
+		return WorkflowRunAttempt{}, fmt.Errorf("error creating request: %v", err)
 	}
 	response, err := client.Do(req)
 	if err != nil {
-		return time.Time{}, time.Duration(0), fmt.Errorf("error sending request: %v", err)
+		return WorkflowRunAttempt{}, fmt.Errorf("error sending request: %v", err)
 	}
 	defer response.Body.Close()
 	responseBody, err := io.ReadAll(response.Body)
 	if err != nil {
-		return time.Time{}, time.Duration(0), fmt.Errorf("error reading response: %v", err)
+		return WorkflowRunAttempt{}, fmt.Errorf("error reading response: %v", err)
 	}
 	log.Println(string(responseBody))
 	var run WorkflowRunAttempt
 	if err := json.Unmarshal(responseBody, &run); err != nil {
-		return time.Time{}, time.Duration(0), fmt.Errorf("error unmarshalling response: %v", err)
+		return WorkflowRunAttempt{}, fmt.Errorf("error unmarshalling response: %v", err)
 	}
 	// log.Println(run)
-	if run.Status != "completed" {
-		return time.Time{}, time.Duration(0), fmt.Errorf("workflow run is not completed")
+	if run.Status == "" {
+		return WorkflowRunAttempt{}, fmt.Errorf("workflow run attempt not found")
 	}
-	// return subtract of run.UpdateAt - run.RunStartedAt
-	return run.RunStartedAt, run.UpdatedAt.Sub(run.RunStartedAt), nil
-
+	return run, nil
 }
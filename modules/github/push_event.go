@@ -0,0 +1,56 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// PushEvent is the part of the payload of a GitHub push webhook the parameter store reads
+type PushEvent struct {
+	Ref        string `json:"ref"` // refs/heads/BRANCH
+	After      string `json:"after"`
+	Repository struct {
+		FullName      string `json:"full_name"` // OWNER/REPO
+		DefaultBranch string `json:"default_branch"`
+	} `json:"repository"`
+	Commits []struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+}
+
+// Branch is the branch pushed to, empty when a tag was pushed
+func (e PushEvent) Branch() string {
+	if !strings.HasPrefix(e.Ref, "refs/heads/") {
+		return ""
+	}
+	return strings.TrimPrefix(e.Ref, "refs/heads/")
+}
+
+// Touches tells if a commit of the push added, modified or removed the path
+func (e PushEvent) Touches(path string) bool {
+	for _, commit := range e.Commits {
+		for _, files := range [][]string{commit.Added, commit.Modified, commit.Removed} {
+			for _, file := range files {
+				if file == path {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// VerifyWebhookSignature checks the X-Hub-Signature-256 header GitHub computes with the webhook secret
+func VerifyWebhookSignature(secret string, body []byte, signature string) bool {
+	if secret == "" || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,87 @@
+package github
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// TarballMaxFileSize skips files larger than this, they are rarely source code
+	TarballMaxFileSize = 1 << 20
+	// tarballMaxSize stops reading archives larger than this
+	tarballMaxSize = 512 << 20
+)
+
+func makeGetTarballRequest(owner, repo, ref, token string) (*http.Request, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/tarball", GitHubAPIEndpoint, owner, repo)
+	if ref != "" {
+		url += "/" + ref
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	return request, nil
+}
+
+// WalkRepoFiles downloads the repository at ref in one request, empty for the default branch, and calls visit
+// with the path and content of every text file up to TarballMaxFileSize. It returns the commit of the archive.
+func WalkRepoFiles(owner, repo, ref, token string, visit func(path string, content []byte) error) (string, error) {
+	request, err := makeGetTarballRequest(owner, repo, ref, token)
+	if err != nil {
+		return "", err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download github.com/%s/%s: %s", owner, repo, response.Status)
+	}
+	gzipReader, err := gzip.NewReader(io.LimitReader(response.Body, tarballMaxSize))
+	if err != nil {
+		return "", err
+	}
+	defer gzipReader.Close()
+	reader := tar.NewReader(gzipReader)
+	commit := ""
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return commit, nil
+		}
+		if err != nil {
+			return commit, err
+		}
+		// entries sit under a OWNER-REPO-COMMIT directory
+		root, path, _ := strings.Cut(header.Name, "/")
+		if commit == "" {
+			if index := strings.LastIndex(root, "-"); index >= 0 {
+				commit = root[index+1:]
+			}
+		}
+		if header.Typeflag != tar.TypeReg || path == "" || header.Size > TarballMaxFileSize {
+			continue
+		}
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return commit, err
+		}
+		// a NUL byte in the first block means a binary file
+		if bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0 {
+			continue
+		}
+		if err := visit(path, content); err != nil {
+			return commit, err
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gitops
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is where the manifest is read in the project repo
+const DefaultPath = ".parameter-store.yaml"
+
+// Entry is one parameter declared by the manifest or held by the latest version
+type Entry struct {
+	Stage       string `json:"stage"`
+	Environment string `json:"environment"`
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
+
+// Key identifies the parameter across versions
+func (e Entry) Key() string {
+	return e.Stage + "/" + e.Environment + "/" + e.Name
+}
+
+// manifest is the file format, a value is either a string or a map with value and description:
+//
+//	version: 1
+//	stages:
+//	  Build:
+//	    Production:
+//	      LOG_LEVEL: info
+//	      API_URL:
+//	        value: https://api.example.com
+//	        description: public API
+type manifest struct {
+	Version int                                            `yaml:"version"`
+	Stages  map[string]map[string]map[string]manifestValue `yaml:"stages"`
+}
+
+type manifestValue struct {
+	Value       string `yaml:"value"`
+	Description string `yaml:"description"`
+}
+
+func (v *manifestValue) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&v.Value)
+	}
+	type plain manifestValue
+	return node.Decode((*plain)(v))
+}
+
+// Parse reads the entries of a manifest, sorted by key
+func Parse(data []byte) ([]Entry, error) {
+	var m manifest
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if m.Version != 1 {
+		return nil, fmt.Errorf("unsupported manifest version %d, expected 1", m.Version)
+	}
+	var entries []Entry
+	for stage, environments := range m.Stages {
+		for environment, parameters := range environments {
+			for name, value := range parameters {
+				if strings.TrimSpace(name) == "" {
+					return nil, fmt.Errorf("empty parameter name in %s/%s", stage, environment)
+				}
+				entries = append(entries, Entry{Stage: stage, Environment: environment, Name: name, Value: value.Value, Description: value.Description})
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key() < entries[j].Key() })
+	return entries, nil
+}
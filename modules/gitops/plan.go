@@ -0,0 +1,101 @@
+package gitops
+
+import "sort"
+
+// Change actions
+const (
+	ActionAdd    = "add"
+	ActionUpdate = "update"
+	ActionRemove = "remove"
+)
+
+// Change is one difference between the manifest and the latest version
+type Change struct {
+	Action      string `json:"action"`
+	Stage       string `json:"stage"`
+	Environment string `json:"environment"`
+	Name        string `json:"name"`
+	Before      *Entry `json:"before,omitempty"`
+	After       *Entry `json:"after,omitempty"`
+	// Conflict is set when the current value was edited in the UI since the last sync
+	Conflict bool   `json:"conflict"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Plan is what applying the manifest would change
+type Plan struct {
+	Changes   []Change `json:"changes"`
+	Conflicts int      `json:"conflicts"`
+	Unchanged int      `json:"unchanged"`
+	Errors    []string `json:"errors"`
+}
+
+// Diff compares the manifest with the current parameters.
+// baseline holds the values the last sync wrote, by key: a parameter whose value still matches it is owned by the manifest,
+// one that differs was edited in the UI and is a conflict. Parameters neither in the manifest nor in the baseline are left alone.
+func Diff(declared []Entry, current []Entry, baseline map[string]string) Plan {
+	plan := Plan{Changes: []Change{}, Errors: []string{}}
+	currentByKey := map[string]Entry{}
+	for _, entry := range current {
+		currentByKey[entry.Key()] = entry
+	}
+	declaredKeys := map[string]bool{}
+
+	for _, entry := range declared {
+		entry := entry
+		declaredKeys[entry.Key()] = true
+		existing, exists := currentByKey[entry.Key()]
+		if !exists {
+			plan.Changes = append(plan.Changes, Change{Action: ActionAdd, Stage: entry.Stage, Environment: entry.Environment, Name: entry.Name, After: &entry})
+			continue
+		}
+		if existing.Value == entry.Value && existing.Description == entry.Description {
+			plan.Unchanged++
+			continue
+		}
+		change := Change{Action: ActionUpdate, Stage: entry.Stage, Environment: entry.Environment, Name: entry.Name, Before: &existing, After: &entry}
+		if existing.Value != entry.Value {
+			base, synced := baseline[entry.Key()]
+			switch {
+			case !synced:
+				change.Conflict, change.Reason = true, "value was set in the UI and was never synced from the manifest"
+			case existing.Value != base:
+				change.Conflict, change.Reason = true, "value was edited in the UI since the last sync"
+			}
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	// parameters the manifest declared before and no longer does
+	var removed []string
+	for key := range baseline {
+		if _, exists := currentByKey[key]; exists && !declaredKeys[key] {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	for _, key := range removed {
+		existing := currentByKey[key]
+		change := Change{Action: ActionRemove, Stage: existing.Stage, Environment: existing.Environment, Name: existing.Name, Before: &existing}
+		if existing.Value != baseline[key] {
+			change.Conflict, change.Reason = true, "value was edited in the UI since the last sync"
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	for _, change := range plan.Changes {
+		if change.Conflict {
+			plan.Conflicts++
+		}
+	}
+	return plan
+}
+
+// Baseline is the baseline to store after the manifest was applied
+func Baseline(declared []Entry) map[string]string {
+	baseline := map[string]string{}
+	for _, entry := range declared {
+		baseline[entry.Key()] = entry.Value
+	}
+	return baseline
+}
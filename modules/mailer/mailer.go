@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// ErrNotConfigured is returned when no mail driver is set
+var ErrNotConfigured = errors.New("mail is not configured, set MAIL_DRIVER")
+
+// Sender delivers emails, implementations are SMTPSender, MemorySender, LogSender and DisabledSender
+type Sender interface {
+	Send(message Message) error
+}
+
+// NewSenderFromEnv returns the sender selected by MAIL_DRIVER: smtp, memory or log. Emails carry password reset
+// and invitation links, so they are only printed with an explicit MAIL_DRIVER=log and are not sent otherwise.
+func NewSenderFromEnv() Sender {
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			port = 587
+		}
+		return &SMTPSender{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+	case "memory":
+		return NewMemorySender()
+	case "log":
+		return LogSender{}
+	}
+	return DisabledSender{}
+}
+
+// LogSender prints whole emails, links included, to the server log. It is meant for development.
+type LogSender struct{}
+
+func (LogSender) Send(message Message) error {
+	log.Printf("mail to %s: %s\n%s\n", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// DisabledSender drops emails and logs only their recipient and subject
+type DisabledSender struct{}
+
+func (DisabledSender) Send(message Message) error {
+	log.Printf("mail to %s not sent: %s: %v\n", message.To, message.Subject, ErrNotConfigured)
+	return ErrNotConfigured
+}
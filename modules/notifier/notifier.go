@@ -0,0 +1,161 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"parameter-store-be/modules/webhook"
+	"strings"
+)
+
+// Levels of a message, they pick the color of the card
+const (
+	LevelInfo    = "info"
+	LevelSuccess = "success"
+	LevelFailure = "failure"
+)
+
+// Field is a name/value line of a message, e.g. Stage: production
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Message is rendered as a Slack block message, a Teams adaptive card or a plain text email
+type Message struct {
+	Title    string
+	Text     string
+	Level    string
+	Fields   []Field
+	LinkURL  string
+	LinkText string
+}
+
+func (m Message) color() string {
+	switch m.Level {
+	case LevelSuccess:
+		return "#2eb67d"
+	case LevelFailure:
+		return "#e01e5a"
+	}
+	return "#1d9bd1"
+}
+
+// SlackPayload renders the message for a Slack incoming webhook
+func SlackPayload(m Message) map[string]interface{} {
+	blocks := []interface{}{
+		map[string]interface{}{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": m.Title},
+		},
+	}
+	if m.Text != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": m.Text},
+		})
+	}
+	if len(m.Fields) > 0 {
+		var fields []interface{}
+		for _, f := range m.Fields {
+			fields = append(fields, map[string]interface{}{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%s", f.Name, f.Value)})
+		}
+		blocks = append(blocks, map[string]interface{}{"type": "section", "fields": fields})
+	}
+	if m.LinkURL != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "actions",
+			"elements": []interface{}{map[string]interface{}{
+				"type": "button",
+				"text": map[string]interface{}{"type": "plain_text", "text": linkText(m)},
+				"url":  m.LinkURL,
+			}},
+		})
+	}
+	return map[string]interface{}{
+		"text":        m.Title, // shown in notifications
+		"attachments": []interface{}{map[string]interface{}{"color": m.color(), "blocks": blocks}},
+	}
+}
+
+// TeamsPayload renders the message as an adaptive card for a Teams incoming webhook or workflow
+func TeamsPayload(m Message) map[string]interface{} {
+	style := "accent"
+	switch m.Level {
+	case LevelSuccess:
+		style = "good"
+	case LevelFailure:
+		style = "attention"
+	}
+	body := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": m.Title, "weight": "Bolder", "size": "Medium", "color": style, "wrap": true},
+	}
+	if m.Text != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": m.Text, "wrap": true})
+	}
+	if len(m.Fields) > 0 {
+		var facts []interface{}
+		for _, f := range m.Fields {
+			facts = append(facts, map[string]interface{}{"title": f.Name, "value": f.Value})
+		}
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if m.LinkURL != "" {
+		card["actions"] = []interface{}{map[string]interface{}{"type": "Action.OpenUrl", "title": linkText(m), "url": m.LinkURL}}
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{map[string]interface{}{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
+
+// PlainText renders the message for an email body
+func PlainText(m Message) string {
+	var b strings.Builder
+	b.WriteString(m.Title + "\n\n")
+	if m.Text != "" {
+		// emails do not render the *bold* markup of chat messages
+		b.WriteString(strings.ReplaceAll(m.Text, "*", "") + "\n\n")
+	}
+	for _, f := range m.Fields {
+		b.WriteString(fmt.Sprintf("%s: %s\n", f.Name, f.Value))
+	}
+	if m.LinkURL != "" {
+		b.WriteString(fmt.Sprintf("\n%s: %s\n", linkText(m), m.LinkURL))
+	}
+	return b.String()
+}
+
+func linkText(m Message) string {
+	if m.LinkText != "" {
+		return m.LinkText
+	}
+	return "Open"
+}
+
+// PostJSON posts a rendered payload to a chat webhook on a public address
+func PostJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	response, err := webhook.Client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	// the response body is not kept, the error is shown to project admins and the receiver controls it
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// ProviderMetadata is the subset of the OpenID Provider Configuration this service needs
+type ProviderMetadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+// Discover fetches {issuer}/.well-known/openid-configuration and checks it belongs to the issuer
+func Discover(issuerURL string) (*ProviderMetadata, error) {
+	issuerURL = strings.TrimSuffix(issuerURL, "/")
+	response, err := httpClient.Get(issuerURL + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("error sending discovery request: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error getting provider configuration: %s", response.Status)
+	}
+	var metadata ProviderMetadata
+	if err := json.NewDecoder(response.Body).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("error decoding provider configuration: %v", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != issuerURL {
+		return nil, fmt.Errorf("issuer mismatch: expected %s, got %s", issuerURL, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("provider configuration of %s is missing required endpoints", issuerURL)
+	}
+	return &metadata, nil
+}
@@ -0,0 +1,234 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksCacheTTL is how long the signing keys of a provider are reused before they are fetched again
+const jwksCacheTTL = time.Hour
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type cachedKeySet struct {
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+var (
+	jwksCache   = map[string]cachedKeySet{}
+	jwksCacheMu sync.Mutex
+)
+
+// IDTokenClaims are the standard claims read from a validated id_token, Raw holds every claim
+type IDTokenClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Raw               jwt.MapClaims
+}
+
+// Groups returns the values of a string or string array claim, e.g. "groups" or "roles"
+func (c IDTokenClaims) Groups(claimName string) []string {
+	var groups []string
+	switch v := c.Raw[claimName].(type) {
+	case string:
+		groups = append(groups, v)
+	case []interface{}:
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	return groups
+}
+
+// CheckAccountLink tells if the identity may be attached to the account with the given email. The provider must have
+// verified the email whatever its settings, otherwise anyone able to set any email at the provider takes the account.
+func (c IDTokenClaims) CheckAccountLink(accountEmail string) error {
+	if c.Email == "" || !c.EmailVerified {
+		return fmt.Errorf("identity provider did not verify the email")
+	}
+	if !strings.EqualFold(c.Email, accountEmail) {
+		return fmt.Errorf("identity provider email %s does not match the account", c.Email)
+	}
+	return nil
+}
+
+// VerifyIDToken checks the signature against the provider JWKS and validates iss, aud, exp and nonce
+func VerifyIDToken(metadata *ProviderMetadata, rawIDToken, clientID, nonce string) (*IDTokenClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	token, err := parser.Parse(rawIDToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return signingKey(metadata.JWKSURI, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %v", err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid id_token claims")
+	}
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, fmt.Errorf("invalid id_token: nonce mismatch")
+	}
+	// with several audiences the token must be issued to this client
+	if azp, ok := claims["azp"].(string); ok && azp != clientID {
+		return nil, fmt.Errorf("invalid id_token: authorized party mismatch")
+	}
+	result := &IDTokenClaims{Raw: claims}
+	result.Subject, _ = claims["sub"].(string)
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+	result.PreferredUsername, _ = claims["preferred_username"].(string)
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = v
+	case string: // some providers send "true"
+		result.EmailVerified = v == "true"
+	}
+	if result.Subject == "" {
+		return nil, fmt.Errorf("invalid id_token: missing sub")
+	}
+	return result, nil
+}
+
+// signingKey returns the key with the given kid, refetching the key set once when the kid is unknown
+func signingKey(jwksURI, kid string) (interface{}, error) {
+	jwksCacheMu.Lock()
+	cached, ok := jwksCache[jwksURI]
+	jwksCacheMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < jwksCacheTTL {
+		if key := pickKey(cached.keys, kid); key != nil {
+			return key, nil
+		}
+	}
+	keys, err := fetchKeySet(jwksURI)
+	if err != nil {
+		return nil, err
+	}
+	jwksCacheMu.Lock()
+	jwksCache[jwksURI] = cachedKeySet{keys: keys, fetchedAt: time.Now()}
+	jwksCacheMu.Unlock()
+	if key := pickKey(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("signing key %q not found in %s", kid, jwksURI)
+}
+
+func pickKey(keys map[string]interface{}, kid string) interface{} {
+	if kid != "" {
+		return keys[kid]
+	}
+	// tokens without kid are accepted only when the provider publishes a single key
+	if len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return nil
+}
+
+func fetchKeySet(jwksURI string) (map[string]interface{}, error) {
+	response, err := httpClient.Get(jwksURI)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching jwks: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching jwks: %s", response.Status)
+	}
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&keySet); err != nil {
+		return nil, fmt.Errorf("error decoding jwks: %v", err)
+	}
+	keys := map[string]interface{}{}
+	for _, k := range keySet.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,116 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// FakeClient keeps ParameterStoreSync resources, Secrets and ConfigMaps in memory, used by tests
+type FakeClient struct {
+	mu      sync.Mutex
+	version int
+	syncs   map[string]ParameterStoreSync
+	objects map[string]Object
+}
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{syncs: map[string]ParameterStoreSync{}, objects: map[string]Object{}}
+}
+
+func fakeKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+func (f *FakeClient) nextVersion() string {
+	f.version++
+	return strconv.Itoa(f.version)
+}
+
+// AddSync stores a ParameterStoreSync, a missing uid and generation are filled in
+func (f *FakeClient) AddSync(sync ParameterStoreSync) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if sync.Metadata.UID == "" {
+		sync.Metadata.UID = "uid-" + sync.Metadata.Name
+	}
+	if sync.Metadata.Generation == 0 {
+		sync.Metadata.Generation = 1
+	}
+	sync.Metadata.ResourceVersion = f.nextVersion()
+	f.syncs[fakeKey(Kind, sync.Metadata.Namespace, sync.Metadata.Name)] = sync
+}
+
+// Sync returns a stored ParameterStoreSync
+func (f *FakeClient) Sync(namespace, name string) (ParameterStoreSync, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sync, ok := f.syncs[fakeKey(Kind, namespace, name)]
+	return sync, ok
+}
+
+// DeleteObject removes a Secret or ConfigMap
+func (f *FakeClient) DeleteObject(kind, namespace, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.objects, fakeKey(kind, namespace, name))
+}
+
+func (f *FakeClient) ListSyncs(ctx context.Context, namespace string) ([]ParameterStoreSync, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var syncs []ParameterStoreSync
+	for _, sync := range f.syncs {
+		if namespace == "" || sync.Metadata.Namespace == namespace {
+			syncs = append(syncs, sync)
+		}
+	}
+	return syncs, nil
+}
+
+func (f *FakeClient) UpdateSyncStatus(ctx context.Context, sync ParameterStoreSync) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := fakeKey(Kind, sync.Metadata.Namespace, sync.Metadata.Name)
+	stored, ok := f.syncs[key]
+	if !ok {
+		return fmt.Errorf("parameterstoresync %s not found", sync.Metadata.Name)
+	}
+	stored.Status = sync.Status
+	stored.Metadata.ResourceVersion = f.nextVersion()
+	f.syncs[key] = stored
+	return nil
+}
+
+func (f *FakeClient) GetObject(ctx context.Context, kind, namespace, name string) (*Object, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	object, ok := f.objects[fakeKey(kind, namespace, name)]
+	if !ok {
+		return nil, nil
+	}
+	copied := object
+	copied.Data = map[string]string{}
+	for key, value := range object.Data {
+		copied.Data[key] = value
+	}
+	return &copied, nil
+}
+
+func (f *FakeClient) PutObject(ctx context.Context, object Object) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := fakeKey(object.Kind, object.Metadata.Namespace, object.Metadata.Name)
+	stored, exists := f.objects[key]
+	// same optimistic concurrency as the API server
+	if exists && object.Metadata.ResourceVersion != stored.Metadata.ResourceVersion {
+		return fmt.Errorf("conflict: %s %s was modified", object.Kind, object.Metadata.Name)
+	}
+	if !exists && object.Metadata.ResourceVersion != "" {
+		return fmt.Errorf("%s %s not found", object.Kind, object.Metadata.Name)
+	}
+	object.Metadata.ResourceVersion = f.nextVersion()
+	f.objects[key] = object
+	return nil
+}
@@ -0,0 +1,202 @@
+package operator
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Client is the part of the Kubernetes API the operator uses, RESTClient talks to a cluster and FakeClient keeps objects in memory
+type Client interface {
+	// ListSyncs lists ParameterStoreSync resources, of every namespace when namespace is empty
+	ListSyncs(ctx context.Context, namespace string) ([]ParameterStoreSync, error)
+	UpdateSyncStatus(ctx context.Context, sync ParameterStoreSync) error
+	// GetObject returns nil without error when the object does not exist
+	GetObject(ctx context.Context, kind, namespace, name string) (*Object, error)
+	// PutObject creates the object, or replaces it when it has a resource version
+	PutObject(ctx context.Context, object Object) error
+}
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// RESTClient calls the Kubernetes API server with a bearer token
+type RESTClient struct {
+	Host       string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewRESTClientFromEnv uses KUBE_API_URL and KUBE_TOKEN when set, otherwise the in-cluster service account
+func NewRESTClientFromEnv() (*RESTClient, error) {
+	if host := os.Getenv("KUBE_API_URL"); host != "" {
+		return &RESTClient{
+			Host:  host,
+			Token: os.Getenv("KUBE_TOKEN"),
+			HTTPClient: &http.Client{Timeout: 30 * time.Second, Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: os.Getenv("KUBE_INSECURE_SKIP_VERIFY") == "true"},
+			}},
+		}, nil
+	}
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a cluster, set KUBE_API_URL and KUBE_TOKEN")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+	return &RESTClient{
+		Host:  "https://" + host + ":" + port,
+		Token: strings.TrimSpace(string(token)),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second, Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}},
+	}, nil
+}
+
+// statusError is a non 2xx answer of the API server
+type statusError struct {
+	Code int
+	Body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("kubernetes api responded %d: %s", e.Code, e.Body)
+}
+
+func (c *RESTClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.Host, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	response, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return &statusError{Code: response.StatusCode, Body: string(responseBody)}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+func syncPath(namespace string) string {
+	if namespace == "" {
+		return fmt.Sprintf("/apis/%s/%s/%s", Group, Version, Resource)
+	}
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", Group, Version, namespace, Resource)
+}
+
+func objectPath(kind, namespace string) (string, error) {
+	switch kind {
+	case TargetSecret:
+		return fmt.Sprintf("/api/v1/namespaces/%s/secrets", namespace), nil
+	case TargetConfigMap:
+		return fmt.Sprintf("/api/v1/namespaces/%s/configmaps", namespace), nil
+	}
+	return "", fmt.Errorf("unsupported kind %s, use Secret or ConfigMap", kind)
+}
+
+func (c *RESTClient) ListSyncs(ctx context.Context, namespace string) ([]ParameterStoreSync, error) {
+	var list struct {
+		Items []ParameterStoreSync `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodGet, syncPath(namespace), nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (c *RESTClient) UpdateSyncStatus(ctx context.Context, sync ParameterStoreSync) error {
+	sync.APIVersion = Group + "/" + Version
+	sync.Kind = Kind
+	return c.do(ctx, http.MethodPut, syncPath(sync.Metadata.Namespace)+"/"+sync.Metadata.Name+"/status", sync, nil)
+}
+
+// kubeObject is the wire format of Secrets and ConfigMaps
+type kubeObject struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   ObjectMeta        `json:"metadata"`
+	Type       string            `json:"type,omitempty"`
+	Data       map[string]string `json:"data"`
+}
+
+func (c *RESTClient) GetObject(ctx context.Context, kind, namespace, name string) (*Object, error) {
+	path, err := objectPath(kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var raw kubeObject
+	if err := c.do(ctx, http.MethodGet, path+"/"+name, nil, &raw); err != nil {
+		var status *statusError
+		if errors.As(err, &status) && status.Code == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	object := &Object{Kind: kind, Metadata: raw.Metadata, Data: raw.Data}
+	if kind == TargetSecret {
+		object.Data = map[string]string{}
+		for key, value := range raw.Data {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding key %s of secret %s: %v", key, name, err)
+			}
+			object.Data[key] = string(decoded)
+		}
+	}
+	return object, nil
+}
+
+func (c *RESTClient) PutObject(ctx context.Context, object Object) error {
+	path, err := objectPath(object.Kind, object.Metadata.Namespace)
+	if err != nil {
+		return err
+	}
+	raw := kubeObject{APIVersion: "v1", Kind: object.Kind, Metadata: object.Metadata, Data: object.Data}
+	if object.Kind == TargetSecret {
+		raw.Type = "Opaque"
+		raw.Data = map[string]string{}
+		for key, value := range object.Data {
+			raw.Data[key] = base64.StdEncoding.EncodeToString([]byte(value))
+		}
+	}
+	if object.Metadata.ResourceVersion == "" {
+		return c.do(ctx, http.MethodPost, path, raw, nil)
+	}
+	return c.do(ctx, http.MethodPut, path+"/"+object.Metadata.Name, raw, nil)
+}
@@ -0,0 +1,79 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parameter is a parameter delivered to an agent
+type Parameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ParameterSet is the answer of the agent watch API
+type ParameterSet struct {
+	Revision    int64       `json:"revision"`
+	Hash        string      `json:"hash"`
+	Version     string      `json:"version"`
+	Project     string      `json:"project"`
+	Stage       string      `json:"stage"`
+	Environment string      `json:"environment"`
+	Parameters  []Parameter `json:"parameters"`
+}
+
+// ParameterSource returns the parameters of an agent token, changed is false when since is still the current revision
+type ParameterSource interface {
+	Fetch(ctx context.Context, token string, since int64) (set ParameterSet, changed bool, err error)
+}
+
+// WatchClient reads parameters from GET /api/v1/agents/watch of a Parameter Store server
+type WatchClient struct {
+	BaseURL string // e.g. https://param-store.example.com
+	// Timeout is how long the server may hold the request open waiting for a change, 0 answers at once
+	Timeout    time.Duration
+	HTTPClient *http.Client
+}
+
+// Fetch asks for the parameters after revision since, a negative since always returns the current set
+func (w *WatchClient) Fetch(ctx context.Context, token string, since int64) (ParameterSet, bool, error) {
+	query := url.Values{}
+	query.Set("timeout", strconv.Itoa(int(w.Timeout.Seconds())))
+	if since >= 0 {
+		query.Set("since", strconv.FormatInt(since, 10))
+	}
+	endpoint := strings.TrimSuffix(w.BaseURL, "/") + "/api/v1/agents/watch?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return ParameterSet{}, false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	client := w.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: w.Timeout + 30*time.Second}
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return ParameterSet{}, false, err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusNotModified:
+		return ParameterSet{}, false, nil
+	case http.StatusOK:
+		var set ParameterSet
+		if err := json.NewDecoder(response.Body).Decode(&set); err != nil {
+			return ParameterSet{}, false, fmt.Errorf("error decoding parameters: %v", err)
+		}
+		return set, true, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+	return ParameterSet{}, false, fmt.Errorf("parameter store responded %s: %s", response.Status, body)
+}
@@ -0,0 +1,222 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// validDataKey is the key format Kubernetes accepts in Secrets and ConfigMaps
+var validDataKey = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// Reconciler writes the parameters of every ParameterStoreSync into its Secret or ConfigMap
+type Reconciler struct {
+	Kube   Client
+	Source ParameterSource
+	// Namespace limits the watched ParameterStoreSync resources, empty for every namespace
+	Namespace string
+	Now       func() time.Time
+
+	nextCheck map[string]time.Time
+	seen      map[string]int64 // generation last reconciled
+}
+
+func (r *Reconciler) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// Run reconciles the due ParameterStoreSync resources every tick until the context is done
+func (r *Reconciler) Run(ctx context.Context, tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		if err := r.ReconcileAll(ctx); err != nil {
+			log.Println("Failed to list ParameterStoreSync resources:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReconcileAll reconciles the resources whose refresh period passed or whose spec changed
+func (r *Reconciler) ReconcileAll(ctx context.Context) error {
+	if r.nextCheck == nil {
+		r.nextCheck = map[string]time.Time{}
+		r.seen = map[string]int64{}
+	}
+	syncs, err := r.Kube.ListSyncs(ctx, r.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, sync := range syncs {
+		key := sync.Metadata.Namespace + "/" + sync.Metadata.Name
+		if r.now().Before(r.nextCheck[key]) && r.seen[key] == sync.Metadata.Generation {
+			continue
+		}
+		if err := r.Reconcile(ctx, sync); err != nil {
+			log.Printf("Failed to reconcile ParameterStoreSync %s: %v", key, err)
+		}
+		r.nextCheck[key] = r.now().Add(sync.refreshPeriod())
+		r.seen[key] = sync.Metadata.Generation
+	}
+	return nil
+}
+
+// Reconcile brings the target of one ParameterStoreSync up to date and records the result in its status
+func (r *Reconciler) Reconcile(ctx context.Context, sync ParameterStoreSync) error {
+	namespace := sync.Metadata.Namespace
+	status := sync.Status
+	status.LastCheckedAt = r.now()
+	status.ObservedGeneration = sync.Metadata.Generation
+	fail := func(err error) error {
+		status.Ready = false
+		status.Message = err.Error()
+		sync.Status = status
+		if updateErr := r.Kube.UpdateSyncStatus(ctx, sync); updateErr != nil {
+			log.Println("Failed to update ParameterStoreSync status:", updateErr)
+		}
+		return err
+	}
+
+	kind := sync.targetKind()
+	if kind != TargetSecret && kind != TargetConfigMap {
+		return fail(fmt.Errorf("target kind must be %s or %s", TargetSecret, TargetConfigMap))
+	}
+	token, err := r.agentToken(ctx, sync)
+	if err != nil {
+		return fail(err)
+	}
+	target, err := r.Kube.GetObject(ctx, kind, namespace, sync.targetName())
+	if err != nil {
+		return fail(err)
+	}
+	if target != nil && target.Metadata.Labels[ManagedByLabel] != ManagedByValue {
+		return fail(fmt.Errorf("%s %s already exists and is not managed by %s", kind, sync.targetName(), ManagedByValue))
+	}
+
+	// ask only for changes when the target still holds what was synced, otherwise rewrite it
+	since := int64(-1)
+	if target != nil && sync.Status.Revision > 0 && sync.Status.ObservedGeneration == sync.Metadata.Generation &&
+		target.Metadata.Annotations[RevisionAnnotation] == strconv.FormatInt(sync.Status.Revision, 10) &&
+		target.Metadata.Annotations[HashAnnotation] == sync.Status.Hash {
+		since = sync.Status.Revision
+	}
+	set, changed, err := r.Source.Fetch(ctx, token, since)
+	if err != nil {
+		return fail(err)
+	}
+	if !changed {
+		if sync.Status.Ready {
+			return nil
+		}
+		status.Ready = true
+		status.Message = "Up to date"
+		sync.Status = status
+		return r.Kube.UpdateSyncStatus(ctx, sync)
+	}
+	if err := checkSpecMatches(sync.Spec, set); err != nil {
+		return fail(err)
+	}
+
+	data := map[string]string{}
+	var skipped []string
+	for _, parameter := range set.Parameters {
+		if !validDataKey.MatchString(parameter.Name) {
+			skipped = append(skipped, parameter.Name)
+			continue
+		}
+		data[parameter.Name] = parameter.Value
+	}
+	object := Object{
+		Kind: kind,
+		Metadata: ObjectMeta{
+			Name:        sync.targetName(),
+			Namespace:   namespace,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+			OwnerReferences: []OwnerReference{{
+				APIVersion: Group + "/" + Version,
+				Kind:       Kind,
+				Name:       sync.Metadata.Name,
+				UID:        sync.Metadata.UID,
+				Controller: true,
+			}},
+		},
+		Data: data,
+	}
+	if target != nil {
+		// keep what others put on the object
+		for key, value := range target.Metadata.Labels {
+			object.Metadata.Labels[key] = value
+		}
+		for key, value := range target.Metadata.Annotations {
+			object.Metadata.Annotations[key] = value
+		}
+		object.Metadata.ResourceVersion = target.Metadata.ResourceVersion
+	}
+	object.Metadata.Labels[ManagedByLabel] = ManagedByValue
+	object.Metadata.Annotations[RevisionAnnotation] = strconv.FormatInt(set.Revision, 10)
+	object.Metadata.Annotations[HashAnnotation] = set.Hash
+	object.Metadata.Annotations[SyncNameAnnotation] = sync.Metadata.Name
+	if err := r.Kube.PutObject(ctx, object); err != nil {
+		return fail(err)
+	}
+
+	status.Ready = true
+	status.Revision = set.Revision
+	status.Hash = set.Hash
+	status.Version = set.Version
+	status.LastSyncedAt = r.now()
+	status.Message = fmt.Sprintf("Synced %d parameters to %s %s", len(data), kind, object.Metadata.Name)
+	if len(skipped) > 0 {
+		sort.Strings(skipped)
+		status.Message += fmt.Sprintf(", skipped invalid keys: %s", strings.Join(skipped, ", "))
+	}
+	sync.Status = status
+	return r.Kube.UpdateSyncStatus(ctx, sync)
+}
+
+// agentToken reads the agent token from the Secret referenced by the spec
+func (r *Reconciler) agentToken(ctx context.Context, sync ParameterStoreSync) (string, error) {
+	ref := sync.Spec.AgentTokenRef
+	if ref.Name == "" {
+		return "", fmt.Errorf("spec.agentTokenRef.name is required")
+	}
+	key := ref.Key
+	if key == "" {
+		key = defaultTokenKey
+	}
+	secret, err := r.Kube.GetObject(ctx, TargetSecret, sync.Metadata.Namespace, ref.Name)
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		return "", fmt.Errorf("agent token secret %s not found", ref.Name)
+	}
+	token := strings.TrimSpace(secret.Data[key])
+	if token == "" {
+		return "", fmt.Errorf("key %s of agent token secret %s is empty", key, ref.Name)
+	}
+	return token, nil
+}
+
+// checkSpecMatches refuses to sync when the token belongs to another project, stage or environment than the spec says
+func checkSpecMatches(spec ParameterStoreSyncSpec, set ParameterSet) error {
+	if (spec.Project != "" && spec.Project != set.Project) ||
+		(spec.Stage != "" && spec.Stage != set.Stage) ||
+		(spec.Environment != "" && spec.Environment != set.Environment) {
+		return fmt.Errorf("agent token belongs to project %s, stage %s, environment %s", set.Project, set.Stage, set.Environment)
+	}
+	return nil
+}
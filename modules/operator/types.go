@@ -0,0 +1,124 @@
+package operator
+
+import "time"
+
+// Group, version and resource of the ParameterStoreSync custom resource, see deploy/kubernetes/crd.yaml
+const (
+	Group    = "parameterstore.io"
+	Version  = "v1alpha1"
+	Kind     = "ParameterStoreSync"
+	Resource = "parameterstoresyncs"
+)
+
+// Kinds of objects a ParameterStoreSync writes
+const (
+	TargetSecret    = "Secret"
+	TargetConfigMap = "ConfigMap"
+)
+
+// Labels and annotations put on the written objects
+const (
+	ManagedByLabel      = "app.kubernetes.io/managed-by"
+	ManagedByValue      = "parameter-store-operator"
+	RevisionAnnotation  = "parameterstore.io/revision"
+	HashAnnotation      = "parameterstore.io/hash"
+	SyncNameAnnotation  = "parameterstore.io/sync"
+	defaultTokenKey     = "token"
+	defaultResyncPeriod = 30 * time.Second
+)
+
+// ObjectMeta is the part of the Kubernetes metadata the operator reads and writes
+type ObjectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	UID             string            `json:"uid,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Generation      int64             `json:"generation,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	OwnerReferences []OwnerReference  `json:"ownerReferences,omitempty"`
+}
+
+// OwnerReference makes Kubernetes delete the written object with its ParameterStoreSync
+type OwnerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid"`
+	Controller bool   `json:"controller"`
+}
+
+// SecretKeyRef points to a key of a Secret in the namespace of the ParameterStoreSync
+type SecretKeyRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key,omitempty"` // default "token"
+}
+
+// SyncTarget is the Secret or ConfigMap the parameters are written to
+type SyncTarget struct {
+	Kind string `json:"kind,omitempty"` // Secret (default) or ConfigMap
+	Name string `json:"name,omitempty"` // default the name of the ParameterStoreSync
+}
+
+// ParameterStoreSyncSpec references the parameters of a project, stage and environment through an agent token.
+// Project, stage and environment are checked against what the token belongs to, an empty one is not checked.
+type ParameterStoreSyncSpec struct {
+	Project        string       `json:"project,omitempty"`
+	Stage          string       `json:"stage,omitempty"`
+	Environment    string       `json:"environment,omitempty"`
+	AgentTokenRef  SecretKeyRef `json:"agentTokenRef"`
+	Target         SyncTarget   `json:"target,omitempty"`
+	RefreshSeconds int          `json:"refreshSeconds,omitempty"`
+}
+
+// ParameterStoreSyncStatus is what the operator last synced
+type ParameterStoreSyncStatus struct {
+	ObservedGeneration int64     `json:"observedGeneration,omitempty"`
+	Revision           int64     `json:"revision,omitempty"`
+	Hash               string    `json:"hash,omitempty"`
+	Version            string    `json:"version,omitempty"`
+	Ready              bool      `json:"ready"`
+	Message            string    `json:"message,omitempty"`
+	LastSyncedAt       time.Time `json:"lastSyncedAt,omitempty"`
+	LastCheckedAt      time.Time `json:"lastCheckedAt,omitempty"`
+}
+
+// ParameterStoreSync is the custom resource reconciled by the operator
+type ParameterStoreSync struct {
+	APIVersion string                   `json:"apiVersion"`
+	Kind       string                   `json:"kind"`
+	Metadata   ObjectMeta               `json:"metadata"`
+	Spec       ParameterStoreSyncSpec   `json:"spec"`
+	Status     ParameterStoreSyncStatus `json:"status,omitempty"`
+}
+
+// targetKind returns the kind of the written object
+func (s ParameterStoreSync) targetKind() string {
+	if s.Spec.Target.Kind == "" {
+		return TargetSecret
+	}
+	return s.Spec.Target.Kind
+}
+
+// targetName returns the name of the written object
+func (s ParameterStoreSync) targetName() string {
+	if s.Spec.Target.Name == "" {
+		return s.Metadata.Name
+	}
+	return s.Spec.Target.Name
+}
+
+// refreshPeriod is how often the parameters of the sync are checked
+func (s ParameterStoreSync) refreshPeriod() time.Duration {
+	if s.Spec.RefreshSeconds <= 0 {
+		return defaultResyncPeriod
+	}
+	return time.Duration(s.Spec.RefreshSeconds) * time.Second
+}
+
+// Object is a Secret or a ConfigMap with string values, the client encodes Secret data
+type Object struct {
+	Kind     string
+	Metadata ObjectMeta
+	Data     map[string]string
+}
@@ -0,0 +1,158 @@
+package paramio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A document holds several stages and environments, in JSON or YAML. It has the shape of the GitOps manifest,
+// a value is a string or an object with value and description:
+//
+//	version: 1
+//	stages:
+//	  Build:
+//	    Production:
+//	      LOG_LEVEL: info
+//	      API_URL:
+//	        value: https://api.example.com
+//	        description: public API
+
+type documentValue struct {
+	Value       string `json:"value" yaml:"value"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// ParseDocument reads a JSON or YAML document, a malformed parameter is a RowError at its line
+func ParseDocument(data []byte) ([]Row, []RowError, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, fmt.Errorf("invalid document: %v", err)
+	}
+	if len(root.Content) == 0 {
+		return nil, nil, fmt.Errorf("document is empty")
+	}
+	document := root.Content[0]
+	if document.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("document must be an object with version and stages")
+	}
+	var rows []Row
+	var rowErrors []RowError
+	for i := 0; i+1 < len(document.Content); i += 2 {
+		key, value := document.Content[i], document.Content[i+1]
+		switch key.Value {
+		case "version":
+			if value.Value != "1" {
+				return nil, nil, fmt.Errorf("unsupported document version %s, expected 1", value.Value)
+			}
+		case "project", "version_number":
+			// written by the export, informative only
+		case "stages":
+			if value.Kind != yaml.MappingNode {
+				return nil, nil, fmt.Errorf("line %d: stages must be an object", value.Line)
+			}
+			rows, rowErrors = parseStages(value)
+		default:
+			return nil, nil, fmt.Errorf("line %d: unknown field %s", key.Line, key.Value)
+		}
+	}
+	return rows, rowErrors, nil
+}
+
+func parseStages(stages *yaml.Node) ([]Row, []RowError) {
+	var rows []Row
+	var rowErrors []RowError
+	for i := 0; i+1 < len(stages.Content); i += 2 {
+		stage, environments := stages.Content[i], stages.Content[i+1]
+		if environments.Kind != yaml.MappingNode {
+			rowErrors = append(rowErrors, RowError{Line: environments.Line, Error: fmt.Sprintf("stage %s must be an object of environments", stage.Value)})
+			continue
+		}
+		for j := 0; j+1 < len(environments.Content); j += 2 {
+			environment, parameters := environments.Content[j], environments.Content[j+1]
+			if parameters.Kind != yaml.MappingNode {
+				rowErrors = append(rowErrors, RowError{Line: parameters.Line, Error: fmt.Sprintf("environment %s must be an object of parameters", environment.Value)})
+				continue
+			}
+			for k := 0; k+1 < len(parameters.Content); k += 2 {
+				name, node := parameters.Content[k], parameters.Content[k+1]
+				row := Row{Line: name.Line, Stage: stage.Value, Environment: environment.Value, Name: name.Value}
+				switch node.Kind {
+				case yaml.ScalarNode:
+					row.Value = node.Value
+				case yaml.MappingNode:
+					var value documentValue
+					if err := node.Decode(&value); err != nil {
+						rowErrors = append(rowErrors, RowError{Line: name.Line, Error: err.Error()})
+						continue
+					}
+					row.Value, row.Description = value.Value, value.Description
+				default:
+					rowErrors = append(rowErrors, RowError{Line: name.Line, Error: fmt.Sprintf("%s must be a string or an object with value and description", name.Value)})
+					continue
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows, rowErrors
+}
+
+// MarshalDocument writes the rows as a JSON or YAML document, sorted by stage, environment and name
+func MarshalDocument(format string, project string, version string, rows []Row) ([]byte, error) {
+	stages := map[string]map[string]map[string]interface{}{}
+	for _, row := range rows {
+		if stages[row.Stage] == nil {
+			stages[row.Stage] = map[string]map[string]interface{}{}
+		}
+		if stages[row.Stage][row.Environment] == nil {
+			stages[row.Stage][row.Environment] = map[string]interface{}{}
+		}
+		var value interface{} = row.Value
+		if row.Description != "" {
+			value = documentValue{Value: row.Value, Description: row.Description}
+		}
+		stages[row.Stage][row.Environment][row.Name] = value
+	}
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(map[string]interface{}{"version": 1, "project": project, "version_number": version, "stages": stages}, "", "  ")
+	case FormatYAML:
+		// a node keeps the fields in this order, yaml sorts the keys of the maps
+		document := &yaml.Node{Kind: yaml.MappingNode}
+		for _, field := range []struct {
+			key   string
+			value interface{}
+		}{{"version", 1}, {"project", project}, {"version_number", version}, {"stages", stages}} {
+			var value yaml.Node
+			if err := value.Encode(field.value); err != nil {
+				return nil, err
+			}
+			document.Content = append(document.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: field.key}, &value)
+		}
+		var buffer bytes.Buffer
+		encoder := yaml.NewEncoder(&buffer)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported document format %q", format)
+}
+
+// sortRows orders rows by stage, environment and name
+func sortRows(rows []Row) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Stage != rows[j].Stage {
+			return rows[i].Stage < rows[j].Stage
+		}
+		if rows[i].Environment != rows[j].Environment {
+			return rows[i].Environment < rows[j].Environment
+		}
+		return rows[i].Name < rows[j].Name
+	})
+}
@@ -0,0 +1,118 @@
+package paramio
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var dotenvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+// ParseDotenv reads KEY=VALUE lines. Comments, blank lines and a leading "export" are ignored,
+// values may be single quoted (literal) or double quoted (with \n, \t, \" and \\ escapes),
+// an unquoted value ends at " #".
+func ParseDotenv(data []byte, stage, environment string) ([]Row, []RowError) {
+	var rows []Row
+	var rowErrors []RowError
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, found := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !found {
+			rowErrors = append(rowErrors, RowError{Line: number, Error: "expected KEY=VALUE"})
+			continue
+		}
+		if !dotenvName.MatchString(name) {
+			rowErrors = append(rowErrors, RowError{Line: number, Error: fmt.Sprintf("invalid name %q", name)})
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`):
+			// a double quoted value may span lines
+			raw := value[1:]
+			for !closingQuote(raw) && i+1 < len(lines) {
+				i++
+				raw += "\n" + lines[i]
+			}
+			if !closingQuote(raw) {
+				rowErrors = append(rowErrors, RowError{Line: number, Error: "unterminated double quote"})
+				continue
+			}
+			value = unescapeDouble(raw[:closingQuoteIndex(raw)])
+		case strings.HasPrefix(value, `'`):
+			end := strings.Index(value[1:], `'`)
+			if end < 0 {
+				rowErrors = append(rowErrors, RowError{Line: number, Error: "unterminated single quote"})
+				continue
+			}
+			value = value[1 : end+1]
+		default:
+			if index := strings.Index(value, " #"); index >= 0 {
+				value = strings.TrimSpace(value[:index])
+			}
+		}
+		rows = append(rows, Row{Line: number, Stage: stage, Environment: environment, Name: name, Value: value})
+	}
+	return rows, rowErrors
+}
+
+func closingQuoteIndex(raw string) int {
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func closingQuote(raw string) bool {
+	return closingQuoteIndex(raw) >= 0
+}
+
+func unescapeDouble(raw string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`)
+	return replacer.Replace(raw)
+}
+
+// dotenvValue quotes a value when reading it back unquoted would change it
+func dotenvValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\"'#\\") {
+		return value
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// WriteDotenv writes the rows as KEY=VALUE lines sorted by name, descriptions become comments
+func WriteDotenv(w io.Writer, header string, rows []Row) error {
+	sorted := append([]Row(nil), rows...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	if header != "" {
+		if _, err := fmt.Fprintf(w, "# %s\n", header); err != nil {
+			return err
+		}
+	}
+	for _, row := range sorted {
+		if row.Description != "" {
+			if _, err := fmt.Fprintf(w, "# %s\n", strings.ReplaceAll(row.Description, "\n", " ")); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", row.Name, dotenvValue(row.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
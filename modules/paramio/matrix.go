@@ -0,0 +1,174 @@
+package paramio
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"sort"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// MissingMarker stands for a parameter that does not exist in a column of the exported matrix
+const MissingMarker = "<missing>"
+
+// MatrixSheet is the sheet of the exported matrix
+const MatrixSheet = "Matrix"
+
+// MatrixColumn is one stage and environment of the matrix
+type MatrixColumn struct {
+	Stage       string `json:"stage"`
+	Environment string `json:"environment"`
+}
+
+// Label is the header of the column in exports
+func (column MatrixColumn) Label() string {
+	return column.Stage + " / " + column.Environment
+}
+
+// MatrixCell is the value of a parameter in one column
+type MatrixCell struct {
+	Value   string `json:"value,omitempty"`
+	Missing bool   `json:"missing"`
+}
+
+// MatrixRow is one parameter name across every column.
+// MissingIn has the columns without the name among the stages that have it in some environment,
+// Differs tells if the existing values are not all the same.
+type MatrixRow struct {
+	Name      string         `json:"name"`
+	Cells     []MatrixCell   `json:"cells"`
+	MissingIn []MatrixColumn `json:"missing_in"`
+	Differs   bool           `json:"differs"`
+}
+
+// Matrix compares the parameters of every stage and environment
+type Matrix struct {
+	Columns []MatrixColumn `json:"columns"`
+	Rows    []MatrixRow    `json:"rows"`
+}
+
+// BuildMatrix puts the rows in the given columns, rows of other columns are ignored and names are sorted
+func BuildMatrix(columns []MatrixColumn, rows []Row) Matrix {
+	indexes := map[MatrixColumn]int{}
+	for i, column := range columns {
+		indexes[column] = i
+	}
+	values := map[string][]*string{}
+	for i := range rows {
+		index, ok := indexes[MatrixColumn{Stage: rows[i].Stage, Environment: rows[i].Environment}]
+		if !ok {
+			continue
+		}
+		if values[rows[i].Name] == nil {
+			values[rows[i].Name] = make([]*string, len(columns))
+		}
+		values[rows[i].Name][index] = &rows[i].Value
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matrix := Matrix{Columns: columns, Rows: make([]MatrixRow, 0, len(names))}
+	for _, name := range names {
+		row := MatrixRow{Name: name, Cells: make([]MatrixCell, len(columns)), MissingIn: []MatrixColumn{}}
+		stages := map[string]bool{}
+		var first *string
+		for i, value := range values[name] {
+			if value == nil {
+				row.Cells[i].Missing = true
+				continue
+			}
+			row.Cells[i].Value = *value
+			stages[columns[i].Stage] = true
+			if first == nil {
+				first = value
+			} else if *first != *value {
+				row.Differs = true
+			}
+		}
+		for i, cell := range row.Cells {
+			if cell.Missing && stages[columns[i].Stage] {
+				row.MissingIn = append(row.MissingIn, columns[i])
+			}
+		}
+		matrix.Rows = append(matrix.Rows, row)
+	}
+	return matrix
+}
+
+// records are the lines of the exported matrix, with the header first
+func (matrix Matrix) records() [][]string {
+	header := []string{"Parameter Name"}
+	for _, column := range matrix.Columns {
+		header = append(header, column.Label())
+	}
+	records := [][]string{append(header, "Missing In")}
+	for _, row := range matrix.Rows {
+		record := []string{row.Name}
+		for _, cell := range row.Cells {
+			if cell.Missing {
+				record = append(record, MissingMarker)
+			} else {
+				record = append(record, cell.Value)
+			}
+		}
+		missingIn := ""
+		for i, column := range row.MissingIn {
+			if i > 0 {
+				missingIn += ", "
+			}
+			missingIn += column.Label()
+		}
+		records = append(records, append(record, missingIn))
+	}
+	return records
+}
+
+// WriteMatrixCSV writes the matrix with a column per stage and environment, missing values are MissingMarker
+func WriteMatrixCSV(w io.Writer, matrix Matrix) error {
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(matrix.records()); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// MarshalMatrixXLSX writes the matrix in the Matrix sheet, missing values and names missing somewhere are highlighted
+func MarshalMatrixXLSX(matrix Matrix) ([]byte, error) {
+	file := excelize.NewFile()
+	defer file.Close()
+	file.SetSheetName("Sheet1", MatrixSheet)
+	missingStyle, err := file.NewStyle(&excelize.Style{
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFC7CE"}},
+		Font: &excelize.Font{Color: "9C0006", Italic: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	headerStyle, err := file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return nil, err
+	}
+	for i, record := range matrix.records() {
+		for j, value := range record {
+			cell, _ := excelize.CoordinatesToCellName(j+1, i+1)
+			// as a string, so that values like 0012 are kept
+			file.SetCellStr(MatrixSheet, cell, value)
+			switch {
+			case i == 0:
+				file.SetCellStyle(MatrixSheet, cell, cell, headerStyle)
+			case j > 0 && j <= len(matrix.Columns) && matrix.Rows[i-1].Cells[j-1].Missing:
+				file.SetCellStyle(MatrixSheet, cell, cell, missingStyle)
+			}
+		}
+	}
+	file.SetPanes(MatrixSheet, &excelize.Panes{Freeze: true, XSplit: 1, YSplit: 1, TopLeftCell: "B2", ActivePane: "bottomRight"})
+	var buffer bytes.Buffer
+	if err := file.Write(&buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,68 @@
+// Package paramio reads and writes parameters in dotenv, JSON, YAML and Excel files
+package paramio
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Formats
+const (
+	FormatDotenv = "dotenv"
+	FormatJSON   = "json"
+	FormatYAML   = "yaml"
+	FormatXLSX   = "xlsx"
+)
+
+// Formats are the supported formats
+var Formats = []string{FormatDotenv, FormatJSON, FormatYAML, FormatXLSX}
+
+// Row is one parameter of a file, Line is where it starts (the sheet row for Excel)
+type Row struct {
+	Line        int    `json:"line"`
+	Stage       string `json:"stage"`
+	Environment string `json:"environment"`
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
+
+// RowError is a line that could not be read, the other lines are still returned
+type RowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// FormatOf guesses the format from a file name
+func FormatOf(fileName string) string {
+	name := strings.ToLower(filepath.Base(fileName))
+	switch {
+	case strings.HasSuffix(name, ".json"):
+		return FormatJSON
+	case strings.HasSuffix(name, ".yaml"), strings.HasSuffix(name, ".yml"):
+		return FormatYAML
+	case strings.HasSuffix(name, ".xlsx"):
+		return FormatXLSX
+	case strings.HasSuffix(name, ".env"), strings.HasPrefix(name, ".env"), strings.HasSuffix(name, ".txt"):
+		return FormatDotenv
+	}
+	return ""
+}
+
+// Parse reads the rows of a file, stage and environment are those of every row of a dotenv file
+func Parse(format string, data []byte, stage, environment string) ([]Row, []RowError, error) {
+	switch format {
+	case FormatDotenv:
+		if stage == "" || environment == "" {
+			return nil, nil, fmt.Errorf("stage and environment are required for a dotenv file")
+		}
+		rows, rowErrors := ParseDotenv(data, stage, environment)
+		return rows, rowErrors, nil
+	case FormatJSON, FormatYAML:
+		return ParseDocument(data)
+	case FormatXLSX:
+		return ParseXLSX(data)
+	}
+	return nil, nil, fmt.Errorf("unsupported format %q, use one of %s", format, strings.Join(Formats, ", "))
+}
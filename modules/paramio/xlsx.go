@@ -0,0 +1,101 @@
+package paramio
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Sheet is the sheet of parameters, the columns are found by their header
+const Sheet = "Parameters"
+
+var xlsxHeaders = []string{"Parameter Name", "Value", "Description", "Stage", "Environment"}
+
+// ParseXLSX reads the Parameters sheet, the header row names the columns in any order
+func ParseXLSX(data []byte) ([]Row, []RowError, error) {
+	file, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid xlsx file: %v", err)
+	}
+	defer file.Close()
+	sheetRows, err := file.GetRows(Sheet)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sheet %s not found", Sheet)
+	}
+	if len(sheetRows) == 0 {
+		return nil, nil, fmt.Errorf("sheet %s is empty", Sheet)
+	}
+	columns := map[string]int{}
+	for i, header := range sheetRows[0] {
+		columns[strings.ToLower(strings.TrimSpace(header))] = i
+	}
+	column := func(names ...string) int {
+		for _, name := range names {
+			if index, ok := columns[name]; ok {
+				return index
+			}
+		}
+		return -1
+	}
+	nameColumn, valueColumn := column("parameter name", "name"), column("value")
+	descriptionColumn, stageColumn, environmentColumn := column("description"), column("stage"), column("environment")
+	if nameColumn < 0 || valueColumn < 0 || stageColumn < 0 || environmentColumn < 0 {
+		return nil, nil, fmt.Errorf("header must have the columns %s", strings.Join(xlsxHeaders, ", "))
+	}
+	cell := func(row []string, index int) string {
+		if index < 0 || index >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[index])
+	}
+
+	var rows []Row
+	var rowErrors []RowError
+	for i, sheetRow := range sheetRows[1:] {
+		line := i + 2
+		row := Row{
+			Line:        line,
+			Name:        cell(sheetRow, nameColumn),
+			Value:       cell(sheetRow, valueColumn),
+			Description: cell(sheetRow, descriptionColumn),
+			Stage:       cell(sheetRow, stageColumn),
+			Environment: cell(sheetRow, environmentColumn),
+		}
+		if row == (Row{Line: line}) {
+			continue
+		}
+		if row.Stage == "" || row.Environment == "" {
+			rowErrors = append(rowErrors, RowError{Line: line, Error: "stage and environment are required"})
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+// MarshalXLSX writes the rows in the Parameters sheet with the header of the upload template
+func MarshalXLSX(rows []Row) ([]byte, error) {
+	file := excelize.NewFile()
+	defer file.Close()
+	file.SetSheetName("Sheet1", Sheet)
+	for i, header := range xlsxHeaders {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		file.SetCellValue(Sheet, cell, header)
+	}
+	sorted := append([]Row(nil), rows...)
+	sortRows(sorted)
+	for i, row := range sorted {
+		for j, value := range []string{row.Name, row.Value, row.Description, row.Stage, row.Environment} {
+			cell, _ := excelize.CoordinatesToCellName(j+1, i+2)
+			// as a string, so that values like 0012 are kept
+			file.SetCellStr(Sheet, cell, value)
+		}
+	}
+	var buffer bytes.Buffer
+	if err := file.Write(&buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,122 @@
+// Package projecttemplate checks the values of parameters against the required parameters of a project template
+package projecttemplate
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Types of required parameters
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeURL     = "url"
+	TypeSecret  = "secret"
+)
+
+// Types are the supported types
+var Types = []string{TypeString, TypeNumber, TypeBoolean, TypeURL, TypeSecret}
+
+// Problems of an issue
+const (
+	ProblemMissing = "missing"
+	ProblemInvalid = "invalid"
+)
+
+// IsType tells if the type is supported, empty means string
+func IsType(kind string) bool {
+	if kind == "" {
+		return true
+	}
+	for _, t := range Types {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateValue tells why the value is not of the type, strings and secrets only have to be set
+func ValidateValue(kind, value string) error {
+	if value == "" {
+		return fmt.Errorf("value is empty")
+	}
+	switch kind {
+	case TypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+	case TypeBoolean:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+	case TypeURL:
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%q is not an absolute URL", value)
+		}
+	}
+	return nil
+}
+
+// Requirement is a parameter a project must have, in one stage and environment or in every one when they are empty
+type Requirement struct {
+	Name        string
+	Type        string
+	Stage       string
+	Environment string
+}
+
+// Issue is a required parameter missing or invalid in a stage and environment, the value is never reported
+type Issue struct {
+	Name        string `json:"name"`
+	Stage       string `json:"stage"`
+	Environment string `json:"environment"`
+	Problem     string `json:"problem"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// Key identifies a value by the names of its stage and environment
+func Key(stage, environment, name string) string {
+	return stage + "/" + environment + "/" + name
+}
+
+// Check returns the issues of the values, keyed by Key, against the requirements in the given stages and environments.
+// A requirement for a stage or environment the project does not have is missing there.
+func Check(requirements []Requirement, stages, environments []string, values map[string]string) []Issue {
+	issues := []Issue{}
+	for _, requirement := range requirements {
+		requiredStages, requiredEnvironments := stages, environments
+		if requirement.Stage != "" {
+			requiredStages = []string{requirement.Stage}
+		}
+		if requirement.Environment != "" {
+			requiredEnvironments = []string{requirement.Environment}
+		}
+		for _, stage := range requiredStages {
+			for _, environment := range requiredEnvironments {
+				issue := Issue{Name: requirement.Name, Stage: stage, Environment: environment}
+				value, exists := values[Key(stage, environment, requirement.Name)]
+				if !exists {
+					issue.Problem = ProblemMissing
+				} else if err := ValidateValue(requirement.Type, value); err != nil {
+					issue.Problem, issue.Reason = ProblemInvalid, err.Error()
+					if requirement.Type == TypeSecret {
+						issue.Reason = "value is empty"
+					}
+				} else {
+					continue
+				}
+				issues = append(issues, issue)
+			}
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return strings.Compare(Key(issues[i].Stage, issues[i].Environment, issues[i].Name), Key(issues[j].Stage, issues[j].Environment, issues[j].Name)) < 0
+	})
+	return issues
+}
@@ -0,0 +1,66 @@
+// Package rotation tells how close a credential is to its rotation date or its expiry
+package rotation
+
+import (
+	"math"
+	"time"
+)
+
+// Statuses of a credential, from the least to the most urgent
+const (
+	StatusOK       = "ok"
+	StatusExpiring = "expiring" // rotation or expiry due within the warning window
+	StatusStale    = "stale"    // not rotated for longer than the policy allows
+	StatusExpired  = "expired"  // past its expiry date
+)
+
+var statusRanks = map[string]int{StatusOK: 0, StatusExpiring: 1, StatusStale: 2, StatusExpired: 3}
+
+// Policy is the rotation policy of a credential, a zero RotationDays or ExpiresAt disables that part
+type Policy struct {
+	RotationDays int
+	ExpiresAt    time.Time
+	RotatedAt    time.Time // when the value last changed
+}
+
+// State is where a credential stands against its policy
+type State struct {
+	Status   string    `json:"status"`
+	DueAt    time.Time `json:"due_at"`    // the earliest of the rotation date and the expiry
+	DaysLeft int       `json:"days_left"` // negative when overdue
+}
+
+// HasPolicy tells if the policy asks for anything
+func HasPolicy(policy Policy) bool {
+	return policy.RotationDays > 0 || !policy.ExpiresAt.IsZero()
+}
+
+// Evaluate places the credential against its policy at now, warn is how early it is reported as expiring
+func Evaluate(policy Policy, now time.Time, warn time.Duration) State {
+	if !HasPolicy(policy) {
+		return State{Status: StatusOK}
+	}
+	var rotateAt time.Time
+	if policy.RotationDays > 0 {
+		rotateAt = policy.RotatedAt.AddDate(0, 0, policy.RotationDays)
+	}
+	dueAt := rotateAt
+	if !policy.ExpiresAt.IsZero() && (dueAt.IsZero() || policy.ExpiresAt.Before(dueAt)) {
+		dueAt = policy.ExpiresAt
+	}
+	state := State{Status: StatusOK, DueAt: dueAt, DaysLeft: int(math.Floor(dueAt.Sub(now).Hours() / 24))}
+	switch {
+	case !policy.ExpiresAt.IsZero() && !now.Before(policy.ExpiresAt):
+		state.Status = StatusExpired
+	case !rotateAt.IsZero() && !now.Before(rotateAt):
+		state.Status = StatusStale
+	case dueAt.Sub(now) <= warn:
+		state.Status = StatusExpiring
+	}
+	return state
+}
+
+// MoreUrgent tells if status a is more urgent than status b
+func MoreUrgent(a, b string) bool {
+	return statusRanks[a] > statusRanks[b]
+}
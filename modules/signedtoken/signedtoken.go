@@ -0,0 +1,45 @@
+package signedtoken
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Generate returns a token "<random>.<signature>" bound to the purpose, and the hash to store in the database
+func Generate(secret, purpose string) (token string, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	random := base64.RawURLEncoding.EncodeToString(raw)
+	token = random + "." + sign(secret, purpose, random)
+	return token, Hash(token), nil
+}
+
+// Verify checks the signature of the token for the purpose and returns the hash to look up
+func Verify(secret, purpose, token string) (string, bool) {
+	random, signature, found := strings.Cut(token, ".")
+	if !found || random == "" {
+		return "", false
+	}
+	if !hmac.Equal([]byte(signature), []byte(sign(secret, purpose, random))) {
+		return "", false
+	}
+	return Hash(token), true
+}
+
+// Hash is the value stored instead of the token, so a database leak does not expose usable tokens
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func sign(secret, purpose, random string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose + ":" + random))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,105 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Period is the time step in seconds (RFC 6238 default)
+	Period = 30
+	// Digits is the length of the generated code
+	Digits = 6
+	// Skew is the number of periods accepted before and after the current one
+	Skew = 1
+	// secretSize is the size of the generated secret in bytes (160 bits as recommended by RFC 4226)
+	secretSize = 20
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random base32 encoded secret
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(secret), nil
+}
+
+// ProvisioningURI returns the otpauth:// URI that authenticator apps read from a QR code
+func ProvisioningURI(secret, issuer, accountName string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(accountName)
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(Digits))
+	v.Set("period", fmt.Sprint(Period))
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// GenerateCode returns the code for the given secret at time t
+func GenerateCode(secret string, t time.Time) (string, error) {
+	key, err := b32.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", fmt.Errorf("invalid totp secret: %v", err)
+	}
+	return hotp(key, uint64(t.Unix()/Period)), nil
+}
+
+// Validate checks the code against the secret at time t, allowing Skew periods of clock drift
+func Validate(secret, code string, t time.Time) bool {
+	_, ok := ValidateStep(secret, code, t, 0)
+	return ok
+}
+
+// ValidateStep checks the code like Validate and returns the time step it matched. Steps at or below lastStep,
+// the step of the last accepted code, are rejected so a code can not be replayed.
+func ValidateStep(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+	key, err := b32.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return 0, false
+	}
+	counter := t.Unix() / Period
+	for i := -Skew; i <= Skew; i++ {
+		step := counter + int64(i)
+		if step <= lastStep {
+			continue
+		}
+		expected := hotp(key, uint64(step))
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// hotp implements RFC 4226 with HMAC-SHA1 and dynamic truncation
+func hotp(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
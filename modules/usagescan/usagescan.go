@@ -0,0 +1,139 @@
+// Package usagescan finds where parameter names appear in the files of a repository
+package usagescan
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// snippetMaxLength keeps stored lines short, minified files have very long ones
+const snippetMaxLength = 200
+
+// skippedDirs hold dependencies and build output, not code of the project
+var skippedDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "dist": true, "build": true, "target": true, ".next": true, "__pycache__": true,
+}
+
+// skippedFiles are lock files full of package names that look like parameters
+var skippedFiles = map[string]bool{
+	"package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true, "go.sum": true, "Cargo.lock": true, "poetry.lock": true, "composer.lock": true,
+}
+
+// Occurrence is a line of a file where a name appears
+type Occurrence struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Snippet string `json:"snippet"`
+}
+
+// Skip tells if a file is left out of the scan
+func Skip(filePath string) bool {
+	if skippedFiles[path.Base(filePath)] {
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(filePath), "/") {
+		if skippedDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// words splits a line into its identifiers
+func words(line string) []string {
+	var result []string
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		if i < len(line) && isWordByte(line[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			result = append(result, line[start:i])
+			start = -1
+		}
+	}
+	return result
+}
+
+// containsWord tells if the name appears in the line without identifier characters around it
+func containsWord(line, name string) bool {
+	for offset := 0; ; {
+		index := strings.Index(line[offset:], name)
+		if index < 0 {
+			return false
+		}
+		start, end := offset+index, offset+index+len(name)
+		if (start == 0 || !isWordByte(line[start-1])) && (end == len(line) || !isWordByte(line[end])) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+// Scanner looks for a fixed set of names, as whole words so DB_HOST does not match DB_HOSTNAME
+type Scanner struct {
+	identifiers map[string]bool
+	others      []string // names with characters like . or - that identifiers never contain
+}
+
+// NewScanner prepares the names to look for
+func NewScanner(names []string) *Scanner {
+	scanner := &Scanner{identifiers: map[string]bool{}}
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if parts := words(name); len(parts) == 1 && parts[0] == name {
+			scanner.identifiers[name] = true
+		} else {
+			scanner.others = append(scanner.others, name)
+		}
+	}
+	return scanner
+}
+
+// Scan returns the occurrences of the names in a file, a name appears once per line
+func (s *Scanner) Scan(filePath string, content []byte) []Occurrence {
+	var occurrences []Occurrence
+	for number, line := range strings.Split(string(content), "\n") {
+		found := map[string]bool{}
+		for _, word := range words(line) {
+			if s.identifiers[word] {
+				found[word] = true
+			}
+		}
+		for _, name := range s.others {
+			if containsWord(line, name) {
+				found[name] = true
+			}
+		}
+		if len(found) == 0 {
+			continue
+		}
+		snippet := strings.TrimSpace(line)
+		if len(snippet) > snippetMaxLength {
+			snippet = snippet[:snippetMaxLength]
+		}
+		// the cut or the file itself may leave broken UTF-8, which the database rejects
+		snippet = strings.ToValidUTF8(snippet, "")
+		for name := range found {
+			occurrences = append(occurrences, Occurrence{Name: name, Path: filePath, Line: number + 1, Snippet: snippet})
+		}
+	}
+	sort.Slice(occurrences, func(i, j int) bool {
+		if occurrences[i].Line != occurrences[j].Line {
+			return occurrences[i].Line < occurrences[j].Line
+		}
+		return occurrences[i].Name < occurrences[j].Name
+	})
+	return occurrences
+}
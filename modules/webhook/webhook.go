@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent      = "X-Parameter-Store-Event"
+	HeaderDelivery   = "X-Parameter-Store-Delivery"
+	HeaderTimestamp  = "X-Parameter-Store-Timestamp"
+	HeaderSignature  = "X-Parameter-Store-Signature"
+	maxResponseBytes = 4096
+	// excerptLength is the part of the response kept for the delivery log
+	excerptLength = 200
+)
+
+// ErrForbiddenAddress is returned for receivers on loopback, private, link-local or multicast addresses
+var ErrForbiddenAddress = errors.New("webhook receiver must be on a public address")
+
+// reservedNetworks are not covered by the net.IP helpers: this network, carrier-grade NAT, IETF protocol
+// assignments, benchmarking and reserved
+var reservedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// allowPrivateNetworks lets on-premise installations deliver to receivers inside their network
+func allowPrivateNetworks() bool {
+	return os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true"
+}
+
+// IsPublicIP tells if the address is routable on the internet, the cloud metadata address 169.254.169.254 is not
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateURL checks the scheme and that every address of the host is public
+func ValidateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Hostname() == "" {
+		return errors.New("url must be an http or https URL")
+	}
+	if allowPrivateNetworks() {
+		return nil
+	}
+	ips, err := net.LookupIP(parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s", parsed.Hostname())
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// checkDialAddress runs on the resolved address of every connection, so a host resolving to a public address
+// at validation and to a private one at delivery is still refused
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	if allowPrivateNetworks() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublicIP(net.ParseIP(host)) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// Client refuses non public addresses on every connection and does not follow redirects, the chat notifications
+// post with it too
+var Client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: checkDialAddress}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        20,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	// a redirect is reported as the response, receivers must answer on the configured URL
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// excerpt keeps the start of a response with control characters replaced, the receiver controls its content
+func excerpt(body []byte) string {
+	text := strings.ToValidUTF8(string(body), "")
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text)
+	if runes := []rune(text); len(runes) > excerptLength {
+		text = string(runes[:excerptLength]) + "..."
+	}
+	return strings.TrimSpace(text)
+}
+
+// Result is the outcome of one delivery attempt
+type Result struct {
+	StatusCode int
+	Body       string
+	Duration   time.Duration
+}
+
+// GenerateSecret returns a random secret used to sign the deliveries of a webhook
+func GenerateSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// NewDeliveryID returns a random ID sent in the delivery header and payload, receivers use it to deduplicate
+func NewDeliveryID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns "sha256=<hex>" of HMAC-SHA256(secret, "<timestamp>.<body>").
+// Receivers recompute it and reject old timestamps to stop replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature made by Sign
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Deliver posts the signed payload, a 2xx response is a success. Redirects are not followed and receivers on
+// non public addresses are refused, the Body of the result is a short excerpt of the response.
+func Deliver(url, secret, event, deliveryID string, body []byte) (Result, error) {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "parameter-store-webhook")
+	request.Header.Set(HeaderEvent, event)
+	request.Header.Set(HeaderDelivery, deliveryID)
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+
+	start := time.Now()
+	response, err := Client.Do(request)
+	result := Result{Duration: time.Since(start)}
+	if err != nil {
+		return result, err
+	}
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(io.LimitReader(response.Body, maxResponseBytes))
+	result.StatusCode = response.StatusCode
+	result.Body = excerpt(responseBody)
+	return result, nil
+}
@@ -12,5 +12,6 @@ func setupGroupAgent(r *gin.RouterGroup) {
 		agentGroup.POST("/:agent_id/rerun-workflow", controllers.RerunWorkFlowByAgent)
 		agentGroup.POST("/auth-parameters", controllers.GetParameterByAuthAgent)
 		agentGroup.GET("/download", controllers.DownloadAgentScript)
+		agentGroup.GET("/watch", controllers.WatchAgentParameters)
 	}
 }
@@ -12,8 +12,25 @@ func setupGroupAuth(r *gin.RouterGroup) {
 	{
 		authGroup.POST("/login", controllers.Login)
 		authGroup.POST("/login/github", controllers.LoginWithGithub)
+		authGroup.POST("/login/mfa", controllers.LoginWithMFA)
+		authGroup.POST("/login/oidc", controllers.LoginWithOIDC)
+		authGroup.GET("/oidc/authorize", controllers.StartOIDCLogin)
+		authGroup.POST("/oidc/link", middleware.RequiredAuth, controllers.LinkOIDCAccount)
 		authGroup.POST("/register", controllers.Register)
 		authGroup.GET("/validate", middleware.RequiredAuth, controllers.Validate)
 		authGroup.POST("/logout", middleware.RequiredAuth, controllers.Logout)
+		authGroup.POST("/password/forgot", controllers.ForgotPassword)
+		authGroup.POST("/password/reset", controllers.ResetPassword)
+		authGroup.POST("/email/verify", controllers.VerifyEmail)
+		authGroup.POST("/email/resend-verification", middleware.RequiredAuth, controllers.ResendVerificationEmail)
+		authGroup.GET("/invitations", controllers.GetInvitation)
+		authGroup.POST("/invitations/accept", controllers.AcceptInvitation)
+		mfaGroup := authGroup.Group("/mfa", middleware.RequiredAuth)
+		{
+			mfaGroup.POST("/enroll", controllers.EnrollMFA)
+			mfaGroup.POST("/verify", controllers.VerifyMFAEnrollment)
+			mfaGroup.POST("/disable", controllers.DisableMFA)
+			mfaGroup.POST("/recovery-codes", controllers.RegenerateRecoveryCodes)
+		}
 	}
 }
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"parameter-store-be/controllers"
+	"parameter-store-be/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupGroupDeclarative(r *gin.RouterGroup) {
+	declarativeGroup := r.Group("/declarative/projects", middleware.RequiredAuth)
+	{
+		declarativeGroup.GET("/:project_name", controllers.GetDeclarativeProject)
+		declarativeGroup.PUT("/:project_name", controllers.PutDeclarativeProject)
+		declarativeGroup.DELETE("/:project_name", controllers.DeleteDeclarativeProject)
+
+		declarativeGroup.GET("/:project_name/stages/:stage_name", controllers.GetDeclarativeStage)
+		declarativeGroup.PUT("/:project_name/stages/:stage_name", controllers.PutDeclarativeStage)
+		declarativeGroup.DELETE("/:project_name/stages/:stage_name", controllers.DeleteDeclarativeStage)
+
+		declarativeGroup.GET("/:project_name/environments/:environment_name", controllers.GetDeclarativeEnvironment)
+		declarativeGroup.PUT("/:project_name/environments/:environment_name", controllers.PutDeclarativeEnvironment)
+		declarativeGroup.DELETE("/:project_name/environments/:environment_name", controllers.DeleteDeclarativeEnvironment)
+
+		declarativeGroup.GET("/:project_name/agents/:agent_name", controllers.GetDeclarativeAgent)
+		declarativeGroup.PUT("/:project_name/agents/:agent_name", controllers.PutDeclarativeAgent)
+		declarativeGroup.DELETE("/:project_name/agents/:agent_name", controllers.DeleteDeclarativeAgent)
+
+		parameterPath := "/:project_name/stages/:stage_name/environments/:environment_name/parameters/:parameter_name"
+		declarativeGroup.GET(parameterPath, controllers.GetDeclarativeParameter)
+		declarativeGroup.PUT(parameterPath, controllers.PutDeclarativeParameter)
+		declarativeGroup.DELETE(parameterPath, controllers.DeleteDeclarativeParameter)
+	}
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"parameter-store-be/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupGroupGitOps(r *gin.RouterGroup) {
+	gitopsGroup := r.Group("/gitops")
+	{
+		// authenticated by the signature of the project webhook secret
+		gitopsGroup.POST("/github/push", controllers.ReceiveGitHubPush)
+	}
+}
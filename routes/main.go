@@ -5,6 +5,7 @@ package routes
 import (
 	"os"
 	docs "parameter-store-be/docs"
+	"parameter-store-be/middleware"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -25,18 +26,22 @@ func SetupV1Router() *gin.Engine {
 		"https://chienduynguyen1702.github.io",
 		"http://localhost:" + os.Getenv("PORT"),
 	}
-	r := gin.Default()
+	// the default logger with tokens of query strings redacted
+	r := gin.New()
+	r.Use(gin.LoggerWithFormatter(middleware.AccessLogFormatter), gin.Recovery())
 	
 	// CORS setup
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     whiteList,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length", "Content-Type", "Content-Description", "Content-Disposition"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "If-Match", "If-None-Match", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", "Content-Type", "Content-Description", "Content-Disposition", "ETag", middleware.RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * 30 * time.Hour,
 	}))
 
+	r.Use(middleware.RequestID)
+
 	// Setup routes for the API version 1
 	v1 := r.Group("/api/v1")
 	{
@@ -48,6 +53,8 @@ func SetupV1Router() *gin.Engine {
 		setupGroupAgent(v1)
 		setupGroupStage(v1)
 		setupGroupEnvironment(v1)
+		setupGroupDeclarative(v1)
+		setupGroupGitOps(v1)
 	}
 	if os.Getenv("GIN_MODE") == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -14,5 +14,37 @@ func setupGroupOrganization(r *gin.RouterGroup) {
 		organizationGroup.GET("/dashboard/logs", middleware.RequiredIsOrgAdmin, controllers.GetOrganizationDashboardLogs)
 		organizationGroup.GET("/dashboard/totals", middleware.RequiredIsOrgAdmin, controllers.GetOrganizationDashboardTotals)
 		organizationGroup.PUT("/:organization_id", middleware.RequiredIsOrgAdmin, controllers.UpdateOrganizationInformation)
+		organizationGroup.PUT("/:organization_id/mfa-policy", middleware.RequiredIsOrgAdmin, controllers.UpdateOrganizationMFAPolicy)
+		organizationGroup.GET("/secret-rotation", middleware.RequiredIsOrgAdmin, controllers.GetOrganizationRotationReport)
+		auditGroup := organizationGroup.Group("/audit-logs", middleware.RequiredIsOrgAdmin)
+		{
+			auditGroup.GET("/", controllers.ListAuditLogs)
+			auditGroup.GET("/verify", controllers.VerifyAuditLogs)
+			auditGroup.GET("/head", controllers.GetAuditLogHead)
+			auditGroup.GET("/export", controllers.ExportAuditLogs)
+		}
+		oidcGroup := organizationGroup.Group("/oidc-providers", middleware.RequiredIsOrgAdmin)
+		{
+			oidcGroup.GET("/", controllers.ListOIDCProviders)
+			oidcGroup.POST("/", controllers.CreateOIDCProvider)
+			oidcGroup.PUT("/:provider_id", controllers.UpdateOIDCProvider)
+			oidcGroup.DELETE("/:provider_id", controllers.DeleteOIDCProvider)
+		}
+		templateGroup := organizationGroup.Group("/project-templates", middleware.RequiredIsOrgAdmin)
+		{
+			templateGroup.GET("/", controllers.ListProjectTemplates)
+			templateGroup.POST("/", controllers.CreateProjectTemplate)
+			templateGroup.PUT("/:template_id", controllers.UpdateProjectTemplate)
+			templateGroup.DELETE("/:template_id", controllers.DeleteProjectTemplate)
+			templateGroup.GET("/:template_id/compliance", controllers.GetProjectTemplateCompliance)
+		}
+		parameterSetGroup := organizationGroup.Group("/parameter-sets", middleware.RequiredIsOrgAdmin)
+		{
+			parameterSetGroup.GET("/", controllers.ListSharedParameterSets)
+			parameterSetGroup.POST("/", controllers.CreateSharedParameterSet)
+			parameterSetGroup.GET("/:set_id", controllers.GetSharedParameterSet)
+			parameterSetGroup.PUT("/:set_id", controllers.UpdateSharedParameterSet)
+			parameterSetGroup.DELETE("/:set_id", controllers.DeleteSharedParameterSet)
+		}
 	}
 }
@@ -8,9 +8,12 @@ import (
 )
 
 func setupGroupProject(r *gin.RouterGroup) {
+	// outside the group so that the stream also accepts a ticket instead of the Authorization header
+	r.GET("/projects/:project_id/events", middleware.RequiredStreamAuth, middleware.RequiredBelongToProject, controllers.StreamProjectEvents)
 	projectGroup := r.Group("/projects/:project_id", middleware.RequiredAuth, middleware.RequiredBelongToProject)
 	{
 		projectGroup.GET("/", controllers.GetProjectAllInfo)
+		projectGroup.POST("/events/ticket", controllers.CreateStreamTicket)
 		projectGroup.POST("/apply-parameters", middleware.RequiredIsAdmin, controllers.ApplyParametersInProject)
 		overviewGroup := projectGroup.Group("/overview")
 		{
@@ -22,6 +25,37 @@ func setupGroupProject(r *gin.RouterGroup) {
 			overviewGroup.PUT("/users/:user_id", controllers.UpdateUserInProject)
 			overviewGroup.DELETE("/users/:user_id", middleware.RequiredIsAdmin, controllers.RemoveUserFromProject)
 		}
+		webhookGroup := projectGroup.Group("/webhooks", middleware.RequiredIsAdmin)
+		{
+			webhookGroup.GET("/", controllers.ListWebhooks)
+			webhookGroup.POST("/", controllers.CreateWebhook)
+			webhookGroup.PUT("/:webhook_id", controllers.UpdateWebhook)
+			webhookGroup.DELETE("/:webhook_id", controllers.DeleteWebhook)
+			webhookGroup.POST("/:webhook_id/ping", controllers.PingWebhook)
+			webhookGroup.GET("/:webhook_id/deliveries", controllers.ListWebhookDeliveries)
+			webhookGroup.POST("/:webhook_id/deliveries/:delivery_id/redeliver", controllers.RedeliverWebhookDelivery)
+		}
+		notificationGroup := projectGroup.Group("/notifications", middleware.RequiredIsAdmin)
+		{
+			notificationGroup.GET("/", controllers.ListNotificationChannels)
+			notificationGroup.POST("/", controllers.CreateNotificationChannel)
+			notificationGroup.PUT("/:channel_id", controllers.UpdateNotificationChannel)
+			notificationGroup.DELETE("/:channel_id", controllers.DeleteNotificationChannel)
+			notificationGroup.POST("/:channel_id/test", controllers.TestNotificationChannel)
+		}
+		gitopsGroup := projectGroup.Group("/gitops")
+		{
+			gitopsGroup.GET("/", controllers.GetGitOpsConfig)
+			gitopsGroup.PUT("/", middleware.RequiredIsAdmin, controllers.UpdateGitOpsConfig)
+			gitopsGroup.GET("/plan", controllers.PreviewGitOpsSync)
+			gitopsGroup.POST("/apply", middleware.RequiredIsAdmin, controllers.ApplyGitOpsSync)
+		}
+		invitationGroup := projectGroup.Group("/invitations", middleware.RequiredIsAdmin)
+		{
+			invitationGroup.GET("/", controllers.ListProjectInvitations)
+			invitationGroup.POST("/", controllers.InviteUserToProject)
+			invitationGroup.DELETE("/:invitation_id", controllers.RevokeInvitation)
+		}
 		workflowGroup := projectGroup.Group("/workflows")
 		{
 			workflowGroup.GET("/", controllers.GetProjectWorkflows)
@@ -49,6 +83,7 @@ func setupGroupProject(r *gin.RouterGroup) {
 		{
 			versionGroup.GET("/", controllers.GetProjectVersions)
 			versionGroup.POST("/", middleware.RequiredIsAdmin, controllers.CreateNewVersion)
+			versionGroup.GET("/:version_id", controllers.GetProjectVersion)
 
 		}
 		parameterGroup := projectGroup.Group("/parameters")
@@ -66,14 +101,53 @@ func setupGroupProject(r *gin.RouterGroup) {
 
 			parameterGroup.GET("/download-template", controllers.DownloadExecelTemplateParameters)
 			parameterGroup.POST("/upload", middleware.RequiredIsAdmin, controllers.UploadParameters)
+			parameterGroup.POST("/import", middleware.RequiredIsAdmin, controllers.ImportParameters)
+			parameterGroup.GET("/export", controllers.ExportParameters)
+			parameterGroup.GET("/matrix", controllers.GetParameterMatrix)
+			parameterGroup.POST("/promote", middleware.RequiredIsAdmin, controllers.PromoteParameters)
+			parameterGroup.PUT("/:parameter_id/rotation-policy", middleware.RequiredIsAdmin, controllers.UpdateParameterRotationPolicy)
+			parameterGroup.PUT("/:parameter_id/dynamic-source", middleware.RequiredIsAdmin, controllers.UpdateParameterDynamicSource)
+			parameterGroup.GET("/usage", controllers.GetParameterUsage)
+			parameterGroup.GET("/usage/unused", controllers.GetUnusedParameters)
+			parameterGroup.POST("/usage/scan", middleware.RequiredIsAdmin, controllers.ScanParameterUsage)
+			parameterGroup.GET("/missing", controllers.GetMissingParameters)
+			parameterGroup.POST("/missing/drafts", middleware.RequiredIsAdmin, controllers.CreateMissingParameterDrafts)
 			parameterGroup.GET("/:parameter_id/search-in-repo", controllers.SearchParameterInRepo)
 			parameterGroup.GET("/:parameter_id/get-file-content", controllers.TestGetFileContent)
 
 			parameterGroup.POST("/check-using", controllers.CheckParameterUsing)
 		}
+		parameterSetGroup := projectGroup.Group("/parameter-sets")
+		{
+			parameterSetGroup.GET("/", controllers.GetProjectParameterSets)
+			parameterSetGroup.POST("/:set_id", middleware.RequiredIsAdmin, controllers.LinkProjectParameterSet)
+			parameterSetGroup.DELETE("/:set_id", middleware.RequiredIsAdmin, controllers.UnlinkProjectParameterSet)
+		}
+		dynamicCredentialGroup := projectGroup.Group("/dynamic-credentials", middleware.RequiredIsAdmin)
+		{
+			dynamicCredentialGroup.GET("/", controllers.GetDynamicCredentialSources)
+			dynamicCredentialGroup.POST("/", controllers.CreateDynamicCredentialSource)
+			dynamicCredentialGroup.GET("/leases", controllers.GetDynamicCredentialLeases)
+			dynamicCredentialGroup.DELETE("/leases/:lease_id", controllers.RevokeDynamicCredentialLease)
+			dynamicCredentialGroup.PUT("/:source_id", controllers.UpdateDynamicCredentialSource)
+			dynamicCredentialGroup.DELETE("/:source_id", controllers.DeleteDynamicCredentialSource)
+		}
+		rotationGroup := projectGroup.Group("/secret-rotation")
+		{
+			rotationGroup.GET("/", controllers.GetProjectRotationReport)
+			rotationGroup.PUT("/settings", middleware.RequiredIsAdmin, controllers.UpdateProjectRotationSettings)
+		}
+		scheduledChangeGroup := projectGroup.Group("/scheduled-changes")
+		{
+			scheduledChangeGroup.GET("/", controllers.GetScheduledChanges)
+			scheduledChangeGroup.POST("/", middleware.RequiredIsAdmin, controllers.CreateScheduledChange)
+			scheduledChangeGroup.DELETE("/:change_id", middleware.RequiredIsAdmin, controllers.CancelScheduledChange)
+		}
 		trackingGroup := projectGroup.Group("/tracking")
 		{
 			trackingGroup.GET("/logs", controllers.GetProjectTracking)
+			trackingGroup.GET("/audit-logs", controllers.GetProjectAuditLogs)
+			trackingGroup.GET("/audit-logs/export", controllers.ExportProjectAuditLogs)
 			// trackingGroup.POST("/", controllers.CreateNewTracking)
 			// trackingGroup.PUT("/:tracking_id", controllers.UpdateTracking)
 			// trackingGroup.DELETE("/:tracking_id", controllers.DeleteTracking)
@@ -12,6 +12,7 @@ func setupGroupProjectList(r *gin.RouterGroup) {
 	{
 		projectListGroup.GET("/", controllers.ListProjects)
 		projectListGroup.POST("/", middleware.RequiredIsOrgAdmin, controllers.CreateNewProject)
+		projectListGroup.POST("/:project_id/clone", middleware.RequiredIsOrgAdmin, controllers.CloneProject)
 		// projectListGroup.DELETE("/:project_id", middleware.RequiredIsOrgAdmin, controllers.DeleteProject)
 
 		projectListGroup.GET("/archived", controllers.ListArchivedProjects)
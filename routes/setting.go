@@ -22,6 +22,12 @@ func setupGroupSetting(r *gin.RouterGroup) {
 			userGroup.PATCH("/:user_id/archive", middleware.RequiredIsAdmin, controllers.ArchiveUser)
 			userGroup.PATCH("/:user_id/unarchive", middleware.RequiredIsAdmin, controllers.RestoreUser)
 		}
+		tokenGroup := userSettingGroup.Group("/tokens")
+		{
+			tokenGroup.GET("", controllers.ListPersonalAccessTokens)
+			tokenGroup.POST("", controllers.CreatePersonalAccessToken)
+			tokenGroup.DELETE("/:token_id", controllers.RevokePersonalAccessToken)
+		}
 		roleGroup := userSettingGroup.Group("/roles")
 		{
 			roleGroup.GET("/", controllers.ListRole)
@@ -41,19 +41,34 @@ if [ -z "$PARAMETER_STORE_TOKEN" ]; then
     exit 1
 fi
 
-response=$(curl -s  -D - -o $output_file  POST https://param-store-be.datn.live/api/v1/agents/auth-parameters \
+# Send the ETag of the last pull, the server answers 304 when the parameters did not change
+etag_file="$output_file.etag"
+etag_header=()
+if [ -f "$etag_file" ] && [ -f "$output_file" ]; then
+    etag_header=(-H "If-None-Match: $(cat "$etag_file")")
+fi
+body_file=$(mktemp)
+trap 'rm -f "$body_file"' EXIT
+
+response=$(curl -s  -D - -o "$body_file" -X POST https://param-store-be.datn.live/api/v1/agents/auth-parameters \
     -H "Content-Type: application/json" \
+    "${etag_header[@]}" \
     -d "{\"api_token\":\"$PARAMETER_STORE_TOKEN\"}")
 
 # Separate the headers, body, and status code
 headers=$(echo "$response" | sed -n '/^\r$/q;p')
-body=$(echo "$response" | sed -n '/^\r$/,$p' | sed '1d')
+body=$(cat "$body_file")
 
 # Extract the status code from the headers
 status_code=$(echo "$headers" | grep HTTP | awk '{print $2}')
 
 timestamp=$(date +"%Y-%m-%d %H:%M:%S")
 
+if [ "$status_code" == "304" ]; then
+    echo "Parameters not changed, $output_file is up to date."
+    echo "$timestamp $status_code Parameters not changed." >> "$log_file"
+    exit 0
+fi
 if [ "$status_code" == "401" ]; then
     echo "Unauthorized. Check the API token."
     echo "$timestamp $status_code Unauthorized. Check the API token." >> "$log_file"
@@ -87,4 +102,5 @@ if [ "$status_code" == "200" ]; then
     echo "$timestamp $status_code Parameters retrieved successfully." >> "$log_file"
 fi
 echo "$parameters" > "$output_file"
+echo "$headers" | grep -i '^etag:' | cut -d' ' -f2- | tr -d '\r' > "$etag_file"
 echo "Parameters written to $output_file successfully."
@@ -0,0 +1,28 @@
+package test
+
+import (
+	"parameter-store-be/middleware"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAccessLog(t *testing.T) {
+	assert.Equal(t, "/api/v1/projects/1/events?last_event_id=7&ticket=REDACTED", middleware.RedactQuery("/api/v1/projects/1/events?ticket=abc.def&last_event_id=7"))
+	assert.Equal(t, "/api/v1/auth/invitations?token=REDACTED", middleware.RedactQuery("/api/v1/auth/invitations?token=secret"))
+	assert.Equal(t, "/api/v1/projects/", middleware.RedactQuery("/api/v1/projects/"))
+
+	line := middleware.AccessLogFormatter(gin.LogFormatterParams{
+		TimeStamp:  time.Now(),
+		StatusCode: 200,
+		Latency:    time.Millisecond,
+		ClientIP:   "10.0.0.1",
+		Method:     "GET",
+		Path:       "/events?access_token=eyJhbGciOi",
+	})
+	assert.False(t, strings.Contains(line, "eyJhbGciOi"))
+	assert.Contains(t, line, "access_token=REDACTED")
+}
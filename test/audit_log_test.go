@@ -0,0 +1,45 @@
+package test
+
+import (
+	"parameter-store-be/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testAuditLogChain(t *testing.T) {
+	key := []byte("audit-key")
+	createdAt := time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC)
+	var chain []models.AuditLog
+	prevHash := ""
+	for i, action := range []string{"parameter.create", "parameter.update", "version.create"} {
+		entry := models.AuditLog{
+			OrganizationID: 1,
+			Sequence:       uint64(i + 1),
+			CreatedAt:      createdAt,
+			ActorType:      models.AuditActorUser,
+			ActorID:        7,
+			Action:         action,
+			After:          `{"value":"v1"}`,
+			PrevHash:       prevHash,
+		}
+		entry.Hash = entry.ComputeHash(key)
+		prevHash = entry.Hash
+		chain = append(chain, entry)
+	}
+	assert.Equal(t, chain[0].Hash, chain[1].PrevHash)
+
+	// the hash does not depend on the time zone the row is read in
+	local := chain[1]
+	local.CreatedAt = local.CreatedAt.In(time.FixedZone("UTC+7", 7*3600))
+	assert.Equal(t, chain[1].Hash, local.ComputeHash(key))
+
+	tampered := chain[1]
+	tampered.After = `{"value":"v2"}`
+	assert.NotEqual(t, chain[1].Hash, tampered.ComputeHash(key))
+
+	// without the key a rewritten entry can not be given a matching hash
+	assert.NotEqual(t, chain[1].Hash, chain[1].ComputeHash([]byte("other-key")))
+	assert.NotEqual(t, chain[1].Hash, chain[1].ComputeHash(nil))
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"os"
+	"parameter-store-be/modules/auditsink"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testAuditSink(t *testing.T) {
+	event := auditsink.Event{
+		ID:             "1-42",
+		Time:           time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		OrganizationID: 1,
+		Action:         "parameter.update",
+		Actor:          `admin "root"`,
+		Status:         200,
+		Data:           []byte(`{"sequence":42}`),
+	}
+	message := auditsink.FormatRFC5424(auditsink.FacilityLogAudit, event, "parameter-store")
+	// facility 13 * 8 + severity info 6
+	assert.True(t, strings.HasPrefix(message, "<110>1 2024-05-01T10:00:00.000000Z "), message)
+	assert.Contains(t, message, ` parameter.update [audit@32473 id="1-42" org="1" action="parameter.update" actor="admin \"root\""`)
+	assert.True(t, strings.HasSuffix(message, `{"sequence":42}`))
+
+	event.Status = 403
+	assert.True(t, strings.HasPrefix(auditsink.FormatRFC5424(auditsink.FacilityLogAudit, event, "app"), "<108>1 "))
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink := &auditsink.FileSink{Path: path, MaxBytes: 40, MaxBackups: 2}
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, sink.Write(event))
+	}
+	assert.NoError(t, sink.Close())
+	// 2 lines of 16 bytes fit in 40 bytes: the 5 writes leave 1 line in the current file and 2 in each backup
+	line := "{\"sequence\":42}\n"
+	for name, expected := range map[string]string{path: line, path + ".1": line + line, path + ".2": line + line} {
+		content, err := os.ReadFile(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+	}
+	_, err := os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,23 @@
+package test
+
+import (
+	"parameter-store-be/controllers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSameRepoURL(t *testing.T) {
+	assert.True(t, controllers.SameRepoURL("github.com/acme/api", "github.com/acme/api"))
+	// owner and name are not case sensitive
+	assert.True(t, controllers.SameRepoURL("github.com/Acme/API", "github.com/acme/api"))
+	// a trailing slash and spaces name the same repository
+	assert.True(t, controllers.SameRepoURL("github.com/acme/api/", " github.com/acme/api"))
+	// the token of the source is never reused for another owner or another repository
+	assert.False(t, controllers.SameRepoURL("github.com/acme/api", "github.com/other/api"))
+	assert.False(t, controllers.SameRepoURL("github.com/acme/api", "github.com/acme/web"))
+	assert.False(t, controllers.SameRepoURL("github.com/acme/api", "github.com/acme/api-web"))
+	// URLs that do not parse are never the same repository
+	assert.False(t, controllers.SameRepoURL("", ""))
+	assert.False(t, controllers.SameRepoURL("github.com/acme/api/tree", "github.com/acme/api/tree"))
+}
@@ -0,0 +1,127 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"parameter-store-be/controllers"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/declarative"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func testDeclarative(t *testing.T) {
+	ctx := context.Background()
+	exists := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/declarative/projects/shop/stages/Build/environments/Production/parameters/DB%20HOST", r.URL.EscapedPath())
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && !exists:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "parameter DB HOST not found"}`))
+		case r.Method == http.MethodPut && r.Header.Get("If-None-Match") == "*" && exists:
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error": "Resource already exists, import it instead of creating it"}`))
+		case r.Method == http.MethodPut && r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != `"1-1"`:
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`{"error": "Resource was changed or removed since it was read"}`))
+		case r.Method == http.MethodPut:
+			w.Header().Set("ETag", `"1-1"`)
+			if !exists {
+				w.WriteHeader(http.StatusCreated)
+			}
+			w.Write([]byte(`{"parameter": {"id": 1, "name": "DB HOST", "value": "db"}, "created": ` + map[bool]string{true: "false", false: "true"}[exists] + `, "changed": true}`))
+			exists = true
+		default:
+			w.Header().Set("ETag", `"1-1"`)
+			w.Write([]byte(`{"parameter": {"id": 1, "name": "DB HOST", "value": "db"}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := &declarative.Client{BaseURL: server.URL, Token: "pat"}
+	path := declarative.ParameterPath("shop", "Build", "Production", "DB HOST")
+	var parameter declarative.Parameter
+
+	_, err := client.Get(ctx, path, "parameter", &parameter)
+	assert.True(t, errors.Is(err, declarative.ErrNotFound))
+
+	result, err := client.Put(ctx, path, "parameter", declarative.ParameterBody{Value: "db"}, declarative.WriteOptions{CreateOnly: true}, &parameter)
+	assert.NoError(t, err)
+	assert.True(t, result.Created)
+	assert.Equal(t, `"1-1"`, result.ETag)
+	assert.Equal(t, uint(1), parameter.ID)
+
+	_, err = client.Put(ctx, path, "parameter", declarative.ParameterBody{Value: "db"}, declarative.WriteOptions{CreateOnly: true}, nil)
+	assert.True(t, errors.Is(err, declarative.ErrConflict))
+
+	_, err = client.Put(ctx, path, "parameter", declarative.ParameterBody{Value: "db"}, declarative.WriteOptions{IfMatch: `"1-0"`}, nil)
+	assert.True(t, errors.Is(err, declarative.ErrPreconditionFailed))
+
+	etag, err := client.Get(ctx, path, "parameter", &parameter)
+	assert.NoError(t, err)
+	assert.Equal(t, `"1-1"`, etag)
+	assert.Equal(t, "DB HOST", parameter.Name)
+}
+
+func testDeclarativeServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	preconditions := func(headers map[string]string, exists bool) (bool, int) {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPut, "/", nil)
+		for key, value := range headers {
+			c.Request.Header.Set(key, value)
+		}
+		ok := controllers.CheckDeclarativePreconditions(c, exists, `"1-1"`)
+		return ok, recorder.Code
+	}
+	ok, _ := preconditions(nil, true)
+	assert.True(t, ok)
+	ok, _ = preconditions(map[string]string{"If-None-Match": "*"}, false)
+	assert.True(t, ok)
+	ok, code := preconditions(map[string]string{"If-None-Match": "*"}, true)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusConflict, code)
+	ok, _ = preconditions(map[string]string{"If-Match": `"1-1"`}, true)
+	assert.True(t, ok)
+	ok, code = preconditions(map[string]string{"If-Match": `"1-0"`}, true)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	ok, code = preconditions(map[string]string{"If-Match": `"1-1"`}, false)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+
+	// names are only usable when they are unique
+	assert.Equal(t, http.StatusNotFound, controllers.NameLookupStatus(0))
+	assert.Equal(t, http.StatusOK, controllers.NameLookupStatus(1))
+	assert.Equal(t, http.StatusConflict, controllers.NameLookupStatus(2))
+
+	// a PUT restores an archived parameter even with the same value
+	archived := models.Parameter{Value: "db", Description: "host", IsArchived: true, ArchivedBy: "jane", ArchivedAt: time.Now(), IsApplied: true}
+	assert.True(t, controllers.ApplyDeclarativeParameter(&archived, "db", "host"))
+	assert.False(t, archived.IsArchived)
+	assert.Empty(t, archived.ArchivedBy)
+	assert.True(t, archived.ArchivedAt.IsZero())
+	assert.False(t, archived.IsApplied)
+
+	unchanged := models.Parameter{Value: "db", Description: "host", IsApplied: true}
+	assert.False(t, controllers.ApplyDeclarativeParameter(&unchanged, "db", "host"))
+	assert.True(t, unchanged.IsApplied)
+
+	// a new description alone keeps the applied value
+	described := models.Parameter{Value: "db", Description: "host", IsApplied: true}
+	assert.True(t, controllers.ApplyDeclarativeParameter(&described, "db", "database host"))
+	assert.True(t, described.IsApplied)
+
+	draft := models.Parameter{IsDraft: true}
+	assert.True(t, controllers.ApplyDeclarativeParameter(&draft, "value", ""))
+	assert.False(t, draft.IsDraft)
+	assert.False(t, draft.IsApplied)
+}
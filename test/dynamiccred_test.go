@@ -0,0 +1,35 @@
+package test
+
+import (
+	"parameter-store-be/modules/dynamiccred"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testDynamicCred(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	credentials, err := dynamiccred.NewCredentials("Orders-API", time.Hour, now)
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^orders_api_1718452800_[0-9a-f]{12}$`), credentials.Username)
+	assert.Len(t, credentials.Password, 48)
+	assert.Equal(t, now.Add(time.Hour), credentials.ExpiresAt)
+
+	other, _ := dynamiccred.NewCredentials("Orders-API", time.Hour, now)
+	assert.NotEqual(t, credentials.Username, other.Username)
+
+	credentials = dynamiccred.Credentials{Username: "app_1", Password: "p'w", ExpiresAt: now}
+	assert.Equal(t, []string{
+		`CREATE ROLE "app_1" WITH LOGIN PASSWORD 'p''w' VALID UNTIL '2024-06-15 12:00:00+00'`,
+		`GRANT CONNECT ON DATABASE app TO "app_1"`,
+		`GRANT SELECT ON ALL TABLES IN SCHEMA public TO "app_1"`,
+	}, dynamiccred.CreateStatements(credentials, "GRANT CONNECT ON DATABASE app TO {{role}};\n GRANT SELECT ON ALL TABLES IN SCHEMA public TO {{role}};"))
+	assert.Equal(t, `DROP ROLE "app_1"`, dynamiccred.RevokeStatements("app_1", "")[2])
+
+	assert.True(t, dynamiccred.HasPlaceholder("postgres://{{username}}:{{password}}@db/app"))
+	assert.False(t, dynamiccred.HasPlaceholder("postgres://app@db/app"))
+	assert.Equal(t, "postgres://app_1:p'w@db/app?until=2024-06-15T12:00:00Z",
+		dynamiccred.Render("postgres://{{username}}:{{password}}@db/app?until={{expires_at}}", credentials))
+}
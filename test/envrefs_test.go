@@ -0,0 +1,47 @@
+package test
+
+import (
+	"parameter-store-be/models"
+	"parameter-store-be/modules/envrefs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testEnvRefs(t *testing.T) {
+	code := []byte("host := os.Getenv(\"DB_HOST\")\nconst url = process.env.API_URL || process.env['API_URL']\nsha := os.Getenv(\"GITHUB_SHA\")\nflag = os.environ.get(\"NEW_FLAG\")\n")
+	assert.Equal(t, []envrefs.Reference{
+		{Name: "DB_HOST", Path: "main.go", Line: 1, Source: envrefs.SourceCode},
+		{Name: "API_URL", Path: "main.go", Line: 2, Source: envrefs.SourceCode},
+		{Name: "NEW_FLAG", Path: "main.go", Line: 4, Source: envrefs.SourceCode},
+	}, envrefs.CodeReferences("main.go", code))
+
+	workflow := []byte(`name: Deploy
+env:
+  REGISTRY: ghcr.io
+jobs:
+  deploy:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo ${{ env.REGISTRY }} ${{ env.NEW_FLAG }}
+        env:
+          LOCAL: "1"
+      - run: deploy --to ${{ env.LOCAL }} --sha ${{ env.GITHUB_SHA }}
+`)
+	references, defined, err := envrefs.WorkflowReferences(".github/workflows/deploy.yml", workflow)
+	assert.NoError(t, err)
+	assert.Equal(t, []envrefs.Reference{{Name: "NEW_FLAG", Path: ".github/workflows/deploy.yml", Line: 8, Source: envrefs.SourceWorkflow}}, references)
+	assert.Equal(t, []string{"LOCAL", "REGISTRY"}, defined)
+
+	_, _, err = envrefs.WorkflowReferences("broken.yml", []byte("jobs: [unclosed"))
+	assert.Error(t, err)
+
+	// writing a value, even an empty one, turns a draft into a parameter agents receive
+	draft := models.Parameter{Name: "NEW_FLAG", IsDraft: true}
+	draft.SetValue("")
+	assert.False(t, draft.IsDraft)
+	draft = models.Parameter{Name: "NEW_FLAG", IsDraft: true}
+	draft.SetValue("on")
+	assert.Equal(t, "on", draft.Value)
+	assert.False(t, draft.IsDraft)
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"parameter-store-be/controllers"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeRows are the columns and rows a fake database answers to a query, no columns is an empty result
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// fakeResponder answers the queries of gorm, tests match the table in the query text
+type fakeResponder func(query string, args []driver.NamedValue) fakeRows
+
+var (
+	fakeDriverOnce sync.Once
+	fakeMu         sync.Mutex
+	fakeRespond    fakeResponder
+)
+
+// useFakeDB points controllers.DB at a database answering with respond until the end of the test
+func useFakeDB(t *testing.T, respond fakeResponder) {
+	fakeDriverOnce.Do(func() { sql.Register("fakedb", fakeDriver{}) })
+	fakeMu.Lock()
+	fakeRespond = respond
+	fakeMu.Unlock()
+	sqlDB, err := sql.Open("fakedb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := controllers.DB
+	controllers.SetDB(db)
+	t.Cleanup(func() {
+		controllers.SetDB(previous)
+		sqlDB.Close()
+	})
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb does not prepare statements")
+}
+func (fakeConn) Close() error              { return nil }
+func (fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	fakeMu.Lock()
+	respond := fakeRespond
+	fakeMu.Unlock()
+	rows := respond(query, args)
+	return &fakeResult{rows: rows}, nil
+}
+
+func (fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeResult struct {
+	rows fakeRows
+	next int
+}
+
+func (r *fakeResult) Columns() []string { return r.rows.columns }
+func (r *fakeResult) Close() error      { return nil }
+
+func (r *fakeResult) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows.values) {
+		return io.EOF
+	}
+	copy(dest, r.rows.values[r.next])
+	r.next++
+	return nil
+}
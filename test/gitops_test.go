@@ -0,0 +1,78 @@
+package test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"parameter-store-be/modules/github"
+	"parameter-store-be/modules/gitops"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testGitOps(t *testing.T) {
+	declared, err := gitops.Parse([]byte(`
+version: 1
+stages:
+  Build:
+    Production:
+      LOG_LEVEL: info
+      API_URL:
+        value: https://api.example.com
+        description: public API
+      REGION: eu
+      TIMEOUT: "30"
+`))
+	assert.NoError(t, err)
+	assert.Len(t, declared, 4)
+	assert.Equal(t, gitops.Entry{Stage: "Build", Environment: "Production", Name: "API_URL", Value: "https://api.example.com", Description: "public API"}, declared[0])
+
+	_, err = gitops.Parse([]byte("version: 2\n"))
+	assert.Error(t, err)
+	_, err = gitops.Parse([]byte("version: 1\nparameters: []\n"))
+	assert.Error(t, err, "unknown fields are refused")
+
+	current := []gitops.Entry{
+		{Stage: "Build", Environment: "Production", Name: "LOG_LEVEL", Value: "debug"},    // synced, untouched in the UI
+		{Stage: "Build", Environment: "Production", Name: "REGION", Value: "us"},          // synced, edited in the UI
+		{Stage: "Build", Environment: "Production", Name: "TIMEOUT", Value: "10"},         // set in the UI only
+		{Stage: "Build", Environment: "Production", Name: "OLD_FLAG", Value: "on"},        // removed from the manifest
+		{Stage: "Build", Environment: "Production", Name: "DB_PASSWORD", Value: "secret"}, // never managed by the manifest
+	}
+	baseline := map[string]string{
+		"Build/Production/LOG_LEVEL": "debug",
+		"Build/Production/REGION":    "eu",
+		"Build/Production/OLD_FLAG":  "on",
+	}
+	plan := gitops.Diff(declared, current, baseline)
+	actions := map[string]gitops.Change{}
+	for _, change := range plan.Changes {
+		actions[change.Name] = change
+	}
+	assert.Len(t, plan.Changes, 5)
+	assert.Equal(t, gitops.ActionAdd, actions["API_URL"].Action)
+	assert.Equal(t, gitops.ActionUpdate, actions["LOG_LEVEL"].Action)
+	assert.False(t, actions["LOG_LEVEL"].Conflict)
+	assert.True(t, actions["REGION"].Conflict)
+	assert.True(t, actions["TIMEOUT"].Conflict)
+	assert.Equal(t, gitops.ActionRemove, actions["OLD_FLAG"].Action)
+	assert.False(t, actions["OLD_FLAG"].Conflict)
+	assert.NotContains(t, actions, "DB_PASSWORD")
+	assert.Equal(t, 2, plan.Conflicts)
+	assert.Equal(t, "info", gitops.Baseline(declared)["Build/Production/LOG_LEVEL"])
+
+	body := []byte(`{"ref":"refs/heads/main","repository":{"full_name":"acme/shop","default_branch":"main"},"commits":[{"modified":[".parameter-store.yaml"]}]}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	assert.True(t, github.VerifyWebhookSignature("secret", body, signature))
+	assert.False(t, github.VerifyWebhookSignature("other", body, signature))
+	assert.False(t, github.VerifyWebhookSignature("", body, ""))
+	var event github.PushEvent
+	assert.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, "main", event.Branch())
+	assert.True(t, event.Touches(gitops.DefaultPath))
+	assert.False(t, event.Touches("README.md"))
+}
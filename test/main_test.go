@@ -25,6 +25,35 @@ func TestMain(t *testing.T) {
 		t.Run("TestConnecttions", testMultiple3)
 		t.Run("TestFunc", testMultiple4)
 		t.Run("TestHappyCase", testMultiple5)
+		t.Run("TestTOTP", testTOTP)
+		t.Run("TestOIDC", testOIDC)
+		t.Run("TestSignedToken", testSignedToken)
+		t.Run("TestAuditLogChain", testAuditLogChain)
+		t.Run("TestAuditSink", testAuditSink)
+		t.Run("TestWebhook", testWebhook)
+		t.Run("TestNotifier", testNotifier)
+		t.Run("TestAccessLog", testAccessLog)
+		t.Run("TestStream", testStream)
+		t.Run("TestParameterSetHash", testParameterSetHash)
+		t.Run("TestOperator", testOperator)
+		t.Run("TestDeclarative", testDeclarative)
+		t.Run("TestDeclarativeServer", testDeclarativeServer)
+		t.Run("TestGitOps", testGitOps)
+		t.Run("TestParamIO", testParamIO)
+		t.Run("TestParameterMatrix", testParameterMatrix)
+		t.Run("TestProjectTemplate", testProjectTemplate)
+		t.Run("TestRotation", testRotation)
+		t.Run("TestDynamicCred", testDynamicCred)
+		t.Run("TestUsageScan", testUsageScan)
+		t.Run("TestEnvRefs", testEnvRefs)
+		t.Run("TestPersonalAccessToken", testPersonalAccessToken)
+		t.Run("TestPromotion", testPromotion)
+		t.Run("TestParameterSet", testParameterSet)
+		t.Run("TestScheduledChangeRevert", testScheduledChangeRevert)
+		t.Run("TestRequiredIsOrgAdmin", testRequiredIsOrgAdmin)
+		t.Run("TestProjectGuards", testProjectGuards)
+		t.Run("TestProjectRole", testProjectRole)
+		t.Run("TestSameRepoURL", testSameRepoURL)
 	}
 }
 
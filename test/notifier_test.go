@@ -0,0 +1,63 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"parameter-store-be/modules/notifier"
+	"parameter-store-be/modules/webhook"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testNotifier(t *testing.T) {
+	message := notifier.Message{
+		Title:   "[shop] Workflow deploy finished: success",
+		Text:    "Run #42 of *deploy* finished with *success*",
+		Level:   notifier.LevelSuccess,
+		Fields:  []notifier.Field{{Name: "Stage", Value: "production"}, {Name: "Duration", Value: "1m30s"}},
+		LinkURL: "https://github.com/acme/shop/actions/runs/42",
+	}
+
+	slack, _ := json.Marshal(notifier.SlackPayload(message))
+	assert.Contains(t, string(slack), `"url":"https://github.com/acme/shop/actions/runs/42"`)
+	assert.Contains(t, string(slack), `*Stage*\nproduction`)
+	assert.Contains(t, string(slack), `"color":"#2eb67d"`)
+
+	teams, _ := json.Marshal(notifier.TeamsPayload(message))
+	assert.Contains(t, string(teams), `"contentType":"application/vnd.microsoft.card.adaptive"`)
+	assert.Contains(t, string(teams), `{"title":"Duration","value":"1m30s"}`)
+	assert.Contains(t, string(teams), `"type":"Action.OpenUrl"`)
+
+	text := notifier.PlainText(message)
+	assert.Contains(t, text, "Run #42 of deploy finished with success")
+	assert.Contains(t, text, "Open: https://github.com/acme/shop/actions/runs/42")
+
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	// the test receiver listens on loopback, refused unless private networks are allowed
+	assert.ErrorIs(t, notifier.PostJSON(server.URL, notifier.SlackPayload(message)), webhook.ErrForbiddenAddress)
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "true")
+	assert.NoError(t, notifier.PostJSON(server.URL, notifier.SlackPayload(message)))
+	assert.Equal(t, message.Title, received["text"])
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer failing.Close()
+	err := notifier.PostJSON(failing.URL, notifier.TeamsPayload(message))
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "invalid_token")
+
+	// redirects are not followed, they could lead to an internal address
+	redirecting := httptest.NewServer(http.RedirectHandler(server.URL, http.StatusFound))
+	defer redirecting.Close()
+	received = nil
+	assert.Error(t, notifier.PostJSON(redirecting.URL, notifier.SlackPayload(message)))
+	assert.Nil(t, received)
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"parameter-store-be/modules/oidc"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockIdP is a minimal OpenID provider serving discovery, JWKS and the token endpoint
+type mockIdP struct {
+	server        *httptest.Server
+	key           *rsa.PrivateKey
+	codeChallenge string
+	nonce         string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	idp := &mockIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "test", "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good-code" || oidc.CodeChallengeS256(r.Form.Get("code_verifier")) != idp.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            idp.server.URL,
+			"aud":            "client-1",
+			"sub":            "user-42",
+			"email":          "jane@example.com",
+			"email_verified": true,
+			"groups":         []string{"platform", "oncall"},
+			"nonce":          idp.nonce,
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = "test"
+		signed, _ := token.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "at", "token_type": "Bearer", "id_token": signed})
+	})
+	idp.server = httptest.NewServer(mux)
+	return idp
+}
+
+func testOIDC(t *testing.T) {
+	idp := newMockIdP(t)
+	defer idp.server.Close()
+
+	metadata, err := oidc.Discover(idp.server.URL)
+	assert.NoError(t, err)
+
+	verifier, challenge, err := oidc.GeneratePKCE()
+	assert.NoError(t, err)
+	idp.codeChallenge = challenge
+	idp.nonce = "nonce-1"
+
+	authURL, err := url.Parse(oidc.AuthorizationURL(metadata, "client-1", "http://localhost/callback", []string{"openid", "email"}, "state-1", "nonce-1", challenge))
+	assert.NoError(t, err)
+	assert.Equal(t, "S256", authURL.Query().Get("code_challenge_method"))
+
+	_, err = oidc.ExchangeCode(metadata, "client-1", "secret", "http://localhost/callback", "good-code", "wrong-verifier")
+	assert.Error(t, err)
+
+	tokenResponse, err := oidc.ExchangeCode(metadata, "client-1", "secret", "http://localhost/callback", "good-code", verifier)
+	assert.NoError(t, err)
+
+	claims, err := oidc.VerifyIDToken(metadata, tokenResponse.IDToken, "client-1", "nonce-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "user-42", claims.Subject)
+	assert.True(t, claims.EmailVerified)
+	assert.Equal(t, []string{"platform", "oncall"}, claims.Groups("groups"))
+
+	_, err = oidc.VerifyIDToken(metadata, tokenResponse.IDToken, "client-1", "other-nonce")
+	assert.Error(t, err)
+	_, err = oidc.VerifyIDToken(metadata, tokenResponse.IDToken, "client-2", "nonce-1")
+	assert.Error(t, err)
+
+	// an existing account is linked only to a verified, matching email, whatever the provider settings
+	assert.NoError(t, claims.CheckAccountLink("Jane@Example.com"))
+	assert.Error(t, claims.CheckAccountLink("bob@example.com"))
+	unverified := *claims
+	unverified.EmailVerified = false
+	assert.Error(t, unverified.CheckAccountLink(claims.Email))
+	unverified.Email = ""
+	assert.Error(t, unverified.CheckAccountLink(""))
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"parameter-store-be/modules/operator"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testOperator(t *testing.T) {
+	ctx := context.Background()
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Query().Get("since"))
+		if r.Header.Get("Authorization") != "Bearer agent-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("since") == "2" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		json.NewEncoder(w).Encode(operator.ParameterSet{
+			Revision: 2, Hash: "abc", Version: "1.0", Project: "shop", Stage: "build", Environment: "production",
+			Parameters: []operator.Parameter{{Name: "DB_HOST", Value: "db"}, {Name: "BAD KEY", Value: "x"}},
+		})
+	}))
+	defer server.Close()
+
+	kube := operator.NewFakeClient()
+	kube.PutObject(ctx, operator.Object{Kind: operator.TargetSecret, Metadata: operator.ObjectMeta{Name: "token", Namespace: "default"}, Data: map[string]string{"token": "agent-token"}})
+	kube.AddSync(operator.ParameterStoreSync{
+		Metadata: operator.ObjectMeta{Name: "shop-config", Namespace: "default"},
+		Spec: operator.ParameterStoreSyncSpec{
+			Project: "shop", Stage: "build", Environment: "production",
+			AgentTokenRef: operator.SecretKeyRef{Name: "token"},
+		},
+	})
+	reconciler := &operator.Reconciler{Kube: kube, Source: &operator.WatchClient{BaseURL: server.URL}}
+
+	assert.NoError(t, reconciler.ReconcileAll(ctx))
+	secret, _ := kube.GetObject(ctx, operator.TargetSecret, "default", "shop-config")
+	if assert.NotNil(t, secret) {
+		assert.Equal(t, map[string]string{"DB_HOST": "db"}, secret.Data)
+		assert.Equal(t, "2", secret.Metadata.Annotations[operator.RevisionAnnotation])
+		assert.Equal(t, operator.ManagedByValue, secret.Metadata.Labels[operator.ManagedByLabel])
+		assert.Equal(t, "uid-shop-config", secret.Metadata.OwnerReferences[0].UID)
+	}
+	sync, _ := kube.Sync("default", "shop-config")
+	assert.True(t, sync.Status.Ready)
+	assert.Equal(t, int64(2), sync.Status.Revision)
+	assert.Contains(t, sync.Status.Message, "skipped invalid keys: BAD KEY")
+
+	// unchanged revision answers 304 and leaves the secret alone
+	assert.NoError(t, reconciler.Reconcile(ctx, sync))
+	unchanged, _ := kube.GetObject(ctx, operator.TargetSecret, "default", "shop-config")
+	assert.Equal(t, secret.Metadata.ResourceVersion, unchanged.Metadata.ResourceVersion)
+	assert.Equal(t, []string{"", "2"}, requests)
+
+	// a deleted secret is written again from a full fetch
+	kube.DeleteObject(operator.TargetSecret, "default", "shop-config")
+	assert.NoError(t, reconciler.Reconcile(ctx, sync))
+	rewritten, _ := kube.GetObject(ctx, operator.TargetSecret, "default", "shop-config")
+	assert.NotNil(t, rewritten)
+	assert.Equal(t, "", requests[len(requests)-1])
+
+	// the token must belong to the stage of the spec
+	sync.Spec.Stage = "deploy"
+	sync.Metadata.Generation = 2
+	assert.Error(t, reconciler.Reconcile(ctx, sync))
+	failed, _ := kube.Sync("default", "shop-config")
+	assert.False(t, failed.Status.Ready)
+	assert.Contains(t, failed.Status.Message, "stage build")
+
+	// objects not written by the operator are not overwritten
+	kube.PutObject(ctx, operator.Object{Kind: operator.TargetConfigMap, Metadata: operator.ObjectMeta{Name: "app", Namespace: "default"}, Data: map[string]string{"KEEP": "1"}})
+	sync.Spec.Stage = "build"
+	sync.Spec.Target = operator.SyncTarget{Kind: operator.TargetConfigMap, Name: "app"}
+	assert.Error(t, reconciler.Reconcile(ctx, sync))
+	configMap, _ := kube.GetObject(ctx, operator.TargetConfigMap, "default", "app")
+	assert.Equal(t, map[string]string{"KEEP": "1"}, configMap.Data)
+}
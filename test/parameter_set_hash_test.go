@@ -0,0 +1,25 @@
+package test
+
+import (
+	"parameter-store-be/controllers"
+	"parameter-store-be/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testParameterSetHash(t *testing.T) {
+	a := []models.Parameter{{Name: "DB_HOST", Value: "db"}, {Name: "PORT", Value: "8080"}}
+	b := []models.Parameter{{Name: "PORT", Value: "8080"}, {Name: "DB_HOST", Value: "db"}}
+	assert.Equal(t, controllers.ParameterSetHash(a), controllers.ParameterSetHash(b))
+	assert.Len(t, controllers.ParameterSetHash(a), 64)
+
+	changed := []models.Parameter{{Name: "DB_HOST", Value: "db"}, {Name: "PORT", Value: "8081"}}
+	assert.NotEqual(t, controllers.ParameterSetHash(a), controllers.ParameterSetHash(changed))
+
+	// a separator inside a value must not collide with another split
+	assert.NotEqual(t,
+		controllers.ParameterSetHash([]models.Parameter{{Name: "A", Value: "1\nB=2"}}),
+		controllers.ParameterSetHash([]models.Parameter{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}}))
+	assert.Equal(t, controllers.ParameterSetHash(nil), controllers.ParameterSetHash([]models.Parameter{}))
+}
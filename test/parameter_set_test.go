@@ -0,0 +1,79 @@
+package test
+
+import (
+	"parameter-store-be/controllers"
+	"parameter-store-be/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testParameterSet(t *testing.T) {
+	shared := func(name, value, environment string) models.SharedParameter {
+		return models.SharedParameter{Name: name, Value: value, Environment: environment}
+	}
+	agent := models.Agent{ProjectID: 1, StageID: 2, EnvironmentID: 3}
+	tests := []struct {
+		name       string
+		sets       [][]models.SharedParameter // in the order they were linked
+		parameters []models.Parameter         // parameters of the project
+		expected   map[string]string
+	}{
+		{
+			name:     "environment specific beats all environments",
+			sets:     [][]models.SharedParameter{{shared("HOST", "staging.db", "Staging"), shared("HOST", "db", "")}},
+			expected: map[string]string{"HOST": "staging.db"},
+		},
+		{
+			name:     "other environments are ignored",
+			sets:     [][]models.SharedParameter{{shared("HOST", "db", ""), shared("HOST", "prod.db", "Production"), shared("PORT", "1", "Production")}},
+			expected: map[string]string{"HOST": "db"},
+		},
+		{
+			name:     "a set linked later wins",
+			sets:     [][]models.SharedParameter{{shared("HOST", "first", "")}, {shared("HOST", "second", "")}},
+			expected: map[string]string{"HOST": "second"},
+		},
+		{
+			name:     "a later set for every environment does not beat an earlier one for the environment",
+			sets:     [][]models.SharedParameter{{shared("HOST", "staging.db", "Staging")}, {shared("HOST", "db", "")}},
+			expected: map[string]string{"HOST": "staging.db"},
+		},
+		{
+			name:     "a set linked later wins for the environment",
+			sets:     [][]models.SharedParameter{{shared("HOST", "first", "Staging")}, {shared("HOST", "second", "Staging")}},
+			expected: map[string]string{"HOST": "second"},
+		},
+		{
+			name:       "project parameters win",
+			sets:       [][]models.SharedParameter{{shared("HOST", "staging.db", "Staging"), shared("PORT", "5432", "")}},
+			parameters: []models.Parameter{{Name: "HOST", Value: "own"}},
+			expected:   map[string]string{"HOST": "own", "PORT": "5432"},
+		},
+	}
+	for _, tt := range tests {
+		var sets []models.SharedParameterSet
+		for _, parameters := range tt.sets {
+			sets = append(sets, models.SharedParameterSet{Parameters: parameters})
+		}
+		merged := controllers.MergeSharedValues(agent, tt.parameters, controllers.SharedValues(sets, "Staging"))
+		values := map[string]string{}
+		for _, parameter := range merged {
+			values[parameter.Name] = parameter.Value
+		}
+		assert.Equal(t, tt.expected, values, tt.name)
+	}
+
+	// shared values are delivered as parameters of the agent, sorted by name
+	merged := controllers.MergeSharedValues(agent, []models.Parameter{{Name: "B"}}, map[string]models.SharedParameter{"A": shared("A", "a", ""), "C": shared("C", "c", "")})
+	assert.Equal(t, []string{"A", "B", "C"}, []string{merged[0].Name, merged[1].Name, merged[2].Name})
+	assert.Equal(t, uint(3), merged[0].EnvironmentID)
+	assert.Equal(t, uint(0), merged[0].ID)
+
+	// the plaintext flag of a shared value follows it into the parameters of the agent
+	smtp := shared("SMTP_HOST", "smtp.example.com", "")
+	smtp.IsPlaintext = true
+	merged = controllers.MergeSharedValues(agent, nil, map[string]models.SharedParameter{"SMTP_HOST": smtp, "SMTP_PASSWORD": shared("SMTP_PASSWORD", "p", "")})
+	assert.True(t, merged[0].IsPlaintext)
+	assert.False(t, merged[1].IsPlaintext)
+}
@@ -0,0 +1,102 @@
+package test
+
+import (
+	"parameter-store-be/modules/paramio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testParamIO(t *testing.T) {
+	rows, rowErrors := paramio.ParseDotenv([]byte(`# comment
+export DB_HOST=db.internal # inline comment
+GREETING="hello\nworld"
+LITERAL='a "b" #c'
+MULTI="line one
+line two"
+not a pair
+1BAD=x
+EMPTY=
+`), "Build", "Production")
+	assert.Equal(t, []paramio.RowError{{Line: 7, Error: "expected KEY=VALUE"}, {Line: 8, Error: `invalid name "1BAD"`}}, rowErrors)
+	values := map[string]string{}
+	for _, row := range rows {
+		assert.Equal(t, "Build", row.Stage)
+		values[row.Name] = row.Value
+	}
+	assert.Equal(t, map[string]string{
+		"DB_HOST":  "db.internal",
+		"GREETING": "hello\nworld",
+		"LITERAL":  `a "b" #c`,
+		"MULTI":    "line one\nline two",
+		"EMPTY":    "",
+	}, values)
+
+	// dotenv round trip
+	var builder strings.Builder
+	assert.NoError(t, paramio.WriteDotenv(&builder, "", rows))
+	again, rowErrors := paramio.ParseDotenv([]byte(builder.String()), "Build", "Production")
+	assert.Empty(t, rowErrors)
+	for _, row := range again {
+		assert.Equal(t, values[row.Name], row.Value, row.Name)
+	}
+
+	document := []paramio.Row{
+		{Stage: "Build", Environment: "Production", Name: "API_URL", Value: "https://api.example.com", Description: "public API"},
+		{Stage: "Build", Environment: "Staging", Name: "TIMEOUT", Value: "0030"},
+	}
+	for _, format := range []string{paramio.FormatJSON, paramio.FormatYAML} {
+		data, err := paramio.MarshalDocument(format, "shop", "1.0.0", document)
+		assert.NoError(t, err)
+		parsed, rowErrors, err := paramio.ParseDocument(data)
+		assert.NoError(t, err)
+		assert.Empty(t, rowErrors)
+		for i := range parsed {
+			parsed[i].Line = 0
+		}
+		assert.ElementsMatch(t, document, parsed, format)
+	}
+
+	_, rowErrors, err := paramio.ParseDocument([]byte("version: 1\nstages:\n  Build:\n    Production:\n      OK: yes\n      BAD: [1, 2]\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []paramio.RowError{{Line: 6, Error: "BAD must be a string or an object with value and description"}}, rowErrors)
+
+	data, err := paramio.MarshalXLSX(document)
+	assert.NoError(t, err)
+	parsed, rowErrors, err := paramio.ParseXLSX(data)
+	assert.NoError(t, err)
+	assert.Empty(t, rowErrors)
+	assert.Equal(t, "0030", parsed[1].Value)
+	assert.Equal(t, 3, parsed[1].Line)
+
+	assert.Equal(t, paramio.FormatDotenv, paramio.FormatOf(".env.production"))
+	assert.Equal(t, paramio.FormatYAML, paramio.FormatOf("params.yml"))
+}
+
+func testParameterMatrix(t *testing.T) {
+	columns := []paramio.MatrixColumn{{Stage: "Build", Environment: "Staging"}, {Stage: "Build", Environment: "Production"}, {Stage: "Deploy", Environment: "Staging"}}
+	matrix := paramio.BuildMatrix(columns, []paramio.Row{
+		{Stage: "Build", Environment: "Staging", Name: "DB_HOST", Value: "db.staging"},
+		{Stage: "Build", Environment: "Production", Name: "DB_HOST", Value: "db.prod"},
+		{Stage: "Build", Environment: "Staging", Name: "DEBUG", Value: "true"},
+		{Stage: "Deploy", Environment: "Staging", Name: "DEBUG", Value: "true"},
+		{Stage: "Test", Environment: "Staging", Name: "IGNORED", Value: "x"},
+	})
+	assert.Len(t, matrix.Rows, 2)
+	assert.Equal(t, "DB_HOST", matrix.Rows[0].Name)
+	assert.True(t, matrix.Rows[0].Differs)
+	// Deploy has no DB_HOST at all, so it is not reported as missing
+	assert.Empty(t, matrix.Rows[0].MissingIn)
+	assert.True(t, matrix.Rows[0].Cells[2].Missing)
+	assert.False(t, matrix.Rows[1].Differs)
+	assert.Equal(t, []paramio.MatrixColumn{{Stage: "Build", Environment: "Production"}}, matrix.Rows[1].MissingIn)
+
+	var builder strings.Builder
+	assert.NoError(t, paramio.WriteMatrixCSV(&builder, matrix))
+	assert.Equal(t, "Parameter Name,Build / Staging,Build / Production,Deploy / Staging,Missing In\n"+
+		"DB_HOST,db.staging,db.prod,<missing>,\n"+
+		"DEBUG,true,<missing>,true,Build / Production\n", builder.String())
+	_, err := paramio.MarshalMatrixXLSX(matrix)
+	assert.NoError(t, err)
+}
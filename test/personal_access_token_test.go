@@ -0,0 +1,98 @@
+package test
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"parameter-store-be/controllers"
+	"parameter-store-be/middleware"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/signedtoken"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func testPersonalAccessToken(t *testing.T) {
+	admin := models.PersonalAccessToken{Scope: models.TokenScopeAdmin}
+	assert.True(t, controllers.PersonalAccessTokenAllows(admin, http.MethodDelete, "7"))
+	assert.True(t, controllers.PersonalAccessTokenAllows(admin, http.MethodPost, ""))
+
+	// read only tokens only read, wherever they are used
+	readOnly := models.PersonalAccessToken{Scope: models.TokenScopeReadOnly}
+	assert.True(t, controllers.PersonalAccessTokenAllows(readOnly, http.MethodGet, "7"))
+	assert.True(t, controllers.PersonalAccessTokenAllows(readOnly, http.MethodHead, ""))
+	assert.False(t, controllers.PersonalAccessTokenAllows(readOnly, http.MethodPost, "7"))
+	assert.False(t, controllers.PersonalAccessTokenAllows(readOnly, http.MethodPut, ""))
+
+	// project tokens do everything on their project and nothing on organization routes or other projects
+	project := models.PersonalAccessToken{Scope: models.TokenScopeProject, ProjectID: 7}
+	assert.True(t, controllers.PersonalAccessTokenAllows(project, http.MethodPut, "7"))
+	assert.True(t, controllers.PersonalAccessTokenAllows(project, http.MethodGet, "7"))
+	assert.False(t, controllers.PersonalAccessTokenAllows(project, http.MethodGet, ""))
+	assert.False(t, controllers.PersonalAccessTokenAllows(project, http.MethodGet, "8"))
+	assert.False(t, controllers.PersonalAccessTokenAllows(project, http.MethodPost, "8"))
+	assert.False(t, controllers.PersonalAccessTokenAllows(project, http.MethodPost, ""))
+
+	assert.False(t, controllers.PersonalAccessTokenAllows(models.PersonalAccessToken{Scope: "other"}, http.MethodGet, ""))
+
+	// RequiredAuth checks the scope against the project_id of the matched route, organization routes have none
+	const raw = controllers.PersonalAccessTokenPrefix + "test-token"
+	useFakeDB(t, func(query string, args []driver.NamedValue) fakeRows {
+		switch {
+		case strings.Contains(query, `FROM "personal_access_tokens"`) && len(args) > 0 && args[0].Value == signedtoken.Hash(raw):
+			return fakeRows{
+				columns: []string{"id", "user_id", "scope", "project_id", "last_used_at", "last_used_ip"},
+				values:  [][]driver.Value{{int64(1), int64(5), models.TokenScopeProject, int64(7), time.Now(), "192.0.2.1"}},
+			}
+		case strings.Contains(query, `FROM "users"`):
+			return fakeRows{columns: []string{"id", "organization_id"}, values: [][]driver.Value{{int64(5), int64(1)}}}
+		}
+		return fakeRows{}
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	v1 := r.Group("/api/v1")
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	v1.GET("/organizations/audit-logs/", middleware.RequiredAuth, ok)
+	v1.GET("/organizations/parameter-sets/", middleware.RequiredAuth, ok)
+	v1.GET("/settings/users", middleware.RequiredAuth, ok)
+	v1.GET("/project-list/", middleware.RequiredAuth, ok)
+	v1.GET("/projects/:project_id/parameters/", middleware.RequiredAuth, ok)
+	v1.PUT("/projects/:project_id/parameters/:parameter_id", middleware.RequiredAuth, ok)
+	request := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	for _, path := range []string{"/api/v1/organizations/audit-logs/", "/api/v1/organizations/parameter-sets/", "/api/v1/settings/users", "/api/v1/project-list/", "/api/v1/projects/8/parameters/"} {
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet, path, raw), path)
+	}
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/api/v1/projects/7/parameters/", raw))
+	assert.Equal(t, http.StatusOK, request(http.MethodPut, "/api/v1/projects/7/parameters/3", raw))
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPut, "/api/v1/projects/8/parameters/3", raw))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/api/v1/projects/7/parameters/", controllers.PersonalAccessTokenPrefix+"unknown"))
+
+	// expiry and revocation
+	assert.NoError(t, controllers.CheckPersonalAccessToken(models.PersonalAccessToken{}))
+	assert.NoError(t, controllers.CheckPersonalAccessToken(models.PersonalAccessToken{ExpiresAt: time.Now().Add(time.Hour)}))
+	assert.EqualError(t, controllers.CheckPersonalAccessToken(models.PersonalAccessToken{ExpiresAt: time.Now().Add(-time.Hour)}), "Token is expired")
+	assert.EqualError(t, controllers.CheckPersonalAccessToken(models.PersonalAccessToken{IsRevoked: true, ExpiresAt: time.Now().Add(time.Hour)}), "Token is revoked")
+
+	// organization MFA policy
+	enabledAt := time.Now().Add(-24 * time.Hour)
+	withMFA := models.User{MFAEnabled: true, MFAEnabledAt: enabledAt}
+	before := models.PersonalAccessToken{}
+	before.CreatedAt = enabledAt.Add(-time.Hour)
+	after := models.PersonalAccessToken{}
+	after.CreatedAt = enabledAt.Add(time.Hour)
+	assert.True(t, controllers.PersonalAccessTokenMeetsMFAPolicy(before, models.User{}, false))
+	assert.False(t, controllers.PersonalAccessTokenMeetsMFAPolicy(after, models.User{}, true))
+	assert.False(t, controllers.PersonalAccessTokenMeetsMFAPolicy(before, withMFA, true))
+	assert.True(t, controllers.PersonalAccessTokenMeetsMFAPolicy(after, withMFA, true))
+}
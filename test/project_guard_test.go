@@ -0,0 +1,84 @@
+package test
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"parameter-store-be/middleware"
+	"parameter-store-be/models"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProjectMembership answers the guard queries: the projects of the organization and the role of the user in them
+func fakeProjectMembership(orgProjects int64, roleName string) fakeResponder {
+	return func(query string, args []driver.NamedValue) fakeRows {
+		switch {
+		case strings.Contains(query, `FROM "projects"`):
+			return fakeRows{columns: []string{"count"}, values: [][]driver.Value{{orgProjects}}}
+		case strings.Contains(query, `FROM "user_role_projects"`) && roleName != "":
+			return fakeRows{columns: []string{"id", "user_id", "project_id", "role_id"}, values: [][]driver.Value{{int64(1), int64(5), int64(7), int64(3)}}}
+		case strings.Contains(query, `FROM "roles"`) && roleName != "":
+			return fakeRows{columns: []string{"id", "name"}, values: [][]driver.Value{{int64(3), roleName}}}
+		}
+		return fakeRows{}
+	}
+}
+
+func testProjectGuards(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(guard gin.HandlerFunc, user models.User) (int, bool) {
+		handled := false
+		r := gin.New()
+		r.PUT("/projects/:project_id/parameters/:parameter_id", func(c *gin.Context) {
+			c.Set("user", user)
+			c.Next()
+		}, guard, func(c *gin.Context) {
+			handled = true
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/projects/7/parameters/1", nil))
+		return w.Code, handled
+	}
+	member := models.User{OrganizationID: 1}
+	member.ID = 5
+	orgAdmin := models.User{OrganizationID: 1, IsOrganizationAdmin: true}
+
+	// the guards abort, the handler never runs after a rejection
+	useFakeDB(t, fakeProjectMembership(0, ""))
+	code, handled := serve(middleware.RequiredBelongToProject, member)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.False(t, handled)
+
+	// the role is loaded, a developer is not a project admin
+	useFakeDB(t, fakeProjectMembership(0, "Developer"))
+	code, handled = serve(middleware.RequiredBelongToProject, member)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, handled)
+	code, handled = serve(middleware.RequiredIsAdmin, member)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.False(t, handled)
+
+	useFakeDB(t, fakeProjectMembership(0, "Project Admin"))
+	code, handled = serve(middleware.RequiredIsAdmin, member)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, handled)
+
+	// organization admins only reach the projects of their organization
+	useFakeDB(t, fakeProjectMembership(0, ""))
+	for _, guard := range []gin.HandlerFunc{middleware.RequiredBelongToProject, middleware.RequiredIsAdmin} {
+		code, handled = serve(guard, orgAdmin)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.False(t, handled)
+	}
+	useFakeDB(t, fakeProjectMembership(1, ""))
+	for _, guard := range []gin.HandlerFunc{middleware.RequiredBelongToProject, middleware.RequiredIsAdmin} {
+		code, handled = serve(guard, orgAdmin)
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, handled)
+	}
+}
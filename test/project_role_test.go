@@ -0,0 +1,17 @@
+package test
+
+import (
+	"parameter-store-be/controllers"
+	"parameter-store-be/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testProjectRole(t *testing.T) {
+	assert.True(t, controllers.IsProjectRole(models.Role{Name: "Project Admin"}))
+	assert.True(t, controllers.IsProjectRole(models.Role{Name: "Developer"}))
+	// organization admin is a flag of the user, it is never granted through a project
+	assert.False(t, controllers.IsProjectRole(models.Role{Name: "Organization Admin"}))
+	assert.False(t, controllers.IsProjectRole(models.Role{}))
+}
@@ -0,0 +1,38 @@
+package test
+
+import (
+	"parameter-store-be/modules/projecttemplate"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testProjectTemplate(t *testing.T) {
+	assert.NoError(t, projecttemplate.ValidateValue(projecttemplate.TypeNumber, "3.5"))
+	assert.Error(t, projecttemplate.ValidateValue(projecttemplate.TypeBoolean, "yes"))
+	assert.Error(t, projecttemplate.ValidateValue(projecttemplate.TypeURL, "smtp.internal"))
+	assert.True(t, projecttemplate.IsType(""))
+	assert.False(t, projecttemplate.IsType("date"))
+
+	requirements := []projecttemplate.Requirement{
+		{Name: "DB_PORT", Type: projecttemplate.TypeNumber},
+		{Name: "SENTRY_DSN", Type: projecttemplate.TypeURL, Stage: "Deploy", Environment: "Production"},
+	}
+	values := map[string]string{
+		projecttemplate.Key("Build", "Staging", "DB_PORT"):        "5432",
+		projecttemplate.Key("Build", "Production", "DB_PORT"):     "five",
+		projecttemplate.Key("Deploy", "Staging", "DB_PORT"):       "5432",
+		projecttemplate.Key("Deploy", "Production", "DB_PORT"):    "5432",
+		projecttemplate.Key("Deploy", "Production", "SENTRY_DSN"): "https://key@sentry.io/1",
+	}
+	issues := projecttemplate.Check(requirements, []string{"Build", "Deploy"}, []string{"Production", "Staging"}, values)
+	assert.Equal(t, []projecttemplate.Issue{
+		{Name: "DB_PORT", Stage: "Build", Environment: "Production", Problem: projecttemplate.ProblemInvalid, Reason: `"five" is not a number`},
+	}, issues)
+
+	delete(values, projecttemplate.Key("Deploy", "Production", "SENTRY_DSN"))
+	issues = projecttemplate.Check(requirements, []string{"Build", "Deploy"}, []string{"Production", "Staging"}, values)
+	assert.Len(t, issues, 2)
+	assert.Equal(t, projecttemplate.ProblemMissing, issues[1].Problem)
+	assert.Equal(t, "SENTRY_DSN", issues[1].Name)
+}
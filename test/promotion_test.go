@@ -0,0 +1,91 @@
+package test
+
+import (
+	"parameter-store-be/controllers"
+	"parameter-store-be/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPromotion(t *testing.T) {
+	const dev, prod = 1, 2
+	const api, web = 10, 20
+	stageNames := map[uint]string{api: "api", web: "web"}
+	parameters := []models.Parameter{
+		{StageID: api, EnvironmentID: dev, Name: "DB_HOST", Value: "db.dev"},
+		{StageID: api, EnvironmentID: dev, Name: "DB_PORT", Value: "5432"},
+		{StageID: api, EnvironmentID: dev, Name: "DB_NAME", Value: "app", IsPlaintext: true},
+		{StageID: api, EnvironmentID: dev, Name: "LOG_LEVEL", Value: "debug"},
+		{StageID: api, EnvironmentID: dev, Name: "DB_REPLICA", Value: "replica.dev", IsEnvironmentSpecific: true},
+		{StageID: api, EnvironmentID: dev, Name: "DB_USER", Value: "dev"},
+		{StageID: web, EnvironmentID: dev, Name: "DB_HOST", Value: "web.dev"},
+		{StageID: api, EnvironmentID: prod, Name: "DB_PORT", Value: "5432"},
+		{StageID: api, EnvironmentID: prod, Name: "DB_NAME", Value: "old", IsPlaintext: true},
+		{StageID: api, EnvironmentID: prod, Name: "DB_USER", Value: "prod", IsEnvironmentSpecific: true},
+	}
+	actions := func(changes []controllers.PromotionChange) map[string]string {
+		result := map[string]string{}
+		for _, change := range changes {
+			result[change.Stage+"/"+change.Name] = change.Action
+		}
+		return result
+	}
+
+	// every stage, DB_* only, LOG_LEVEL is not included
+	changes := controllers.PlanPromotion([]string{"DB_*"}, nil, parameters, stageNames, dev, prod, 0)
+	assert.Equal(t, map[string]string{
+		"api/DB_HOST":    "create",
+		"api/DB_PORT":    "unchanged",
+		"api/DB_NAME":    "update",
+		"api/DB_REPLICA": "skip", // environment specific in the source
+		"api/DB_USER":    "skip", // environment specific in the target
+		"web/DB_HOST":    "create",
+	}, actions(changes))
+	for _, change := range changes {
+		switch change.Name {
+		case "DB_REPLICA":
+			assert.Equal(t, "environment specific in the source", change.Reason)
+		case "DB_USER":
+			assert.Equal(t, "environment specific in the target", change.Reason)
+		case "DB_NAME":
+			assert.Equal(t, "old", change.Before)
+			assert.Equal(t, "app", change.After)
+		case "DB_HOST":
+			// values are masked unless the parameter is plaintext, whatever its name
+			assert.True(t, strings.HasPrefix(change.After, "masked:"), change.After)
+		}
+	}
+
+	// exclude wins over include, the stage limits the source
+	changes = controllers.PlanPromotion([]string{"DB_*", "LOG_*"}, []string{"DB_RE*", "DB_USER"}, parameters, stageNames, dev, prod, api)
+	assert.Equal(t, map[string]string{
+		"api/DB_HOST":   "create",
+		"api/DB_PORT":   "unchanged",
+		"api/DB_NAME":   "update",
+		"api/LOG_LEVEL": "create",
+	}, actions(changes))
+
+	// without include every name is promoted, a malformed pattern matches nothing
+	changes = controllers.PlanPromotion(nil, []string{"["}, parameters, stageNames, dev, prod, web)
+	assert.Equal(t, map[string]string{"web/DB_HOST": "create"}, actions(changes))
+	changes = controllers.PlanPromotion([]string{"["}, nil, parameters, stageNames, dev, prod, 0)
+	assert.Empty(t, changes)
+
+	// drafts and dynamic credentials stay where they are, a draft target is updated
+	drafts := []models.Parameter{
+		{StageID: api, EnvironmentID: dev, Name: "TOKEN", IsDraft: true},
+		{StageID: api, EnvironmentID: dev, Name: "DB_URL", Value: "postgres://{{username}}", DynamicSourceID: 3},
+		{StageID: api, EnvironmentID: dev, Name: "CACHE_URL", Value: "redis"},
+		{StageID: api, EnvironmentID: dev, Name: "QUEUE_URL", Value: "amqp"},
+		{StageID: api, EnvironmentID: prod, Name: "CACHE_URL", Value: "redis", DynamicSourceID: 4},
+		{StageID: api, EnvironmentID: prod, Name: "QUEUE_URL", Value: "amqp", IsDraft: true},
+	}
+	assert.Equal(t, map[string]string{
+		"api/TOKEN":     "skip",
+		"api/DB_URL":    "skip",
+		"api/CACHE_URL": "skip",
+		"api/QUEUE_URL": "update",
+	}, actions(controllers.PlanPromotion(nil, nil, drafts, stageNames, dev, prod, 0)))
+}
@@ -0,0 +1,39 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"parameter-store-be/middleware"
+	"parameter-store-be/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRequiredIsOrgAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(user models.User) (int, bool) {
+		handled := false
+		r := gin.New()
+		r.PUT("/organizations/:organization_id/mfa-policy", func(c *gin.Context) {
+			c.Set("user", user)
+			c.Next()
+		}, middleware.RequiredIsOrgAdmin, func(c *gin.Context) {
+			handled = true
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/organizations/1/mfa-policy", nil))
+		return w.Code, handled
+	}
+
+	// the guard aborts, the protected handler never runs for a member
+	code, handled := serve(models.User{OrganizationID: 1})
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.False(t, handled)
+
+	code, handled = serve(models.User{OrganizationID: 1, IsOrganizationAdmin: true})
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, handled)
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"parameter-store-be/controllers"
+	"parameter-store-be/models"
+	"parameter-store-be/modules/rotation"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRotation(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	warn := 14 * 24 * time.Hour
+
+	assert.Equal(t, rotation.StatusOK, rotation.Evaluate(rotation.Policy{RotatedAt: now.AddDate(-1, 0, 0)}, now, warn).Status)
+
+	fresh := rotation.Evaluate(rotation.Policy{RotationDays: 90, RotatedAt: now.AddDate(0, 0, -10)}, now, warn)
+	assert.Equal(t, rotation.StatusOK, fresh.Status)
+	assert.Equal(t, 80, fresh.DaysLeft)
+
+	assert.Equal(t, rotation.StatusExpiring, rotation.Evaluate(rotation.Policy{RotationDays: 90, RotatedAt: now.AddDate(0, 0, -80)}, now, warn).Status)
+
+	stale := rotation.Evaluate(rotation.Policy{RotationDays: 30, RotatedAt: now.AddDate(0, 0, -40)}, now, warn)
+	assert.Equal(t, rotation.StatusStale, stale.Status)
+	assert.Equal(t, -10, stale.DaysLeft)
+
+	// the expiry comes first and wins over a recent rotation
+	expired := rotation.Evaluate(rotation.Policy{RotationDays: 90, RotatedAt: now, ExpiresAt: now.Add(-time.Hour)}, now, warn)
+	assert.Equal(t, rotation.StatusExpired, expired.Status)
+	assert.Equal(t, now.Add(-time.Hour), expired.DueAt)
+
+	assert.True(t, rotation.MoreUrgent(rotation.StatusExpired, rotation.StatusStale))
+	assert.False(t, rotation.MoreUrgent(rotation.StatusOK, rotation.StatusExpiring))
+
+	// the rotation counts from the last change of the value, other edits do not reset it
+	parameter := models.Parameter{Value: "secret", RotationDays: 30}
+	parameter.CreatedAt = now.AddDate(0, 0, -60)
+	parameter.EditedAt = now
+	assert.Equal(t, now, controllers.ParameterRotationPolicy(parameter).RotatedAt)
+	parameter.ValueChangedAt = now.AddDate(0, 0, -40)
+	assert.Equal(t, now.AddDate(0, 0, -40), controllers.ParameterRotationPolicy(parameter).RotatedAt)
+	parameter.SetValue("secret")
+	assert.Equal(t, now.AddDate(0, 0, -40), parameter.ValueChangedAt)
+	parameter.SetValue("rotated")
+	assert.WithinDuration(t, time.Now(), parameter.ValueChangedAt, time.Minute)
+}
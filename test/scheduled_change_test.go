@@ -0,0 +1,37 @@
+package test
+
+import (
+	"parameter-store-be/controllers"
+	"parameter-store-be/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testScheduledChangeRevert(t *testing.T) {
+	latest := map[string]models.Parameter{
+		"HOST":    {Name: "HOST", Value: "new.db"},
+		"PORT":    {Name: "PORT", Value: "6543"}, // changed again after the scheduled change
+		"FEATURE": {Name: "FEATURE", Value: "on"},
+	}
+	current := func(stageID, environmentID uint, name string) (models.Parameter, bool) {
+		parameter, ok := latest[name]
+		return parameter, ok
+	}
+	applied := []controllers.ScheduledAppliedChange{
+		{StageID: 1, EnvironmentID: 2, Name: "HOST", Existed: true, Before: "old.db", BeforeDescription: "database", After: "new.db"},
+		{StageID: 1, EnvironmentID: 2, Name: "PORT", Existed: true, Before: "5432", After: "5433"},
+		{StageID: 1, EnvironmentID: 2, Name: "FEATURE", Existed: false, After: "on"},
+		{StageID: 1, EnvironmentID: 2, Name: "REMOVED", Existed: true, Before: "x", After: "y"}, // removed since
+	}
+	reverts, skipped := controllers.PlanScheduledRevert(applied, current)
+	assert.Equal(t, 2, skipped)
+	assert.Equal(t, []controllers.VersionChange{
+		{StageID: 1, EnvironmentID: 2, Name: "HOST", Value: "old.db", Description: "database"},
+		{StageID: 1, EnvironmentID: 2, Name: "FEATURE", Remove: true},
+	}, reverts)
+
+	reverts, skipped = controllers.PlanScheduledRevert(nil, current)
+	assert.Empty(t, reverts)
+	assert.Equal(t, 0, skipped)
+}
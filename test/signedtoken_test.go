@@ -0,0 +1,39 @@
+package test
+
+import (
+	"parameter-store-be/modules/mailer"
+	"parameter-store-be/modules/signedtoken"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSignedToken(t *testing.T) {
+	token, hash, err := signedtoken.Generate("secret", "password_reset")
+	assert.NoError(t, err)
+
+	verified, ok := signedtoken.Verify("secret", "password_reset", token)
+	assert.True(t, ok)
+	assert.Equal(t, hash, verified)
+
+	// a token is bound to its purpose and to the secret
+	_, ok = signedtoken.Verify("secret", "email_verification", token)
+	assert.False(t, ok)
+	_, ok = signedtoken.Verify("other", "password_reset", token)
+	assert.False(t, ok)
+	_, ok = signedtoken.Verify("secret", "password_reset", token+"x")
+	assert.False(t, ok)
+
+	sender := mailer.NewMemorySender()
+	assert.NoError(t, sender.Send(mailer.Message{To: "a@example.com", Subject: "Reset", Body: token}))
+	message, found := sender.Last("a@example.com")
+	assert.True(t, found)
+	assert.Contains(t, message.Body, token)
+
+	// without an explicit driver emails are dropped, not printed with their links
+	t.Setenv("MAIL_DRIVER", "")
+	assert.IsType(t, mailer.DisabledSender{}, mailer.NewSenderFromEnv())
+	assert.ErrorIs(t, mailer.NewSenderFromEnv().Send(mailer.Message{To: "a@example.com", Subject: "Reset", Body: token}), mailer.ErrNotConfigured)
+	t.Setenv("MAIL_DRIVER", "log")
+	assert.IsType(t, mailer.LogSender{}, mailer.NewSenderFromEnv())
+}
@@ -0,0 +1,20 @@
+package test
+
+import (
+	"parameter-store-be/controllers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testStream(t *testing.T) {
+	// nothing of the project was pruned, events of other projects do not matter
+	assert.False(t, controllers.StreamMissedEvents(0, 0))
+	assert.False(t, controllers.StreamMissedEvents(0, 42))
+	// the client already received the newest pruned event
+	assert.False(t, controllers.StreamMissedEvents(40, 40))
+	assert.False(t, controllers.StreamMissedEvents(40, 42))
+	// events after the last one received are gone
+	assert.True(t, controllers.StreamMissedEvents(43, 42))
+	assert.True(t, controllers.StreamMissedEvents(1, 0))
+}
@@ -0,0 +1,43 @@
+package test
+
+import (
+	"parameter-store-be/modules/totp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 6238 appendix B secret "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func testTOTP(t *testing.T) {
+	code, err := totp.GenerateCode(rfcSecret, time.Unix(59, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "287082", code)
+
+	code, err = totp.GenerateCode(rfcSecret, time.Unix(1111111109, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "081804", code)
+
+	// one period of clock drift is accepted, two are not
+	assert.True(t, totp.Validate(rfcSecret, "287082", time.Unix(59+totp.Period, 0)))
+	assert.False(t, totp.Validate(rfcSecret, "287082", time.Unix(59+2*totp.Period, 0)))
+	assert.False(t, totp.Validate(rfcSecret, "28708", time.Unix(59, 0)))
+
+	// the step of an accepted code is returned, a code of that step or an older one is a replay
+	step, ok := totp.ValidateStep(rfcSecret, "287082", time.Unix(59, 0), 0)
+	assert.True(t, ok)
+	assert.Equal(t, int64(59/totp.Period), step)
+	_, ok = totp.ValidateStep(rfcSecret, "287082", time.Unix(59, 0), step)
+	assert.False(t, ok)
+	_, ok = totp.ValidateStep(rfcSecret, "287082", time.Unix(59+totp.Period, 0), step)
+	assert.False(t, ok)
+	_, ok = totp.ValidateStep(rfcSecret, "287082", time.Unix(59, 0), step-1)
+	assert.True(t, ok)
+
+	codes, err := totp.GenerateRecoveryCodes()
+	assert.NoError(t, err)
+	assert.Len(t, codes, totp.RecoveryCodeCount)
+	assert.Equal(t, codes[0], totp.NormalizeRecoveryCode(" "+codes[0]+" "))
+}
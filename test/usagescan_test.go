@@ -0,0 +1,23 @@
+package test
+
+import (
+	"parameter-store-be/modules/usagescan"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testUsageScan(t *testing.T) {
+	scanner := usagescan.NewScanner([]string{"DB_HOST", "api.url", "PORT"})
+	content := []byte("host := os.Getenv(\"DB_HOST\")\nhostname := os.Getenv(\"DB_HOSTNAME\")\nurl: ${api.url} PORT=${PORT}\n")
+	assert.Equal(t, []usagescan.Occurrence{
+		{Name: "DB_HOST", Path: "main.go", Line: 1, Snippet: `host := os.Getenv("DB_HOST")`},
+		{Name: "PORT", Path: "main.go", Line: 3, Snippet: "url: ${api.url} PORT=${PORT}"},
+		{Name: "api.url", Path: "main.go", Line: 3, Snippet: "url: ${api.url} PORT=${PORT}"},
+	}, scanner.Scan("main.go", content))
+	assert.Empty(t, scanner.Scan("main.go", []byte("API_PORT=1\napi.urls=2")))
+
+	assert.True(t, usagescan.Skip("web/node_modules/pkg/index.js"))
+	assert.True(t, usagescan.Skip("go.sum"))
+	assert.False(t, usagescan.Skip(".github/workflows/deploy.yml"))
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"parameter-store-be/modules/webhook"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testWebhook(t *testing.T) {
+	secret, err := webhook.GenerateSecret()
+	assert.NoError(t, err)
+	body := []byte(`{"event":"parameter.updated"}`)
+
+	var received bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+		received = webhook.Verify(secret, timestamp, payload, r.Header.Get(webhook.HeaderSignature))
+		assert.Equal(t, "parameter.updated", r.Header.Get(webhook.HeaderEvent))
+		assert.Equal(t, "delivery-1", r.Header.Get(webhook.HeaderDelivery))
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("queued\n\x1b[31m" + strings.Repeat("x", 500)))
+	}))
+	defer server.Close()
+
+	// the test receiver listens on loopback, refused unless private networks are allowed
+	_, err = webhook.Deliver(server.URL, secret, "parameter.updated", "delivery-1", body)
+	assert.ErrorIs(t, err, webhook.ErrForbiddenAddress)
+	assert.ErrorIs(t, webhook.ValidateURL(server.URL), webhook.ErrForbiddenAddress)
+	assert.ErrorIs(t, webhook.ValidateURL("http://169.254.169.254/latest/meta-data/"), webhook.ErrForbiddenAddress)
+	assert.ErrorIs(t, webhook.ValidateURL("http://10.0.0.1/hook"), webhook.ErrForbiddenAddress)
+	assert.ErrorIs(t, webhook.ValidateURL("http://[::1]:8080/hook"), webhook.ErrForbiddenAddress)
+	assert.Error(t, webhook.ValidateURL("ftp://example.com/hook"))
+	for ip, public := range map[string]bool{
+		"8.8.8.8": true, "2606:4700::1111": true, "127.0.0.1": false, "192.168.1.10": false, "172.16.0.1": false,
+		"169.254.169.254": false, "100.64.0.1": false, "0.0.0.0": false, "224.0.0.1": false, "fe80::1": false, "fd00::1": false,
+	} {
+		assert.Equal(t, public, webhook.IsPublicIP(net.ParseIP(ip)), ip)
+	}
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "true")
+
+	result, err := webhook.Deliver(server.URL, secret, "parameter.updated", "delivery-1", body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, result.StatusCode)
+	assert.True(t, received)
+	// only a short excerpt without control characters is kept
+	assert.True(t, strings.HasPrefix(result.Body, "queued  [31mxxx"))
+	assert.LessOrEqual(t, len(result.Body), 203)
+
+	redirect := httptest.NewServer(http.RedirectHandler(server.URL, http.StatusFound))
+	defer redirect.Close()
+	result, err = webhook.Deliver(redirect.URL, secret, "parameter.updated", "delivery-2", body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusFound, result.StatusCode)
+
+	// the signature covers the timestamp and the body
+	signature := webhook.Sign(secret, 1700000000, body)
+	assert.True(t, webhook.Verify(secret, 1700000000, body, signature))
+	assert.False(t, webhook.Verify(secret, 1700000001, body, signature))
+	assert.False(t, webhook.Verify(secret, 1700000000, []byte(`{}`), signature))
+	assert.False(t, webhook.Verify("other", 1700000000, body, signature))
+}